@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,27 +20,105 @@ type Logging struct {
 }
 
 type QQ struct {
-	Enable      bool   `yaml:"enable"`
-	Id          int    `yaml:"id"`
-	Uid         int    `yaml:"uid"`
-	Secret      string `yaml:"secret"`
-	Token       string `yaml:"token"`
-	ScopeType   string `yaml:"type"`
-	Sandbox     bool   `yaml:"sandbox"`
-	WebhookPath string `yaml:"webhook_path"`
+	Enable bool `yaml:"enable"`
+	Id     int  `yaml:"id"`
+	// Uid 指定机器人的 self_id，为 0 时根据 /users/@me 返回的机器人id生成
+	Uid    int    `yaml:"uid"`
+	Secret string `yaml:"secret"`
+	// SecondarySecret 密钥轮换期间的备用密钥，回调签名使用任一密钥校验通过即可
+	SecondarySecret string `yaml:"secondary_secret,omitempty"`
+	Token           string `yaml:"token"`
+	ScopeType       string `yaml:"type"`
+	Sandbox         bool   `yaml:"sandbox"`
+	WebhookPath     string `yaml:"webhook_path"`
+	// AutoPermissionDemand 发送消息遇到接口无权限时自动在子频道发起授权申请
+	AutoPermissionDemand bool `yaml:"auto_permission_demand"`
+	// TimestampWindow 回调签名时间戳允许的最大偏差(秒)，超出视为重放请求
+	TimestampWindow int `yaml:"timestamp_window"`
+	// DedupeSize 保留的最近事件id数量，用于忽略重复投递的事件
+	DedupeSize int `yaml:"dedupe_size"`
+	// Mode 事件接收方式：webhook(默认) 或 websocket，无法暴露回调地址时使用 websocket 网关
+	Mode string `yaml:"mode"`
+	// Intents websocket 模式订阅的事件，为空时使用默认订阅
+	Intents []string `yaml:"intents,omitempty"`
+	// DurableQueue 事件先写入 SQLite 队列再处理，进程重启后继续处理未完成的事件
+	DurableQueue bool `yaml:"durable_queue"`
+	// Relays 验签通过的回调原文转发到其他地址
+	Relays []Relay `yaml:"relays,omitempty"`
+	// Channels 该机器人独立使用的功能端连接，为空时使用全局 channels
+	Channels []Channel `yaml:"channels,omitempty"`
+}
+
+// Relay 回调转发目标
+type Relay struct {
+	URL string `yaml:"url"`
+	// Secret 转发时重新签名使用的密钥，为空时沿用原始签名请求头
+	Secret string `yaml:"secret,omitempty"`
+	// Events 转发的事件类型，为空时转发全部事件
+	Events     []string `yaml:"events,omitempty"`
+	MaxRetries int      `yaml:"max-retries"`
+	RetryDelay int64    `yaml:"retry-delay"`
+	Timeout    int      `yaml:"timeout"`
+}
+
+// QQList 多个 QQ 机器人配置，兼容旧版单个机器人的写法
+type QQList []QQ
+
+// UnmarshalYAML 同时支持单个机器人(映射)与多个机器人(列表)两种写法
+func (l *QQList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var qq QQ
+		if err := value.Decode(&qq); err != nil {
+			return err
+		}
+		*l = QQList{qq}
+		return nil
+	}
+	var list []QQ
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
 }
 
 type Telegram struct {
 	Enable bool   `yaml:"enable"`
 	Token  string `yaml:"token"`
+	// ApiUrl Bot API 地址，可替换为自建的 Bot API 服务
+	ApiUrl string `yaml:"api_url,omitempty"`
+	// PollTimeout getUpdates 长轮询的等待时间(秒)
+	PollTimeout int `yaml:"poll_timeout,omitempty"`
+	// Mode 事件接收方式：polling(默认) 或 webhook
+	Mode string `yaml:"mode"`
+	// WebhookPath webhook 模式的回调地址
+	WebhookPath string `yaml:"webhook_path,omitempty"`
+	// SecretToken 校验请求头 X-Telegram-Bot-Api-Secret-Token
+	SecretToken string `yaml:"secret_token,omitempty"`
+	// WebhookUrl 不为空时启动时调用 setWebhook 设置为该地址
+	WebhookUrl string `yaml:"webhook_url,omitempty"`
+	// FileProxyUrl 文件代理的公网地址，不为空时媒体消息段的 url 为 该地址/file_id，为空时 url 留空
+	FileProxyUrl string `yaml:"file_proxy_url,omitempty"`
+	// Channels 该机器人独立使用的功能端连接，为空时使用全局 channels
+	Channels []Channel `yaml:"channels,omitempty"`
 }
 
 type Kook struct {
 	Enable bool   `yaml:"enable"`
 	Token  string `yaml:"token"`
+	// Mode 事件接收方式：websocket(默认) 或 webhook
+	Mode string `yaml:"mode"`
+	// WebhookPath webhook 模式的回调地址
+	WebhookPath string `yaml:"webhook_path,omitempty"`
+	// VerifyToken 开发者后台的 Verify Token，用于校验回调请求
+	VerifyToken string `yaml:"verify_token,omitempty"`
+	// EncryptKey 开发者后台的 Encrypt Key，设置后回调内容为 AES 加密
+	EncryptKey string `yaml:"encrypt_key,omitempty"`
+	// Channels 该机器人独立使用的功能端连接，为空时使用全局 channels
+	Channels []Channel `yaml:"channels,omitempty"`
 }
 type Bot struct {
-	QQ       QQ       `yaml:"qq"`
+	QQ       QQList   `yaml:"qq"`
 	Telegram Telegram `yaml:"telegram"`
 	Kook     Kook     `yaml:"kook"`
 }
@@ -65,19 +144,99 @@ type WS struct {
 	Authorization string `yaml:"authorization"`
 }
 
+// RelayRule 跨平台消息转发规则，在群组之间同步消息
+type RelayRule struct {
+	Name   string        `yaml:"name"`
+	Source RelayEndpoint `yaml:"source"`
+	// Targets 来源群的消息转发到的群
+	Targets []RelayEndpoint `yaml:"targets"`
+	// Direction 转发方向：oneway(默认) 仅来源转发到目标，both 目标群的消息同样转发到来源群及其他目标群
+	Direction string `yaml:"direction"`
+	// Format 转发消息的前缀，支持 {platform} {nickname} {user_id} {group_id} {rule}
+	Format string `yaml:"format"`
+	// Media 媒体消息的处理方式：forward(默认) 原样转发，link 转为链接文本，drop 丢弃
+	Media string `yaml:"media"`
+}
+
+// RelayEndpoint 转发规则中的群
+type RelayEndpoint struct {
+	// Platform 平台名称：qq、kook、telegram
+	Platform string `yaml:"platform"`
+	// SelfId 收发消息的机器人，为空时使用该平台的任一机器人
+	SelfId int64 `yaml:"self_id,omitempty"`
+	// GroupId 事件中的 group_id
+	GroupId int32 `yaml:"group_id"`
+}
+
+// Database 存储配置
+type Database struct {
+	// Driver 存储方式：sqlite(默认) 或 memory，memory 不保存到文件，重启后映射与消息记录丢失
+	Driver string `yaml:"driver"`
+	// Path SQLite 数据库文件路径，所在目录不存在时自动创建
+	Path string `yaml:"path"`
+}
+
+// MessageHistory 收发消息的记录，用于 get_msg 等动作
+type MessageHistory struct {
+	// RetentionDays 消息保留天数，小于 0 时永久保留
+	RetentionDays int `yaml:"retention_days"`
+	// PurgeInterval 清理过期消息的间隔(分钟)
+	PurgeInterval int `yaml:"purge_interval"`
+	// VacuumInterval 清理后执行 VACUUM 回收空间的最小间隔(小时)，小于 0 时不执行
+	VacuumInterval int `yaml:"vacuum_interval"`
+}
+
+// DataLifecycle 机器人被移出群或被删除好友后的数据处理
+type DataLifecycle struct {
+	// Policy delete 删除消息记录、别名与id映射，anonymize 保留消息记录的时间与会话并清除内容与发送者，keep 不处理
+	Policy string `yaml:"policy"`
+	// GracePeriod 处理前等待的时间(小时)，期间重新添加机器人时取消
+	GracePeriod int `yaml:"grace_period"`
+}
+
+// Profile 用户资料
+type Profile struct {
+	// TTL 从平台获取的用户资料缓存时间(秒)
+	TTL int `yaml:"ttl"`
+}
+
+// Admin 管理接口
+type Admin struct {
+	// Token 访问令牌，请求头 Authorization: Bearer <token>，为空时不启用管理接口
+	Token string `yaml:"token"`
+}
+
+// IdMapping 平台原始id与 OneBot 数字id的映射
+type IdMapping struct {
+	// Mode 分配方式：sequence(默认) 按顺序分配，hash 由原始id计算，数据库重置或多实例部署时id保持不变
+	Mode string `yaml:"mode"`
+	// CacheSize 内存中缓存的映射数量
+	CacheSize int `yaml:"cache_size"`
+}
+
 // Config 结构体用于存储服务器配置
 type Config struct {
 	Server   Server    `yaml:"server"`
 	Logging  Logging   `yaml:"logging"`
 	Bot      Bot       `yaml:"bot"`
 	Channels []Channel `yaml:"channels"`
+	// RelayRules 跨平台消息转发规则
+	RelayRules []RelayRule `yaml:"relay_rules,omitempty"`
+	IdMapping  IdMapping   `yaml:"id_mapping"`
+	Database   Database    `yaml:"database"`
+	// MessageHistory 消息记录
+	MessageHistory MessageHistory `yaml:"message_history"`
+	Admin          Admin          `yaml:"admin"`
+	Profile        Profile        `yaml:"profile"`
+	// DataLifecycle 数据删除策略
+	DataLifecycle DataLifecycle `yaml:"data_lifecycle"`
 }
 
-// 全局配置变量
-var config Config
+// current 当前生效的配置，每次加载解析为新的配置后整体替换，已发布的配置不再修改
+var current atomic.Pointer[Config]
 
 func WriteConfig(filename string) {
-	config = Config{
+	config := Config{
 		Server: Server{
 			Port:    "8080",
 			Timeout: 10,
@@ -89,25 +248,51 @@ func WriteConfig(filename string) {
 			JSON:      false,
 		},
 		Bot: Bot{
-			QQ: QQ{
+			QQ: QQList{{
+				Enable:          false,
+				Id:              1,
+				Secret:          "",
+				Token:           "",
+				ScopeType:       "public",
+				Sandbox:         false,
+				WebhookPath:     "/qq",
+				Mode:            "webhook",
+				TimestampWindow: 300,
+				DedupeSize:      10000,
+			}},
+			Telegram: Telegram{
 				Enable:      false,
-				Id:          1,
-				Uid:         1,
-				Secret:      "",
 				Token:       "",
-				ScopeType:   "public",
-				Sandbox:     false,
-				WebhookPath: "/qq",
-			},
-			Telegram: Telegram{
-				Enable: false,
-				Token:  "",
+				ApiUrl:      "https://api.telegram.org",
+				PollTimeout: 30,
+				Mode:        "polling",
 			},
 			Kook: Kook{
 				Enable: false,
 				Token:  "",
+				Mode:   "websocket",
 			},
 		},
+		IdMapping: IdMapping{
+			Mode:      "sequence",
+			CacheSize: 10000,
+		},
+		Database: Database{
+			Driver: "sqlite",
+			Path:   "./sqlite.db",
+		},
+		MessageHistory: MessageHistory{
+			RetentionDays:  30,
+			PurgeInterval:  60,
+			VacuumInterval: 168,
+		},
+		Profile: Profile{
+			TTL: 3600,
+		},
+		DataLifecycle: DataLifecycle{
+			Policy:      "delete",
+			GracePeriod: 72,
+		},
 		Channels: []Channel{
 			{
 				WSReverse: &WSReverse{
@@ -156,7 +341,9 @@ func LoadConfig(filename string) error {
 		os.Exit(0)
 	}
 
-	err = yaml.Unmarshal(file, &config)
+	// 解析到新的配置中，其他协程读取的旧配置保持不变
+	config := &Config{}
+	err = yaml.Unmarshal(file, config)
 	if err != nil {
 		return fmt.Errorf("无法解析 YAML 文件: %v", err)
 	}
@@ -169,13 +356,85 @@ func LoadConfig(filename string) error {
 	if config.Server.Timeout == 0 {
 		config.Server.Timeout = 30
 	}
+	for i := range config.Bot.QQ {
+		if config.Bot.QQ[i].TimestampWindow == 0 {
+			config.Bot.QQ[i].TimestampWindow = 300
+		}
+		if config.Bot.QQ[i].DedupeSize == 0 {
+			config.Bot.QQ[i].DedupeSize = 10000
+		}
+		if config.Bot.QQ[i].WebhookPath == "" {
+			config.Bot.QQ[i].WebhookPath = "/qq"
+		}
+	}
+	if config.Bot.Telegram.ApiUrl == "" {
+		config.Bot.Telegram.ApiUrl = "https://api.telegram.org"
+	}
+	if config.Bot.Telegram.PollTimeout == 0 {
+		config.Bot.Telegram.PollTimeout = 30
+	}
+	if config.Bot.Telegram.Mode == "" {
+		config.Bot.Telegram.Mode = "polling"
+	}
+	if config.Bot.Telegram.WebhookPath == "" {
+		config.Bot.Telegram.WebhookPath = "/telegram"
+	}
+	if config.Bot.Kook.Mode == "" {
+		config.Bot.Kook.Mode = "websocket"
+	}
+	if config.Bot.Kook.WebhookPath == "" {
+		config.Bot.Kook.WebhookPath = "/kook"
+	}
+	if config.Database.Driver == "" {
+		config.Database.Driver = "sqlite"
+	}
+	if config.Database.Path == "" {
+		config.Database.Path = "./sqlite.db"
+	}
+	if config.MessageHistory.RetentionDays == 0 {
+		config.MessageHistory.RetentionDays = 30
+	}
+	if config.MessageHistory.PurgeInterval <= 0 {
+		config.MessageHistory.PurgeInterval = 60
+	}
+	if config.MessageHistory.VacuumInterval == 0 {
+		config.MessageHistory.VacuumInterval = 168
+	}
+	if config.Profile.TTL <= 0 {
+		config.Profile.TTL = 3600
+	}
+	if config.DataLifecycle.Policy == "" {
+		config.DataLifecycle.Policy = "delete"
+		if config.DataLifecycle.GracePeriod == 0 {
+			config.DataLifecycle.GracePeriod = 72
+		}
+	}
+	if config.IdMapping.Mode == "" {
+		config.IdMapping.Mode = "sequence"
+	}
+	if config.IdMapping.CacheSize == 0 {
+		config.IdMapping.CacheSize = 10000
+	}
+	for i := range config.RelayRules {
+		if config.RelayRules[i].Direction == "" {
+			config.RelayRules[i].Direction = "oneway"
+		}
+		if config.RelayRules[i].Format == "" {
+			config.RelayRules[i].Format = "[{platform}] {nickname}: "
+		}
+		if config.RelayRules[i].Media == "" {
+			config.RelayRules[i].Media = "forward"
+		}
+	}
 	if config.Logging.Level == "" {
 		config.Logging.Level = "info"
 	}
 
+	current.Store(config)
 	return nil
 }
 
+// GetConfig 当前生效的配置，返回的配置只读，重新加载后返回新的配置
 func GetConfig() *Config {
-	return &config
+	return current.Load()
 }
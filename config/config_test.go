@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeFile(t, path, "bot:\n  qq:\n    - id: 1\n")
+	if err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	cfg := GetConfig()
+	if cfg.Server.Port != "8080" || cfg.Bot.QQ[0].TimestampWindow != 300 || cfg.Bot.QQ[0].WebhookPath != "/qq" {
+		t.Fatalf("默认值未设置: %+v", cfg)
+	}
+}
+
+// TestLoadConfigReplaces 重新加载时发布新的配置，已读取的配置不被修改
+func TestLoadConfigReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeFile(t, path, "server:\n  port: \"8001\"\nbot:\n  kook:\n    token: old\n")
+	if err := LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	old := GetConfig()
+	kook := &old.Bot.Kook
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				// 模拟机器人持有的配置指针被持续读取
+				_ = kook.Token + GetConfig().Server.Port
+			}
+		}
+	}()
+
+	writeFile(t, path, "server:\n  port: \"8002\"\nbot:\n  kook:\n    token: new\n")
+	for i := 0; i < 20; i++ {
+		if err := LoadConfig(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if old.Server.Port != "8001" || kook.Token != "old" {
+		t.Fatalf("旧配置被修改: port=%s token=%s", old.Server.Port, kook.Token)
+	}
+	if cfg := GetConfig(); cfg == old || cfg.Server.Port != "8002" || cfg.Bot.Kook.Token != "new" {
+		t.Fatalf("未发布新的配置: %+v", cfg)
+	}
+
+	// 解析失败时保留当前配置
+	current := GetConfig()
+	writeFile(t, path, "server: [")
+	if err := LoadConfig(path); err == nil {
+		t.Fatal("无效配置应返回错误")
+	}
+	if GetConfig() != current {
+		t.Fatal("解析失败时替换了当前配置")
+	}
+}
@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/logger"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 管理接口
+//
+// 请求头 Authorization: Bearer <token> 校验访问令牌，未配置令牌时不提供服务。
+
+var (
+	token atomic.Pointer[string]
+	once  sync.Once
+)
+
+// Load 加载配置，首次调用时注册管理接口，重新加载配置时再次调用
+func Load(cfg config.Admin) {
+	token.Store(&cfg.Token)
+	once.Do(func() {
+		http.HandleFunc("/admin/search", authorized(http.MethodGet, searchHandler))
+		http.HandleFunc("/admin/erase", authorized(http.MethodPost, eraseHandler))
+		http.HandleFunc("/admin/erasures", authorized(http.MethodGet, erasuresHandler))
+	})
+	if cfg.Token != "" {
+		logger.Infof("管理接口已启用")
+	}
+}
+
+// authorized 校验请求方法与访问令牌
+func authorized(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := *token.Load()
+		if expected == "" {
+			writeError(w, http.StatusNotFound, "管理接口未启用")
+			return
+		}
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, "方法不允许")
+			return
+		}
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			writeError(w, http.StatusUnauthorized, "访问令牌无效")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// writeJSON 返回 JSON 结果
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Warnf("管理接口响应失败: %v", err)
+	}
+}
+
+// writeError 返回错误信息
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
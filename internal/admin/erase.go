@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"GoQHttp/internal/lifecycle"
+	"encoding/json"
+	"net/http"
+)
+
+// EraseParams 删除数据的参数，user_id 与 group_id 二选一，self_id 为空时删除平台全部机器人的数据
+type EraseParams struct {
+	Platform string `json:"platform"`
+	SelfId   int64  `json:"self_id"`
+	UserId   int64  `json:"user_id"`
+	GroupId  int32  `json:"group_id"`
+	// Namespace id所属的映射命名空间，如 qq:user(群成员), qq:c2c:user(单聊用户), qq:guild:user(频道用户)，
+	// 平台的用户id来自多个命名空间时必须指定
+	Namespace string `json:"namespace"`
+	// Policy delete(默认) 或 anonymize
+	Policy string `json:"policy"`
+	Reason string `json:"reason"`
+}
+
+// eraseHandler 立即删除用户或群的数据，返回审计记录
+func eraseHandler(w http.ResponseWriter, r *http.Request) {
+	var p EraseParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "请求内容无效: "+err.Error())
+		return
+	}
+	if (p.UserId == 0) == (p.GroupId == 0) {
+		writeError(w, http.StatusBadRequest, "user_id 与 group_id 需要且只能指定一个")
+		return
+	}
+	request := &lifecycle.Request{
+		Platform:  p.Platform,
+		SelfId:    p.SelfId,
+		Subject:   lifecycle.UserSubject,
+		SubjectId: p.UserId,
+		Namespace: p.Namespace,
+		Policy:    p.Policy,
+		Reason:    p.Reason,
+	}
+	if p.GroupId != 0 {
+		request.Subject, request.SubjectId = lifecycle.GroupSubject, int64(p.GroupId)
+	}
+	if request.Reason == "" {
+		request.Reason = "admin"
+	}
+
+	record, err := lifecycle.Erase(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// erasuresHandler 按时间倒序获取数据删除的审计记录，参数 offset, count
+func erasuresHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	offset, err := parseInt(query, "offset")
+	var count int
+	if err == nil {
+		count, err = parseInt(query, "count")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, total, err := lifecycle.List(offset, count)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"total": total, "erasures": records})
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"GoQHttp/internal/history"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// searchHandler 检索消息记录，参数与 search_msg 相同，self_id 为空时检索全部机器人的消息
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var selfId int64
+	var p history.SearchMsgParams
+	p.Keyword = query.Get("keyword")
+	err := parseInts(query, map[string]*int64{
+		"self_id":    &selfId,
+		"user_id":    &p.UserId,
+		"start_time": &p.StartTime,
+		"end_time":   &p.EndTime,
+	})
+	if err == nil {
+		p.GroupId, err = parseInt32(query, "group_id")
+	}
+	if err == nil {
+		p.Offset, err = parseInt(query, "offset")
+	}
+	if err == nil {
+		p.Count, err = parseInt(query, "count")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := history.Search(selfId, &p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseInts 解析整数参数，参数为空时保持零值
+func parseInts(query url.Values, fields map[string]*int64) error {
+	for name, field := range fields {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("参数 %s 无效: %s", name, value)
+		}
+		*field = parsed
+	}
+	return nil
+}
+
+// parseInt 解析 int 类型的参数，参数为空时返回 0
+func parseInt(query url.Values, name string) (int, error) {
+	var value int64
+	err := parseInts(query, map[string]*int64{name: &value})
+	return int(value), err
+}
+
+// parseInt32 解析 int32 类型的参数，参数为空时返回 0，超出范围时返回错误
+func parseInt32(query url.Values, name string) (int32, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("参数 %s 无效: %s", name, value)
+	}
+	return int32(parsed), nil
+}
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+// request 以 Bearer 令牌调用管理接口，返回状态码与解析后的 JSON
+func request(t *testing.T, handler http.HandlerFunc, method string, target string, bearer string) (int, map[string]any) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	if bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, r)
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("Content-Type 为 %q", contentType)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("返回内容 %q: %v", recorder.Body.String(), err)
+	}
+	return recorder.Code, body
+}
+
+func setToken(t *testing.T, value string) {
+	t.Helper()
+	previous := token.Load()
+	token.Store(&value)
+	t.Cleanup(func() { token.Store(previous) })
+}
+
+func TestSearchAuthorized(t *testing.T) {
+	handler := authorized(http.MethodGet, searchHandler)
+	setToken(t, "")
+	if code, body := request(t, handler, http.MethodGet, "/admin/search", "TOKEN"); code != http.StatusNotFound || body["error"] == nil {
+		t.Fatalf("未配置令牌时返回 %d %v", code, body)
+	}
+
+	setToken(t, "TOKEN")
+	for _, c := range []struct {
+		name   string
+		method string
+		bearer string
+		code   int
+	}{
+		{"方法错误", http.MethodPost, "TOKEN", http.StatusMethodNotAllowed},
+		{"缺少令牌", http.MethodGet, "", http.StatusUnauthorized},
+		{"令牌错误", http.MethodGet, "WRONG", http.StatusUnauthorized},
+	} {
+		if code, body := request(t, handler, c.method, "/admin/search", c.bearer); code != c.code || body["error"] == nil {
+			t.Fatalf("%s 返回 %d %v, 期望 %d", c.name, code, body, c.code)
+		}
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	setToken(t, "TOKEN")
+	handler := authorized(http.MethodGet, searchHandler)
+	for _, target := range []string{
+		"/admin/search?self_id=abc",
+		"/admin/search?group_id=1.5",
+		// 超出 int32 范围的群号不截断
+		"/admin/search?group_id=2147483648",
+		"/admin/search?group_id=-2147483649",
+		"/admin/search?count=many",
+		"/admin/search?offset=-",
+	} {
+		if code, body := request(t, handler, http.MethodGet, target, "TOKEN"); code != http.StatusBadRequest || body["error"] == nil {
+			t.Fatalf("%s 返回 %d %v, 期望 400", target, code, body)
+		}
+	}
+	if code, _ := request(t, handler, http.MethodGet, "/admin/search?group_id=2147483647", "TOKEN"); code != http.StatusOK {
+		t.Fatalf("int32 上限的群号返回 %d", code)
+	}
+}
+
+func TestSearchResult(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	setToken(t, "TOKEN")
+	for i, groupId := range []int32{5, 5, 6} {
+		if err := utils.Store.MessageHistoryInsert(&utils.MessageHistory{
+			Platform:    "test",
+			SelfId:      100,
+			MessageId:   int32(i + 1),
+			MessageType: "group",
+			GroupId:     groupId,
+			SenderId:    7,
+			Sender:      `{"user_id":7,"nickname":"用户"}`,
+			Segments:    `[{"type":"text","data":{"text":"hello"}}]`,
+			RawMessage:  "hello",
+			PlainText:   "hello",
+			TimeStamp:   int64(1000 + i),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, body := request(t, authorized(http.MethodGet, searchHandler), http.MethodGet, "/admin/search?keyword=hello&group_id=5&count=1", "TOKEN")
+	if code != http.StatusOK {
+		t.Fatalf("返回 %d %v", code, body)
+	}
+	messages, ok := body["messages"].([]any)
+	if body["total"] != float64(2) || !ok || len(messages) != 1 {
+		t.Fatalf("检索结果 %v", body)
+	}
+	// 按时间倒序返回，字段与 get_msg 相同
+	message := messages[0].(map[string]any)
+	for key, expected := range map[string]any{
+		"message_id":   float64(2),
+		"self_id":      float64(100),
+		"group_id":     float64(5),
+		"user_id":      float64(7),
+		"message_type": "group",
+		"raw_message":  "hello",
+		"time":         float64(1001),
+	} {
+		if message[key] != expected {
+			t.Fatalf("字段 %s 为 %v, 期望 %v, 消息 %v", key, message[key], expected, message)
+		}
+	}
+	if sender, _ := message["sender"].(map[string]any); sender["nickname"] != "用户" {
+		t.Fatalf("发送者 %v", message["sender"])
+	}
+	if segments, _ := message["message"].([]any); len(segments) != 1 {
+		t.Fatalf("消息内容 %v", message["message"])
+	}
+}
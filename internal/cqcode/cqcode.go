@@ -3,6 +3,7 @@ package cqcode
 import (
 	"GoQHttp/internal/onebot"
 	"GoQHttp/internal/protocol/tencent/dto"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -32,11 +33,11 @@ func (c *CQCode) ParseCQCode(s string) (*CQCode, error) {
 		Raw:    s,
 	}
 
-	// 解析参数
+	// 解析参数，正则中重复的分组只会保留最后一次匹配，因此直接按逗号切分完整内容
 	if len(match) > 2 {
-		paramStr := match[2]
+		paramStr := strings.TrimSuffix(strings.TrimPrefix(match[0], "[CQ:"+match[1]), "]")
 		// 处理多个参数的情况
-		params := strings.Split(paramStr, ",")
+		params := strings.Split(strings.TrimPrefix(paramStr, ","), ",")
 		for _, param := range params {
 			parts := strings.SplitN(param, "=", 2)
 			if len(parts) == 2 {
@@ -113,3 +114,51 @@ func (c *CQCode) BuildCQCodeFromAttachments(data []*dto.MessageAttachment) ([]st
 	}
 	return attachments, nil
 }
+
+// CQUnescape 还原CQ码中被转义的字符
+func CQUnescape(s string) string {
+	return strings.NewReplacer("&#91;", "[", "&#93;", "]", "&#44;", ",", "&amp;", "&").Replace(s)
+}
+
+// ParseMessage 解析动作参数中的 message 字段，兼容数组格式与CQ码字符串格式
+func (c *CQCode) ParseMessage(raw json.RawMessage) ([]*onebot.Element, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		var messages []*onebot.Element
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return nil, fmt.Errorf("无法解析消息: %v", err)
+		}
+		return messages, nil
+	}
+
+	re := regexp.MustCompile(`\[CQ:[^\]]+\]`)
+	var messages []*onebot.Element
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] > last {
+			messages = append(messages, &onebot.Element{
+				ElementType: onebot.TextType,
+				Data:        onebot.Text{Text: CQUnescape(text[last:loc[0]])},
+			})
+		}
+		cq, err := c.ParseCQCode(text[loc[0]:loc[1]])
+		if err == nil {
+			data := make(map[string]string, len(cq.Params))
+			for key, value := range cq.Params {
+				data[key] = CQUnescape(value)
+			}
+			messages = append(messages, &onebot.Element{
+				ElementType: onebot.ElementType(cq.Type),
+				Data:        data,
+			})
+		}
+		last = loc[1]
+	}
+	if last < len(text) {
+		messages = append(messages, &onebot.Element{
+			ElementType: onebot.TextType,
+			Data:        onebot.Text{Text: CQUnescape(text[last:])},
+		})
+	}
+	return messages, nil
+}
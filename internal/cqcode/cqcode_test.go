@@ -0,0 +1,87 @@
+package cqcode
+
+import (
+	"GoQHttp/internal/onebot"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCQCode(t *testing.T) {
+	c := &CQCode{}
+	cq, err := c.ParseCQCode("[CQ:image,file=a.png,url=http://example.com/a.png?x=1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cq.Type != "image" {
+		t.Fatalf("类型 %q", cq.Type)
+	}
+	// 多个参数都需要保留，而不只是最后一个
+	if cq.Params["file"] != "a.png" || cq.Params["url"] != "http://example.com/a.png?x=1" || len(cq.Params) != 2 {
+		t.Fatalf("参数 %v", cq.Params)
+	}
+
+	cq, err = c.ParseCQCode("[CQ:shake]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cq.Type != "shake" || len(cq.Params) != 0 {
+		t.Fatalf("无参数CQ码解析为 %+v", cq)
+	}
+
+	if _, err = c.ParseCQCode("hello"); err == nil {
+		t.Fatal("非CQ码应返回错误")
+	}
+}
+
+func TestCQUnescape(t *testing.T) {
+	got := CQUnescape("&#91;a&#44;b&#93; &amp;#91;")
+	if got != "[a,b] &#91;" {
+		t.Fatalf("CQUnescape 返回 %q", got)
+	}
+	if CQUnescape(onebot.CQEscape("[x,y]&")) != "[x,y]&" {
+		t.Fatal("转义后应可还原")
+	}
+}
+
+func TestParseMessageString(t *testing.T) {
+	c := &CQCode{}
+	raw, _ := json.Marshal("hi [CQ:at,qq=123]&#91;x&#93;[CQ:image,file=a&#44;b.png]")
+	elements, err := c.ParseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		elementType onebot.ElementType
+		key         string
+		value       string
+	}{
+		{onebot.TextType, "text", "hi "},
+		{onebot.ElementType("at"), "qq", "123"},
+		{onebot.TextType, "text", "[x]"},
+		{onebot.ImageType, "file", "a,b.png"},
+	}
+	if len(elements) != len(want) {
+		t.Fatalf("消息段数量 %d, 期望 %d", len(elements), len(want))
+	}
+	for i, w := range want {
+		if elements[i].ElementType != w.elementType || elements[i].Field(w.key) != w.value {
+			t.Fatalf("第 %d 个消息段 %s %s=%q", i, elements[i].ElementType, w.key, elements[i].Field(w.key))
+		}
+	}
+}
+
+func TestParseMessageArray(t *testing.T) {
+	c := &CQCode{}
+	raw := json.RawMessage(`[{"type":"text","data":{"text":"hi"}},{"type":"reply","data":{"id":42}}]`)
+	elements, err := c.ParseMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elements) != 2 || elements[0].Field("text") != "hi" || elements[1].Field("id") != "42" {
+		t.Fatalf("数组格式解析为 %+v", elements)
+	}
+
+	if _, err = c.ParseMessage(json.RawMessage(`{"type":"text"}`)); err == nil {
+		t.Fatal("无效的消息应返回错误")
+	}
+}
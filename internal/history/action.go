@@ -0,0 +1,182 @@
+package history
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultHistoryCount = 20
+	maxHistoryCount     = 100
+)
+
+// Message get_msg 与 get_group_msg_history 返回的消息，message_seq 为消息记录的序号
+type Message struct {
+	Time        int64              `json:"time"`
+	SelfId      int64              `json:"self_id"`
+	MessageType onebot.MessageType `json:"message_type"`
+	MessageId   int32              `json:"message_id"`
+	RealId      int32              `json:"real_id"`
+	MessageSeq  int64              `json:"message_seq"`
+	GroupId     int32              `json:"group_id,omitempty"`
+	UserId      int64              `json:"user_id"`
+	Sender      onebot.Sender      `json:"sender"`
+	Message     []*onebot.Element  `json:"message"`
+	RawMessage  string             `json:"raw_message"`
+}
+
+// GetMsgParams get_msg 参数
+type GetMsgParams struct {
+	MessageId int32 `json:"message_id"`
+}
+
+// GetGroupMsgHistoryParams get_group_msg_history 参数，message_seq 与 message_id 均为空时获取最新的消息
+type GetGroupMsgHistoryParams struct {
+	GroupId    int32 `json:"group_id"`
+	MessageSeq int64 `json:"message_seq"`
+	MessageId  int32 `json:"message_id"`
+	Count      int   `json:"count"`
+}
+
+// SearchMsgParams search_msg 参数，时间为秒级时间戳，为零值的条件不限制
+type SearchMsgParams struct {
+	Keyword   string `json:"keyword"`
+	GroupId   int32  `json:"group_id"`
+	UserId    int64  `json:"user_id"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	Offset    int    `json:"offset"`
+	Count     int    `json:"count"`
+}
+
+// SearchResult 检索结果，total 为符合条件的消息总数
+type SearchResult struct {
+	Total    int        `json:"total"`
+	Messages []*Message `json:"messages"`
+}
+
+// newMessage 将消息记录转换为返回的消息
+func newMessage(history *utils.MessageHistory) (*Message, error) {
+	message := &Message{
+		Time:        history.TimeStamp,
+		SelfId:      history.SelfId,
+		MessageType: onebot.MessageType(history.MessageType),
+		MessageId:   history.MessageId,
+		RealId:      history.MessageId,
+		MessageSeq:  history.ID,
+		GroupId:     history.GroupId,
+		UserId:      history.SenderId,
+		RawMessage:  history.RawMessage,
+	}
+	if err := json.Unmarshal([]byte(history.Sender), &message.Sender); err != nil {
+		return nil, fmt.Errorf("消息 %d 发送者解析失败: %v", history.MessageId, err)
+	}
+	if err := json.Unmarshal([]byte(history.Segments), &message.Message); err != nil {
+		return nil, fmt.Errorf("消息 %d 内容解析失败: %v", history.MessageId, err)
+	}
+	return message, nil
+}
+
+// GetMsgAction 获取消息
+func GetMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	var p GetMsgParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	history, err := utils.Store.MessageHistoryGet(selfId, p.MessageId)
+	if errors.Is(err, utils.ErrNotFound) {
+		return nil, fmt.Errorf("未找到消息 %d", p.MessageId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newMessage(history)
+}
+
+// GetGroupMsgHistoryAction 获取群消息记录，返回 message_seq 及之前的 count 条消息，按时间顺序排列
+func GetGroupMsgHistoryAction(selfId int64, params json.RawMessage) (any, error) {
+	var p GetGroupMsgHistoryParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.GroupId == 0 {
+		return nil, errors.New("group_id 不能为空")
+	}
+	if p.Count <= 0 {
+		p.Count = defaultHistoryCount
+	}
+	if p.Count > maxHistoryCount {
+		p.Count = maxHistoryCount
+	}
+	if p.MessageSeq == 0 && p.MessageId != 0 {
+		history, err := utils.Store.MessageHistoryGet(selfId, p.MessageId)
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, fmt.Errorf("未找到消息 %d", p.MessageId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.MessageSeq = history.ID
+	}
+
+	histories, err := utils.Store.MessageHistoryGroup(selfId, p.GroupId, p.MessageSeq, p.Count)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*Message, 0, len(histories))
+	for i := range histories {
+		message, err := newMessage(&histories[i])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return map[string]any{"messages": messages}, nil
+}
+
+// Search 检索消息记录，selfId 为 0 时检索全部机器人的消息，结果按时间倒序排列
+func Search(selfId int64, p *SearchMsgParams) (*SearchResult, error) {
+	if p.Count <= 0 {
+		p.Count = defaultHistoryCount
+	}
+	if p.Count > maxHistoryCount {
+		p.Count = maxHistoryCount
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	histories, total, err := utils.Store.MessageHistorySearch(&utils.MessageSearchQuery{
+		Keyword:   p.Keyword,
+		SelfId:    selfId,
+		GroupId:   p.GroupId,
+		UserId:    p.UserId,
+		StartTime: p.StartTime,
+		EndTime:   p.EndTime,
+		Offset:    p.Offset,
+		Limit:     p.Count,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &SearchResult{Total: total, Messages: make([]*Message, 0, len(histories))}
+	for i := range histories {
+		message, err := newMessage(&histories[i])
+		if err != nil {
+			return nil, err
+		}
+		result.Messages = append(result.Messages, message)
+	}
+	return result, nil
+}
+
+// SearchMsgAction 检索机器人的消息记录
+func SearchMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	var p SearchMsgParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	return Search(selfId, &p)
+}
@@ -0,0 +1,78 @@
+package history
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/utils"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// groupHistory 调用 get_group_msg_history，返回消息的 message_id
+func groupHistory(t *testing.T, params GetGroupMsgHistoryParams) ([]int32, error) {
+	t.Helper()
+	data, _ := json.Marshal(params)
+	result, err := GetGroupMsgHistoryAction(testIdentity.SelfId, data)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int32
+	for _, message := range result.(map[string]any)["messages"].([]*Message) {
+		ids = append(ids, message.MessageId)
+	}
+	return ids, nil
+}
+
+func TestGetGroupMsgHistory(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	// 群 9 的消息id为 1~110，其他群与私聊的消息穿插其中
+	for i := int32(1); i <= 110; i++ {
+		record(testIdentity, textMessage(onebot.GroupMessage, i, 9, 7, fmt.Sprint(i)), false)
+		record(testIdentity, textMessage(onebot.GroupMessage, 1000+i, 8, 7, fmt.Sprint(i)), false)
+	}
+	record(testIdentity, textMessage(onebot.PrivateMessage, 2000, 0, 7, "私聊"), false)
+	seqOf := func(messageId int32) int64 {
+		history, err := utils.Store.MessageHistoryGet(testIdentity.SelfId, messageId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return history.ID
+	}
+
+	for _, c := range []struct {
+		name   string
+		params GetGroupMsgHistoryParams
+		first  int32
+		last   int32
+		length int
+	}{
+		{"最新消息", GetGroupMsgHistoryParams{GroupId: 9, Count: 3}, 108, 110, 3},
+		{"默认数量", GetGroupMsgHistoryParams{GroupId: 9}, 91, 110, defaultHistoryCount},
+		{"数量上限", GetGroupMsgHistoryParams{GroupId: 9, Count: 1000}, 11, 110, maxHistoryCount},
+		{"按 message_seq", GetGroupMsgHistoryParams{GroupId: 9, MessageSeq: seqOf(50), Count: 5}, 46, 50, 5},
+		{"按 message_id", GetGroupMsgHistoryParams{GroupId: 9, MessageId: 50, Count: 5}, 46, 50, 5},
+		{"message_seq 优先", GetGroupMsgHistoryParams{GroupId: 9, MessageSeq: seqOf(20), MessageId: 50, Count: 5}, 16, 20, 5},
+		{"不足数量", GetGroupMsgHistoryParams{GroupId: 9, MessageId: 2, Count: 5}, 1, 2, 2},
+	} {
+		ids, err := groupHistory(t, c.params)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// 按时间顺序排列，只包含该群的消息
+		if len(ids) != c.length || ids[0] != c.first || ids[len(ids)-1] != c.last {
+			t.Fatalf("%s 得到 %v, 期望 %d~%d 共 %d 条", c.name, ids, c.first, c.last, c.length)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] != ids[i-1]+1 {
+				t.Fatalf("%s 得到 %v", c.name, ids)
+			}
+		}
+	}
+
+	if _, err := groupHistory(t, GetGroupMsgHistoryParams{}); err == nil {
+		t.Fatal("group_id 为空时应返回错误")
+	}
+	if _, err := groupHistory(t, GetGroupMsgHistoryParams{GroupId: 9, MessageId: 3000}); err == nil {
+		t.Fatal("未知的 message_id 应返回错误")
+	}
+}
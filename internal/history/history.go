@@ -0,0 +1,187 @@
+package history
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 收发消息记录
+//
+// 收到的消息由事件钩子在广播给功能端之前写入，功能端收到事件后即可通过 get_msg 获取；机器人发送的消息由发送钩子写入。
+// 后台按保留天数清理过期消息，清理后按间隔执行 VACUUM 回收空间。
+
+const defaultPurgeInterval = 60
+
+// UserNamespace 获取消息中用户id的映射命名空间
+type UserNamespace func(message *onebot.MessageRequest) idmap.Namespace
+
+var (
+	settings atomic.Pointer[config.MessageHistory]
+	once     sync.Once
+	// userNamespaces 平台用户id的命名空间，未注册的平台为 平台:user
+	userNamespaces   = make(map[string]UserNamespace)
+	userNamespacesMu sync.RWMutex
+)
+
+// RegisterUserNamespace 注册平台消息中用户id的命名空间，同一平台的用户id来自多个命名空间时在 init 中注册
+func RegisterUserNamespace(platform string, namespace UserNamespace) {
+	userNamespacesMu.Lock()
+	defer userNamespacesMu.Unlock()
+	userNamespaces[platform] = namespace
+}
+
+// userNamespaceOf 消息中用户id的命名空间名称
+func userNamespaceOf(platform string, message *onebot.MessageRequest) string {
+	userNamespacesMu.RLock()
+	namespace, ok := userNamespaces[platform]
+	userNamespacesMu.RUnlock()
+	if ok {
+		return namespace(message).Name
+	}
+	return idmap.NewNamespace(platform, idmap.User).Name
+}
+
+// Load 加载配置，首次调用时开始记录消息与清理，重新加载配置时再次调用
+func Load(cfg config.MessageHistory) {
+	settings.Store(&cfg)
+	once.Do(func() {
+		protocol.AddEventHook(received)
+		protocol.AddSentHook(sent)
+		protocol.RegisterAction("get_msg", GetMsgAction)
+		protocol.RegisterAction("get_group_msg_history", GetGroupMsgHistoryAction)
+		protocol.RegisterAction("search_msg", SearchMsgAction)
+		go purge()
+	})
+}
+
+// received 记录收到的消息
+func received(identity protocol.Identity, event onebot.Event) {
+	switch e := event.(type) {
+	case onebot.MessageRequest:
+		record(identity, &e, false)
+	case *onebot.MessageRequest:
+		record(identity, e, false)
+	}
+}
+
+// sent 记录机器人发送的消息
+func sent(identity protocol.Identity, message *onebot.MessageRequest) {
+	record(identity, message, true)
+}
+
+// record 写入消息记录，私聊消息的 user_id 为对方，发送的消息发送者为机器人自身
+func record(identity protocol.Identity, message *onebot.MessageRequest, outgoing bool) {
+	if message.MessageId == 0 {
+		return
+	}
+	sender, err := json.Marshal(message.Sender)
+	if err != nil {
+		logger.Warnf("消息 %d 记录失败: %v", message.MessageId, err)
+		return
+	}
+	segments, err := json.Marshal(message.Message)
+	if err != nil {
+		logger.Warnf("消息 %d 记录失败: %v", message.MessageId, err)
+		return
+	}
+	rawMessage := message.RawMessage
+	if rawMessage == "" {
+		rawMessage = onebot.CQString(message.Message)
+	}
+	timeStamp := message.Time
+	if timeStamp == 0 {
+		timeStamp = time.Now().Unix()
+	}
+
+	history := &utils.MessageHistory{
+		Platform:    identity.Platform,
+		SelfId:      identity.SelfId,
+		MessageId:   message.MessageId,
+		MessageType: string(message.MessageType),
+		SenderId:    message.UserId,
+		Sender:      string(sender),
+		Segments:    string(segments),
+		RawMessage:  rawMessage,
+		Outgoing:    outgoing,
+		TimeStamp:   timeStamp,
+		PlainText:   plainText(message.Message),
+		// 删除用户数据时按命名空间区分不同来源的相同数字id
+		UserNamespace: userNamespaceOf(identity.Platform, message),
+	}
+	if outgoing {
+		history.SenderId = identity.SelfId
+	}
+	if message.MessageType == onebot.GroupMessage {
+		history.GroupId = message.GroupId
+	} else {
+		history.UserId = message.UserId
+	}
+	if err = utils.Store.MessageHistoryInsert(history); err != nil {
+		logger.Warnf("消息 %d 记录失败: %v", message.MessageId, err)
+	}
+}
+
+// plainText 提取消息中的文本用于全文检索
+func plainText(elements []*onebot.Element) string {
+	var builder strings.Builder
+	for _, element := range elements {
+		if element.ElementType == onebot.TextType {
+			builder.WriteString(element.Field("text"))
+		}
+	}
+	return builder.String()
+}
+
+// purger 记录上次回收空间的时间及之后清理的消息数量
+type purger struct {
+	lastVacuum time.Time
+	purged     int64
+}
+
+// purge 定期清理过期消息
+func purge() {
+	p := &purger{lastVacuum: time.Now()}
+	for {
+		cfg := settings.Load()
+		p.run(cfg, time.Now())
+
+		interval := cfg.PurgeInterval
+		if interval <= 0 {
+			interval = defaultPurgeInterval
+		}
+		time.Sleep(time.Duration(interval) * time.Minute)
+	}
+}
+
+// run 按保留天数清理一次过期消息，清理后距离上次回收超过间隔时执行 VACUUM
+func (p *purger) run(cfg *config.MessageHistory, now time.Time) {
+	if cfg.RetentionDays >= 0 {
+		before := now.AddDate(0, 0, -cfg.RetentionDays).Unix()
+		rows, err := utils.Store.MessageHistoryPurge(before)
+		if err != nil {
+			logger.Warnf("清理过期消息失败: %v", err)
+		} else if rows > 0 {
+			p.purged += rows
+			logger.Infof("已清理 %d 条过期消息", rows)
+		}
+	}
+
+	vacuumInterval := time.Duration(cfg.VacuumInterval) * time.Hour
+	if cfg.VacuumInterval >= 0 && p.purged > 0 && now.Sub(p.lastVacuum) >= vacuumInterval {
+		if err := utils.Store.Vacuum(); err != nil {
+			logger.Warnf("数据库空间回收失败: %v", err)
+		} else {
+			logger.Infof("数据库空间回收完成")
+			p.lastVacuum, p.purged = now, 0
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+var testIdentity = protocol.Identity{Platform: "test", SelfId: 100}
+
+// textMessage 创建文本消息
+func textMessage(messageType onebot.MessageType, messageId int32, groupId int32, userId int64, content string) *onebot.MessageRequest {
+	return &onebot.MessageRequest{
+		MessageType: messageType,
+		MessageId:   messageId,
+		GroupId:     groupId,
+		UserId:      userId,
+		Sender:      onebot.Sender{UserId: userId, NickName: "用户"},
+		Message:     []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: content}}},
+	}
+}
+
+func TestRecord(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	record(testIdentity, textMessage(onebot.PrivateMessage, 1, 0, 7, "收到"), false)
+	// 发送的私聊消息 user_id 为对方，发送者为机器人
+	record(testIdentity, textMessage(onebot.PrivateMessage, 2, 0, 7, "回复"), true)
+	record(testIdentity, textMessage(onebot.GroupMessage, 3, 9, 0, "群消息"), true)
+	// 没有消息id的消息不记录
+	record(testIdentity, textMessage(onebot.PrivateMessage, 0, 0, 7, "失败"), true)
+
+	for _, c := range []struct {
+		messageId int32
+		outgoing  bool
+		senderId  int64
+		userId    int64
+		groupId   int32
+		text      string
+	}{
+		{1, false, 7, 7, 0, "收到"},
+		{2, true, 100, 7, 0, "回复"},
+		{3, true, 100, 0, 9, "群消息"},
+	} {
+		history, err := utils.Store.MessageHistoryGet(testIdentity.SelfId, c.messageId)
+		if err != nil {
+			t.Fatalf("消息 %d: %v", c.messageId, err)
+		}
+		if history.Outgoing != c.outgoing || history.SenderId != c.senderId || history.UserId != c.userId || history.GroupId != c.groupId ||
+			history.PlainText != c.text || history.RawMessage != c.text || history.UserNamespace != "test:user" {
+			t.Fatalf("消息 %d 记录为 %+v", c.messageId, history)
+		}
+	}
+	if _, err := utils.Store.MessageHistoryGet(testIdentity.SelfId, 0); err == nil {
+		t.Fatal("没有消息id的消息不应记录")
+	}
+
+	// get_msg 返回的 user_id 为发送者
+	params, _ := json.Marshal(GetMsgParams{MessageId: 2})
+	result, err := GetMsgAction(testIdentity.SelfId, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message := result.(*Message); message.UserId != 100 || message.MessageType != onebot.PrivateMessage || len(message.Message) != 1 {
+		t.Fatalf("get_msg 返回 %+v", message)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	now := time.Now()
+	for i, days := range []int{10, 8, 1} {
+		message := textMessage(onebot.GroupMessage, int32(i+1), 9, 7, "消息")
+		message.Time = now.AddDate(0, 0, -days).Unix()
+		record(testIdentity, message, false)
+	}
+	exists := func(messageId int32) bool {
+		_, err := utils.Store.MessageHistoryGet(testIdentity.SelfId, messageId)
+		return err == nil
+	}
+
+	// 不保留期限时不清理
+	p := &purger{lastVacuum: now}
+	p.run(&config.MessageHistory{RetentionDays: -1, VacuumInterval: 0}, now)
+	if !exists(1) || p.purged != 0 {
+		t.Fatal("retention_days 为 -1 时不应清理")
+	}
+
+	// 清理超过保留天数的消息，未到回收间隔时不回收
+	p.run(&config.MessageHistory{RetentionDays: 9, VacuumInterval: 24}, now.Add(time.Hour))
+	if exists(1) || !exists(2) || p.purged != 1 || !p.lastVacuum.Equal(now) {
+		t.Fatalf("清理后 purged=%d lastVacuum=%v", p.purged, p.lastVacuum)
+	}
+	// 关闭回收时只清理
+	p.run(&config.MessageHistory{RetentionDays: 7, VacuumInterval: -1}, now.Add(25*time.Hour))
+	if exists(2) || !exists(3) || p.purged != 2 || !p.lastVacuum.Equal(now) {
+		t.Fatalf("关闭回收后 purged=%d lastVacuum=%v", p.purged, p.lastVacuum)
+	}
+	// 超过回收间隔且有清理的消息时回收
+	later := now.Add(25 * time.Hour)
+	p.run(&config.MessageHistory{RetentionDays: 7, VacuumInterval: 24}, later)
+	if !exists(3) || p.purged != 0 || !p.lastVacuum.Equal(later) {
+		t.Fatalf("回收后 purged=%d lastVacuum=%v", p.purged, p.lastVacuum)
+	}
+	// 没有新清理的消息时不再回收
+	p.run(&config.MessageHistory{RetentionDays: 7, VacuumInterval: 0}, later.Add(time.Hour))
+	if !p.lastVacuum.Equal(later) {
+		t.Fatal("没有清理消息时不应回收")
+	}
+}
@@ -0,0 +1,69 @@
+package idmap
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache 固定容量的 LRU 缓存
+type cache struct {
+	size  int
+	items map[string]*list.Element
+	order *list.List
+	mu    sync.Mutex
+}
+
+type entry struct {
+	key   string
+	value any
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Get 获取缓存并标记为最近使用
+func (c *cache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*entry).value, true
+}
+
+// Add 写入缓存，超出容量时移除最久未使用的缓存
+func (c *cache) Add(key string, value any) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		element.Value.(*entry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+// Remove 移除缓存
+func (c *cache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
@@ -0,0 +1,253 @@
+package idmap
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// 平台原始id与 OneBot 数字id的映射
+//
+// 数字id在命名空间内唯一，按类型限制取值范围：群与消息为 int32，用户、服务器与频道不超过 2^53-1 以便 JavaScript 功能端使用。
+// sequence 模式按顺序分配；hash 模式由命名空间与原始id计算，冲突时依次尝试下一个候选值，数据库重置或多实例部署时id保持不变。
+
+// Kind 映射的id类型
+type Kind string
+
+const (
+	User    Kind = "user"
+	Group   Kind = "group"
+	Guild   Kind = "guild"
+	Channel Kind = "channel"
+	Message Kind = "message"
+)
+
+const (
+	SequenceMode = "sequence"
+	HashMode     = "hash"
+)
+
+const (
+	defaultCacheSize = 10000
+	// maxProbes hash 模式冲突时尝试的候选值数量
+	maxProbes = 16
+	maxSafeId = 1<<53 - 1
+)
+
+// ErrNotFound 数字id没有对应的原始id
+var ErrNotFound = errors.New("id mapping not found")
+
+// Namespace 映射的命名空间，名称为 平台:类型
+type Namespace struct {
+	Name string
+	Kind Kind
+}
+
+// NewNamespace 创建平台指定类型id的命名空间
+func NewNamespace(platform string, kind Kind) Namespace {
+	return Namespace{Name: platform + ":" + string(kind), Kind: kind}
+}
+
+// Max 命名空间中数字id的最大值
+func (n Namespace) Max() int64 {
+	switch n.Kind {
+	case Group, Message:
+		return math.MaxInt32
+	default:
+		return maxSafeId
+	}
+}
+
+// Mapper 带缓存的映射服务
+type Mapper struct {
+	mode  string
+	cache *cache
+	// mu 分配新id时加锁，避免并发分配相同的候选值
+	mu sync.Mutex
+}
+
+var mapper = New(config.IdMapping{Mode: SequenceMode, CacheSize: defaultCacheSize})
+
+// New 创建映射服务
+func New(cfg config.IdMapping) *Mapper {
+	mode := cfg.Mode
+	if mode != HashMode {
+		mode = SequenceMode
+	}
+	return &Mapper{mode: mode, cache: newCache(cfg.CacheSize)}
+}
+
+// Init 按配置初始化全局映射服务
+func Init(cfg config.IdMapping) {
+	if cfg.Mode != SequenceMode && cfg.Mode != HashMode {
+		logger.Warnf("不支持的id映射方式 %s, 使用 %s", cfg.Mode, SequenceMode)
+	}
+	mapper = New(cfg)
+}
+
+// Id 获取原始id对应的数字id，不存在时分配新的id
+func Id(namespace Namespace, rawId string) (int64, error) {
+	return mapper.Id(namespace, rawId)
+}
+
+// Raw 获取数字id对应的原始id
+func Raw(namespace Namespace, id int64) (string, error) {
+	return mapper.Raw(namespace, id)
+}
+
+// Lookup 获取原始id对应的数字id，不存在时返回 ErrNotFound
+func Lookup(namespace Namespace, rawId string) (int64, error) {
+	return mapper.Lookup(namespace, rawId)
+}
+
+// Erase 删除数字id对应的原始id，数字id保留不再分配，映射不存在时返回 false
+func Erase(namespace Namespace, id int64) (bool, error) {
+	return mapper.Erase(namespace, id)
+}
+
+func rawKey(namespace Namespace, rawId string) string {
+	return namespace.Name + "\x00" + rawId
+}
+
+func idKey(namespace Namespace, id int64) string {
+	return namespace.Name + "#" + strconv.FormatInt(id, 10)
+}
+
+// Id 获取原始id对应的数字id，不存在时分配新的id
+func (m *Mapper) Id(namespace Namespace, rawId string) (int64, error) {
+	if value, ok := m.cache.Get(rawKey(namespace, rawId)); ok {
+		return value.(int64), nil
+	}
+	id, err := utils.Store.IdMapId(namespace.Name, rawId)
+	if errors.Is(err, utils.ErrNotFound) {
+		id, err = m.allocate(namespace, rawId)
+	}
+	if err != nil {
+		return 0, err
+	}
+	m.remember(namespace, id, rawId)
+	return id, nil
+}
+
+// Raw 获取数字id对应的原始id
+func (m *Mapper) Raw(namespace Namespace, id int64) (string, error) {
+	if value, ok := m.cache.Get(idKey(namespace, id)); ok {
+		return value.(string), nil
+	}
+	rawId, err := utils.Store.IdMapRaw(namespace.Name, id)
+	if errors.Is(err, utils.ErrNotFound) {
+		return "", fmt.Errorf("%w: %s %d", ErrNotFound, namespace.Name, id)
+	}
+	if err != nil {
+		return "", err
+	}
+	m.remember(namespace, id, rawId)
+	return rawId, nil
+}
+
+// Lookup 获取原始id对应的数字id，不存在时返回 ErrNotFound
+func (m *Mapper) Lookup(namespace Namespace, rawId string) (int64, error) {
+	if value, ok := m.cache.Get(rawKey(namespace, rawId)); ok {
+		return value.(int64), nil
+	}
+	id, err := utils.Store.IdMapId(namespace.Name, rawId)
+	if errors.Is(err, utils.ErrNotFound) {
+		return 0, fmt.Errorf("%w: %s %s", ErrNotFound, namespace.Name, rawId)
+	}
+	if err != nil {
+		return 0, err
+	}
+	m.remember(namespace, id, rawId)
+	return id, nil
+}
+
+// Erase 删除数字id对应的原始id并清除缓存
+func (m *Mapper) Erase(namespace Namespace, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rawId, err := m.Raw(namespace, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	erased, err := utils.Store.IdMapErase(namespace.Name, id)
+	if err != nil {
+		return false, err
+	}
+	m.cache.Remove(rawKey(namespace, rawId))
+	m.cache.Remove(idKey(namespace, id))
+	return erased, nil
+}
+
+func (m *Mapper) remember(namespace Namespace, id int64, rawId string) {
+	m.cache.Add(rawKey(namespace, rawId), id)
+	m.cache.Add(idKey(namespace, id), rawId)
+}
+
+// allocate 为原始id分配数字id，候选值已被其他原始id使用时尝试下一个
+func (m *Mapper) allocate(namespace Namespace, rawId string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for probe := 0; probe < maxProbes; probe++ {
+		candidate, err := m.candidate(namespace, rawId, probe)
+		if err != nil {
+			return 0, err
+		}
+		inserted, err := utils.Store.IdMapInsert(namespace.Name, candidate, rawId)
+		if err != nil {
+			return 0, err
+		}
+		if inserted {
+			return candidate, nil
+		}
+		// 其他实例可能已经为该原始id分配了id
+		id, err := utils.Store.IdMapId(namespace.Name, rawId)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, utils.ErrNotFound) {
+			return 0, err
+		}
+		if m.mode == HashMode {
+			logger.Warnf("id映射冲突: %s 的 %s 与已有映射使用相同的id %d", namespace.Name, rawId, candidate)
+		}
+	}
+	return 0, fmt.Errorf("无法为 %s 的 %s 分配id", namespace.Name, rawId)
+}
+
+// candidate 计算第 probe 次尝试的候选id
+func (m *Mapper) candidate(namespace Namespace, rawId string, probe int) (int64, error) {
+	if m.mode == HashMode {
+		return hashId(namespace, rawId, probe), nil
+	}
+	last, err := utils.Store.IdMapMax(namespace.Name)
+	if err != nil {
+		return 0, err
+	}
+	if last >= namespace.Max() {
+		return 0, fmt.Errorf("%s 的id已用尽", namespace.Name)
+	}
+	return last + 1, nil
+}
+
+// hashId 以 FNV-1a 计算候选id，取值范围为 1 到命名空间的最大值
+func hashId(namespace Namespace, rawId string, probe int) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(namespace.Name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(rawId))
+	if probe > 0 {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(strconv.Itoa(probe)))
+	}
+	return int64(h.Sum64()%uint64(namespace.Max())) + 1
+}
@@ -0,0 +1,124 @@
+package idmap
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+func TestHashId(t *testing.T) {
+	users := NewNamespace("test", User)
+	groups := NewNamespace("test", Group)
+
+	if hashId(users, "raw", 0) != hashId(users, "raw", 0) {
+		t.Fatal("相同的原始id应得到相同的候选值")
+	}
+	if hashId(users, "raw", 0) == hashId(NewNamespace("other", User), "raw", 0) {
+		t.Fatal("不同命名空间的候选值不应相同")
+	}
+	seen := make(map[int64]int)
+	for probe := 0; probe < maxProbes; probe++ {
+		id := hashId(users, "raw", probe)
+		if previous, ok := seen[id]; ok {
+			t.Fatalf("第 %d 次与第 %d 次尝试的候选值相同", probe, previous)
+		}
+		seen[id] = probe
+	}
+	for i := 0; i < 1000; i++ {
+		raw := fmt.Sprint("raw", i)
+		if id := hashId(groups, raw, 0); id < 1 || id > groups.Max() {
+			t.Fatalf("群的候选值 %d 超出范围", id)
+		}
+		if id := hashId(users, raw, 0); id < 1 || id > users.Max() {
+			t.Fatalf("用户的候选值 %d 超出范围", id)
+		}
+	}
+}
+
+func TestHashModeCollision(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	m := New(config.IdMapping{Mode: HashMode})
+	namespace := NewNamespace("test-collision", Group)
+
+	// 其他原始id占用了第一个候选值
+	if _, err := utils.Store.IdMapInsert(namespace.Name, hashId(namespace, "raw", 0), "other"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.Id(namespace, "raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != hashId(namespace, "raw", 1) {
+		t.Fatalf("冲突时应使用下一个候选值, 得到 %d", id)
+	}
+	// 重新创建映射服务后id保持不变
+	if again, err := New(config.IdMapping{Mode: HashMode}).Id(namespace, "raw"); err != nil || again != id {
+		t.Fatalf("再次映射得到 %d, err=%v, 期望 %d", again, err, id)
+	}
+	if raw, err := m.Raw(namespace, id); err != nil || raw != "raw" {
+		t.Fatalf("反查得到 %q, err=%v", raw, err)
+	}
+}
+
+func TestHashModeProbesExhausted(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	m := New(config.IdMapping{Mode: HashMode})
+	namespace := NewNamespace("test-exhausted", Group)
+
+	for probe := 0; probe < maxProbes; probe++ {
+		if _, err := utils.Store.IdMapInsert(namespace.Name, hashId(namespace, "raw", probe), fmt.Sprint("other", probe)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if id, err := m.Id(namespace, "raw"); err == nil {
+		t.Fatalf("候选值全部被占用时应返回错误, 得到 %d", id)
+	}
+}
+
+func TestSequenceMode(t *testing.T) {
+	// 每次运行使用新的存储，-count 重复运行时从 1 开始分配
+	utils.Store = utils.NewMemoryStorage()
+	m := New(config.IdMapping{Mode: SequenceMode, CacheSize: 10})
+	namespace := NewNamespace("test-sequence", User)
+
+	first, err := m.Id(namespace, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Id(namespace, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 1 || second != 2 {
+		t.Fatalf("按顺序分配得到 %d %d", first, second)
+	}
+	if id, _ := m.Id(namespace, "a"); id != first {
+		t.Fatalf("再次映射得到 %d, 期望 %d", id, first)
+	}
+	if _, err = m.Lookup(namespace, "c"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("未映射的原始id err=%v", err)
+	}
+	if _, err = m.Raw(namespace, 3); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("未映射的数字id err=%v", err)
+	}
+
+	// 删除后数字id不再分配
+	if erased, err := m.Erase(namespace, first); err != nil || !erased {
+		t.Fatalf("删除映射 erased=%v err=%v", erased, err)
+	}
+	if raw, _ := m.Raw(namespace, first); raw != utils.ErasedRawId(first) {
+		t.Fatalf("删除后反查得到 %q, 期望占位值", raw)
+	}
+	if id, _ := m.Id(namespace, "a"); id != 3 {
+		t.Fatalf("删除后重新映射得到 %d, 期望 3", id)
+	}
+}
@@ -0,0 +1,192 @@
+package kmarkdown
+
+import (
+	"GoQHttp/internal/onebot"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxContainerImages 单个图片容器模块最多包含的图片数量
+const maxContainerImages = 9
+
+// Card 卡片消息，消息内容为卡片数组
+type Card struct {
+	Type    string    `json:"type"`
+	Theme   string    `json:"theme,omitempty"`
+	Size    string    `json:"size,omitempty"`
+	Modules []*Module `json:"modules"`
+}
+
+// Module 卡片模块
+type Module struct {
+	Type      string     `json:"type"`
+	Text      *Text      `json:"text,omitempty"`
+	Elements  []*Element `json:"elements,omitempty"`
+	Accessory *Element   `json:"accessory,omitempty"`
+	Src       string     `json:"src,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Cover     string     `json:"cover,omitempty"`
+}
+
+// Text 文本元素，type 为 plain-text、kmarkdown 或 paragraph
+type Text struct {
+	Type    string  `json:"type"`
+	Content string  `json:"content,omitempty"`
+	Fields  []*Text `json:"fields,omitempty"`
+}
+
+// Element 模块中的元素，type 为 plain-text、kmarkdown、image 或 button
+type Element struct {
+	Type    string `json:"type"`
+	Content string `json:"content,omitempty"`
+	Src     string `json:"src,omitempty"`
+	Text    *Text  `json:"text,omitempty"`
+}
+
+// ParseCard 将卡片消息转换为消息段，保留文本、提及、表情、图片及文件，按钮等交互元素忽略
+func ParseCard(content string) ([]*onebot.Element, error) {
+	var cards []*Card
+	if err := json.Unmarshal([]byte(content), &cards); err != nil {
+		return nil, fmt.Errorf("无法解析卡片消息: %v", err)
+	}
+
+	var elements []*onebot.Element
+	line := func(parsed []*onebot.Element) {
+		if len(parsed) == 0 {
+			return
+		}
+		if len(elements) > 0 {
+			elements = append(elements, &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: "\n"}})
+		}
+		elements = append(elements, parsed...)
+	}
+	for _, card := range cards {
+		for _, module := range card.Modules {
+			switch module.Type {
+			case "header", "section":
+				line(parseText(module.Text))
+				if module.Accessory != nil && module.Accessory.Type == "image" {
+					elements = append(elements, image(module.Accessory.Src))
+				}
+			case "context":
+				var parsed []*onebot.Element
+				for _, element := range module.Elements {
+					if element.Type == "image" {
+						parsed = append(parsed, image(element.Src))
+					} else {
+						parsed = append(parsed, parseText(&Text{Type: element.Type, Content: element.Content})...)
+					}
+				}
+				line(parsed)
+			case "container", "image-group":
+				for _, element := range module.Elements {
+					elements = append(elements, image(element.Src))
+				}
+			case "file":
+				elements = append(elements, &onebot.Element{ElementType: onebot.FileType, Data: &onebot.File{File: module.Src, Url: module.Src, Name: module.Title}})
+			case "video":
+				elements = append(elements, &onebot.Element{ElementType: onebot.VideoType, Data: &onebot.Video{File: module.Src, Url: module.Src}})
+			case "audio":
+				elements = append(elements, &onebot.Element{ElementType: onebot.RecordType, Data: &onebot.Record{File: module.Src, Url: module.Src}})
+			}
+		}
+	}
+	return merge(elements), nil
+}
+
+// parseText 转换文本元素，plain-text 不做 KMarkdown 解析
+func parseText(text *Text) []*onebot.Element {
+	if text == nil {
+		return nil
+	}
+	switch text.Type {
+	case "kmarkdown":
+		return Parse(text.Content)
+	case "paragraph":
+		var elements []*onebot.Element
+		for i, field := range text.Fields {
+			if i > 0 {
+				elements = append(elements, &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: "\n"}})
+			}
+			elements = append(elements, parseText(field)...)
+		}
+		return elements
+	default:
+		if text.Content == "" {
+			return nil
+		}
+		return []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: text.Content}}}
+	}
+}
+
+func image(src string) *onebot.Element {
+	return &onebot.Element{ElementType: onebot.ImageType, Data: &onebot.Image{File: src, Url: src}}
+}
+
+// merge 合并相邻的文本消息段
+func merge(elements []*onebot.Element) []*onebot.Element {
+	var merged []*onebot.Element
+	for _, element := range elements {
+		if last := len(merged) - 1; last >= 0 && element.ElementType == onebot.TextType && merged[last].ElementType == onebot.TextType {
+			merged[last] = &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: merged[last].Field("text") + element.Field("text")}}
+			continue
+		}
+		merged = append(merged, element)
+	}
+	return merged
+}
+
+// BuildCard 将消息段转换为卡片消息，文本等转换为 KMarkdown 段落，连续的图片放入图片容器
+//
+// 媒体消息段的 url（或 file）需要是已上传到 Kook 的地址。
+func BuildCard(elements []*onebot.Element) (string, error) {
+	card := &Card{Type: "card", Theme: "secondary", Size: "lg"}
+	var inline []*onebot.Element
+	flush := func() {
+		if content := Build(inline); strings.TrimSpace(content) != "" {
+			card.Modules = append(card.Modules, &Module{Type: "section", Text: &Text{Type: "kmarkdown", Content: content}})
+		}
+		inline = nil
+	}
+
+	for _, element := range elements {
+		if Inline(element.ElementType) {
+			inline = append(inline, element)
+			continue
+		}
+		src := element.Field("url")
+		if src == "" {
+			src = element.Field("file")
+		}
+		switch element.ElementType {
+		case onebot.ImageType:
+			flush()
+			last := len(card.Modules) - 1
+			if last < 0 || card.Modules[last].Type != "container" || len(card.Modules[last].Elements) >= maxContainerImages {
+				card.Modules = append(card.Modules, &Module{Type: "container"})
+				last++
+			}
+			card.Modules[last].Elements = append(card.Modules[last].Elements, &Element{Type: "image", Src: src})
+		case onebot.VideoType, onebot.RecordType, onebot.FileType:
+			flush()
+			moduleType := map[onebot.ElementType]string{onebot.VideoType: "video", onebot.RecordType: "audio", onebot.FileType: "file"}[element.ElementType]
+			title := element.Field("name")
+			if title == "" {
+				title = moduleType
+			}
+			card.Modules = append(card.Modules, &Module{Type: moduleType, Src: src, Title: title})
+		}
+	}
+	flush()
+
+	if len(card.Modules) == 0 {
+		return "", errors.New("消息内容为空")
+	}
+	data, err := json.Marshal([]*Card{card})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
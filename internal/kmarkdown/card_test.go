@@ -0,0 +1,86 @@
+package kmarkdown
+
+import (
+	"GoQHttp/internal/onebot"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestParseCard(t *testing.T) {
+	content := `[{"type":"card","modules":[
+		{"type":"header","text":{"type":"plain-text","content":"**标题**"}},
+		{"type":"section","text":{"type":"kmarkdown","content":"(met)123(met) **正文**"},"accessory":{"type":"image","src":"https://img/1.png"}},
+		{"type":"section","text":{"type":"paragraph","fields":[{"type":"kmarkdown","content":"左"},{"type":"plain-text","content":"右"}]}},
+		{"type":"context","elements":[{"type":"plain-text","content":"注释"},{"type":"image","src":"https://img/2.png"}]},
+		{"type":"container","elements":[{"type":"image","src":"https://img/3.png"},{"type":"image","src":"https://img/4.png"}]},
+		{"type":"action-group","elements":[{"type":"button","text":{"type":"plain-text","content":"按钮"}}]},
+		{"type":"file","src":"https://file/a.zip","title":"a.zip"},
+		{"type":"video","src":"https://file/b.mp4"},
+		{"type":"audio","src":"https://file/c.mp3"}
+	]}]`
+	elements, err := ParseCard(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// plain-text 不解析 KMarkdown，相邻文本合并，按钮忽略
+	expected := "text:**标题**\n|at:123|text: 正文|image:https://img/1.png|text:\n左\n右\n注释|image:https://img/2.png|" +
+		"image:https://img/3.png|image:https://img/4.png|file:https://file/a.zip/a.zip|video:https://file/b.mp4|record:https://file/c.mp3"
+	if parsed := summary(elements); parsed != expected {
+		t.Fatalf("解析得到 %q, 期望 %q", parsed, expected)
+	}
+
+	if _, err = ParseCard("not json"); err == nil {
+		t.Fatal("无法解析的卡片应返回错误")
+	}
+}
+
+func TestBuildCard(t *testing.T) {
+	var elements []*onebot.Element
+	elements = append(elements, text("看图 "), at("123"))
+	for i := 0; i < maxContainerImages+1; i++ {
+		elements = append(elements, image(fmt.Sprintf("https://img/%d.png", i)))
+	}
+	elements = append(elements,
+		text(" "),
+		&onebot.Element{ElementType: onebot.FileType, Data: &onebot.File{File: "https://file/a.zip", Name: "a.zip"}},
+		&onebot.Element{ElementType: onebot.VideoType, Data: &onebot.Video{File: "https://file/b.mp4"}},
+		text("结尾"),
+	)
+	content, err := BuildCard(elements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cards []*Card
+	if err = json.Unmarshal([]byte(content), &cards); err != nil || len(cards) != 1 {
+		t.Fatalf("卡片 %s err=%v", content, err)
+	}
+	// 图片超过容器上限时放入新的容器，只有空白的文本不生成模块
+	var modules []string
+	for _, module := range cards[0].Modules {
+		modules = append(modules, fmt.Sprintf("%s:%d", module.Type, len(module.Elements)))
+	}
+	if fmt.Sprint(modules) != fmt.Sprint([]string{"section:0", "container:9", "container:1", "file:0", "video:0", "section:0"}) {
+		t.Fatalf("卡片模块 %v", modules)
+	}
+	if title := cards[0].Modules[4].Title; title != "video" {
+		t.Fatalf("未设置名称时标题 %q", title)
+	}
+
+	// 卡片解析后内容保持不变，之后的段落另起一行
+	parsed, err := ParseCard(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := summary(append(append([]*onebot.Element{text("看图 "), at("123")}, elements[2:2+maxContainerImages+1]...),
+		&onebot.Element{ElementType: onebot.FileType, Data: &onebot.File{Url: "https://file/a.zip", Name: "a.zip"}},
+		&onebot.Element{ElementType: onebot.VideoType, Data: &onebot.Video{Url: "https://file/b.mp4"}},
+		text("\n结尾")))
+	if result := summary(parsed); result != expected {
+		t.Fatalf("解析得到 %q, 期望 %q", result, expected)
+	}
+
+	if _, err = BuildCard([]*onebot.Element{text("  ")}); err == nil {
+		t.Fatal("空消息应返回错误")
+	}
+}
@@ -0,0 +1,196 @@
+// Package kmarkdown 实现 Kook KMarkdown、卡片消息与 OneBot 消息段之间的转换
+package kmarkdown
+
+import (
+	"GoQHttp/internal/onebot"
+	"regexp"
+	"strings"
+)
+
+// escaper KMarkdown 中需要转义的字符
+var escaper = strings.NewReplacer(
+	`\`, `\\`, `*`, `\*`, `~`, `\~`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`,
+	`>`, `\>`, `-`, `\-`, `_`, `\_`, "`", "\\`",
+)
+
+var (
+	// tagRegex 提及、表情及仅影响样式的成对标记
+	tagRegex = regexp.MustCompile(`^\((met|rol|chn)\)([^()\s]+)\((?:met|rol|chn)\)|^\(emj\)([^()]*)\(emj\)\[([^\[\]]+)\]|^\((?:spl|ins)\)|^\(font\)(?:\[\w+\])?`)
+	// linkRegex 超链接 [文本](地址)
+	linkRegex = regexp.MustCompile(`^\[((?:\\.|[^\]\\])*)\]\(([^()\s]+)\)`)
+)
+
+// RolePrefix at 消息段中表示角色提及的前缀，如 role:123 对应 (rol)123(rol)
+const RolePrefix = "role:"
+
+// Escape 转义文本中的 KMarkdown 特殊字符
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// Inline 消息段是否可以直接写入 KMarkdown
+func Inline(elementType onebot.ElementType) bool {
+	switch elementType {
+	case onebot.TextType, onebot.AtType, onebot.FaceType, onebot.ChannelType:
+		return true
+	}
+	return false
+}
+
+// Build 将文本、@、表情及频道提及转换为 KMarkdown，其余消息段忽略
+func Build(elements []*onebot.Element) string {
+	var builder strings.Builder
+	for _, element := range elements {
+		switch element.ElementType {
+		case onebot.TextType:
+			builder.WriteString(Escape(element.Field("text")))
+		case onebot.AtType:
+			target := element.Field("qq")
+			if role, ok := strings.CutPrefix(target, RolePrefix); ok {
+				builder.WriteString("(rol)" + role + "(rol)")
+			} else {
+				builder.WriteString("(met)" + target + "(met)")
+			}
+		case onebot.FaceType:
+			// 只有 Kook 服务器表情(服务器id/表情id)可以发送
+			id := element.Field("id")
+			if !strings.Contains(id, "/") {
+				continue
+			}
+			name := element.Field("name")
+			if name == "" {
+				name = "emoji"
+			}
+			builder.WriteString("(emj)" + name + "(emj)[" + id + "]")
+		case onebot.ChannelType:
+			builder.WriteString("(chn)" + element.Field("id") + "(chn)")
+		}
+	}
+	return builder.String()
+}
+
+// Parse 将 KMarkdown 转换为消息段
+//
+// 提及转换为 at（here 视为 all，角色带 role: 前缀），服务器表情转换为 face，频道提及转换为 channel；
+// 加粗、删除线、剧透等样式标记被去除，代码块内容原样保留，转义字符还原为原文。
+func Parse(content string) []*onebot.Element {
+	p := &parser{}
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		switch c := content[i]; {
+		case c == '\\' && i+1 < len(content) && isPunct(content[i+1]):
+			p.text.WriteByte(content[i+1])
+			i += 2
+		case strings.HasPrefix(rest, "```"):
+			end := strings.Index(rest[3:], "```")
+			if end < 0 {
+				p.text.WriteString(rest)
+				i = len(content)
+				continue
+			}
+			p.text.WriteString(codeBlock(rest[3 : 3+end]))
+			i += end + 6
+		case c == '`':
+			end := strings.IndexByte(rest[1:], '`')
+			if end < 0 {
+				p.text.WriteByte(c)
+				i++
+				continue
+			}
+			p.text.WriteString(rest[1 : 1+end])
+			i += end + 2
+		case strings.HasPrefix(rest, "**"), strings.HasPrefix(rest, "~~"):
+			i += 2
+		case c == '*':
+			i++
+		case c == '(':
+			match := tagRegex.FindStringSubmatch(rest)
+			if match == nil {
+				p.text.WriteByte(c)
+				i++
+				continue
+			}
+			p.tag(match)
+			i += len(match[0])
+		case c == '[':
+			match := linkRegex.FindStringSubmatch(rest)
+			if match == nil {
+				p.text.WriteByte(c)
+				i++
+				continue
+			}
+			// 链接文本可能包含样式与转义，只保留文字
+			text := plain(Parse(match[1]))
+			if text == match[2] {
+				p.text.WriteString(text)
+			} else {
+				p.text.WriteString(text + "(" + match[2] + ")")
+			}
+			i += len(match[0])
+		default:
+			p.text.WriteByte(c)
+			i++
+		}
+	}
+	p.flush()
+	return p.elements
+}
+
+// parser 按顺序收集消息段，相邻文本合并
+type parser struct {
+	elements []*onebot.Element
+	text     strings.Builder
+}
+
+func (p *parser) flush() {
+	if p.text.Len() > 0 {
+		p.elements = append(p.elements, &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: p.text.String()}})
+		p.text.Reset()
+	}
+}
+
+func (p *parser) append(element *onebot.Element) {
+	p.flush()
+	p.elements = append(p.elements, element)
+}
+
+// tag 处理 tagRegex 匹配到的标记
+func (p *parser) tag(match []string) {
+	switch {
+	case match[1] == "met":
+		target := match[2]
+		if target == "here" {
+			target = "all"
+		}
+		p.append(&onebot.Element{ElementType: onebot.AtType, Data: &onebot.At{Uid: target}})
+	case match[1] == "rol":
+		p.append(&onebot.Element{ElementType: onebot.AtType, Data: &onebot.At{Uid: RolePrefix + match[2]}})
+	case match[1] == "chn":
+		p.append(&onebot.Element{ElementType: onebot.ChannelType, Data: &onebot.Channel{Id: match[2]}})
+	case match[4] != "":
+		p.append(&onebot.Element{ElementType: onebot.FaceType, Data: &onebot.Face{Id: match[4], Name: match[3]}})
+	}
+}
+
+// codeBlock 去除代码块首行的语言标识
+func codeBlock(block string) string {
+	if index := strings.IndexByte(block, '\n'); index >= 0 && !strings.ContainsAny(block[:index], " \t") {
+		block = block[index+1:]
+	}
+	return strings.TrimSuffix(block, "\n")
+}
+
+// plain 获取消息段中的文字内容
+func plain(elements []*onebot.Element) string {
+	var builder strings.Builder
+	for _, element := range elements {
+		if element.ElementType == onebot.TextType {
+			builder.WriteString(element.Field("text"))
+		}
+	}
+	return builder.String()
+}
+
+func isPunct(c byte) bool {
+	return c < 0x80 && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
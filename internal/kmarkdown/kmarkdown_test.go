@@ -0,0 +1,95 @@
+package kmarkdown
+
+import (
+	"GoQHttp/internal/onebot"
+	"strings"
+	"testing"
+)
+
+// summary 将消息段转换为 类型:主要字段 的形式便于比较
+func summary(elements []*onebot.Element) string {
+	var parts []string
+	for _, element := range elements {
+		value := element.Field("text")
+		switch element.ElementType {
+		case onebot.AtType:
+			value = element.Field("qq")
+		case onebot.FaceType:
+			value = element.Field("id") + "/" + element.Field("name")
+		case onebot.ChannelType:
+			value = element.Field("id")
+		case onebot.ImageType, onebot.VideoType, onebot.RecordType:
+			value = element.Field("url")
+		case onebot.FileType:
+			value = element.Field("url") + "/" + element.Field("name")
+		}
+		parts = append(parts, string(element.ElementType)+":"+value)
+	}
+	return strings.Join(parts, "|")
+}
+
+func text(content string) *onebot.Element {
+	return &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: content}}
+}
+
+func at(target string) *onebot.Element {
+	return &onebot.Element{ElementType: onebot.AtType, Data: &onebot.At{Uid: target}}
+}
+
+func TestEscape(t *testing.T) {
+	for _, c := range []struct{ text, expected string }{
+		{"plain 文本", "plain 文本"},
+		{`**bold** ~~del~~`, `\*\*bold\*\* \~\~del\~\~`},
+		{"[link](url)", `\[link\]\(url\)`},
+		{`a\b`, `a\\b`},
+		{"> - _ `", "\\> \\- \\_ \\`"},
+	} {
+		if escaped := Escape(c.text); escaped != c.expected {
+			t.Fatalf("转义 %q 得到 %q, 期望 %q", c.text, escaped, c.expected)
+		}
+		// 转义后解析还原为原文
+		if parsed := summary(Parse(Escape(c.text))); parsed != "text:"+c.text {
+			t.Fatalf("还原 %q 得到 %q", c.text, parsed)
+		}
+	}
+}
+
+func TestBuildParseRoundTrip(t *testing.T) {
+	elements := []*onebot.Element{
+		text("你好 *世界* "),
+		at("123"),
+		text(" "),
+		at(RolePrefix + "45"),
+		{ElementType: onebot.FaceType, Data: &onebot.Face{Id: "1/abc", Name: "smile"}},
+		{ElementType: onebot.ChannelType, Data: &onebot.Channel{Id: "678"}},
+		text("(met)fake(met)"),
+	}
+	content := Build(elements)
+	if expected := `你好 \*世界\* (met)123(met) (rol)45(rol)(emj)smile(emj)[1/abc](chn)678(chn)\(met\)fake\(met\)`; content != expected {
+		t.Fatalf("生成 %q, 期望 %q", content, expected)
+	}
+	if parsed, expected := summary(Parse(content)), summary(elements); parsed != expected {
+		t.Fatalf("解析得到 %q, 期望 %q", parsed, expected)
+	}
+
+	// 非服务器表情及其他消息段不写入
+	if content = Build([]*onebot.Element{{ElementType: onebot.FaceType, Data: &onebot.Face{Id: "14"}}, image("https://example.com/1.jpg")}); content != "" {
+		t.Fatalf("生成 %q, 期望为空", content)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct{ content, expected string }{
+		{"**加粗** ~~删除~~ *斜体*", "text:加粗 删除 斜体"},
+		{"(met)here(met)", "at:all"},
+		{"(spl)剧透(spl)(ins)下划线(ins)(font)[red]文字", "text:剧透下划线文字"},
+		{"[官网](https://kookapp.cn)", "text:官网(https://kookapp.cn)"},
+		{"[https://kookapp.cn](https://kookapp.cn)", "text:https://kookapp.cn"},
+		{"`*code*` ```go\nfmt.Println()\n```", "text:*code* fmt.Println()"},
+		{"未闭合 ` 与 ( 与 [", "text:未闭合 ` 与 ( 与 ["},
+	} {
+		if parsed := summary(Parse(c.content)); parsed != c.expected {
+			t.Fatalf("解析 %q 得到 %q, 期望 %q", c.content, parsed, c.expected)
+		}
+	}
+}
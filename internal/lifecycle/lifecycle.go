@@ -0,0 +1,339 @@
+package lifecycle
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/profile"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 数据删除
+//
+// 机器人被移出群或被删除好友时按策略记录删除任务，等待期结束后删除或匿名化消息记录、别名、资料缓存与id映射，
+// 期间重新添加机器人时取消任务。管理员可以立即删除指定对象的数据，全部任务执行后保留为审计记录。
+
+// 删除数据的对象
+const (
+	GroupSubject = "group"
+	UserSubject  = "user"
+)
+
+// 数据处理策略
+const (
+	DeletePolicy    = "delete"
+	AnonymizePolicy = "anonymize"
+	KeepPolicy      = "keep"
+)
+
+// checkInterval 检查到期任务的间隔
+const checkInterval = time.Minute
+
+// Eraser 删除平台自身保存的对象数据，返回删除的记录数量
+type Eraser func(subject string, namespace idmap.Namespace, subjectId int64) (int64, error)
+
+// Result 任务的处理结果
+type Result struct {
+	// Messages 删除或匿名化的消息记录数量
+	Messages int64 `json:"messages"`
+	Aliases  int64 `json:"aliases"`
+	// Mappings 删除的id映射数量
+	Mappings int64 `json:"mappings"`
+	// Platform 平台自身保存的记录数量
+	Platform int64  `json:"platform"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Request 立即删除数据的请求
+type Request struct {
+	Platform  string `json:"platform"`
+	SelfId    int64  `json:"self_id"`
+	Subject   string `json:"subject"`
+	SubjectId int64  `json:"subject_id"`
+	// Namespace 对象id的映射命名空间，对象有多个命名空间时不能为空
+	Namespace string `json:"namespace"`
+	Policy    string `json:"policy"`
+	Reason    string `json:"reason"`
+}
+
+var (
+	settings  atomic.Pointer[config.DataLifecycle]
+	erasers   = make(map[string]Eraser)
+	erasersMu sync.RWMutex
+	// namespaces 平台对象的id映射命名空间，键为 平台:对象
+	namespaces = make(map[string][]idmap.Namespace)
+	// executeMu 同一时间只执行一个任务，避免与取消任务交错
+	executeMu sync.Mutex
+	once      sync.Once
+)
+
+// RegisterEraser 注册平台删除自身数据的方式，平台在 init 中注册
+func RegisterEraser(platform string, eraser Eraser) {
+	erasersMu.Lock()
+	defer erasersMu.Unlock()
+	erasers[platform] = eraser
+}
+
+// RegisterNamespace 注册平台对象的id映射命名空间，对象的id不在 平台:user 或 平台:group 命名空间时在 init 中注册；
+// 同一对象有多个命名空间时依次注册，第一个用于机器人被移除时记录的任务，管理员删除数据时需要指定
+func RegisterNamespace(platform string, subject string, namespace idmap.Namespace) {
+	erasersMu.Lock()
+	defer erasersMu.Unlock()
+	key := platform + ":" + subject
+	namespaces[key] = append(namespaces[key], namespace)
+}
+
+// namespacesOf 获取对象的全部id映射命名空间
+func namespacesOf(platform string, subject string) []idmap.Namespace {
+	erasersMu.RLock()
+	registered, ok := namespaces[platform+":"+subject]
+	erasersMu.RUnlock()
+	if ok {
+		return registered
+	}
+	if subject == GroupSubject {
+		return []idmap.Namespace{idmap.NewNamespace(platform, idmap.Group)}
+	}
+	return []idmap.Namespace{idmap.NewNamespace(platform, idmap.User)}
+}
+
+// namespaceOf 按名称获取对象的id映射命名空间，名称为空时对象只能有一个命名空间
+func namespaceOf(platform string, subject string, name string) (idmap.Namespace, error) {
+	candidates := namespacesOf(platform, subject)
+	if name == "" {
+		if len(candidates) > 1 {
+			names := make([]string, 0, len(candidates))
+			for _, candidate := range candidates {
+				names = append(names, candidate.Name)
+			}
+			return idmap.Namespace{}, fmt.Errorf("%s 的 %s id有多个命名空间, 需要指定: %s", platform, subject, strings.Join(names, ", "))
+		}
+		return candidates[0], nil
+	}
+	for _, candidate := range candidates {
+		if candidate.Name == name {
+			return candidate, nil
+		}
+	}
+	return idmap.Namespace{}, fmt.Errorf("%s 的 %s id不支持命名空间: %s", platform, subject, name)
+}
+
+// Load 加载配置，首次调用时开始执行到期的任务
+func Load(cfg config.DataLifecycle) {
+	switch cfg.Policy {
+	case DeletePolicy, AnonymizePolicy, KeepPolicy:
+	default:
+		logger.Warnf("不支持的数据处理策略 %s, 使用 %s", cfg.Policy, DeletePolicy)
+		cfg.Policy = DeletePolicy
+	}
+	if cfg.GracePeriod < 0 {
+		cfg.GracePeriod = 0
+	}
+	settings.Store(&cfg)
+	once.Do(func() {
+		go run()
+	})
+}
+
+// Removed 机器人被移出群或被删除好友，按策略记录删除任务，等待期为 0 时立即执行
+func Removed(identity protocol.Identity, subject string, subjectId int64, reason string) {
+	cfg := settings.Load()
+	if cfg == nil || cfg.Policy == KeepPolicy {
+		logger.Infof("%s %d 已移除机器人, 按策略保留数据", subject, subjectId)
+		return
+	}
+	now := time.Now()
+	erasure := &utils.DataErasure{
+		Platform:  identity.Platform,
+		SelfId:    identity.SelfId,
+		Subject:   subject,
+		SubjectId: subjectId,
+		Namespace: namespacesOf(identity.Platform, subject)[0].Name,
+		Reason:    reason,
+		Policy:    cfg.Policy,
+		Status:    utils.ErasurePending,
+		CreatedAt: now.Unix(),
+		DueAt:     now.Add(time.Duration(cfg.GracePeriod) * time.Hour).Unix(),
+	}
+	id, err := utils.Store.DataErasureInsert(erasure)
+	if err != nil {
+		logger.Errorf("%s %d 数据删除任务记录失败: %v", subject, subjectId, err)
+		return
+	}
+	erasure.ID = id
+	if cfg.GracePeriod == 0 {
+		process(erasure)
+		return
+	}
+	logger.Infof("%s %d 已移除机器人, 数据将在 %d 小时后按 %s 策略处理", subject, subjectId, cfg.GracePeriod, cfg.Policy)
+}
+
+// Restored 机器人被重新添加，取消等待中的删除任务
+func Restored(identity protocol.Identity, subject string, subjectId int64) {
+	executeMu.Lock()
+	defer executeMu.Unlock()
+	cancelled, err := utils.Store.DataErasureCancel(identity.Platform, identity.SelfId, subject, subjectId)
+	if err != nil {
+		logger.Warnf("%s %d 数据删除任务取消失败: %v", subject, subjectId, err)
+		return
+	}
+	if cancelled > 0 {
+		logger.Infof("%s %d 已重新添加机器人, 取消数据删除任务", subject, subjectId)
+	}
+}
+
+// Erase 立即删除对象的数据并记录，selfId 为 0 时删除平台全部机器人的数据
+func Erase(request *Request) (*Record, error) {
+	if request.Platform == "" || request.SubjectId == 0 {
+		return nil, errors.New("platform 与对象id不能为空")
+	}
+	if request.Subject != GroupSubject && request.Subject != UserSubject {
+		return nil, fmt.Errorf("不支持的对象类型: %s", request.Subject)
+	}
+	if request.Policy == "" {
+		request.Policy = DeletePolicy
+	}
+	if request.Policy != DeletePolicy && request.Policy != AnonymizePolicy {
+		return nil, fmt.Errorf("不支持的数据处理策略: %s", request.Policy)
+	}
+	namespace, err := namespaceOf(request.Platform, request.Subject, request.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	erasure := &utils.DataErasure{
+		Platform:  request.Platform,
+		SelfId:    request.SelfId,
+		Subject:   request.Subject,
+		SubjectId: request.SubjectId,
+		Namespace: namespace.Name,
+		Reason:    request.Reason,
+		Policy:    request.Policy,
+		Status:    utils.ErasurePending,
+		CreatedAt: now,
+		DueAt:     now,
+	}
+	id, err := utils.Store.DataErasureInsert(erasure)
+	if err != nil {
+		return nil, err
+	}
+	erasure.ID = id
+	process(erasure)
+	return newRecord(erasure), nil
+}
+
+// run 定期执行到期的任务，启动时恢复上次运行中断的任务
+func run() {
+	executeMu.Lock()
+	requeued, err := utils.Store.DataErasureRequeue()
+	executeMu.Unlock()
+	if err != nil {
+		logger.Warnf("恢复中断的数据删除任务失败: %v", err)
+	} else if requeued > 0 {
+		logger.Infof("恢复 %d 个中断的数据删除任务", requeued)
+	}
+	for {
+		erasures, err := utils.Store.DataErasureDue(time.Now().Unix())
+		if err != nil {
+			logger.Warnf("获取数据删除任务失败: %v", err)
+		}
+		for i := range erasures {
+			process(&erasures[i])
+		}
+		time.Sleep(checkInterval)
+	}
+}
+
+// process 认领并执行任务后记录结果，任务已被执行或取消时跳过
+func process(erasure *utils.DataErasure) {
+	executeMu.Lock()
+	defer executeMu.Unlock()
+
+	// 定时执行与立即执行可能取到同一任务，认领成功才执行，避免再次执行时以空结果覆盖审计记录
+	claimed, err := utils.Store.DataErasureClaim(erasure.ID)
+	if err != nil {
+		logger.Errorf("数据删除任务 %d 认领失败: %v", erasure.ID, err)
+		return
+	}
+	if !claimed {
+		logger.Debugf("数据删除任务 %d 已被执行或取消", erasure.ID)
+		return
+	}
+
+	result, err := execute(erasure)
+	erasure.Status = utils.ErasureDone
+	if err != nil {
+		result.Error = err.Error()
+		erasure.Status = utils.ErasureFailed
+		logger.Errorf("%s %d 数据处理失败: %v", erasure.Subject, erasure.SubjectId, err)
+	} else {
+		logger.Infof("%s %d 数据已按 %s 策略处理: 消息 %d, 别名 %d, 映射 %d, 平台记录 %d", erasure.Subject, erasure.SubjectId,
+			erasure.Policy, result.Messages, result.Aliases, result.Mappings, result.Platform)
+	}
+	data, _ := json.Marshal(result)
+	erasure.Result = string(data)
+	erasure.DoneAt = time.Now().Unix()
+	if err = utils.Store.DataErasureFinish(erasure.ID, erasure.Status, erasure.Result); err != nil {
+		logger.Errorf("数据删除任务 %d 结果记录失败: %v", erasure.ID, err)
+	}
+}
+
+// execute 删除或匿名化消息记录，删除别名、资料缓存、id映射与平台自身的记录
+func execute(erasure *utils.DataErasure) (*Result, error) {
+	result := &Result{}
+	query := &utils.EraseQuery{
+		Platform:  erasure.Platform,
+		SelfId:    erasure.SelfId,
+		Anonymize: erasure.Policy == AnonymizePolicy,
+	}
+	// 旧版本记录的任务没有命名空间，使用对象的第一个命名空间
+	namespace := namespacesOf(erasure.Platform, erasure.Subject)[0]
+	if erasure.Namespace != "" {
+		var err error
+		if namespace, err = namespaceOf(erasure.Platform, erasure.Subject, erasure.Namespace); err != nil {
+			return result, err
+		}
+	}
+	if erasure.Subject == GroupSubject {
+		query.GroupId = int32(erasure.SubjectId)
+	} else {
+		query.UserId = erasure.SubjectId
+		query.UserNamespace = namespace.Name
+	}
+
+	var err error
+	if result.Messages, err = utils.Store.MessageHistoryErase(query); err != nil {
+		return result, fmt.Errorf("消息记录处理失败: %v", err)
+	}
+	if result.Aliases, err = utils.Store.UserAliasErase(query); err != nil {
+		return result, fmt.Errorf("别名删除失败: %v", err)
+	}
+	profile.Forget(erasure.Platform, query.GroupId, query.UserId)
+
+	erasersMu.RLock()
+	eraser, ok := erasers[erasure.Platform]
+	erasersMu.RUnlock()
+	if ok {
+		if result.Platform, err = eraser(erasure.Subject, namespace, erasure.SubjectId); err != nil {
+			return result, fmt.Errorf("平台记录删除失败: %v", err)
+		}
+	}
+
+	// 最后删除映射，其他数据处理失败时仍可通过数字id再次删除
+	erased, err := idmap.Erase(namespace, erasure.SubjectId)
+	if err != nil {
+		return result, fmt.Errorf("id映射删除失败: %v", err)
+	}
+	if erased {
+		result.Mappings = 1
+	}
+	return result, nil
+}
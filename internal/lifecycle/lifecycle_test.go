@@ -0,0 +1,103 @@
+package lifecycle
+
+import (
+	"GoQHttp/internal/idmap"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+// insertErasure 记录到期的删除任务
+func insertErasure(t *testing.T, platform string, subjectId int64) *utils.DataErasure {
+	t.Helper()
+	erasure := &utils.DataErasure{
+		Platform:  platform,
+		Subject:   UserSubject,
+		SubjectId: subjectId,
+		Policy:    DeletePolicy,
+		Status:    utils.ErasurePending,
+		CreatedAt: time.Now().Unix(),
+		DueAt:     time.Now().Unix(),
+	}
+	id, err := utils.Store.DataErasureInsert(erasure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	erasure.ID = id
+	return erasure
+}
+
+// latest 获取最新的审计记录
+func latest(t *testing.T) *Record {
+	t.Helper()
+	records, _, err := List(0, 1)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("审计记录 %v err=%v", records, err)
+	}
+	return records[0]
+}
+
+func TestProcessClaimsOnce(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	var calls int
+	RegisterEraser("test-claim", func(subject string, namespace idmap.Namespace, subjectId int64) (int64, error) {
+		calls++
+		return 2, nil
+	})
+	erasure := insertErasure(t, "test-claim", 7)
+	// 定时执行与立即执行取到同一任务
+	again := *erasure
+	process(erasure)
+	process(&again)
+	if calls != 1 {
+		t.Fatalf("执行 %d 次, 期望 1", calls)
+	}
+	if record := latest(t); record.Status != utils.ErasureDone || record.Result == nil || record.Result.Platform != 2 {
+		t.Fatalf("审计记录 %+v, 结果 %+v", record, record.Result)
+	}
+}
+
+func TestProcessSkipsCancelled(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	erasure := insertErasure(t, "test-cancel", 7)
+	if _, err := utils.Store.DataErasureCancel("test-cancel", 0, UserSubject, 7); err != nil {
+		t.Fatal(err)
+	}
+	// 取到任务后被取消的任务不再执行
+	process(erasure)
+	if record := latest(t); record.Status != utils.ErasureCancelled || record.Result != nil {
+		t.Fatalf("审计记录 %+v", record)
+	}
+}
+
+func TestEraseNamespace(t *testing.T) {
+	utils.Store = utils.NewMemoryStorage()
+	c2c := idmap.NewNamespace("test-namespace:c2c", idmap.User)
+	member := idmap.NewNamespace("test-namespace", idmap.User)
+	RegisterNamespace("test-namespace", UserSubject, c2c)
+	RegisterNamespace("test-namespace", UserSubject, member)
+
+	// 有多个命名空间时必须指定
+	if _, err := Erase(&Request{Platform: "test-namespace", Subject: UserSubject, SubjectId: 7}); err == nil {
+		t.Fatal("未指定命名空间时应返回错误")
+	}
+	if _, err := Erase(&Request{Platform: "test-namespace", Subject: UserSubject, SubjectId: 7, Namespace: "other:user"}); err == nil {
+		t.Fatal("未注册的命名空间应返回错误")
+	}
+	record, err := Erase(&Request{Platform: "test-namespace", Subject: UserSubject, SubjectId: 7, Namespace: member.Name})
+	if err != nil || record.Namespace != member.Name || record.Status != utils.ErasureDone {
+		t.Fatalf("审计记录 %+v err=%v", record, err)
+	}
+	// 只有一个命名空间的对象使用默认值
+	record, err = Erase(&Request{Platform: "test-namespace", Subject: GroupSubject, SubjectId: 7})
+	if err != nil || record.Namespace != "test-namespace:group" {
+		t.Fatalf("审计记录 %+v err=%v", record, err)
+	}
+}
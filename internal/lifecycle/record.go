@@ -0,0 +1,75 @@
+package lifecycle
+
+import (
+	"GoQHttp/utils"
+	"encoding/json"
+)
+
+const (
+	defaultListCount = 20
+	maxListCount     = 100
+)
+
+// Record 数据删除任务的审计记录，时间为秒级时间戳
+type Record struct {
+	Id        int64   `json:"id"`
+	Platform  string  `json:"platform"`
+	SelfId    int64   `json:"self_id"`
+	Subject   string  `json:"subject"`
+	SubjectId int64   `json:"subject_id"`
+	Namespace string  `json:"namespace"`
+	Reason    string  `json:"reason"`
+	Policy    string  `json:"policy"`
+	Status    string  `json:"status"`
+	Result    *Result `json:"result,omitempty"`
+	CreatedAt int64   `json:"created_at"`
+	DueAt     int64   `json:"due_at"`
+	DoneAt    int64   `json:"done_at"`
+}
+
+// newRecord 将任务转换为审计记录
+func newRecord(erasure *utils.DataErasure) *Record {
+	record := &Record{
+		Id:        erasure.ID,
+		Platform:  erasure.Platform,
+		SelfId:    erasure.SelfId,
+		Subject:   erasure.Subject,
+		SubjectId: erasure.SubjectId,
+		Namespace: erasure.Namespace,
+		Reason:    erasure.Reason,
+		Policy:    erasure.Policy,
+		Status:    erasure.Status,
+		CreatedAt: erasure.CreatedAt,
+		DueAt:     erasure.DueAt,
+		DoneAt:    erasure.DoneAt,
+	}
+	if erasure.Result != "" {
+		record.Result = &Result{}
+		if err := json.Unmarshal([]byte(erasure.Result), record.Result); err != nil {
+			record.Result = &Result{Error: erasure.Result}
+		}
+	}
+	return record
+}
+
+// List 按时间倒序获取审计记录与总数
+func List(offset int, count int) ([]*Record, int, error) {
+	if count <= 0 {
+		count = defaultListCount
+	}
+	if count > maxListCount {
+		count = maxListCount
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	erasures, total, err := utils.Store.DataErasureList(offset, count)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]*Record, 0, len(erasures))
+	for i := range erasures {
+		records = append(records, newRecord(&erasures[i]))
+	}
+	return records, total, nil
+}
@@ -3,6 +3,7 @@ package onebot
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +14,8 @@ const (
 	NoticePost    PostType = "notice"
 	RequestPost   PostType = "request"
 	MetaEventPost PostType = "meta_event"
+	// MessageSentPost 机器人自身发送的消息
+	MessageSentPost PostType = "message_sent"
 )
 
 // MessageBase 定义消息结构
@@ -22,16 +25,31 @@ type MessageBase struct {
 	PostType PostType `json:"post_type,omitempty"`
 }
 
+// GetSelfId 事件所属机器人，用于按连接分发事件
+func (m MessageBase) GetSelfId() int64 {
+	return m.SelfId
+}
+
+// Event 推送给功能端的事件
+type Event interface {
+	GetSelfId() int64
+}
+
 type SubType string
 
 const (
-	Enable  SubType = "enable"
-	Disable SubType = "disable"
-	Connect SubType = "connect"
-	Friend  SubType = "friend"
-	Group   SubType = "group"
-	Other   SubType = "other"
-	Normal  SubType = "normal"
+	Enable         SubType = "enable"
+	Disable        SubType = "disable"
+	Connect        SubType = "connect"
+	Friend         SubType = "friend"
+	Group          SubType = "group"
+	Other          SubType = "other"
+	Normal         SubType = "normal"
+	ChannelSubType SubType = "channel"
+	Approve        SubType = "approve"
+	Leave          SubType = "leave"
+	Add            SubType = "add"
+	Remove         SubType = "remove"
 )
 
 type MetaEventType string
@@ -64,6 +82,8 @@ type MessageType string
 const (
 	PrivateMessage MessageType = "private"
 	GroupMessage   MessageType = "group"
+	// GuildMessage 频道消息，guild_id 与 channel_id 为频道与子频道
+	GuildMessage MessageType = "guild"
 )
 
 type Sex string
@@ -105,6 +125,8 @@ type MessageRequest struct {
 	MessageId       int32             `json:"message_id,omitempty"`
 	GroupId         int32             `json:"group_id"`
 	UserId          int64             `json:"user_id"`
+	GuildId         int64             `json:"guild_id,omitempty"` // 频道消息的频道，频道私信时为私信会话所在的频道
+	ChannelId       int64             `json:"channel_id,omitempty"`
 	Anonymous       *NoneBotAnonymous `json:"anonymous,omitempty"`
 	OriginalMessage []*Element        `json:"original_message,omitempty"`
 	Message         []*Element        `json:"message,omitempty"`
@@ -114,6 +136,31 @@ type MessageRequest struct {
 	AutoEscape      bool              `json:"auto_escape,omitempty"`
 }
 
+type NoticeType string
+
+const (
+	GroupIncreaseNotice NoticeType = "group_increase"
+	GroupDecreaseNotice NoticeType = "group_decrease"
+	ReactionNotice      NoticeType = "reaction"
+	// GuildMemberIncreaseNotice 频道成员增加，guild_id 为频道
+	GuildMemberIncreaseNotice NoticeType = "guild_member_increase"
+	GuildMemberDecreaseNotice NoticeType = "guild_member_decrease"
+)
+
+// NoticeRequest 通知事件，reaction 为表情回应，字段与 Lagrange.OneBot 保持一致
+type NoticeRequest struct {
+	MessageBase
+	NoticeType NoticeType `json:"notice_type"`
+	SubType    SubType    `json:"sub_type,omitempty"`
+	GroupId    int32      `json:"group_id,omitempty"`
+	GuildId    int64      `json:"guild_id,omitempty"`
+	UserId     int64      `json:"user_id,omitempty"`
+	OperatorId int64      `json:"operator_id,omitempty"`
+	MessageId  int32      `json:"message_id,omitempty"`
+	Code       string     `json:"code,omitempty"`
+	Count      int        `json:"count,omitempty"`
+}
+
 type ElementType string
 
 const (
@@ -137,6 +184,8 @@ const (
 	NodeType      ElementType = "node"
 	XmlType       ElementType = "xml"
 	JsonType      ElementType = "json"
+	FileType      ElementType = "file"
+	ChannelType   ElementType = "channel"
 )
 
 type Element struct {
@@ -147,6 +196,28 @@ type Element struct {
 type Message interface {
 }
 
+// Field 读取消息段 data 中的字段，兼容结构体、数组格式与CQ码解析得到的字符串参数
+func (e *Element) Field(key string) string {
+	marshal, err := json.Marshal(e.Data)
+	if err != nil {
+		return ""
+	}
+	var data map[string]any
+	if err = json.Unmarshal(marshal, &data); err != nil {
+		return ""
+	}
+	switch value := data[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
 //type Message struct {
 //	Text        string      `json:"text,omitempty"`
 //	Face        Face        `json:"face,omitempty"`
@@ -172,31 +243,39 @@ type Message interface {
 //	Json        Json        `json:"json,omitempty"`
 //}
 
-// CQEscape 转义CQ码中的特殊字符
-func CQEscape(s string) string {
-	// 注意转义顺序：先转义&，避免重复转义
-	replacements := map[string]string{
-		"&": "&amp;",
-		"[": "&#91;",
-		"]": "&#93;",
-		",": "&#44;",
+// CQString 生成CQ码格式的消息，用于 raw_message
+func CQString(messages []*Element) string {
+	var builder strings.Builder
+	for _, message := range messages {
+		switch data := message.Data.(type) {
+		case *Text:
+			builder.WriteString(CQEscape(data.Text))
+		case fmt.Stringer:
+			builder.WriteString(data.String())
+		}
 	}
+	return builder.String()
+}
 
-	// 按顺序替换，确保&最先被处理
-	result := s
-	for oldText, newText := range replacements {
-		result = strings.ReplaceAll(result, oldText, newText)
-	}
+// cqEscaper CQ码中需要转义的字符，单次替换避免&被重复转义
+var cqEscaper = strings.NewReplacer("&", "&amp;", "[", "&#91;", "]", "&#93;", ",", "&#44;")
 
-	return result
+// CQEscape 转义CQ码中的特殊字符
+func CQEscape(s string) string {
+	return cqEscaper.Replace(s)
 }
 
+// Face 表情，Name 为 Kook 服务器表情名
 type Face struct {
 	Message
-	Id string `json:"id,omitempty"`
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
 }
 
 func (f Face) String() string {
+	if f.Name != "" {
+		return fmt.Sprintf("[CQ:face,id=%s,name=%s]", CQEscape(f.Id), CQEscape(f.Name))
+	}
 	return fmt.Sprintf("[CQ:face,id=%s]", f.Id)
 }
 
@@ -236,7 +315,7 @@ func (f Record) String() string {
 	f.File = CQEscape(f.File)
 	f.Url = CQEscape(f.Url)
 
-	return fmt.Sprintf("[CQ:record,file=%s,magic=%s,url=%s,cache=%v,proxy=%v,timeout=%v]", f.File, f.Magic, f.Url, f.Cache, f.Proxy, f.TimeOut)
+	return fmt.Sprintf("[CQ:record,file=%s,magic=%d,url=%s,cache=%v,proxy=%v,timeout=%v]", f.File, f.Magic, f.Url, f.Cache, f.Proxy, f.TimeOut)
 }
 
 type Video struct {
@@ -432,6 +511,21 @@ func (f Xml) String() string {
 	return fmt.Sprintf("[CQ:xml,data=%s]", f.Data)
 }
 
+type File struct {
+	Message
+	File string `json:"file,omitempty"`
+	Url  string `json:"url,omitempty"`
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size,omitempty"`
+}
+
+func (f File) String() string {
+	f.File = CQEscape(f.File)
+	f.Url = CQEscape(f.Url)
+	f.Name = CQEscape(f.Name)
+	return fmt.Sprintf("[CQ:file,file=%s,url=%s,name=%s,size=%v]", f.File, f.Url, f.Name, f.Size)
+}
+
 type Json struct {
 	Message
 	Data string `json:"data,omitempty"`
@@ -440,3 +534,13 @@ type Json struct {
 func (f Json) String() string {
 	return fmt.Sprintf("[CQ:json,data=%s]", f.Data)
 }
+
+// Channel 频道提及，对应 Kook KMarkdown 中的 (chn)id(chn)
+type Channel struct {
+	Message
+	Id string `json:"id,omitempty"`
+}
+
+func (f Channel) String() string {
+	return fmt.Sprintf("[CQ:channel,id=%s]", CQEscape(f.Id))
+}
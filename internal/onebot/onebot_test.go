@@ -0,0 +1,23 @@
+package onebot
+
+import "testing"
+
+func TestElementField(t *testing.T) {
+	tests := []struct {
+		element *Element
+		key     string
+		want    string
+	}{
+		{&Element{ElementType: TextType, Data: &Text{Text: "hello"}}, "text", "hello"},
+		{&Element{ElementType: ImageType, Data: map[string]string{"file": "a.png"}}, "file", "a.png"},
+		{&Element{ElementType: "reply", Data: map[string]any{"id": float64(1234567890)}}, "id", "1234567890"},
+		{&Element{ElementType: "at", Data: map[string]any{"qq": true}}, "qq", "true"},
+		{&Element{ElementType: TextType, Data: &Text{}}, "text", ""},
+		{&Element{ElementType: TextType, Data: nil}, "text", ""},
+	}
+	for _, test := range tests {
+		if got := test.element.Field(test.key); got != test.want {
+			t.Errorf("%s 的 %s 为 %q, 期望 %q", test.element.ElementType, test.key, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package openapi
+
+import (
+	dto2 "GoQHttp/internal/protocol/tencent/dto"
+	"net/http"
+)
+
+// GetGatewayBot 获取带分片信息的 websocket 接入点
+func (o *OpenApi) GetGatewayBot() (*dto2.WebsocketAP, error) {
+	var ap *dto2.WebsocketAP
+	if err := o.doRequest(http.MethodGet, "/gateway/bot", nil, &ap); err != nil {
+		return nil, err
+	}
+	return ap, nil
+}
+
+// AuthToken 获取 websocket 鉴权使用的凭证，AccessToken 过期时先刷新
+func (o *OpenApi) AuthToken() (string, error) {
+	if err := o.ensureToken(); err != nil {
+		return "", err
+	}
+	return "QQBot " + o.token(), nil
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"GoQHttp/internal/idmap"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QQ 的 id 映射命名空间，原始id为 appid:原始id，不同机器人获得的 openid 不同
+var (
+	GroupNamespace = idmap.NewNamespace("qq", idmap.Group)
+	// UserNamespace 群成员，原始id为群成员的 member_openid
+	UserNamespace = idmap.NewNamespace("qq", idmap.User)
+	// C2CUserNamespace 单聊用户，原始id为 user_openid，与同一用户在群中的 member_openid 不同
+	C2CUserNamespace = idmap.NewNamespace("qq:c2c", idmap.User)
+	GuildNamespace   = idmap.NewNamespace("qq", idmap.Guild)
+	ChannelNamespace = idmap.NewNamespace("qq", idmap.Channel)
+	// GuildUserNamespace 频道用户，原始id为频道用户id
+	GuildUserNamespace = idmap.NewNamespace("qq:guild", idmap.User)
+	// MessageNamespace 频道与私信消息，群与单聊消息记录在 TencentGroupMessage
+	MessageNamespace = idmap.NewNamespace("qq", idmap.Message)
+)
+
+// GroupId 获取群 openid 对应的群号
+func GroupId(appId int, groupOpenId string) (int32, error) {
+	id, err := idmap.Id(GroupNamespace, rawId(appId, groupOpenId))
+	return int32(id), err
+}
+
+// GroupOpenId 获取群号对应的群 openid
+func GroupOpenId(appId int, group int32) (string, error) {
+	return RawOf(GroupNamespace, appId, int64(group))
+}
+
+// UserId 获取用户 openid 对应的数字id
+func UserId(appId int, userOpenId string) (int64, error) {
+	return idmap.Id(UserNamespace, rawId(appId, userOpenId))
+}
+
+// LookupGroupId 获取已映射的群号，群 openid 未映射时返回 idmap.ErrNotFound
+func LookupGroupId(appId int, groupOpenId string) (int32, error) {
+	id, err := idmap.Lookup(GroupNamespace, rawId(appId, groupOpenId))
+	return int32(id), err
+}
+
+// LookupUserId 获取已映射的用户数字id，用户 openid 未映射时返回 idmap.ErrNotFound
+func LookupUserId(appId int, userOpenId string) (int64, error) {
+	return idmap.Lookup(UserNamespace, rawId(appId, userOpenId))
+}
+
+// UserOpenId 获取数字id对应的用户 openid
+func UserOpenId(appId int, user int64) (string, error) {
+	return RawOf(UserNamespace, appId, user)
+}
+
+// C2CUserId 获取单聊用户 openid 对应的数字id
+func C2CUserId(appId int, userOpenId string) (int64, error) {
+	return IdOf(C2CUserNamespace, appId, userOpenId)
+}
+
+// LookupC2CUserId 获取已映射的单聊用户数字id，未映射时返回 idmap.ErrNotFound
+func LookupC2CUserId(appId int, userOpenId string) (int64, error) {
+	return idmap.Lookup(C2CUserNamespace, rawId(appId, userOpenId))
+}
+
+// C2CUserOpenId 获取数字id对应的单聊用户 openid
+func C2CUserOpenId(appId int, user int64) (string, error) {
+	return RawOf(C2CUserNamespace, appId, user)
+}
+
+// IdOf 获取命名空间中原始id对应的数字id，不存在时分配新的id
+func IdOf(namespace idmap.Namespace, appId int, raw string) (int64, error) {
+	return idmap.Id(namespace, rawId(appId, raw))
+}
+
+// RawOf 获取命名空间中数字id对应的原始id，映射属于其他机器人时视为不存在
+func RawOf(namespace idmap.Namespace, appId int, id int64) (string, error) {
+	raw, err := idmap.Raw(namespace, id)
+	if err != nil {
+		return "", err
+	}
+	owner, openId, _ := strings.Cut(raw, ":")
+	if owner != strconv.Itoa(appId) {
+		return "", fmt.Errorf("%w: %s %d 不属于机器人 %d", idmap.ErrNotFound, namespace.Name, id, appId)
+	}
+	return openId, nil
+}
+
+func rawId(appId int, openId string) string {
+	return strconv.Itoa(appId) + ":" + openId
+}
@@ -0,0 +1,397 @@
+// Package mock 提供本地 QQ 开放平台模拟服务，用于在没有网络的环境下对整个桥接流程进行集成测试。
+//
+// Server 模拟 bots.qq.com 与 api.sgroup.qq.com 的常用接口，会记录收到的全部请求，
+// 并支持按接口预置返回内容；WebhookEmitter 则按照官方回调规则签名并推送事件。
+package mock
+
+import (
+	"GoQHttp/internal/openapi"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request 记录的一次请求
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Decode 将请求体解析到 v
+func (r *Request) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Response 预置的返回内容
+type Response struct {
+	Status int
+	Body   any
+}
+
+// ErrorResponse 开放平台错误返回结构
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	ErrCode int    `json:"err_code,omitempty"`
+	TraceId string `json:"trace_id,omitempty"`
+}
+
+// Server 模拟的 QQ 开放平台
+type Server struct {
+	*httptest.Server
+	AccessToken string
+	ExpiresIn   int
+	// GatewayURL /gateway/bot 返回的 websocket 接入点
+	GatewayURL string
+	// Me /users/@me 返回的机器人信息
+	Me *dto.User
+
+	mu       sync.Mutex
+	requests []*Request
+	scripts  map[string][]*Response
+	guilds   map[string]*dto.Guild
+	channels map[string]*dto.Channel
+	// members 频道成员，频道id -> 用户id -> 成员
+	members map[string]map[string]*dto.Member
+	// groupMembers 群成员列表，群 openid -> 成员
+	groupMembers map[string][]*dto.GroupMember
+	seq          int64
+}
+
+// NewServer 创建并启动模拟服务
+func NewServer() *Server {
+	s := &Server{
+		AccessToken:  "mock-access-token",
+		ExpiresIn:    7200,
+		Me:           &dto.User{ID: "10000", Username: "mock-bot", Bot: true},
+		scripts:      make(map[string][]*Response),
+		guilds:       make(map[string]*dto.Guild),
+		channels:     make(map[string]*dto.Channel),
+		members:      make(map[string]map[string]*dto.Member),
+		groupMembers: make(map[string][]*dto.GroupMember),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Install 将 openapi 的请求地址指向模拟服务，返回用于恢复原地址的函数
+func (s *Server) Install() func() {
+	apiUrl, tokenUrl := openapi.ApiUrl, openapi.TokenUrl
+	openapi.ApiUrl = s.URL
+	openapi.TokenUrl = s.URL + "/app/getAppAccessToken"
+	return func() {
+		openapi.ApiUrl = apiUrl
+		openapi.TokenUrl = tokenUrl
+	}
+}
+
+// Script 为指定接口预置一次返回，多次调用按先进先出顺序消费；path 需与实际请求路径完全一致
+func (s *Server) Script(method string, path string, status int, body any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	s.scripts[key] = append(s.scripts[key], &Response{Status: status, Body: body})
+}
+
+// AddGuild 添加可被查询的频道
+func (s *Server) AddGuild(guild *dto.Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[guild.ID] = guild
+}
+
+// AddChannel 添加可被查询的子频道
+func (s *Server) AddChannel(channel *dto.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[channel.ID] = channel
+}
+
+// AddMember 添加可被查询的频道成员
+func (s *Server) AddMember(member *dto.Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.members[member.GuildID] == nil {
+		s.members[member.GuildID] = make(map[string]*dto.Member)
+	}
+	s.members[member.GuildID][member.User.ID] = member
+}
+
+// AddGroupMembers 添加群成员
+func (s *Server) AddGroupMembers(groupOpenId string, members ...*dto.GroupMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groupMembers[groupOpenId] = append(s.groupMembers[groupOpenId], members...)
+}
+
+// Requests 返回目前记录的全部请求
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]*Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// RequestsTo 返回指定方法且路径以 prefix 开头的请求
+func (s *Server) RequestsTo(method string, prefix string) []*Request {
+	var requests []*Request
+	for _, request := range s.Requests() {
+		if request.Method == method && strings.HasPrefix(request.Path, prefix) {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// Reset 清空请求记录与预置返回
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.scripts = make(map[string][]*Response)
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s-%d", prefix, s.seq)
+}
+
+func (s *Server) record(r *http.Request) *Request {
+	body, _ := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	request := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	s.mu.Unlock()
+	return request
+}
+
+func (s *Server) scripted(method string, path string) *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	queue := s.scripts[key]
+	if len(queue) == 0 {
+		return nil
+	}
+	s.scripts[key] = queue[1:]
+	return queue[0]
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	request := s.record(r)
+
+	if response := s.scripted(r.Method, r.URL.Path); response != nil {
+		writeJSON(w, response.Status, response.Body)
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Path == "/app/getAppAccessToken" {
+		s.handleAccessToken(w, request)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "QQBot "+s.AccessToken {
+		writeJSON(w, http.StatusUnauthorized, &ErrorResponse{Code: 11244, Message: "token not exist or expire"})
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	// v2 群聊与单聊
+	case match(segments, "v2", "groups", "*", "messages") && r.Method == http.MethodPost,
+		match(segments, "v2", "users", "*", "messages") && r.Method == http.MethodPost:
+		writeJSON(w, http.StatusOK, &dto.C2CMsgResp{
+			Id:        s.nextID("msg"),
+			Timestamp: dto.Timestamp(time.Now().Format(time.RFC3339)),
+		})
+	case match(segments, "v2", "groups", "*", "files") && r.Method == http.MethodPost,
+		match(segments, "v2", "users", "*", "files") && r.Method == http.MethodPost:
+		s.handleUpload(w, request)
+	case match(segments, "v2", "groups", "*", "messages", "*") && r.Method == http.MethodDelete,
+		match(segments, "v2", "users", "*", "messages", "*") && r.Method == http.MethodDelete,
+		match(segments, "channels", "*", "messages", "*") && r.Method == http.MethodDelete,
+		match(segments, "dms", "*", "messages", "*") && r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusOK)
+	// 频道
+	case match(segments, "channels", "*", "messages") && r.Method == http.MethodPost:
+		s.handleChannelMessage(w, request, segments[1])
+	case match(segments, "dms", "*", "messages") && r.Method == http.MethodPost:
+		var req dto.MessageToCreate
+		if err := request.Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, &ErrorResponse{Code: 50006, Message: "invalid request"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &dto.Message{
+			ID:            s.nextID("msg"),
+			GuildID:       segments[1],
+			Content:       req.Content,
+			Timestamp:     dto.Timestamp(time.Now().Format(time.RFC3339)),
+			DirectMessage: true,
+		})
+	case match(segments, "guilds", "*", "api_permission") && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &dto.APIPermissions{APIList: []*dto.APIPermission{}})
+	case match(segments, "guilds", "*", "api_permission", "demand") && r.Method == http.MethodPost:
+		var req dto.APIPermissionDemandToCreate
+		if err := request.Decode(&req); err != nil || req.APIIdentify == nil {
+			writeJSON(w, http.StatusBadRequest, &ErrorResponse{Code: 50006, Message: "invalid request"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &dto.APIPermissionDemand{
+			GuildID:     segments[1],
+			ChannelID:   req.ChannelID,
+			APIIdentify: req.APIIdentify,
+			Desc:        req.Desc,
+		})
+	case match(segments, "guilds", "*", "members", "*") && r.Method == http.MethodGet:
+		s.mu.Lock()
+		member, ok := s.members[segments[1]][segments[3]]
+		s.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, &ErrorResponse{Code: 50001, Message: "unknown member"})
+			return
+		}
+		writeJSON(w, http.StatusOK, member)
+	case match(segments, "v2", "groups", "*", "members") && r.Method == http.MethodGet:
+		s.handleGroupMembers(w, r, segments[2])
+	case match(segments, "guilds", "*") && r.Method == http.MethodGet:
+		s.mu.Lock()
+		guild, ok := s.guilds[segments[1]]
+		s.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, &ErrorResponse{Code: 10001, Message: "unknown guild"})
+			return
+		}
+		writeJSON(w, http.StatusOK, guild)
+	case match(segments, "users", "@me") && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Me)
+	case match(segments, "gateway", "bot") && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &dto.WebsocketAP{
+			URL:               s.GatewayURL,
+			Shards:            1,
+			SessionStartLimit: dto.SessionStartLimit{Total: 1000, Remaining: 1000, MaxConcurrency: 1},
+		})
+	case match(segments, "channels", "*") && r.Method == http.MethodGet:
+		s.mu.Lock()
+		channel, ok := s.channels[segments[1]]
+		s.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, &ErrorResponse{Code: 10003, Message: "unknown channel"})
+			return
+		}
+		writeJSON(w, http.StatusOK, channel)
+	default:
+		writeJSON(w, http.StatusNotFound, &ErrorResponse{Code: 404, Message: "mock: unsupported api " + r.Method + " " + r.URL.Path})
+	}
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, request *Request) {
+	var req dto.GetAccessTokenReq
+	if err := request.Decode(&req); err != nil || req.AppID == "" || req.ClientSecret == "" {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{Code: 100016, Message: "invalid appid or secret"})
+		return
+	}
+	writeJSON(w, http.StatusOK, &dto.GetAccessTokenResp{
+		AccessToken: s.AccessToken,
+		ExpiresIn:   fmt.Sprintf("%d", s.ExpiresIn),
+	})
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, request *Request) {
+	var req dto.C2CRichMediaMessageToCreate
+	if err := request.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{Code: 40034001, Message: "invalid request"})
+		return
+	}
+	if req.Url == "" && req.FileData == "" {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{Code: 40034002, Message: "url or file_data required"})
+		return
+	}
+	uuid := s.nextID("file")
+	writeJSON(w, http.StatusOK, &dto.RichMediaMsgResp{
+		FileUuid: uuid,
+		FileInfo: "info-" + uuid,
+		Ttl:      3600,
+	})
+}
+
+func (s *Server) handleChannelMessage(w http.ResponseWriter, request *Request, channelId string) {
+	var req dto.MessageToCreate
+	if err := request.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{Code: 50006, Message: "invalid request"})
+		return
+	}
+	s.mu.Lock()
+	channel, ok := s.channels[channelId]
+	s.mu.Unlock()
+	guildId := ""
+	if ok {
+		guildId = channel.GuildID
+	}
+	writeJSON(w, http.StatusOK, &dto.Message{
+		ID:        s.nextID("msg"),
+		ChannelID: channelId,
+		GuildID:   guildId,
+		Content:   req.Content,
+		Timestamp: dto.Timestamp(time.Now().Format(time.RFC3339)),
+	})
+}
+
+// handleGroupMembers 按 limit 与 start_index 分页返回群成员
+func (s *Server) handleGroupMembers(w http.ResponseWriter, r *http.Request, groupOpenId string) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	start, _ := strconv.Atoi(r.URL.Query().Get("start_index"))
+	s.mu.Lock()
+	members := s.groupMembers[groupOpenId]
+	s.mu.Unlock()
+	if limit <= 0 {
+		limit = len(members)
+	}
+	resp := &dto.GetGroupMembersResp{}
+	if start < len(members) {
+		end := min(start+limit, len(members))
+		resp.Members = members[start:end]
+		if end < len(members) {
+			resp.NextIndex = end
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// match 判断路径分段是否符合模板，* 匹配任意单个分段
+func match(segments []string, pattern ...string) bool {
+	if len(segments) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,516 @@
+package mock_test
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/openapi/mock"
+	"GoQHttp/internal/profile"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/protocol/tencent"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testAppId  = 102000001
+	testSecret = "0123456789abcdef0123456789abcdef"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	idmap.Init(config.IdMapping{Mode: "sequence"})
+	profile.Load(config.Profile{})
+	m.Run()
+}
+
+// startBot 使用模拟服务启动一个 webhook 模式的机器人
+//
+// 每个测试使用新的存储、id映射与资料缓存，-count 重复运行时不受上次的去重记录与缓存影响。
+func startBot(t *testing.T, s *mock.Server, path string, options ...func(bot *config.QQ)) *tencent.Tencent {
+	t.Helper()
+	utils.Store = utils.NewMemoryStorage()
+	idmap.Init(config.IdMapping{Mode: "sequence"})
+	profile.Reset()
+	cfg := &config.Config{}
+	cfg.Bot.QQ = config.QQList{{
+		Enable:          true,
+		Id:              testAppId,
+		Secret:          testSecret,
+		Token:           "token",
+		ScopeType:       "public",
+		WebhookPath:     path,
+		TimestampWindow: 300,
+	}}
+	for _, option := range options {
+		option(&cfg.Bot.QQ[0])
+	}
+	started := protocol.StartBots(cfg)
+	if len(started) != 1 {
+		t.Fatalf("启动机器人数量 %d, 期望 1", len(started))
+	}
+	t.Cleanup(protocol.StopBots)
+	return started[0].Adapter.(*tencent.Tencent)
+}
+
+// nextEvent 等待广播的下一个事件，需要确认送达的事件确认已送达
+func nextEvent(t *testing.T) onebot.Event {
+	t.Helper()
+	select {
+	case event := <-protocol.BroadcastChan:
+		if delivery, ok := event.(*protocol.Delivery); ok {
+			delivery.Done(true)
+		}
+		return protocol.Unwrap(event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 OneBot 事件超时")
+		return nil
+	}
+}
+
+func TestWebhookGroupMessageRoundTrip(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+
+	qq := startBot(t, s, "/mock/qq")
+	if qq.SelfId != 10000 {
+		t.Fatalf("self_id %d, 期望 /users/@me 返回的 10000", qq.SelfId)
+	}
+
+	// 回调经过 webhook 路由按 appid 分发给机器人
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq", testAppId, testSecret)
+
+	resp, err := emitter.Emit(dto.EventGroupATMessageCreate, &dto.GroupATMessageDataEvent{
+		GroupOpenId: "GROUP_OPENID",
+		Content:     " hello",
+		MsgId:       "ROBOT1.0_msg",
+		Author:      &dto.Author{UserOpenId: "MEMBER_OPENID"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("回调返回 %d", resp.StatusCode)
+	}
+
+	message, ok := nextEvent(t).(onebot.MessageRequest)
+	if !ok {
+		t.Fatal("事件不是消息事件")
+	}
+	if message.SelfId != qq.SelfId || message.MessageType != onebot.GroupMessage {
+		t.Fatalf("事件 self_id=%d type=%s", message.SelfId, message.MessageType)
+	}
+	if message.GroupId == 0 || message.UserId == 0 || message.RawMessage != "hello" {
+		t.Fatalf("事件 group_id=%d user_id=%d raw=%q", message.GroupId, message.UserId, message.RawMessage)
+	}
+
+	// 功能端回复 send_group_msg，QQ 机器人未注册该动作，按消息下发
+	params, _ := json.Marshal(map[string]any{
+		"group_id": message.GroupId,
+		"user_id":  message.UserId,
+		"message":  []map[string]any{{"type": "text", "data": map[string]string{"text": "world"}}},
+	})
+	if _, err = protocol.Call(qq.SelfId, "send_group_msg", params); !errors.Is(err, protocol.ErrUnsupportedAction) {
+		t.Fatalf("send_group_msg 应按消息下发, err=%v", err)
+	}
+	var reply onebot.MessageRequest
+	if err = json.Unmarshal(params, &reply); err != nil {
+		t.Fatal(err)
+	}
+	reply.MessageType = onebot.GroupMessage
+	qq.SendMessage(&reply)
+
+	requests := s.RequestsTo(http.MethodPost, "/v2/groups/GROUP_OPENID/messages")
+	if len(requests) != 1 {
+		t.Fatalf("群消息请求数量 %d, 期望 1", len(requests))
+	}
+	var sent dto.GroupMessageToCreate
+	if err = requests[0].Decode(&sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Content != "world" || sent.MsgID != "ROBOT1.0_msg" {
+		t.Fatalf("群消息 content=%q msg_id=%q", sent.Content, sent.MsgID)
+	}
+}
+
+func TestWebhookDuplicateDelivery(t *testing.T) {
+	for _, durable := range []bool{false, true} {
+		s := mock.NewServer()
+		restore := s.Install()
+		path := fmt.Sprintf("/mock/qq-duplicate-%v", durable)
+		startBot(t, s, path, func(bot *config.QQ) { bot.DurableQueue = durable })
+
+		callback := httptest.NewServer(http.DefaultServeMux)
+		emitter := mock.NewWebhookEmitter(callback.URL+path, testAppId, testSecret)
+		payload := &dto.Payload{
+			PayloadBase: dto.PayloadBase{
+				OPCode: dto.WSDispatchEvent,
+				ID:     fmt.Sprintf("GROUP_AT_MESSAGE_CREATE:duplicate-%v", durable),
+				Type:   dto.EventGroupATMessageCreate,
+			},
+			Data: &dto.GroupATMessageDataEvent{
+				GroupOpenId: "GROUP_OPENID",
+				Content:     "again",
+				MsgId:       fmt.Sprintf("ROBOT1.0_duplicate_%v", durable),
+				Author:      &dto.Author{UserOpenId: "MEMBER_OPENID"},
+			},
+		}
+		// 平台重试投递同一事件
+		for i := 0; i < 2; i++ {
+			resp, err := emitter.EmitPayload(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("回调返回 %d", resp.StatusCode)
+			}
+		}
+		if message, ok := nextEvent(t).(onebot.MessageRequest); !ok || message.RawMessage != "again" {
+			t.Fatalf("durable=%v 事件 %+v", durable, message)
+		}
+		select {
+		case event := <-protocol.BroadcastChan:
+			t.Fatalf("durable=%v 重复事件被分发: %+v", durable, event)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		callback.Close()
+		protocol.StopBots()
+		restore()
+		s.Close()
+	}
+}
+
+func TestWebhookRejectsBadSignature(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	startBot(t, s, "/mock/qq-signature")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	// 使用其他密钥签名
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-signature", testAppId, "fedcba9876543210fedcba9876543210")
+	resp, err := emitter.Emit(dto.EventGroupATMessageCreate, &dto.GroupATMessageDataEvent{
+		GroupOpenId: "GROUP_OPENID",
+		Content:     "hello",
+		MsgId:       "ROBOT1.0_bad",
+		Author:      &dto.Author{UserOpenId: "MEMBER_OPENID"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("签名错误的回调返回 %d, 期望 %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	startBot(t, s, "/mock/qq-validation")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-validation", testAppId, testSecret)
+	emitter.Now = func() time.Time { return time.Unix(1700000000, 0) }
+	resp, err := emitter.Validate("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		PlainToken string `json:"plain_token"`
+		Signature  string `json:"signature"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.PlainToken != "plain" || !emitter.VerifyValidation("1700000000", "plain", result.Signature) {
+		t.Fatalf("回调地址验证返回 %+v", result)
+	}
+}
+
+func TestWebhookC2CMessageRoundTrip(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	qq := startBot(t, s, "/mock/qq-c2c")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-c2c", testAppId, testSecret)
+	resp, err := emitter.Emit(dto.EventC2CMessageCreate, &dto.C2CMessageDataEvent{
+		Id:      "ROBOT1.0_c2c",
+		Content: "hi",
+		Author:  &dto.C2CAuthor{UserOpenId: "USER_OPENID"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	message, ok := nextEvent(t).(onebot.MessageRequest)
+	if !ok || message.MessageType != onebot.PrivateMessage || message.SubType != onebot.Friend {
+		t.Fatalf("事件 %+v, 期望好友私聊消息", message)
+	}
+	if message.UserId == 0 || message.Sender.UserId != message.UserId || message.RawMessage != "hi" {
+		t.Fatalf("事件 user_id=%d sender=%d raw=%q", message.UserId, message.Sender.UserId, message.RawMessage)
+	}
+
+	// 回复私聊消息，携带收到的消息id
+	qq.SendMessage(&onebot.MessageRequest{
+		MessageType: onebot.PrivateMessage,
+		UserId:      message.UserId,
+		Message:     []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: "hello"}}},
+	})
+	requests := s.RequestsTo(http.MethodPost, "/v2/users/USER_OPENID/messages")
+	if len(requests) != 1 {
+		t.Fatalf("单聊消息请求数量 %d, 期望 1", len(requests))
+	}
+	var sent dto.GroupMessageToCreate
+	if err = requests[0].Decode(&sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Content != "hello" || sent.MsgID != "ROBOT1.0_c2c" {
+		t.Fatalf("单聊消息 content=%q msg_id=%q", sent.Content, sent.MsgID)
+	}
+}
+
+func TestWebhookGuildMessageRoundTrip(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	s.AddGuild(&dto.Guild{ID: "GUILD", Name: "测试频道"})
+	s.AddChannel(&dto.Channel{ID: "CHANNEL", GuildID: "GUILD", ChannelValueObject: dto.ChannelValueObject{Name: "闲聊"}})
+	qq := startBot(t, s, "/mock/qq-guild")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-guild", testAppId, testSecret)
+	resp, err := emitter.Emit(dto.EventAtMessageCreate, &dto.ATMessageDataEvent{
+		ID:        "GUILD_MSG",
+		GuildID:   "GUILD",
+		ChannelID: "CHANNEL",
+		Content:   "ping",
+		Author:    &dto.User{ID: "GUILD_USER", Username: "用户"},
+		Member:    &dto.Member{Nick: "昵称"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	message, ok := nextEvent(t).(onebot.MessageRequest)
+	if !ok || message.MessageType != onebot.GuildMessage || message.SubType != onebot.ChannelSubType {
+		t.Fatalf("事件 %+v, 期望子频道消息", message)
+	}
+	if message.GuildId == 0 || message.ChannelId == 0 || message.UserId == 0 || message.MessageId == 0 {
+		t.Fatalf("事件 guild_id=%d channel_id=%d user_id=%d message_id=%d",
+			message.GuildId, message.ChannelId, message.UserId, message.MessageId)
+	}
+	if message.Sender.NickName != "用户" || message.Sender.Card != "昵称" || message.RawMessage != "ping" {
+		t.Fatalf("事件 sender=%+v raw=%q", message.Sender, message.RawMessage)
+	}
+
+	// send_guild_channel_msg 使用事件中的数字id
+	params, _ := json.Marshal(map[string]any{
+		"guild_id":   message.GuildId,
+		"channel_id": message.ChannelId,
+		"message":    fmt.Sprintf("[CQ:at,qq=%d]pong", message.UserId),
+	})
+	result, err := protocol.Call(qq.SelfId, "send_guild_channel_msg", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent, ok := result.(map[string]int32); !ok || sent["message_id"] == 0 {
+		t.Fatalf("send_guild_channel_msg 返回 %+v", result)
+	}
+	requests := s.RequestsTo(http.MethodPost, "/channels/CHANNEL/messages")
+	if len(requests) != 1 {
+		t.Fatalf("子频道消息请求数量 %d, 期望 1", len(requests))
+	}
+	var sent dto.MessageToCreate
+	if err = requests[0].Decode(&sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Content != "<@!GUILD_USER>pong" {
+		t.Fatalf("子频道消息 content=%q", sent.Content)
+	}
+
+	// 未映射的数字id
+	params, _ = json.Marshal(map[string]any{"guild_id": message.GuildId, "channel_id": message.ChannelId + 1000, "message": "x"})
+	if _, err = protocol.Call(qq.SelfId, "send_guild_channel_msg", params); !errors.Is(err, idmap.ErrNotFound) {
+		t.Fatalf("未映射的子频道 err=%v", err)
+	}
+}
+
+func TestWebhookDirectMessageRoundTrip(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	qq := startBot(t, s, "/mock/qq-dm")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-dm", testAppId, testSecret)
+	resp, err := emitter.Emit(dto.EventDirectMessageCreate, &dto.DirectMessageDataEvent{
+		ID:            "DM_MSG",
+		GuildID:       "DM_GUILD",
+		ChannelID:     "DM_CHANNEL",
+		Content:       "secret",
+		Author:        &dto.User{ID: "GUILD_USER", Username: "用户"},
+		DirectMessage: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	message, ok := nextEvent(t).(onebot.MessageRequest)
+	if !ok || message.MessageType != onebot.PrivateMessage || message.GuildId == 0 || message.UserId == 0 {
+		t.Fatalf("事件 %+v, 期望携带 guild_id 的私聊消息", message)
+	}
+
+	// 携带 guild_id 的私聊消息通过频道私信回复
+	qq.SendMessage(&onebot.MessageRequest{
+		MessageType: onebot.PrivateMessage,
+		UserId:      message.UserId,
+		GuildId:     message.GuildId,
+		Message:     []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: "reply"}}},
+	})
+	requests := s.RequestsTo(http.MethodPost, "/dms/DM_GUILD/messages")
+	if len(requests) != 1 {
+		t.Fatalf("频道私信请求数量 %d, 期望 1", len(requests))
+	}
+}
+
+func TestGroupMemberProfile(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	s.AddGroupMembers("PROFILE_GROUP", &dto.GroupMember{MemberOpenId: "PROFILE_MEMBER"}, &dto.GroupMember{MemberOpenId: "OTHER_MEMBER"})
+	startBot(t, s, "/mock/qq-group-profile")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-group-profile", testAppId, testSecret)
+	for i, member := range []string{"PROFILE_MEMBER", "OTHER_MEMBER"} {
+		resp, err := emitter.Emit(dto.EventGroupATMessageCreate, &dto.GroupATMessageDataEvent{
+			GroupOpenId: "PROFILE_GROUP",
+			Content:     "hello",
+			MsgId:       fmt.Sprintf("ROBOT1.0_profile_%d", i),
+			Author:      &dto.Author{UserOpenId: member},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		message, ok := nextEvent(t).(onebot.MessageRequest)
+		if !ok || message.Sender.Role != onebot.Member || message.Sender.NickName == "" {
+			t.Fatalf("事件发送者 %+v", message.Sender)
+		}
+	}
+	// 群成员列表在有效期内只获取一次
+	if requests := s.RequestsTo(http.MethodGet, "/v2/groups/PROFILE_GROUP/members"); len(requests) != 1 {
+		t.Fatalf("获取群成员列表 %d 次, 期望 1", len(requests))
+	}
+}
+
+func TestGuildATWithoutGuildInfo(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	// 频道与子频道信息均无法获取时仍分发消息
+	startBot(t, s, "/mock/qq-guild-at", func(bot *config.QQ) { bot.AutoPermissionDemand = true })
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-guild-at", testAppId, testSecret)
+	resp, err := emitter.Emit(dto.EventAtMessageCreate, &dto.ATMessageDataEvent{
+		ID:        "AT_MSG",
+		GuildID:   "UNKNOWN_GUILD",
+		ChannelID: "UNKNOWN_CHANNEL",
+		Content:   "hi",
+		Author:    &dto.User{ID: "AT_USER", Username: "用户"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if message, ok := nextEvent(t).(onebot.MessageRequest); !ok || message.RawMessage != "hi" {
+		t.Fatalf("事件 %+v", message)
+	}
+	for _, prefix := range []string{"/guilds/UNKNOWN_GUILD", "/channels/UNKNOWN_CHANNEL"} {
+		if requests := s.RequestsTo(http.MethodGet, prefix); len(requests) != 0 {
+			t.Fatalf("请求 %s %d 次, 期望不请求", prefix, len(requests))
+		}
+	}
+	if requests := s.RequestsTo(http.MethodPost, "/guilds/UNKNOWN_GUILD/api_permission/demand"); len(requests) != 0 {
+		t.Fatalf("发送权限申请 %d 次", len(requests))
+	}
+}
+
+func TestGuildMemberProfile(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	s.AddGuild(&dto.Guild{ID: "PROFILE_GUILD", Name: "测试频道"})
+	s.AddChannel(&dto.Channel{ID: "PROFILE_CHANNEL", GuildID: "PROFILE_GUILD"})
+	s.AddMember(&dto.Member{GuildID: "PROFILE_GUILD", Nick: "频道昵称", Roles: []string{"1", "4"},
+		User: &dto.User{ID: "PROFILE_USER", Username: "接口用户名"}})
+	qq := startBot(t, s, "/mock/qq-guild-profile")
+
+	callback := httptest.NewServer(http.DefaultServeMux)
+	defer callback.Close()
+	emitter := mock.NewWebhookEmitter(callback.URL+"/mock/qq-guild-profile", testAppId, testSecret)
+	resp, err := emitter.Emit(dto.EventAtMessageCreate, &dto.ATMessageDataEvent{
+		ID:        "PROFILE_MSG",
+		GuildID:   "PROFILE_GUILD",
+		ChannelID: "PROFILE_CHANNEL",
+		Content:   "hi",
+		Author:    &dto.User{ID: "PROFILE_USER", Username: "用户"},
+		Member:    &dto.Member{Nick: "频道昵称", Roles: []string{"1", "2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	message, ok := nextEvent(t).(onebot.MessageRequest)
+	if !ok || message.Sender.Role != onebot.Admin {
+		t.Fatalf("事件发送者 %+v, 期望管理员", message.Sender)
+	}
+
+	// 未在消息中的资料由频道成员接口获取
+	params, _ := json.Marshal(map[string]any{"user_id": message.UserId, "no_cache": true})
+	result, err := protocol.Call(qq.SelfId, "get_stranger_info", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, ok := result.(*profile.StrangerInfo); !ok || info.Nickname != "接口用户名" {
+		t.Fatalf("get_stranger_info 返回 %+v", result)
+	}
+	if requests := s.RequestsTo(http.MethodGet, "/guilds/PROFILE_GUILD/members/PROFILE_USER"); len(requests) != 1 {
+		t.Fatalf("获取频道成员 %d 次, 期望 1", len(requests))
+	}
+	sender := profile.Get(qq.Identity(), 0, message.UserId, false)
+	if sender.Card != "频道昵称" || sender.Role != onebot.Owner {
+		t.Fatalf("频道成员资料 %+v", sender)
+	}
+}
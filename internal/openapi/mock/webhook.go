@@ -0,0 +1,122 @@
+package mock
+
+import (
+	"GoQHttp/internal/protocol/tencent/dto"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebhookEmitter 按照 QQ 官方回调规则签名并推送事件
+type WebhookEmitter struct {
+	URL    string
+	AppId  int
+	Client *http.Client
+	// Now 签名时间戳来源，可替换以模拟过期或重放的请求
+	Now func() time.Time
+
+	mu         sync.Mutex
+	privateKey ed25519.PrivateKey
+	seq        uint32
+}
+
+// eventIds 自动生成的事件id，在进程内唯一，避免不同推送器的事件被当作重复事件
+var eventIds atomic.Int64
+
+// NewWebhookEmitter 创建事件推送器，secret 为机器人密钥
+func NewWebhookEmitter(url string, appId int, secret string) *WebhookEmitter {
+	seed := secret
+	for len(seed) < ed25519.SeedSize && seed != "" {
+		seed = strings.Repeat(seed, 2)
+	}
+	if seed == "" {
+		seed = strings.Repeat("\x00", ed25519.SeedSize)
+	}
+	return &WebhookEmitter{
+		URL:        url,
+		AppId:      appId,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		Now:        time.Now,
+		privateKey: ed25519.NewKeyFromSeed([]byte(seed[:ed25519.SeedSize])),
+	}
+}
+
+// Sign 计算 timestamp+body 的签名
+func (e *WebhookEmitter) Sign(timestamp string, body []byte) string {
+	var msg bytes.Buffer
+	msg.WriteString(timestamp)
+	msg.Write(body)
+	return hex.EncodeToString(ed25519.Sign(e.privateKey, msg.Bytes()))
+}
+
+// Emit 推送一个分发事件，payload id 自动生成
+func (e *WebhookEmitter) Emit(eventType dto.EventType, data any) (*http.Response, error) {
+	e.mu.Lock()
+	e.seq++
+	payload := &dto.Payload{
+		PayloadBase: dto.PayloadBase{
+			OPCode: dto.WSDispatchEvent,
+			ID:     fmt.Sprintf("%s:mock-%d", eventType, eventIds.Add(1)),
+			Seq:    e.seq,
+			Type:   eventType,
+		},
+		Data: data,
+	}
+	e.mu.Unlock()
+	return e.EmitPayload(payload)
+}
+
+// EmitPayload 原样推送 payload，可用于模拟重复投递
+func (e *WebhookEmitter) EmitPayload(payload *dto.Payload) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return e.EmitRaw(body)
+}
+
+// EmitRaw 签名并推送原始请求体
+func (e *WebhookEmitter) EmitRaw(body []byte) (*http.Response, error) {
+	timestamp := strconv.FormatInt(e.Now().Unix(), 10)
+	r, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("User-Agent", "QQBot-Callback")
+	r.Header.Set("X-Bot-Appid", strconv.Itoa(e.AppId))
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	r.Header.Set("X-Signature-Ed25519", e.Sign(timestamp, body))
+	return e.Client.Do(r)
+}
+
+// Validate 发起回调地址验证 (op 13)
+func (e *WebhookEmitter) Validate(plainToken string) (*http.Response, error) {
+	payload := &dto.Payload{
+		PayloadBase: dto.PayloadBase{
+			OPCode: dto.HTTPCallbackValidation,
+		},
+		Data: map[string]string{
+			"plain_token": plainToken,
+			"event_ts":    strconv.FormatInt(e.Now().Unix(), 10),
+		},
+	}
+	return e.EmitPayload(payload)
+}
+
+// VerifyValidation 校验回调地址验证的返回签名是否正确
+func (e *WebhookEmitter) VerifyValidation(eventTs string, plainToken string, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(e.privateKey.Public().(ed25519.PublicKey), []byte(eventTs+plainToken), sig)
+}
@@ -2,7 +2,6 @@ package openapi
 
 import (
 	"GoQHttp/internal/onebot"
-	"GoQHttp/internal/protocol"
 	dto2 "GoQHttp/internal/protocol/tencent/dto"
 	"GoQHttp/logger"
 	"GoQHttp/utils"
@@ -16,24 +15,29 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// OpenApi QQ 开放平台接口，每个机器人持有独立的实例与 AccessToken
 type OpenApi struct {
+	AppId   int
+	Secret  string
+	Sandbox bool
+
+	mu          sync.RWMutex
+	accessToken string
+	timestamp   int64
+	timer       *time.Timer
+	asyncId     int64
 }
 
 var ApiUrl = "https://api.sgroup.qq.com"
 
 const SandboxApiUrl = "https://sandbox.api.sgroup.qq.com"
 
-var (
-	accessToken   string
-	timestamp     int64
-	timer         *time.Timer
-	TencentAppId  int
-	TencentSecret string
-	asyncId       int64
-)
+// TokenUrl 获取 AccessToken 的地址，测试时可替换为本地模拟服务
+var TokenUrl = "https://bots.qq.com/app/getAppAccessToken"
 
 type GetAccessTokenReq struct {
 	AppID        string `json:"appId"`
@@ -45,15 +49,22 @@ type GetAccessTokenResp struct {
 	ExpiresIn   string `json:"expires_in"`
 }
 
-func (o *OpenApi) Init(appId int, secret string, sandbox bool) error {
-	TencentAppId = appId
-	TencentSecret = secret
-	asyncId = 0
-	if sandbox {
-		ApiUrl = SandboxApiUrl
+// NewOpenApi 创建机器人对应的开放平台接口
+func NewOpenApi(appId int, secret string, sandbox bool) *OpenApi {
+	return &OpenApi{
+		AppId:   appId,
+		Secret:  secret,
+		Sandbox: sandbox,
 	}
+}
 
-	err := getAppAccessToken()
+func (o *OpenApi) Init(appId int, secret string, sandbox bool) error {
+	o.AppId = appId
+	o.Secret = secret
+	o.Sandbox = sandbox
+	o.asyncId = 0
+
+	err := o.getAppAccessToken()
 	if err != nil {
 		return err
 	}
@@ -61,18 +72,53 @@ func (o *OpenApi) Init(appId int, secret string, sandbox bool) error {
 	return nil
 }
 
-func getAppAccessToken() error {
+// SetSecret 更新机器人密钥，下次获取 AccessToken 时使用
+func (o *OpenApi) SetSecret(secret string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.Secret = secret
+}
+
+// apiUrl 当前机器人使用的接口地址
+func (o *OpenApi) apiUrl() string {
+	if o.Sandbox {
+		return SandboxApiUrl
+	}
+	return ApiUrl
+}
+
+// token 当前有效的 AccessToken
+func (o *OpenApi) token() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.accessToken
+}
+
+// ensureToken AccessToken 已过期时立即刷新
+func (o *OpenApi) ensureToken() error {
+	o.mu.RLock()
+	expired := time.Now().Unix() >= o.timestamp
+	o.mu.RUnlock()
+	if expired {
+		return o.getAppAccessToken()
+	}
+	return nil
+}
+
+func (o *OpenApi) getAppAccessToken() error {
+	o.mu.RLock()
 	req := GetAccessTokenReq{
-		AppID:        strconv.Itoa(TencentAppId),
-		ClientSecret: TencentSecret,
+		AppID:        strconv.Itoa(o.AppId),
+		ClientSecret: o.Secret,
 	}
+	o.mu.RUnlock()
 
 	data, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("marshal request error: %v", err)
 	}
 
-	resp, err := http.Post("https://bots.qq.com/app/getAppAccessToken", "application/json", bytes.NewBuffer(data))
+	resp, err := http.Post(TokenUrl, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("http post error: %v", err)
 	}
@@ -88,18 +134,21 @@ func getAppAccessToken() error {
 		return fmt.Errorf("unmarshal error: %v", err)
 	}
 
-	accessToken = result.AccessToken
 	expiresIn, err := strconv.Atoi(result.ExpiresIn)
 	if err != nil {
 		return fmt.Errorf("parse expires_in error: %v", err)
 	}
 
-	// 保存过期时间
-	timestamp = time.Now().Unix() + int64(expiresIn)
+	// 保存凭证与过期时间
+	expiresAt := time.Now().Unix() + int64(expiresIn)
+	o.mu.Lock()
+	o.accessToken = result.AccessToken
+	o.timestamp = expiresAt
+	o.mu.Unlock()
 
 	// 打印北京时间
-	expireTime := time.Unix(timestamp, 0).In(time.FixedZone("CST", 8*3600))
-	logger.Infof("[INFO] QQ凭证获取成功: %s", accessToken)
+	expireTime := time.Unix(expiresAt, 0).In(time.FixedZone("CST", 8*3600))
+	logger.Infof("[INFO] QQ凭证获取成功[%d]: %s", o.AppId, result.AccessToken)
 	logger.Infof("[INFO] 有效期至: %s", expireTime.Format("2006-01-02 15:04:05"))
 
 	// 重新设置定时任务（提前 60 秒刷新）
@@ -107,32 +156,45 @@ func getAppAccessToken() error {
 	if next < 60 {
 		next = 60
 	}
-	scheduleRefresh(next)
+	o.scheduleRefresh(next)
 	return nil
 }
 
 // 安排下一次刷新
-func scheduleRefresh(seconds int) {
-	if timer != nil {
-		timer.Stop()
+func (o *OpenApi) scheduleRefresh(seconds int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.timer != nil {
+		o.timer.Stop()
 	}
 
 	logger.Infof("下次刷新将在 %d 秒后", seconds)
-	timer = time.AfterFunc(time.Duration(seconds)*time.Second, func() {
-		if err := getAppAccessToken(); err != nil {
+	o.timer = time.AfterFunc(time.Duration(seconds)*time.Second, func() {
+		if err := o.getAppAccessToken(); err != nil {
 			logger.Errorf("自动刷新失败: %v", err)
 			// 失败时，5分钟后重试
-			scheduleRefresh(300)
+			o.scheduleRefresh(300)
 		}
 	})
 }
 
+// Stop 停止 AccessToken 自动刷新
+func (o *OpenApi) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+}
+
 func (o *OpenApi) NextAsyncID() int64 {
-	asyncId++
-	newID := asyncId
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.asyncId++
+	newID := o.asyncId
 
 	if newID > 1000000 {
-		asyncId = rand.Int63n(1060000)
+		o.asyncId = rand.Int63n(1060000)
 	}
 	logger.Debugf("AsyncID: %v", newID)
 	return newID
@@ -213,6 +275,11 @@ func (o *OpenApi) Upload(file string) (string, error) {
 
 // UploadFile 本地上传base64图片
 func (o *OpenApi) UploadFile(file string, groupId string) (*dto2.RichMediaMsgResp, error) {
+	return o.uploadFile(file, "groups/"+groupId)
+}
+
+// uploadFile 上传群或单聊的富媒体文件，target 为 groups/{group_openid} 或 users/{openid}
+func (o *OpenApi) uploadFile(file string, target string) (*dto2.RichMediaMsgResp, error) {
 
 	//encoded, _ := base64.StdEncoding.DecodeString(file)
 	//upload, err := o.Upload(file)
@@ -235,82 +302,63 @@ func (o *OpenApi) UploadFile(file string, groupId string) (*dto2.RichMediaMsgRes
 		}
 	}
 
-	sendData, err := json.Marshal(groupRichMediaMessageToCreate)
-	if err != nil {
-		return nil, err
-	}
-
-	r, err := http.NewRequest("POST", fmt.Sprintf("%s/v2/groups/%s/files", ApiUrl, groupId), bytes.NewBuffer(sendData))
+	var richMediaMsgResp *dto2.RichMediaMsgResp
+	err := o.doRequest(http.MethodPost, fmt.Sprintf("/v2/%s/files", target), &groupRichMediaMessageToCreate, &richMediaMsgResp)
 	if err != nil {
-		logger.Errorf("Error creating request: %v", err)
 		return nil, err
 	}
+	return richMediaMsgResp, nil
+}
 
-	r.Header = http.Header{
-		"Content-Type":  []string{"application/json"},
-		"Authorization": []string{"QQBot " + accessToken},
+// SendMessage 发送功能端下发的群或单聊消息，返回最后一条发送成功的消息id与第一个发送错误
+func (o *OpenApi) SendMessage(payload *onebot.MessageRequest) (string, error) {
+	switch payload.MessageType {
+	case onebot.GroupMessage:
+		return o.SendGroupMessage(payload)
+	case onebot.PrivateMessage:
+		return o.SendC2CMessage(payload)
+	default:
+		marshal, err := json.Marshal(payload)
+		if err != nil {
+			return "", fmt.Errorf("SendPacket json: %v", err)
+		}
+		return "", fmt.Errorf("暂不支持的数据包: %s", string(marshal))
 	}
+}
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(r)
+// SendGroupMessage 逐个消息段发送群消息，返回最后一条发送成功的消息id，发送失败的消息段跳过并返回第一个错误
+func (o *OpenApi) SendGroupMessage(data *onebot.MessageRequest) (string, error) {
+	GroupId, err := GroupOpenId(o.AppId, data.GroupId)
 	if err != nil {
-		logger.Errorf("Error sending request: %v", err)
-		return nil, err
+		return "", fmt.Errorf("GroupOpenId err: %v", err)
 	}
 
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, _ := io.ReadAll(resp.Body)
-
-	var richMediaMsgResp *dto2.RichMediaMsgResp
-	err = json.Unmarshal(body, &richMediaMsgResp)
+	MessageId, err := utils.Store.GetGroupMessageID(o.AppId, data.GroupId, data.UserId)
 	if err != nil {
-		return nil, err
-	}
-
-	return richMediaMsgResp, err
-}
-
-func (o *OpenApi) SendPacket() {
-	for payload := range protocol.QQOfficialChan {
-		switch payload.MessageType {
-		case onebot.GroupMessage:
-			o.SendGroupMessage(payload)
-		default:
-			marshal, err := json.Marshal(payload)
-			if err != nil {
-				logger.Errorf("SendPacket json: %v", err)
-				return
-			}
-			logger.Warnf("暂不支持的数据包: %+v", marshal)
-		}
-
+		return "", fmt.Errorf("GetGroupMessageID err: %v", err)
 	}
+	return o.sendElements("groups/"+GroupId, MessageId, data.Message)
 }
 
-func (o *OpenApi) SendGroupMessage(data *onebot.MessageRequest) {
-	if time.Now().Unix() <= timestamp {
-		err := getAppAccessToken()
-		if err != nil {
-			logger.Errorf("GetAppAccessToken err: %v", err)
-			return
-		}
-	}
-	GroupId, err := utils.DBUtil.GetGroupID(data.GroupId)
+// SendC2CMessage 逐个消息段发送单聊消息，回复用户最近一条消息，返回值与 SendGroupMessage 相同
+func (o *OpenApi) SendC2CMessage(data *onebot.MessageRequest) (string, error) {
+	UserId, err := C2CUserOpenId(o.AppId, data.UserId)
 	if err != nil {
-		logger.Errorf("DBUtil.GetGroupID err: %v", err)
-		return
+		return "", fmt.Errorf("C2CUserOpenId err: %v", err)
 	}
 
-	MessageId, err := utils.DBUtil.GetGroupMessageID(data.GroupId, data.UserId)
-
+	MessageId, err := utils.Store.GetGroupMessageID(o.AppId, 0, data.UserId)
 	if err != nil {
-		logger.Errorf("DBUtil.GetGroupMessageID err: %v", err)
-		return
+		return "", fmt.Errorf("GetGroupMessageID err: %v", err)
 	}
-	for _, element := range data.Message {
+	return o.sendElements("users/"+UserId, MessageId, data.Message)
+}
+
+// sendElements 逐个消息段发送群或单聊消息，target 为 groups/{group_openid} 或 users/{openid}
+func (o *OpenApi) sendElements(target string, MessageId string, elements []*onebot.Element) (string, error) {
+	var sentId string
+	var firstErr error
+	for _, element := range elements {
 		var groupMessageToCreate *dto2.GroupMessageToCreate
 		seq := o.NextAsyncID()
 		if element.ElementType == onebot.TextType {
@@ -352,9 +400,12 @@ func (o *OpenApi) SendGroupMessage(data *onebot.MessageRequest) {
 			if err != nil {
 				continue
 			}
-			file, err := o.UploadFile(image.File, GroupId)
+			file, err := o.uploadFile(image.File, target)
 			if err != nil {
 				logger.Errorf("UploadFile err: %v", err)
+				if firstErr == nil {
+					firstErr = err
+				}
 				continue
 			}
 			logger.Debugf("UploadFile : %+v", file)
@@ -379,113 +430,85 @@ func (o *OpenApi) SendGroupMessage(data *onebot.MessageRequest) {
 			continue
 		}
 
-		if groupMessageToCreate == nil {
-			logger.Warnf("群消息构建失败")
-			continue
-		}
-
-		sendData, err := json.Marshal(groupMessageToCreate)
-		if err != nil {
-			logger.Errorf("json Marshal err: %v", err)
-			continue
-		}
-		r, err := http.NewRequest("POST", fmt.Sprintf("%s/v2/groups/%s/messages", ApiUrl, GroupId), bytes.NewBuffer(sendData))
-		if err != nil {
-			logger.Errorf("Error creating request: %v", err)
-			continue
-		}
-
-		r.Header = http.Header{
-			"Content-Type":  []string{"application/json"},
-			"Authorization": []string{"QQBot " + accessToken},
-		}
-
-		// 发送请求
-		client := &http.Client{}
-		resp, err := client.Do(r)
+		var groupMsgResp *dto2.GroupMsgResp
+		err := o.doRequest(http.MethodPost, fmt.Sprintf("/v2/%s/messages", target), groupMessageToCreate, &groupMsgResp)
 		if err != nil {
-			logger.Errorf("Error sending request: %v", err)
+			logger.Warnf("发送消息失败: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
 			continue
 		}
-		defer resp.Body.Close()
-
-		// 读取响应
-		//_, _ := io.ReadAll(resp.Body)
-		if resp.StatusCode != 200 {
-			body, _ := io.ReadAll(resp.Body)
-			logger.Warnf("发送群消息失败: %v", string(body))
-		} else {
-			logger.Info("发送群消息成功")
+		logger.Info("发送消息成功")
+		if groupMsgResp != nil && groupMsgResp.Id != "" {
+			sentId = groupMsgResp.Id
 		}
 	}
-
+	return sentId, firstErr
 }
 
-func (o *OpenApi) GetGuild(guildId string) (*dto2.Guild, error) {
-	r, err := http.NewRequest("GET", fmt.Sprintf("%s/guilds/%s", ApiUrl, guildId), nil)
-	if err != nil {
-		logger.Errorf("Error creating request: %v", err)
-		return nil, err
-	}
-
-	r.Header = http.Header{
-		"Content-Type":  []string{"application/json"},
-		"Authorization": []string{"QQBot " + accessToken},
-	}
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(r)
-	if err != nil {
-		logger.Errorf("Error sending request: %v", err)
+// GetMe 获取机器人自身的用户信息
+func (o *OpenApi) GetMe() (*dto2.User, error) {
+	var user *dto2.User
+	if err := o.doRequest(http.MethodGet, "/users/@me", nil, &user); err != nil {
 		return nil, err
 	}
+	return user, nil
+}
 
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	logger.Debugf("%v", string(body))
+// GetGuild 获取频道信息
+func (o *OpenApi) GetGuild(guildId string) (*dto2.Guild, error) {
 	var guild *dto2.Guild
-	err = json.Unmarshal(body, &guild)
-
-	if err != nil {
-		logger.Errorf("json Unmarshal err: %v", err)
+	if err := o.doRequest(http.MethodGet, fmt.Sprintf("/guilds/%s", guildId), nil, &guild); err != nil {
 		return nil, err
 	}
-
 	return guild, nil
 }
 
+// GetChannel 获取子频道信息
 func (o *OpenApi) GetChannel(channelId string) (*dto2.Channel, error) {
-	r, err := http.NewRequest("GET", fmt.Sprintf("%s/channels/%s", ApiUrl, channelId), nil)
-	if err != nil {
-		logger.Errorf("Error creating request: %v", err)
+	var channel *dto2.Channel
+	if err := o.doRequest(http.MethodGet, fmt.Sprintf("/channels/%s", channelId), nil, &channel); err != nil {
 		return nil, err
 	}
+	return channel, nil
+}
 
-	r.Header = http.Header{
-		"Content-Type":  []string{"application/json"},
-		"Authorization": []string{"QQBot " + accessToken},
+// SendDirectMessage 发送频道私信，guildId 为私信会话所在的频道
+func (o *OpenApi) SendDirectMessage(guildId string, message *dto2.MessageToCreate) (*dto2.Message, error) {
+	var result *dto2.Message
+	err := o.doRequest(http.MethodPost, fmt.Sprintf("/dms/%s/messages", guildId), message, &result)
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(r)
+// SendChannelMessage 发送子频道消息
+func (o *OpenApi) SendChannelMessage(channelId string, message *dto2.MessageToCreate) (*dto2.Message, error) {
+	var result *dto2.Message
+	err := o.doRequest(http.MethodPost, fmt.Sprintf("/channels/%s/messages", channelId), message, &result)
 	if err != nil {
-		logger.Errorf("Error sending request: %v", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	logger.Debugf("%v", string(body))
-	var channel *dto2.Channel
-	err = json.Unmarshal(body, &channel)
+	return result, nil
+}
 
-	if err != nil {
-		logger.Errorf("json Unmarshal err: %v", err)
+// GetGuildMember 获取频道成员信息
+func (o *OpenApi) GetGuildMember(guildId string, userId string) (*dto2.Member, error) {
+	var member *dto2.Member
+	if err := o.doRequest(http.MethodGet, fmt.Sprintf("/guilds/%s/members/%s", guildId, userId), nil, &member); err != nil {
 		return nil, err
 	}
+	return member, nil
+}
 
-	return channel, nil
+// GetGroupMembers 分页获取群成员列表，返回的 NextIndex 为 0 时没有更多成员
+func (o *OpenApi) GetGroupMembers(groupId string, req *dto2.GetGroupMembersReq) (*dto2.GetGroupMembersResp, error) {
+	var resp *dto2.GetGroupMembersResp
+	path := fmt.Sprintf("/v2/groups/%s/members?limit=%d&start_index=%d", groupId, req.Limit, req.StartIndex)
+	if err := o.doRequest(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
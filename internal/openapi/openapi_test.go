@@ -0,0 +1,144 @@
+package openapi_test
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/openapi"
+	"GoQHttp/internal/openapi/mock"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const testAppId = 102000002
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	idmap.Init(config.IdMapping{Mode: "sequence"})
+	m.Run()
+}
+
+// newGroupMessage 准备可回复的群消息
+func newGroupMessage(t *testing.T, groupOpenId string, text string) *onebot.MessageRequest {
+	t.Helper()
+	groupId, err := openapi.GroupId(testAppId, groupOpenId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := openapi.UserId(testAppId, "MEMBER_OPENID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = utils.Store.GroupMessageInsert(testAppId, "ROBOT1.0_"+groupOpenId, groupId, userId); err != nil {
+		t.Fatal(err)
+	}
+	return &onebot.MessageRequest{
+		MessageType: onebot.GroupMessage,
+		GroupId:     groupId,
+		UserId:      userId,
+		Message:     []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: text}}},
+	}
+}
+
+func newApi(t *testing.T) *openapi.OpenApi {
+	t.Helper()
+	api := openapi.NewOpenApi(testAppId, "secret", false)
+	if err := api.Init(testAppId, "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(api.Stop)
+	return api
+}
+
+func TestSendGroupMessage(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	api := newApi(t)
+
+	msgId, err := api.SendMessage(newGroupMessage(t, "GROUP_OK", "hello"))
+	if err != nil || msgId == "" {
+		t.Fatalf("发送群消息 id=%q err=%v", msgId, err)
+	}
+	if len(s.RequestsTo(http.MethodPost, "/v2/groups/GROUP_OK/messages")) != 1 {
+		t.Fatal("未记录群消息请求")
+	}
+}
+
+func TestSendGroupMessageNoPermission(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	api := newApi(t)
+
+	s.Script(http.MethodPost, "/v2/groups/GROUP_DENIED/messages", http.StatusForbidden,
+		&mock.ErrorResponse{Code: 11264, Message: "no permission"})
+	msgId, err := api.SendMessage(newGroupMessage(t, "GROUP_DENIED", "hello"))
+	if msgId != "" {
+		t.Fatalf("发送失败时返回消息id %q", msgId)
+	}
+	var apiErr *openapi.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsNoPermission() || apiErr.Status != http.StatusForbidden {
+		t.Fatalf("应返回无权限错误, err=%v", err)
+	}
+}
+
+func TestUploadFileError(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	api := newApi(t)
+
+	s.Script(http.MethodPost, "/v2/groups/GROUP_UPLOAD/files", http.StatusForbidden,
+		&mock.ErrorResponse{Code: 11264, Message: "no permission"})
+	if _, err := api.UploadFile("https://example.com/a.png", "GROUP_UPLOAD"); err == nil {
+		t.Fatal("上传失败时应返回错误")
+	}
+	file, err := api.UploadFile("https://example.com/a.png", "GROUP_UPLOAD")
+	if err != nil || file.FileInfo == "" {
+		t.Fatalf("上传 file=%+v err=%v", file, err)
+	}
+}
+
+func TestGetGuildAndChannel(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	api := newApi(t)
+
+	if _, err := api.GetGuild("missing"); err == nil {
+		t.Fatal("频道不存在时应返回错误")
+	}
+	s.AddChannel(&dto.Channel{ID: "CHANNEL", GuildID: "GUILD", ChannelValueObject: dto.ChannelValueObject{Name: "闲聊"}})
+	channel, err := api.GetChannel("CHANNEL")
+	if err != nil || channel.Name != "闲聊" {
+		t.Fatalf("子频道 %+v err=%v", channel, err)
+	}
+}
+
+func TestRawOfOwner(t *testing.T) {
+	id, err := openapi.IdOf(openapi.ChannelNamespace, testAppId, "CHANNEL_OWNED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := openapi.RawOf(openapi.ChannelNamespace, testAppId, id); err != nil || raw != "CHANNEL_OWNED" {
+		t.Fatalf("反查得到 %q, err=%v", raw, err)
+	}
+	// 其他机器人的映射视为不存在
+	if _, err = openapi.RawOf(openapi.ChannelNamespace, testAppId+1, id); !errors.Is(err, idmap.ErrNotFound) {
+		t.Fatalf("其他机器人反查 err=%v", err)
+	}
+	// 单聊用户与群成员使用不同的命名空间
+	c2c, err := openapi.C2CUserId(testAppId, "SAME_OPENID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := openapi.UserOpenId(testAppId, c2c); err == nil && raw == "SAME_OPENID" {
+		t.Fatal("单聊用户不应映射到群成员命名空间")
+	}
+}
@@ -0,0 +1,109 @@
+package openapi
+
+import (
+	dto2 "GoQHttp/internal/protocol/tencent/dto"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError 开放平台返回的错误信息
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	TraceId string `json:"trace_id,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("openapi error: status=%d code=%d message=%s trace_id=%s", e.Status, e.Code, e.Message, e.TraceId)
+}
+
+// noPermissionCodes 表示机器人缺少接口权限的错误码
+var noPermissionCodes = map[int]string{
+	11241: "频道 API 无授权",
+	11242: "仅频道管理员可调用",
+	11243: "子频道权限不足",
+	11264: "接口无权限",
+}
+
+// IsNoPermission 是否为缺少接口权限的错误
+func (e *APIError) IsNoPermission() bool {
+	_, ok := noPermissionCodes[e.Code]
+	return ok
+}
+
+// doRequest 发送开放平台请求，非 2xx 返回时解析为 *APIError
+func (o *OpenApi) doRequest(method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request error: %v", err)
+		}
+		reader = bytes.NewBuffer(data)
+	}
+
+	if err := o.ensureToken(); err != nil {
+		return err
+	}
+
+	r, err := http.NewRequest(method, o.apiUrl()+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request error: %v", err)
+	}
+	r.Header = http.Header{
+		"Content-Type":  []string{"application/json"},
+		"Authorization": []string{"QQBot " + o.token()},
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(r)
+	if err != nil {
+		return fmt.Errorf("send request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{Status: resp.StatusCode}
+		if err = json.Unmarshal(respBody, apiErr); err != nil {
+			apiErr.Message = string(respBody)
+		}
+		apiErr.TraceId = resp.Header.Get("X-Tps-trace-ID")
+		return apiErr
+	}
+
+	if result != nil && len(respBody) > 0 {
+		if err = json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("unmarshal error: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetAPIPermissions 获取机器人在频道内可用的接口权限列表
+func (o *OpenApi) GetAPIPermissions(guildId string) (*dto2.APIPermissions, error) {
+	var permissions *dto2.APIPermissions
+	err := o.doRequest(http.MethodGet, fmt.Sprintf("/guilds/%s/api_permission", guildId), nil, &permissions)
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// RequireAPIPermissions 在子频道中发送接口授权链接，由频道管理员确认授权
+func (o *OpenApi) RequireAPIPermissions(guildId string, demand *dto2.APIPermissionDemandToCreate) (*dto2.APIPermissionDemand, error) {
+	var result *dto2.APIPermissionDemand
+	err := o.doRequest(http.MethodPost, fmt.Sprintf("/guilds/%s/api_permission/demand", guildId), demand, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package profile
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SetUserAliasParams set_user_alias 参数，group_id 为空时设置全局别名，alias 为空时删除别名
+type SetUserAliasParams struct {
+	UserId  int64  `json:"user_id"`
+	GroupId int32  `json:"group_id"`
+	Alias   string `json:"alias"`
+}
+
+// GetStrangerInfoParams get_stranger_info 参数
+type GetStrangerInfoParams struct {
+	UserId  int64 `json:"user_id"`
+	NoCache bool  `json:"no_cache"`
+}
+
+// StrangerInfo get_stranger_info 返回的用户资料
+type StrangerInfo struct {
+	UserId   int64      `json:"user_id"`
+	Nickname string     `json:"nickname"`
+	Sex      onebot.Sex `json:"sex"`
+	Age      int32      `json:"age"`
+}
+
+// identityOf 获取发起动作的连接所对应的机器人身份
+func identityOf(selfId int64) (protocol.Identity, error) {
+	adapter, ok := protocol.GetAdapter(selfId)
+	if !ok {
+		return protocol.Identity{}, fmt.Errorf("%w: 未找到 self_id 为 %d 的机器人", protocol.ErrBotNotFound, selfId)
+	}
+	return adapter.Identity(), nil
+}
+
+// SetUserAliasAction 设置机器人所在平台用户的别名
+func SetUserAliasAction(selfId int64, params json.RawMessage) (any, error) {
+	var p SetUserAliasParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.UserId == 0 {
+		return nil, errors.New("user_id 不能为空")
+	}
+	identity, err := identityOf(selfId)
+	if err != nil {
+		return nil, err
+	}
+	return nil, SetAlias(identity.Platform, p.GroupId, p.UserId, p.Alias)
+}
+
+// GetStrangerInfoAction 获取用户资料
+func GetStrangerInfoAction(selfId int64, params json.RawMessage) (any, error) {
+	var p GetStrangerInfoParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.UserId == 0 {
+		return nil, errors.New("user_id 不能为空")
+	}
+	identity, err := identityOf(selfId)
+	if err != nil {
+		return nil, err
+	}
+	sender := Get(identity, 0, p.UserId, p.NoCache)
+	return &StrangerInfo{UserId: sender.UserId, Nickname: sender.NickName, Sex: sender.Sex, Age: sender.Age}, nil
+}
@@ -0,0 +1,260 @@
+package profile
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 用户资料
+//
+// 事件中已有的资料写入缓存，缺少昵称或群角色时从缓存或平台获取，平台获取的资料按 ttl 过期后重新获取。
+// 管理员设置的别名优先：群别名作为群名片，全局别名作为昵称。
+
+const (
+	defaultTTL = 3600
+	// maxEntries 缓存数量超过后清理过期的资料
+	maxEntries = 10000
+)
+
+// Resolver 从平台获取用户资料，groupId 为 0 时获取用户资料，否则获取群成员资料，平台无法提供时返回 nil
+type Resolver func(selfId int64, groupId int32, userId int64) (*onebot.Sender, error)
+
+// key 资料缓存以机器人区分，同一平台的不同机器人获得的用户id可能不同；别名不区分机器人，selfId 为 0
+type key struct {
+	platform string
+	selfId   int64
+	groupId  int32
+	userId   int64
+}
+
+type entry struct {
+	sender onebot.Sender
+	expire time.Time
+}
+
+var (
+	ttl         atomic.Int64
+	resolvers   = make(map[string]Resolver)
+	resolversMu sync.RWMutex
+	entries     = make(map[key]*entry)
+	entriesMu   sync.Mutex
+	aliases     = make(map[key]string)
+	aliasesMu   sync.RWMutex
+	once        sync.Once
+)
+
+func init() {
+	ttl.Store(defaultTTL)
+}
+
+// RegisterResolver 注册平台获取用户资料的方式，平台在 init 中注册
+func RegisterResolver(platform string, resolver Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[platform] = resolver
+}
+
+// Load 加载配置，首次调用时读取别名并注册动作
+func Load(cfg config.Profile) {
+	if cfg.TTL > 0 {
+		ttl.Store(int64(cfg.TTL))
+	}
+	once.Do(func() {
+		list, err := utils.Store.UserAliasList()
+		if err != nil {
+			logger.Warnf("读取用户别名失败: %v", err)
+		}
+		aliasesMu.Lock()
+		for _, alias := range list {
+			aliases[key{platform: alias.Platform, groupId: alias.GroupId, userId: alias.UserId}] = alias.Alias
+		}
+		aliasesMu.Unlock()
+		protocol.RegisterAction("set_user_alias", SetUserAliasAction)
+		protocol.RegisterAction("get_stranger_info", GetStrangerInfoAction)
+	})
+}
+
+// TTL 平台获取的资料的有效期
+func TTL() time.Duration {
+	return time.Duration(ttl.Load()) * time.Second
+}
+
+// Enrich 补全消息发送者的资料并应用别名，groupId 为 0 时为私聊消息
+func Enrich(identity protocol.Identity, groupId int32, sender *onebot.Sender) {
+	if sender.NickName != "" {
+		remember(key{platform: identity.Platform, selfId: identity.SelfId, userId: sender.UserId}, onebot.Sender{UserId: sender.UserId, NickName: sender.NickName, Sex: sender.Sex})
+	}
+	if sender.NickName == "" || (groupId != 0 && sender.Role == "") {
+		fill(sender, lookup(identity, groupId, sender.UserId, false))
+	} else if groupId != 0 {
+		remember(key{platform: identity.Platform, selfId: identity.SelfId, groupId: groupId, userId: sender.UserId}, *sender)
+	}
+	applyDefaults(sender, groupId)
+	applyAlias(identity.Platform, groupId, sender)
+}
+
+// Apply 只应用别名与默认值，用于平台无法提供资料的用户
+func Apply(identity protocol.Identity, groupId int32, sender *onebot.Sender) {
+	applyDefaults(sender, groupId)
+	applyAlias(identity.Platform, groupId, sender)
+}
+
+// Get 获取用户资料并应用别名，noCache 时忽略缓存从平台获取
+func Get(identity protocol.Identity, groupId int32, userId int64, noCache bool) onebot.Sender {
+	sender := lookup(identity, groupId, userId, noCache)
+	applyDefaults(&sender, groupId)
+	applyAlias(identity.Platform, groupId, &sender)
+	return sender
+}
+
+// lookup 从缓存获取资料，缓存不存在或已过期时从平台获取，获取失败时使用过期的缓存
+func lookup(identity protocol.Identity, groupId int32, userId int64, noCache bool) onebot.Sender {
+	k := key{platform: identity.Platform, selfId: identity.SelfId, groupId: groupId, userId: userId}
+	entriesMu.Lock()
+	cached, ok := entries[k]
+	entriesMu.Unlock()
+	if ok && !noCache && time.Now().Before(cached.expire) {
+		return cached.sender
+	}
+
+	resolversMu.RLock()
+	resolver, found := resolvers[identity.Platform]
+	resolversMu.RUnlock()
+	result := onebot.Sender{UserId: userId}
+	if found {
+		resolved, err := resolver(identity.SelfId, groupId, userId)
+		if err != nil {
+			logger.Debugf("获取用户 %d 资料失败: %v", userId, err)
+			if ok {
+				return cached.sender
+			}
+		} else if resolved != nil {
+			result = *resolved
+			result.UserId = userId
+		}
+	}
+	// 平台无法提供资料时同样缓存，避免每条消息都请求平台
+	remember(k, result)
+	return result
+}
+
+// remember 写入缓存
+func remember(k key, sender onebot.Sender) {
+	entriesMu.Lock()
+	defer entriesMu.Unlock()
+	now := time.Now()
+	if len(entries) >= maxEntries {
+		for old, e := range entries {
+			if now.After(e.expire) {
+				delete(entries, old)
+			}
+		}
+	}
+	entries[k] = &entry{sender: sender, expire: now.Add(TTL())}
+}
+
+// fill 使用缓存的资料补全缺少的字段
+func fill(sender *onebot.Sender, cached onebot.Sender) {
+	if sender.NickName == "" {
+		sender.NickName = cached.NickName
+	}
+	if sender.Card == "" {
+		sender.Card = cached.Card
+	}
+	if sender.Role == "" {
+		sender.Role = cached.Role
+	}
+	if sender.Sex == "" {
+		sender.Sex = cached.Sex
+	}
+	if sender.Title == "" {
+		sender.Title = cached.Title
+	}
+}
+
+// applyDefaults 无法获取昵称时使用用户id，群消息默认为普通成员
+func applyDefaults(sender *onebot.Sender, groupId int32) {
+	if sender.NickName == "" {
+		sender.NickName = strconv.FormatInt(sender.UserId, 10)
+	}
+	if sender.Sex == "" {
+		sender.Sex = onebot.Unknown
+	}
+	if groupId != 0 && sender.Role == "" {
+		sender.Role = onebot.Member
+	}
+}
+
+// applyAlias 应用别名，群别名作为群名片，全局别名作为昵称
+func applyAlias(platform string, groupId int32, sender *onebot.Sender) {
+	aliasesMu.RLock()
+	defer aliasesMu.RUnlock()
+	if alias, ok := aliases[key{platform: platform, userId: sender.UserId}]; ok {
+		sender.NickName = alias
+	}
+	if groupId == 0 {
+		return
+	}
+	if alias, ok := aliases[key{platform: platform, groupId: groupId, userId: sender.UserId}]; ok {
+		sender.Card = alias
+	}
+}
+
+// SetAlias 设置用户别名，groupId 为 0 时为全局别名，alias 为空时删除别名
+func SetAlias(platform string, groupId int32, userId int64, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if err := utils.Store.UserAliasSet(platform, groupId, userId, alias); err != nil {
+		return err
+	}
+	k := key{platform: platform, groupId: groupId, userId: userId}
+	aliasesMu.Lock()
+	defer aliasesMu.Unlock()
+	if alias == "" {
+		delete(aliases, k)
+	} else {
+		aliases[k] = alias
+	}
+	return nil
+}
+
+// Reset 清除全部资料缓存，别名不受影响
+func Reset() {
+	entriesMu.Lock()
+	defer entriesMu.Unlock()
+	entries = make(map[key]*entry)
+}
+
+// Forget 清除全部机器人缓存的群中成员或用户的资料与别名，groupId 不为 0 时清除群，否则清除用户
+func Forget(platform string, groupId int32, userId int64) {
+	match := func(k key) bool {
+		if k.platform != platform {
+			return false
+		}
+		if groupId != 0 {
+			return k.groupId == groupId
+		}
+		return k.userId == userId
+	}
+	entriesMu.Lock()
+	for k := range entries {
+		if match(k) {
+			delete(entries, k)
+		}
+	}
+	entriesMu.Unlock()
+	aliasesMu.Lock()
+	for k := range aliases {
+		if match(k) {
+			delete(aliases, k)
+		}
+	}
+	aliasesMu.Unlock()
+}
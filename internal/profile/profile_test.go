@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+// testResolver 清空缓存与别名后注册按机器人返回不同昵称的平台，返回调用次数
+func testResolver(platform string) *atomic.Int64 {
+	Reset()
+	aliasesMu.Lock()
+	aliases = make(map[key]string)
+	aliasesMu.Unlock()
+	var calls atomic.Int64
+	RegisterResolver(platform, func(selfId int64, groupId int32, userId int64) (*onebot.Sender, error) {
+		calls.Add(1)
+		return &onebot.Sender{NickName: fmt.Sprintf("bot%d-user%d", selfId, userId), Role: onebot.Admin}, nil
+	})
+	return &calls
+}
+
+func TestCacheKeyedBySelfId(t *testing.T) {
+	calls := testResolver("test-self")
+	first := protocol.Identity{Platform: "test-self", SelfId: 1}
+	second := protocol.Identity{Platform: "test-self", SelfId: 2}
+
+	// 同一平台的两个机器人获得的相同用户id互不影响
+	if sender := Get(first, 0, 7, false); sender.NickName != "bot1-user7" {
+		t.Fatalf("机器人 1 获取 %q", sender.NickName)
+	}
+	if sender := Get(second, 0, 7, false); sender.NickName != "bot2-user7" {
+		t.Fatalf("机器人 2 获取 %q", sender.NickName)
+	}
+	// 消息中的昵称只写入所属机器人的缓存
+	Enrich(second, 0, &onebot.Sender{UserId: 7, NickName: "消息昵称"})
+	if sender := Get(first, 0, 7, false); sender.NickName != "bot1-user7" {
+		t.Fatalf("机器人 1 的缓存被覆盖为 %q", sender.NickName)
+	}
+	if sender := Get(second, 0, 7, false); sender.NickName != "消息昵称" {
+		t.Fatalf("机器人 2 获取 %q", sender.NickName)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("获取资料 %d 次, 期望 2", calls.Load())
+	}
+
+	// 别名不区分机器人
+	if err := SetAlias("test-self", 0, 7, "别名"); err != nil {
+		t.Fatal(err)
+	}
+	if Get(first, 0, 7, false).NickName != "别名" || Get(second, 0, 7, false).NickName != "别名" {
+		t.Fatal("全局别名应对全部机器人生效")
+	}
+	// 清除全部机器人的缓存与别名
+	Forget("test-self", 0, 7)
+	if sender := Get(second, 0, 7, false); sender.NickName != "bot2-user7" {
+		t.Fatalf("清除后获取 %q", sender.NickName)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	calls := testResolver("test-ttl")
+	identity := protocol.Identity{Platform: "test-ttl", SelfId: 1}
+	defer ttl.Store(defaultTTL)
+
+	Get(identity, 10, 7, false)
+	if sender := Get(identity, 10, 7, false); sender.Role != onebot.Admin || calls.Load() != 1 {
+		t.Fatalf("缓存有效期内获取 %d 次, 角色 %q", calls.Load(), sender.Role)
+	}
+	if Get(identity, 10, 7, true); calls.Load() != 2 {
+		t.Fatalf("no_cache 时获取 %d 次, 期望 2", calls.Load())
+	}
+	// 缓存过期后重新获取
+	ttl.Store(-1)
+	Get(identity, 10, 8, false)
+	Get(identity, 10, 8, false)
+	if calls.Load() != 4 {
+		t.Fatalf("缓存过期后获取 %d 次, 期望 4", calls.Load())
+	}
+}
+
+func TestApplySkipsResolver(t *testing.T) {
+	calls := testResolver("test-apply")
+	identity := protocol.Identity{Platform: "test-apply", SelfId: 1}
+	sender := onebot.Sender{UserId: 7}
+	Apply(identity, 0, &sender)
+	if calls.Load() != 0 || sender.NickName != "7" || sender.Sex != onebot.Unknown {
+		t.Fatalf("只应用默认值, 获取 %d 次, 资料 %+v", calls.Load(), sender)
+	}
+}
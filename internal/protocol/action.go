@@ -0,0 +1,97 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ActionHandler OneBot 动作处理函数，selfId 为发起请求的连接所对应的机器人
+type ActionHandler func(selfId int64, params json.RawMessage) (any, error)
+
+// ErrBotNotFound 未找到 selfId 对应的机器人
+var ErrBotNotFound = errors.New("bot not found")
+
+// ErrUnsupportedAction 机器人不支持该动作
+var ErrUnsupportedAction = errors.New("unsupported action")
+
+// Actions 平台支持的 OneBot 动作
+type Actions map[string]ActionHandler
+
+// Call 执行动作，未注册的动作返回 ErrUnsupportedAction
+func (a Actions) Call(selfId int64, action string, params json.RawMessage) (any, error) {
+	handler, ok := a[action]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAction, action)
+	}
+	return handler(selfId, params)
+}
+
+// Names 已注册的动作名称
+func (a Actions) Names() []string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	actions   = make(Actions)
+	actionsMu sync.RWMutex
+)
+
+// LoginInfo get_login_info 返回的机器人信息
+type LoginInfo struct {
+	UserId   int64  `json:"user_id"`
+	Nickname string `json:"nickname"`
+}
+
+func init() {
+	RegisterAction("get_login_info", GetLoginInfoAction)
+}
+
+// GetLoginInfoAction 获取机器人的 self_id 与名称
+func GetLoginInfoAction(selfId int64, params json.RawMessage) (any, error) {
+	adapter, ok := GetAdapter(selfId)
+	if !ok {
+		return nil, fmt.Errorf("%w: 未找到 self_id 为 %d 的机器人", ErrBotNotFound, selfId)
+	}
+	identity := adapter.Identity()
+	return &LoginInfo{UserId: identity.SelfId, Nickname: identity.Nickname}, nil
+}
+
+// RegisterAction 注册与平台无关的 OneBot 动作，机器人不支持同名动作时使用
+func RegisterAction(action string, handler ActionHandler) {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+	actions[action] = handler
+}
+
+// GetAction 获取已注册的与平台无关的 OneBot 动作
+func GetAction(action string) (ActionHandler, bool) {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	handler, ok := actions[action]
+	return handler, ok
+}
+
+// Call 由 selfId 对应的机器人执行动作，机器人不支持时使用与平台无关的动作
+func Call(selfId int64, action string, params json.RawMessage) (any, error) {
+	if adapter, ok := GetAdapter(selfId); ok {
+		data, err := adapter.Call(action, params)
+		if !errors.Is(err, ErrUnsupportedAction) {
+			return data, err
+		}
+	}
+	if handler, ok := GetAction(action); ok {
+		return handler(selfId, params)
+	}
+	if _, ok := GetAdapter(selfId); !ok {
+		return nil, fmt.Errorf("%w: 未找到 self_id 为 %d 的机器人", ErrBotNotFound, selfId)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAction, action)
+}
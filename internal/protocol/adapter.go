@@ -0,0 +1,204 @@
+package protocol
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/logger"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Identity 机器人身份
+type Identity struct {
+	// Platform 平台名称
+	Platform string
+	// SelfId OneBot 的 self_id
+	SelfId int64
+	// UserId 机器人在平台的原始用户id
+	UserId   string
+	Nickname string
+}
+
+// Adapter 平台适配器，负责与平台通信并将平台事件转换为 OneBot 事件
+type Adapter interface {
+	// Identity 机器人身份，Start 成功后可用
+	Identity() Identity
+	// Start 登录平台并开始接收事件
+	Start() error
+	// Stop 停止接收事件并断开连接
+	Stop() error
+	// Events 转换后的 OneBot 事件
+	Events() <-chan onebot.Event
+	// SendMessage 发送功能端下发的消息
+	SendMessage(data *onebot.MessageRequest)
+	// Call 执行 OneBot 动作，不支持时返回 ErrUnsupportedAction
+	Call(action string, params json.RawMessage) (any, error)
+	// Capabilities 支持的 OneBot 动作
+	Capabilities() []string
+}
+
+// Reloader 支持重新加载配置的适配器
+type Reloader interface {
+	Reload(cfg *config.Config) error
+}
+
+// Bot 平台根据配置创建的机器人
+type Bot struct {
+	Adapter Adapter
+	// Channels 机器人单独配置的功能端，为空时使用全局配置
+	Channels []config.Channel
+}
+
+// Platform 根据配置创建平台的机器人，配置无效的机器人由平台记录日志并跳过
+type Platform func(cfg *config.Config) []*Bot
+
+type platform struct {
+	name   string
+	create Platform
+}
+
+// EventHook 事件广播给功能端之前调用，用于跨平台转发等与功能端无关的处理，不应阻塞
+type EventHook func(identity Identity, event onebot.Event)
+
+// SentHook 机器人发送消息成功后调用，message 的 MessageId 为发送的消息id，不应阻塞
+type SentHook func(identity Identity, message *onebot.MessageRequest)
+
+var (
+	platforms   []platform
+	adapters    = make(map[int64]Adapter)
+	adaptersMu  sync.RWMutex
+	platformsMu sync.Mutex
+	hooks       []EventHook
+	sentHooks   []SentHook
+	hooksMu     sync.RWMutex
+)
+
+// AddEventHook 注册事件钩子
+func AddEventHook(hook EventHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// AddSentHook 注册发送消息钩子
+func AddSentHook(hook SentHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	sentHooks = append(sentHooks, hook)
+}
+
+// MessageSent 由平台在消息发送成功后调用，发送者为机器人自身
+func MessageSent(identity Identity, message *onebot.MessageRequest) {
+	message.SelfId = identity.SelfId
+	message.PostType = onebot.MessageSentPost
+	if message.Time == 0 {
+		message.Time = time.Now().Unix()
+	}
+	message.Sender.UserId = identity.SelfId
+	message.Sender.NickName = identity.Nickname
+
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, hook := range sentHooks {
+		hook(identity, message)
+	}
+}
+
+// RegisterPlatform 注册平台，平台在 init 中注册，按注册顺序启动
+func RegisterPlatform(name string, create Platform) {
+	platformsMu.Lock()
+	defer platformsMu.Unlock()
+	platforms = append(platforms, platform{name: name, create: create})
+}
+
+// StartBots 创建并启动全部平台的机器人，事件汇入 BroadcastChan，返回启动成功的机器人
+func StartBots(cfg *config.Config) []*Bot {
+	platformsMu.Lock()
+	registered := append([]platform(nil), platforms...)
+	platformsMu.Unlock()
+
+	var started []*Bot
+	for _, p := range registered {
+		for _, bot := range p.create(cfg) {
+			if err := bot.Adapter.Start(); err != nil {
+				logger.Errorf("%s机器人启动失败: %v", p.name, err)
+				continue
+			}
+			identity := bot.Adapter.Identity()
+			adaptersMu.Lock()
+			adapters[identity.SelfId] = bot.Adapter
+			adaptersMu.Unlock()
+			go forward(identity, bot.Adapter.Events())
+			started = append(started, bot)
+		}
+	}
+	return started
+}
+
+// StopBots 停止全部机器人
+func StopBots() {
+	adaptersMu.Lock()
+	stopping := adapters
+	adapters = make(map[int64]Adapter)
+	adaptersMu.Unlock()
+
+	for selfId, adapter := range stopping {
+		if err := adapter.Stop(); err != nil {
+			logger.Warnf("机器人 %d 停止失败: %v", selfId, err)
+		}
+	}
+}
+
+// ReloadBots 通知支持重新加载的机器人刷新配置
+func ReloadBots(cfg *config.Config) {
+	for _, adapter := range Adapters() {
+		reloader, ok := adapter.(Reloader)
+		if !ok {
+			continue
+		}
+		if err := reloader.Reload(cfg); err != nil {
+			logger.Errorf("机器人 %d 重新加载配置失败: %v", adapter.Identity().SelfId, err)
+		}
+	}
+}
+
+// forward 将机器人的事件交给钩子后汇入广播频道，重试送达的事件不再交给钩子
+func forward(identity Identity, events <-chan onebot.Event) {
+	for event := range events {
+		if delivery, ok := event.(*Delivery); !ok || !delivery.Retry {
+			hooksMu.RLock()
+			for _, hook := range hooks {
+				hook(identity, Unwrap(event))
+			}
+			hooksMu.RUnlock()
+		}
+		BroadcastChan <- event
+	}
+}
+
+// GetAdapter 获取 self_id 对应的机器人
+func GetAdapter(selfId int64) (Adapter, bool) {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	adapter, ok := adapters[selfId]
+	return adapter, ok
+}
+
+// Adapters 获取全部已启动的机器人
+func Adapters() []Adapter {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	result := make([]Adapter, 0, len(adapters))
+	for _, adapter := range adapters {
+		result = append(result, adapter)
+	}
+	return result
+}
+
+// HandleWebhook 注册接收平台回调的 HTTP 地址
+func HandleWebhook(platform string, path string, handler http.HandlerFunc) {
+	http.HandleFunc(path, handler)
+	logger.Infof("%s Webhook 监听地址: %s", platform, path)
+}
@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"sync"
+)
+
+// EventDeduper 记录最近处理过的事件id，用于忽略平台重试投递的重复事件
+//
+// 内存中保留最近 size 条记录，同时写入存储，使用 SQLite 存储时重启后仍可识别重复事件。
+// 需要保证事件进入处理流程后才记录时，使用 Reserve 占用事件id，处理流程接收后 Commit，失败时 Release。
+type EventDeduper struct {
+	mu      sync.Mutex
+	prefix  string
+	size    int
+	seen    map[string]struct{}
+	pending map[string]struct{}
+	ring    []string
+	next    int
+	inserts int
+}
+
+// NewEventDeduper 创建事件去重器，namespace 用于区分不同机器人的事件
+func NewEventDeduper(namespace string, size int) *EventDeduper {
+	if size <= 0 {
+		size = 10000
+	}
+	return &EventDeduper{
+		prefix:  namespace + ":",
+		size:    size,
+		seen:    make(map[string]struct{}, size),
+		pending: make(map[string]struct{}),
+		ring:    make([]string, size),
+	}
+}
+
+// Key 事件id在存储中的记录值
+func (d *EventDeduper) Key(eventId string) string {
+	if eventId == "" {
+		return ""
+	}
+	return d.prefix + eventId
+}
+
+// Mark 标记事件已接收，事件首次出现时返回 true
+func (d *EventDeduper) Mark(eventId string) bool {
+	if !d.Reserve(eventId) {
+		return false
+	}
+	d.Commit(eventId)
+	return true
+}
+
+// Reserve 占用首次出现的事件id，已接收或正在处理的事件返回 false，返回 true 后须调用 Commit 或 Release
+func (d *EventDeduper) Reserve(eventId string) bool {
+	if eventId == "" {
+		return true
+	}
+	eventId = d.prefix + eventId
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[eventId]; ok {
+		return false
+	}
+	if _, ok := d.pending[eventId]; ok {
+		return false
+	}
+	if utils.Store != nil {
+		exists, err := utils.Store.EventDedupeExists(eventId)
+		if err != nil {
+			logger.Warnf("事件去重记录查询失败: %v", err)
+		} else if exists {
+			d.remember(eventId)
+			return false
+		}
+	}
+	d.pending[eventId] = struct{}{}
+	return true
+}
+
+// Release 事件未能进入处理流程，释放占用的事件id，平台重试投递时可再次接收
+func (d *EventDeduper) Release(eventId string) {
+	if eventId == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, d.prefix+eventId)
+}
+
+// Commit 事件已进入处理流程，记录事件id；存储中已有记录(如与队列记录在同一事务中写入)时不重复写入
+func (d *EventDeduper) Commit(eventId string) {
+	if eventId == "" {
+		return
+	}
+	eventId = d.prefix + eventId
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, eventId)
+
+	if utils.Store != nil {
+		if _, err := utils.Store.EventDedupeInsert(eventId); err != nil {
+			logger.Warnf("事件去重记录失败: %v", err)
+		}
+
+		// 定期清理超出容量的持久化记录
+		d.inserts++
+		if d.inserts >= d.size/10+1 {
+			d.inserts = 0
+			if _, err := utils.Store.EventDedupePrune(d.prefix, d.size); err != nil {
+				logger.Warnf("事件去重记录清理失败: %v", err)
+			}
+		}
+	}
+
+	d.remember(eventId)
+}
+
+// remember 写入内存记录，容量满时淘汰最早的记录
+func (d *EventDeduper) remember(eventId string) {
+	if old := d.ring[d.next]; old != "" {
+		delete(d.seen, old)
+	}
+	d.ring[d.next] = eventId
+	d.seen[eventId] = struct{}{}
+	d.next = (d.next + 1) % d.size
+}
@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+func TestEventDeduperReserve(t *testing.T) {
+	// 每次运行使用新的存储，-count 重复运行时不受上次的去重记录影响
+	utils.Store = utils.NewMemoryStorage()
+	d := NewEventDeduper("reserve", 10)
+	if !d.Reserve("a") {
+		t.Fatal("首次出现的事件应可占用")
+	}
+	// 处理中的事件不能再次占用
+	if d.Reserve("a") {
+		t.Fatal("处理中的事件被重复占用")
+	}
+	// 未进入处理流程的事件释放后可再次接收
+	d.Release("a")
+	if exists, _ := utils.Store.EventDedupeExists(d.Key("a")); exists {
+		t.Fatal("释放的事件不应写入去重记录")
+	}
+	if !d.Reserve("a") {
+		t.Fatal("释放后的事件应可再次占用")
+	}
+	d.Commit("a")
+	if d.Reserve("a") {
+		t.Fatal("已接收的事件被重复占用")
+	}
+	if exists, _ := utils.Store.EventDedupeExists(d.Key("a")); !exists {
+		t.Fatal("接收的事件未写入去重记录")
+	}
+
+	// 重启后从存储识别重复事件
+	restarted := NewEventDeduper("reserve", 10)
+	if restarted.Reserve("a") {
+		t.Fatal("重启后未识别重复事件")
+	}
+	if restarted.Mark("a") || !restarted.Mark("b") {
+		t.Fatal("Mark 结果错误")
+	}
+}
+
+func TestEventDeduperEmptyId(t *testing.T) {
+	d := NewEventDeduper("empty", 10)
+	// 没有事件id时无法去重，始终接收
+	if !d.Reserve("") || !d.Reserve("") || !d.Mark("") {
+		t.Fatal("没有id的事件应始终接收")
+	}
+}
@@ -0,0 +1,43 @@
+package protocol
+
+import (
+	"GoQHttp/internal/onebot"
+	"sync"
+)
+
+// Delivery 需要确认送达功能端的事件
+//
+// 平台从持久化队列取出的事件包装为 Delivery 后发出，功能端连接写入事件后调用 Done，
+// 平台等待送达结果后才从队列删除事件，未送达时保留事件等待重试。
+type Delivery struct {
+	onebot.Event
+	// Retry 事件上次未送达，重试时不再调用事件钩子
+	Retry bool
+	once  sync.Once
+	done  chan bool
+}
+
+// NewDelivery 包装需要确认送达的事件
+func NewDelivery(event onebot.Event, retry bool) *Delivery {
+	return &Delivery{Event: event, Retry: retry, done: make(chan bool, 1)}
+}
+
+// Done 记录事件是否已写入功能端连接，只有第一次调用生效
+func (d *Delivery) Done(delivered bool) {
+	d.once.Do(func() {
+		d.done <- delivered
+	})
+}
+
+// Result 送达结果，只会收到一次
+func (d *Delivery) Result() <-chan bool {
+	return d.done
+}
+
+// Unwrap 获取 Delivery 包装的事件，其他事件原样返回
+func Unwrap(event onebot.Event) onebot.Event {
+	if delivery, ok := event.(*Delivery); ok {
+		return delivery.Event
+	}
+	return event
+}
@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"GoQHttp/internal/onebot"
+	"testing"
+	"time"
+)
+
+func TestDelivery(t *testing.T) {
+	event := onebot.MessageRequest{MessageBase: onebot.MessageBase{SelfId: 10000}}
+	delivery := NewDelivery(event, false)
+	if delivery.GetSelfId() != 10000 {
+		t.Fatalf("self_id %d, 期望 10000", delivery.GetSelfId())
+	}
+	if _, ok := Unwrap(delivery).(onebot.MessageRequest); !ok {
+		t.Fatal("Unwrap 应返回包装的事件")
+	}
+	if Unwrap(event) == nil {
+		t.Fatal("未包装的事件应原样返回")
+	}
+
+	select {
+	case <-delivery.Result():
+		t.Fatal("未确认时不应有结果")
+	case <-time.After(10 * time.Millisecond):
+	}
+	delivery.Done(true)
+	delivery.Done(false)
+	if delivered := <-delivery.Result(); !delivered {
+		t.Fatal("只有第一次确认生效")
+	}
+}
+
+func TestForwardSkipsHooksOnRetry(t *testing.T) {
+	var called int
+	AddEventHook(func(identity Identity, event onebot.Event) {
+		if _, ok := event.(*Delivery); ok {
+			t.Error("钩子应收到未包装的事件")
+		}
+		called++
+	})
+	defer func() {
+		hooksMu.Lock()
+		hooks = hooks[:len(hooks)-1]
+		hooksMu.Unlock()
+	}()
+
+	events := make(chan onebot.Event, 2)
+	events <- NewDelivery(onebot.MessageRequest{}, false)
+	events <- NewDelivery(onebot.MessageRequest{}, true)
+	close(events)
+	forward(Identity{}, events)
+	for i := 0; i < 2; i++ {
+		if _, ok := (<-BroadcastChan).(*Delivery); !ok {
+			t.Fatal("广播的事件应保留包装")
+		}
+	}
+	if called != 1 {
+		t.Fatalf("钩子调用 %d 次, 期望 1", called)
+	}
+}
@@ -0,0 +1,207 @@
+package kook
+
+import (
+	"GoQHttp/internal/constant"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/kmarkdown"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// actions Kook 机器人支持的动作
+var actions = protocol.Actions{
+	"send_msg":               SendMsgAction,
+	"send_group_msg":         SendGroupMsgAction,
+	"send_private_msg":       SendPrivateMsgAction,
+	"send_guild_channel_msg": SendGuildChannelMessageAction,
+	"delete_msg":             DeleteMsgAction,
+	"update_msg":             UpdateMsgAction,
+}
+
+// SendMsgParams send_msg、send_group_msg、send_private_msg 参数
+type SendMsgParams struct {
+	MessageType onebot.MessageType `json:"message_type"`
+	GroupId     int32              `json:"group_id"`
+	UserId      int64              `json:"user_id"`
+	Message     json.RawMessage    `json:"message"`
+	AutoEscape  bool               `json:"auto_escape"`
+}
+
+// SendGuildChannelMessageParams send_guild_channel_msg 参数，与QQ官方机器人一致使用映射后的数字id，
+// channel_id 为频道消息的 group_id，guild_id 为消息中的 guild_id，可以为空
+type SendGuildChannelMessageParams struct {
+	GuildId   int64           `json:"guild_id"`
+	ChannelId int64           `json:"channel_id"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// MessageIdParams delete_msg 参数
+type MessageIdParams struct {
+	MessageId int32 `json:"message_id"`
+}
+
+// UpdateMsgParams update_msg 参数
+type UpdateMsgParams struct {
+	MessageId int32           `json:"message_id"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// botFor 获取发起动作的连接所对应的机器人
+func botFor(selfId int64) (*Kook, error) {
+	bot, ok := GetBot(selfId)
+	if !ok {
+		return nil, fmt.Errorf("%w: 未找到 self_id 为 %d 的Kook机器人", protocol.ErrBotNotFound, selfId)
+	}
+	return bot, nil
+}
+
+// parseMessage 解析 message 参数，auto_escape 时CQ码作为纯文本发送
+func parseMessage(raw json.RawMessage, autoEscape bool) ([]*onebot.Element, error) {
+	var text string
+	if autoEscape && json.Unmarshal(raw, &text) == nil {
+		return []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: text}}}, nil
+	}
+	return constant.CQCode.ParseMessage(raw)
+}
+
+// send 发送消息并返回消息id
+func send(selfId int64, messageType onebot.MessageType, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p SendMsgParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if messageType == "" {
+		messageType = p.MessageType
+	}
+	target, err := targetOf(messageType, p.GroupId, p.UserId)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := parseMessage(p.Message, p.AutoEscape)
+	if err != nil {
+		return nil, err
+	}
+	msgId, err := bot.Send(target, elements)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int32{"message_id": msgId}, nil
+}
+
+// SendMsgAction 发送消息，未指定 message_type 时按 group_id 判断
+func SendMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	return send(selfId, "", params)
+}
+
+// SendGroupMsgAction 发送频道消息
+func SendGroupMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	return send(selfId, onebot.GroupMessage, params)
+}
+
+// SendPrivateMsgAction 发送私聊消息
+func SendPrivateMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	return send(selfId, onebot.PrivateMessage, params)
+}
+
+// SendGuildChannelMessageAction 向频道发送消息
+func SendGuildChannelMessageAction(selfId int64, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p SendGuildChannelMessageParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ChannelId == 0 {
+		return nil, errors.New("channel_id 不能为空")
+	}
+	if p.GuildId != 0 {
+		if _, err = idmap.Raw(guildNamespace, p.GuildId); err != nil {
+			return nil, fmt.Errorf("未找到 guild_id %d 对应的服务器: %w", p.GuildId, err)
+		}
+	}
+	channelId, err := idmap.Raw(groupNamespace, p.ChannelId)
+	if err != nil {
+		return nil, fmt.Errorf("未找到 channel_id %d 对应的频道: %w", p.ChannelId, err)
+	}
+	elements, err := constant.CQCode.ParseMessage(p.Message)
+	if err != nil {
+		return nil, err
+	}
+	msgId, err := bot.Send(&Target{Id: channelId}, elements)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int32{"message_id": msgId}, nil
+}
+
+// DeleteMsgAction 撤回消息，先按频道消息撤回，失败后按私聊消息撤回
+func DeleteMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p MessageIdParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	msgId, err := idmap.Raw(messageNamespace, int64(p.MessageId))
+	if err != nil {
+		return nil, fmt.Errorf("未找到消息 %d: %v", p.MessageId, err)
+	}
+	if err = bot.Api.DeleteMessage(msgId); err != nil {
+		if directErr := bot.Api.DeleteDirectMessage(msgId); directErr != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// UpdateMsgAction 更新消息内容，仅支持文本、@、表情等可以写入 KMarkdown 的消息段
+func UpdateMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p UpdateMsgParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	msgId, err := idmap.Raw(messageNamespace, int64(p.MessageId))
+	if err != nil {
+		return nil, fmt.Errorf("未找到消息 %d: %v", p.MessageId, err)
+	}
+	elements, err := constant.CQCode.ParseMessage(p.Message)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if !kmarkdown.Inline(element.ElementType) && element.ElementType != onebot.ReplyType {
+			return nil, errors.New("仅支持更新为文本消息")
+		}
+	}
+	messages, err := bot.buildMessages(&Target{}, elements)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, errors.New("消息内容为空")
+	}
+
+	update := &MessageUpdate{MsgId: msgId, Content: strings.TrimSpace(messages[0].Content), Quote: messages[0].Quote}
+	if err = bot.Api.UpdateMessage(update); err != nil {
+		if directErr := bot.Api.UpdateDirectMessage(update); directErr != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
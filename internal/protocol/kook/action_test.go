@@ -0,0 +1,83 @@
+package kook
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// resetStore 使用新的存储与映射服务，-count 重复运行时id重新分配
+func resetStore() {
+	utils.Store = utils.NewMemoryStorage()
+	idmap.Init(config.IdMapping{Mode: idmap.SequenceMode})
+}
+
+// startTestBot 注册指向模拟接口的机器人，返回收到的频道消息
+func startTestBot(t *testing.T, selfId int64) chan MessageCreate {
+	t.Helper()
+	resetStore()
+	sent := make(chan MessageCreate, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message MessageCreate
+		if r.URL.Path == "/message/create" && json.NewDecoder(r.Body).Decode(&message) == nil {
+			sent <- message
+		}
+		_, _ = io.WriteString(w, `{"code":0,"message":"","data":{"msg_id":"SENT","msg_timestamp":1}}`)
+	}))
+	apiUrl := ApiUrl
+	ApiUrl = server.URL
+	k := NewKook(&config.Kook{Token: "token"})
+	k.SelfId = selfId
+	botsMu.Lock()
+	bots[selfId] = k
+	botsMu.Unlock()
+	t.Cleanup(func() {
+		botsMu.Lock()
+		delete(bots, selfId)
+		botsMu.Unlock()
+		ApiUrl = apiUrl
+		server.Close()
+	})
+	return sent
+}
+
+func TestSendGuildChannelMessageMappedIds(t *testing.T) {
+	sent := startTestBot(t, 1)
+	channel, _ := groupId("CHANNEL")
+	guild, _ := guildId("GUILD")
+
+	// 使用事件中的 group_id 与 guild_id
+	params, _ := json.Marshal(map[string]any{"guild_id": guild, "channel_id": channel, "message": "hello"})
+	if _, err := SendGuildChannelMessageAction(1, params); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-sent; message.TargetId != "CHANNEL" {
+		t.Fatalf("发送到 %q, 期望 CHANNEL", message.TargetId)
+	}
+
+	// 原始频道id与未映射的数字id均不能发送
+	for _, params := range []string{
+		`{"channel_id":"CHANNEL","message":"hello"}`,
+		`{"channel_id":99999,"message":"hello"}`,
+		`{"guild_id":99999,"channel_id":` + strconv.Itoa(int(channel)) + `,"message":"hello"}`,
+		`{"message":"hello"}`,
+	} {
+		if _, err := SendGuildChannelMessageAction(1, json.RawMessage(params)); err == nil {
+			t.Fatalf("%s 应返回错误", params)
+		}
+	}
+	params, _ = json.Marshal(map[string]any{"channel_id": 99999, "message": "hello"})
+	if _, err := SendGuildChannelMessageAction(1, params); !errors.Is(err, idmap.ErrNotFound) {
+		t.Fatalf("未映射的频道 err=%v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("参数无效时发送了 %d 条消息", len(sent))
+	}
+}
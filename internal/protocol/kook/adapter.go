@@ -0,0 +1,82 @@
+package kook
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/profile"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"encoding/json"
+	"strconv"
+)
+
+func init() {
+	protocol.RegisterPlatform("Kook", newBots)
+	profile.RegisterResolver("kook", resolveProfile)
+}
+
+// newBots 根据配置创建 Kook 机器人
+func newBots(cfg *config.Config) []*protocol.Bot {
+	bot := &cfg.Bot.Kook
+	if !bot.Enable {
+		return nil
+	}
+	if bot.Token == "" {
+		logger.Warnf("Kook机器人启动失败,请检查设置")
+		return nil
+	}
+	return []*protocol.Bot{{Adapter: NewKook(bot), Channels: bot.Channels}}
+}
+
+// Identity 机器人身份
+func (k *Kook) Identity() protocol.Identity {
+	return protocol.Identity{
+		Platform: "kook",
+		SelfId:   k.SelfId,
+		UserId:   k.UserId,
+		Nickname: k.Nickname,
+	}
+}
+
+// Stop 断开网关连接，webhook 模式下之后的回调不再处理
+func (k *Kook) Stop() error {
+	k.stopped.Store(true)
+	if k.client != nil {
+		k.client.Close()
+	}
+	botsMu.Lock()
+	delete(bots, k.SelfId)
+	botsMu.Unlock()
+	return nil
+}
+
+// Events 转换后的 OneBot 事件
+func (k *Kook) Events() <-chan onebot.Event {
+	return k.events
+}
+
+// Call 执行 OneBot 动作
+func (k *Kook) Call(action string, params json.RawMessage) (any, error) {
+	return actions.Call(k.SelfId, action, params)
+}
+
+// Capabilities 支持的 OneBot 动作
+func (k *Kook) Capabilities() []string {
+	return actions.Names()
+}
+
+// resolveProfile 获取用户资料，频道成员资料以消息中的为准
+func resolveProfile(selfId int64, groupId int32, userId int64) (*onebot.Sender, error) {
+	if groupId != 0 {
+		return nil, nil
+	}
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	user, err := bot.Api.GetUser(strconv.FormatInt(userId, 10))
+	if err != nil {
+		return nil, err
+	}
+	return &onebot.Sender{UserId: userId, NickName: user.Username}, nil
+}
@@ -0,0 +1,211 @@
+package kook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ApiUrl Kook 接口地址，测试时可替换为本地模拟服务
+var ApiUrl = "https://www.kookapp.cn/api/v3"
+
+// Api Kook 开放接口
+type Api struct {
+	Token  string
+	client *http.Client
+}
+
+// APIError Kook 接口返回的错误信息
+type APIError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kook api error: status=%d code=%d message=%s", e.Status, e.Code, e.Message)
+}
+
+// response Kook 接口统一的返回结构
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// NewApi 创建 Kook 接口
+func NewApi(token string) *Api {
+	return &Api{
+		Token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// doRequest 发送请求，code 不为 0 时返回 *APIError
+func (a *Api) doRequest(method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request error: %v", err)
+		}
+		reader = bytes.NewBuffer(data)
+	}
+
+	r, err := http.NewRequest(method, ApiUrl+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request error: %v", err)
+	}
+	r.Header = http.Header{
+		"Content-Type":  []string{"application/json"},
+		"Authorization": []string{"Bot " + a.Token},
+	}
+
+	return a.do(r, result)
+}
+
+// do 发送请求并解析统一返回结构中的 data
+func (a *Api) do(r *http.Request, result any) error {
+	resp, err := a.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("send request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %v", err)
+	}
+
+	var res response
+	if err = json.Unmarshal(respBody, &res); err != nil {
+		return &APIError{Status: resp.StatusCode, Message: string(respBody)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || res.Code != 0 {
+		return &APIError{Status: resp.StatusCode, Code: res.Code, Message: res.Message}
+	}
+
+	if result != nil && len(res.Data) > 0 {
+		if err = json.Unmarshal(res.Data, result); err != nil {
+			return fmt.Errorf("unmarshal error: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetMe 获取机器人自身的用户信息
+func (a *Api) GetMe() (*User, error) {
+	var user *User
+	if err := a.doRequest(http.MethodGet, "/user/me", nil, &user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUser 获取用户信息
+func (a *Api) GetUser(userId string) (*User, error) {
+	var user *User
+	if err := a.doRequest(http.MethodGet, "/user/view?user_id="+url.QueryEscape(userId), nil, &user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// MessageCreate 发送消息的参数，频道消息 target_id 为频道id，私聊消息为用户id
+type MessageCreate struct {
+	Type         MessageType `json:"type,omitempty"`
+	TargetId     string      `json:"target_id"`
+	Content      string      `json:"content"`
+	Quote        string      `json:"quote,omitempty"`
+	Nonce        string      `json:"nonce,omitempty"`
+	TempTargetId string      `json:"temp_target_id,omitempty"`
+}
+
+// MessageCreateResp 发送消息的返回
+type MessageCreateResp struct {
+	MsgId        string `json:"msg_id"`
+	MsgTimestamp int64  `json:"msg_timestamp"`
+	Nonce        string `json:"nonce"`
+}
+
+// MessageUpdate 更新消息的参数，仅支持 KMarkdown 及卡片消息
+type MessageUpdate struct {
+	MsgId   string `json:"msg_id"`
+	Content string `json:"content"`
+	Quote   string `json:"quote,omitempty"`
+}
+
+// CreateMessage 发送频道消息
+func (a *Api) CreateMessage(message *MessageCreate) (*MessageCreateResp, error) {
+	var result *MessageCreateResp
+	if err := a.doRequest(http.MethodPost, "/message/create", message, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateDirectMessage 发送私聊消息
+func (a *Api) CreateDirectMessage(message *MessageCreate) (*MessageCreateResp, error) {
+	var result *MessageCreateResp
+	if err := a.doRequest(http.MethodPost, "/direct-message/create", message, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateMessage 更新频道消息
+func (a *Api) UpdateMessage(message *MessageUpdate) error {
+	return a.doRequest(http.MethodPost, "/message/update", message, nil)
+}
+
+// UpdateDirectMessage 更新私聊消息
+func (a *Api) UpdateDirectMessage(message *MessageUpdate) error {
+	return a.doRequest(http.MethodPost, "/direct-message/update", message, nil)
+}
+
+// DeleteMessage 撤回频道消息
+func (a *Api) DeleteMessage(msgId string) error {
+	return a.doRequest(http.MethodPost, "/message/delete", map[string]string{"msg_id": msgId}, nil)
+}
+
+// DeleteDirectMessage 撤回私聊消息
+func (a *Api) DeleteDirectMessage(msgId string) error {
+	return a.doRequest(http.MethodPost, "/direct-message/delete", map[string]string{"msg_id": msgId}, nil)
+}
+
+// CreateAsset 上传图片、文件等媒体，返回可用于发送消息的地址
+func (a *Api) CreateAsset(name string, data []byte) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fileWriter, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return "", fmt.Errorf("创建表单文件字段失败: %v", err)
+	}
+	if _, err = fileWriter.Write(data); err != nil {
+		return "", fmt.Errorf("写入文件数据失败: %v", err)
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ApiUrl+"/asset/create", body)
+	if err != nil {
+		return "", fmt.Errorf("create request error: %v", err)
+	}
+	r.Header = http.Header{
+		"Content-Type":  []string{writer.FormDataContentType()},
+		"Authorization": []string{"Bot " + a.Token},
+	}
+	var asset struct {
+		Url string `json:"url"`
+	}
+	if err = a.do(r, &asset); err != nil {
+		return "", err
+	}
+	return asset.Url, nil
+}
@@ -0,0 +1,130 @@
+package kook
+
+import "encoding/json"
+
+// ChannelType 消息所在的频道类型
+type ChannelType string
+
+const (
+	GroupChannel     ChannelType = "GROUP"
+	PersonChannel    ChannelType = "PERSON"
+	BroadcastChannel ChannelType = "BROADCAST"
+)
+
+// MessageType 消息类型
+type MessageType int
+
+const (
+	TextMessage      MessageType = 1
+	ImageMessage     MessageType = 2
+	VideoMessage     MessageType = 3
+	FileMessage      MessageType = 4
+	AudioMessage     MessageType = 8
+	KMarkdownMessage MessageType = 9
+	CardMessage      MessageType = 10
+	SystemMessage    MessageType = 255
+)
+
+// 系统事件类型
+const (
+	JoinedGuild            = "joined_guild"
+	ExitedGuild            = "exited_guild"
+	AddedReaction          = "added_reaction"
+	DeletedReaction        = "deleted_reaction"
+	PrivateAddedReaction   = "private_added_reaction"
+	PrivateDeletedReaction = "private_deleted_reaction"
+)
+
+// Event 网关 s=0 信令中的事件数据
+type Event struct {
+	ChannelType  ChannelType `json:"channel_type"`
+	Type         MessageType `json:"type"`
+	TargetId     string      `json:"target_id"`
+	AuthorId     string      `json:"author_id"`
+	Content      string      `json:"content"`
+	MsgId        string      `json:"msg_id"`
+	MsgTimestamp int64       `json:"msg_timestamp"`
+	Nonce        string      `json:"nonce"`
+	// Extra 消息事件为 MessageExtra，系统事件为 SystemExtra
+	Extra json.RawMessage `json:"extra"`
+}
+
+// User 用户信息
+type User struct {
+	Id          string `json:"id"`
+	Username    string `json:"username"`
+	Nickname    string `json:"nickname"`
+	IdentifyNum string `json:"identify_num"`
+	Avatar      string `json:"avatar"`
+	Bot         bool   `json:"bot"`
+	Online      bool   `json:"online"`
+	Roles       []int  `json:"roles"`
+}
+
+// Attachment 图片、视频、文件等附件
+type Attachment struct {
+	Type     string `json:"type"`
+	Url      string `json:"url"`
+	Name     string `json:"name"`
+	FileType string `json:"file_type"`
+	Size     int64  `json:"size"`
+}
+
+// KMarkdown 消息的 KMarkdown 信息
+type KMarkdown struct {
+	RawContent      string `json:"raw_content"`
+	MentionPart     []any  `json:"mention_part"`
+	MentionRolePart []any  `json:"mention_role_part"`
+}
+
+// Quote 引用的消息
+type Quote struct {
+	Id       string `json:"id"`
+	RongId   string `json:"rong_id"`
+	Content  string `json:"content"`
+	CreateAt int64  `json:"create_at"`
+	Author   User   `json:"author"`
+}
+
+// MessageExtra 消息事件的附加信息
+type MessageExtra struct {
+	Type         MessageType `json:"type"`
+	GuildId      string      `json:"guild_id"`
+	ChannelName  string      `json:"channel_name"`
+	Mention      []string    `json:"mention"`
+	MentionAll   bool        `json:"mention_all"`
+	MentionRoles []int       `json:"mention_roles"`
+	MentionHere  bool        `json:"mention_here"`
+	Author       User        `json:"author"`
+	KMarkdown    *KMarkdown  `json:"kmarkdown,omitempty"`
+	Attachments  *Attachment `json:"attachments,omitempty"`
+	Quote        *Quote      `json:"quote,omitempty"`
+}
+
+// SystemExtra 系统事件的附加信息
+type SystemExtra struct {
+	Type string          `json:"type"`
+	Body json.RawMessage `json:"body"`
+}
+
+// GuildMemberBody 用户加入、退出服务器
+type GuildMemberBody struct {
+	UserId   string `json:"user_id"`
+	JoinedAt int64  `json:"joined_at"`
+	ExitedAt int64  `json:"exited_at"`
+}
+
+// Emoji 表情
+type Emoji struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ReactionBody 频道内及私聊消息的表情回应
+type ReactionBody struct {
+	ChannelId string `json:"channel_id"`
+	ChatCode  string `json:"chat_code"`
+	Emoji     Emoji  `json:"emoji"`
+	UserId    string `json:"user_id"`
+	MsgId     string `json:"msg_id"`
+}
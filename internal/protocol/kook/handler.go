@@ -0,0 +1,208 @@
+package kook
+
+import (
+	"GoQHttp/internal/kmarkdown"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/profile"
+	"GoQHttp/logger"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// MessageEventHandler 频道消息与私聊消息
+func (k *Kook) MessageEventHandler(event *Event, extra *MessageExtra) error {
+	logger.Infof("Kook消息: %s", event.Content)
+
+	senderId, err := userId(event.AuthorId)
+	if err != nil {
+		return err
+	}
+	msgId, err := messageId(event.MsgId)
+	if err != nil {
+		return err
+	}
+
+	var messages []*onebot.Element
+	if extra.Quote != nil && extra.Quote.Id != "" {
+		quoteId, err := messageId(extra.Quote.Id)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, &onebot.Element{
+			ElementType: onebot.ReplyType,
+			Data:        &onebot.Reply{Id: strconv.Itoa(int(quoteId))},
+		})
+	}
+	messages = append(messages, k.parseContent(event, extra)...)
+
+	messageRequest := onebot.MessageRequest{
+		MessageBase: onebot.MessageBase{
+			Time:     time.Now().Unix(),
+			SelfId:   k.SelfId,
+			PostType: onebot.MessagePost,
+		},
+		MessageId:       msgId,
+		UserId:          senderId,
+		OriginalMessage: messages,
+		Message:         messages,
+		RawMessage:      onebot.CQString(messages),
+		Font:            1,
+		Sender: onebot.Sender{
+			UserId:   senderId,
+			NickName: extra.Author.Username,
+			Card:     extra.Author.Nickname,
+			Sex:      onebot.Unknown,
+			Role:     onebot.Member,
+		},
+	}
+	if event.MsgTimestamp > 0 {
+		messageRequest.Time = event.MsgTimestamp / 1000
+	}
+
+	switch event.ChannelType {
+	case PersonChannel:
+		messageRequest.MessageType = onebot.PrivateMessage
+		messageRequest.SubType = onebot.Friend
+	default:
+		if messageRequest.GroupId, err = groupId(event.TargetId); err != nil {
+			return err
+		}
+		if extra.GuildId != "" {
+			if messageRequest.GuildId, err = guildId(extra.GuildId); err != nil {
+				return err
+			}
+		}
+		messageRequest.MessageType = onebot.GroupMessage
+		messageRequest.SubType = onebot.Normal
+	}
+	profile.Enrich(k.Identity(), messageRequest.GroupId, &messageRequest.Sender)
+
+	k.events <- messageRequest
+	return nil
+}
+
+// parseContent 根据消息类型转换消息内容
+func (k *Kook) parseContent(event *Event, extra *MessageExtra) []*onebot.Element {
+	url := event.Content
+	if extra.Attachments != nil && extra.Attachments.Url != "" {
+		url = extra.Attachments.Url
+	}
+
+	switch event.Type {
+	case ImageMessage:
+		return []*onebot.Element{{ElementType: onebot.ImageType, Data: &onebot.Image{File: url, Url: url}}}
+	case VideoMessage:
+		return []*onebot.Element{{ElementType: onebot.VideoType, Data: &onebot.Video{File: url, Url: url}}}
+	case AudioMessage:
+		return []*onebot.Element{{ElementType: onebot.RecordType, Data: &onebot.Record{File: url, Url: url}}}
+	case FileMessage:
+		file := &onebot.File{File: url, Url: url}
+		if extra.Attachments != nil {
+			file.Name = extra.Attachments.Name
+			file.Size = extra.Attachments.Size
+		}
+		return []*onebot.Element{{ElementType: onebot.FileType, Data: file}}
+	case CardMessage:
+		// 无法解析或只包含按钮等交互元素时保留原始卡片
+		if elements, err := kmarkdown.ParseCard(event.Content); err == nil && len(elements) > 0 {
+			return elements
+		}
+		return []*onebot.Element{{ElementType: onebot.JsonType, Data: &onebot.Json{Data: event.Content}}}
+	case KMarkdownMessage:
+		return kmarkdown.Parse(event.Content)
+	default:
+		return []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: event.Content}}}
+	}
+}
+
+// SystemEventHandler 系统事件
+func (k *Kook) SystemEventHandler(event *Event, extra *SystemExtra) error {
+	switch extra.Type {
+	case JoinedGuild, ExitedGuild:
+		body := &GuildMemberBody{}
+		if err := json.Unmarshal(extra.Body, body); err != nil {
+			return err
+		}
+		return k.GuildMemberEventHandler(event, extra.Type, body)
+	case AddedReaction, DeletedReaction, PrivateAddedReaction, PrivateDeletedReaction:
+		body := &ReactionBody{}
+		if err := json.Unmarshal(extra.Body, body); err != nil {
+			return err
+		}
+		return k.ReactionEventHandler(event, extra.Type, body)
+	default:
+		logger.Debugf("暂未支持的Kook系统事件%v", extra.Type)
+	}
+	return nil
+}
+
+// GuildMemberEventHandler 用户加入、退出服务器，target_id 为服务器id，与频道消息的 guild_id 相同
+func (k *Kook) GuildMemberEventHandler(event *Event, eventType string, body *GuildMemberBody) error {
+	guild, err := guildId(event.TargetId)
+	if err != nil {
+		return err
+	}
+	user, err := userId(body.UserId)
+	if err != nil {
+		return err
+	}
+
+	notice := onebot.NoticeRequest{
+		MessageBase: onebot.MessageBase{
+			Time:     time.Now().Unix(),
+			SelfId:   k.SelfId,
+			PostType: onebot.NoticePost,
+		},
+		NoticeType: onebot.GuildMemberIncreaseNotice,
+		SubType:    onebot.Approve,
+		GuildId:    guild,
+		UserId:     user,
+		OperatorId: user,
+	}
+	if eventType == ExitedGuild {
+		notice.NoticeType = onebot.GuildMemberDecreaseNotice
+		notice.SubType = onebot.Leave
+	}
+
+	k.events <- notice
+	return nil
+}
+
+// ReactionEventHandler 频道及私聊消息的表情回应
+func (k *Kook) ReactionEventHandler(event *Event, eventType string, body *ReactionBody) error {
+	operator, err := userId(body.UserId)
+	if err != nil {
+		return err
+	}
+	msgId, err := messageId(body.MsgId)
+	if err != nil {
+		return err
+	}
+
+	notice := onebot.NoticeRequest{
+		MessageBase: onebot.MessageBase{
+			Time:     time.Now().Unix(),
+			SelfId:   k.SelfId,
+			PostType: onebot.NoticePost,
+		},
+		NoticeType: onebot.ReactionNotice,
+		SubType:    onebot.Add,
+		UserId:     operator,
+		OperatorId: operator,
+		MessageId:  msgId,
+		Code:       body.Emoji.Id,
+		Count:      1,
+	}
+	if eventType == DeletedReaction || eventType == PrivateDeletedReaction {
+		notice.SubType = onebot.Remove
+	}
+	if body.ChannelId != "" {
+		if notice.GroupId, err = groupId(body.ChannelId); err != nil {
+			return err
+		}
+	}
+
+	k.events <- notice
+	return nil
+}
@@ -0,0 +1,43 @@
+package kook
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+func TestGuildMemberNamespace(t *testing.T) {
+	resetStore()
+	k := NewKook(&config.Kook{})
+
+	err := k.MessageEventHandler(&Event{ChannelType: GroupChannel, Type: TextMessage, TargetId: "CHANNEL", AuthorId: "7", MsgId: "MSG", Content: "hi"},
+		&MessageExtra{GuildId: "GUILD", Author: User{Username: "用户"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := (<-k.events).(onebot.MessageRequest)
+	if err = k.GuildMemberEventHandler(&Event{TargetId: "GUILD"}, JoinedGuild, &GuildMemberBody{UserId: "7"}); err != nil {
+		t.Fatal(err)
+	}
+	notice := (<-k.events).(onebot.NoticeRequest)
+
+	// 服务器与频道的id分别分配，成员通知的 guild_id 与频道消息一致
+	if notice.NoticeType != onebot.GuildMemberIncreaseNotice || notice.GroupId != 0 || notice.GuildId == 0 || notice.GuildId != message.GuildId {
+		t.Fatalf("成员通知 %+v, 频道消息 guild_id=%d", notice, message.GuildId)
+	}
+	if raw, err := idmap.Raw(guildNamespace, notice.GuildId); err != nil || raw != "GUILD" {
+		t.Fatalf("服务器id %d 反查得到 %q err=%v", notice.GuildId, raw, err)
+	}
+	if raw, err := idmap.Raw(groupNamespace, int64(message.GroupId)); err != nil || raw != "CHANNEL" {
+		t.Fatalf("群号 %d 反查得到 %q err=%v", message.GroupId, raw, err)
+	}
+}
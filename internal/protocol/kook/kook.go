@@ -1,4 +1,164 @@
 package kook
 
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"GoQHttp/websocket/client"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// id 映射的命名空间，服务器与频道共用 group 命名空间
+//
+// Kook 用户id本身为数字，直接作为 user_id 使用；频道id超出 int32 范围，消息id为字符串，需要映射。
+var (
+	groupNamespace   = idmap.NewNamespace("kook", idmap.Group)
+	guildNamespace   = idmap.NewNamespace("kook", idmap.Guild)
+	messageNamespace = idmap.NewNamespace("kook", idmap.Message)
+)
+
+// Kook 机器人，将网关推送的事件转换为 OneBot 事件
 type Kook struct {
+	Config *config.Kook
+	Api    *Api
+	SelfId int64
+	// UserId 机器人在 Kook 的用户id
+	UserId string
+	// Nickname 机器人的用户名
+	Nickname string
+	// deduper webhook 模式下忽略重试投递的重复事件
+	deduper *protocol.EventDeduper
+	// client websocket 模式下的网关连接
+	client *client.KookClient
+	// raw 网关或回调接收的原始事件
+	raw chan json.RawMessage
+	// events 转换后的 OneBot 事件
+	events  chan onebot.Event
+	stopped atomic.Bool
+}
+
+var (
+	bots   = make(map[int64]*Kook)
+	botsMu sync.RWMutex
+)
+
+// NewKook 根据配置创建机器人
+func NewKook(bot *config.Kook) *Kook {
+	return &Kook{
+		Config: bot,
+		Api:    NewApi(bot.Token),
+		raw:    make(chan json.RawMessage, 100),
+		events: make(chan onebot.Event, 100),
+	}
+}
+
+// IsWebhook 是否通过 HTTP 回调接收事件
+func (k *Kook) IsWebhook() bool {
+	return k.Config.Mode == "webhook"
+}
+
+// Start 获取机器人信息并开始处理事件，按配置连接网关或注册回调地址
+func (k *Kook) Start() error {
+	if k.IsWebhook() && k.Config.VerifyToken == "" {
+		return fmt.Errorf("webhook 模式需要设置 verify_token")
+	}
+	me, err := k.Api.GetMe()
+	if err != nil {
+		return fmt.Errorf("获取机器人信息失败: %v", err)
+	}
+	k.UserId = me.Id
+	k.Nickname = me.Username
+	if k.SelfId, err = userId(me.Id); err != nil {
+		return err
+	}
+	logger.Infof("Kook机器人 %s#%s(%s) 已登录", me.Username, me.IdentifyNum, me.Id)
+	k.deduper = protocol.NewEventDeduper("kook:"+me.Id, 0)
+
+	botsMu.Lock()
+	bots[k.SelfId] = k
+	botsMu.Unlock()
+
+	go k.HandlerEvent()
+	if k.IsWebhook() {
+		protocol.HandleWebhook("Kook", k.Config.WebhookPath, k.WebhookHandler)
+		return nil
+	}
+	k.client = client.NewKookClient(k.Config.Token, true, k.raw)
+	go k.client.Connect()
+	return nil
+}
+
+// GetBot 获取 self_id 对应的机器人
+func GetBot(selfId int64) (*Kook, bool) {
+	botsMu.RLock()
+	defer botsMu.RUnlock()
+	bot, ok := bots[selfId]
+	return bot, ok
+}
+
+// HandlerEvent 处理网关推送的事件
+func (k *Kook) HandlerEvent() {
+	for raw := range k.raw {
+		event := &Event{}
+		if err := json.Unmarshal(raw, event); err != nil {
+			logger.Warnf("无法解析Kook事件: %v", err)
+			continue
+		}
+		if err := k.dispatch(event); err != nil {
+			logger.Warnf("Kook事件处理失败: %v", err)
+		}
+	}
+}
+
+// dispatch 根据事件类型处理事件
+func (k *Kook) dispatch(event *Event) error {
+	if event.Type == SystemMessage {
+		extra := &SystemExtra{}
+		if err := json.Unmarshal(event.Extra, extra); err != nil {
+			return err
+		}
+		return k.SystemEventHandler(event, extra)
+	}
+
+	// 忽略机器人自己发送的消息
+	if event.AuthorId == k.UserId {
+		return nil
+	}
+	extra := &MessageExtra{}
+	if err := json.Unmarshal(event.Extra, extra); err != nil {
+		return err
+	}
+	return k.MessageEventHandler(event, extra)
+}
+
+// groupId 获取频道对应的群号
+func groupId(rawId string) (int32, error) {
+	id, err := idmap.Id(groupNamespace, rawId)
+	return int32(id), err
+}
+
+// guildId 获取服务器对应的数字id，与频道的群号分开分配
+func guildId(rawId string) (int64, error) {
+	return idmap.Id(guildNamespace, rawId)
+}
+
+// userId 获取用户对应的数字id
+func userId(rawId string) (int64, error) {
+	id, err := strconv.ParseInt(rawId, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("用户id无效: %s", rawId)
+	}
+	return id, nil
+}
+
+// messageId 获取消息对应的数字id
+func messageId(rawId string) (int32, error) {
+	id, err := idmap.Id(messageNamespace, rawId)
+	return int32(id), err
 }
@@ -0,0 +1,206 @@
+package kook
+
+import (
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/kmarkdown"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Target 消息发送目标，Private 为 true 时 Id 为用户id，否则为频道id
+type Target struct {
+	Private bool
+	Id      string
+}
+
+// SendMessage 发送功能端下发的消息
+func (k *Kook) SendMessage(data *onebot.MessageRequest) {
+	target, err := targetOf(data.MessageType, data.GroupId, data.UserId)
+	if err != nil {
+		logger.Warnf("Kook消息发送失败: %v", err)
+		return
+	}
+	if _, err = k.Send(target, data.Message); err != nil {
+		logger.Warnf("Kook消息发送失败: %v", err)
+	}
+}
+
+// targetOf 根据 OneBot 参数获取发送目标，未指定消息类型时按 group_id 判断
+func targetOf(messageType onebot.MessageType, group int32, user int64) (*Target, error) {
+	if messageType == onebot.PrivateMessage || (messageType == "" && group == 0) {
+		if user == 0 {
+			return nil, errors.New("user_id 不能为空")
+		}
+		return &Target{Private: true, Id: strconv.FormatInt(user, 10)}, nil
+	}
+	channelId, err := idmap.Raw(groupNamespace, int64(group))
+	if err != nil {
+		return nil, fmt.Errorf("未找到群号 %d 对应的频道: %v", group, err)
+	}
+	return &Target{Id: channelId}, nil
+}
+
+// Send 发送消息，返回最后一条消息的 OneBot 消息id
+func (k *Kook) Send(target *Target, elements []*onebot.Element) (int32, error) {
+	messages, err := k.buildMessages(target, elements)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, errors.New("消息内容为空")
+	}
+
+	var msgId string
+	for _, message := range messages {
+		var result *MessageCreateResp
+		if target.Private {
+			result, err = k.Api.CreateDirectMessage(message)
+		} else {
+			result, err = k.Api.CreateMessage(message)
+		}
+		if err != nil {
+			return 0, err
+		}
+		msgId = result.MsgId
+	}
+	id, err := messageId(msgId)
+	if err != nil {
+		return 0, err
+	}
+	k.sent(target, elements, id)
+	return id, nil
+}
+
+// sent 通知消息发送成功，私聊目标为用户id，频道目标转换为群号
+func (k *Kook) sent(target *Target, elements []*onebot.Element, msgId int32) {
+	message := &onebot.MessageRequest{MessageId: msgId, Message: elements, RawMessage: onebot.CQString(elements)}
+	if target.Private {
+		message.MessageType = onebot.PrivateMessage
+		message.UserId, _ = strconv.ParseInt(target.Id, 10, 64)
+	} else {
+		group, err := groupId(target.Id)
+		if err != nil {
+			logger.Warnf("Kook频道 %s 映射失败: %v", target.Id, err)
+			return
+		}
+		message.MessageType = onebot.GroupMessage
+		message.GroupId = group
+	}
+	protocol.MessageSent(k.Identity(), message)
+}
+
+// buildMessages 将消息段转换为 Kook 消息
+//
+// 文本、@ 等转换为 KMarkdown，单独的媒体按对应类型发送，文本与媒体混合时合并为一条卡片消息。
+func (k *Kook) buildMessages(target *Target, elements []*onebot.Element) ([]*MessageCreate, error) {
+	var messages []*MessageCreate
+	var segments []*onebot.Element
+	quote := ""
+	flush := func() error {
+		message, err := buildMessage(segments)
+		if err != nil {
+			return err
+		}
+		if message != nil {
+			message.TargetId = target.Id
+			messages = append(messages, message)
+		}
+		segments = nil
+		return nil
+	}
+
+	for _, element := range elements {
+		switch element.ElementType {
+		case onebot.ReplyType:
+			id, err := strconv.ParseInt(element.Field("id"), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("回复消息id无效: %s", element.Field("id"))
+			}
+			if quote, err = idmap.Raw(messageNamespace, id); err != nil {
+				return nil, fmt.Errorf("未找到消息 %d: %v", id, err)
+			}
+		case onebot.ImageType, onebot.VideoType, onebot.RecordType, onebot.FileType:
+			file := element.Field("file")
+			if file == "" {
+				file = element.Field("url")
+			}
+			url, err := k.upload(file)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, &onebot.Element{
+				ElementType: element.ElementType,
+				Data:        map[string]string{"url": url, "name": element.Field("name")},
+			})
+		case onebot.JsonType:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			messages = append(messages, &MessageCreate{Type: CardMessage, TargetId: target.Id, Content: element.Field("data")})
+		default:
+			if !kmarkdown.Inline(element.ElementType) {
+				logger.Warnf("暂不支持的消息类型: %s", element.ElementType)
+				continue
+			}
+			segments = append(segments, element)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if quote != "" && len(messages) > 0 {
+		messages[0].Quote = quote
+	}
+	return messages, nil
+}
+
+// buildMessage 将连续的消息段转换为一条消息，内容为空时返回 nil
+func buildMessage(segments []*onebot.Element) (*MessageCreate, error) {
+	var media []*onebot.Element
+	for _, segment := range segments {
+		if !kmarkdown.Inline(segment.ElementType) {
+			media = append(media, segment)
+		}
+	}
+	content := kmarkdown.Build(segments)
+	switch {
+	case len(media) == 0 && strings.TrimSpace(content) == "":
+		return nil, nil
+	case len(media) == 0:
+		return &MessageCreate{Type: KMarkdownMessage, Content: content}, nil
+	case len(media) == 1 && strings.TrimSpace(content) == "":
+		return &MessageCreate{Type: mediaTypes[media[0].ElementType], Content: media[0].Field("url")}, nil
+	}
+	card, err := kmarkdown.BuildCard(segments)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageCreate{Type: CardMessage, Content: card}, nil
+}
+
+// mediaTypes 媒体消息段对应的 Kook 消息类型
+var mediaTypes = map[onebot.ElementType]MessageType{
+	onebot.ImageType:  ImageMessage,
+	onebot.VideoType:  VideoMessage,
+	onebot.RecordType: AudioMessage,
+	onebot.FileType:   FileMessage,
+}
+
+// upload 读取媒体内容并上传到 Kook
+func (k *Kook) upload(file string) (string, error) {
+	name, data, err := protocol.ReadMedia(file)
+	if err != nil {
+		return "", err
+	}
+	url, err := k.Api.CreateAsset(name, data)
+	if err != nil {
+		return "", fmt.Errorf("媒体上传失败: %v", err)
+	}
+	return url, nil
+}
@@ -0,0 +1,170 @@
+package kook
+
+import (
+	"GoQHttp/logger"
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxWebhookBody 回调请求体大小上限
+const maxWebhookBody = 1 << 20
+
+// challengeChannel 回调地址校验请求的 channel_type
+const challengeChannel = "WEBHOOK_CHALLENGE"
+
+// webhookPayload 解密后的回调内容，d 与网关推送的事件相同，额外携带 verify_token
+type webhookPayload struct {
+	Data json.RawMessage `json:"d"`
+}
+
+// webhookData 回调事件中用于校验与去重的字段
+type webhookData struct {
+	ChannelType string `json:"channel_type"`
+	VerifyToken string `json:"verify_token"`
+	Challenge   string `json:"challenge"`
+	MsgId       string `json:"msg_id"`
+}
+
+// WebhookHandler 处理 Kook 的 HTTP 回调：解压、解密、校验 verify_token 后进入事件处理
+func (k *Kook) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+	if k.stopped.Load() {
+		http.Error(w, "机器人已停止", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "无法读取请求体", http.StatusBadRequest)
+		return
+	}
+	payload, err := k.decodeWebhook(body)
+	if err != nil {
+		logger.Warnf("Kook回调解析失败: %v", err)
+		http.Error(w, "无法解析请求", http.StatusBadRequest)
+		return
+	}
+
+	var data webhookData
+	if err = json.Unmarshal(payload.Data, &data); err != nil {
+		http.Error(w, "无法解析请求", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(data.VerifyToken), []byte(k.Config.VerifyToken)) != 1 {
+		logger.Warnf("Kook回调 verify_token 不相符")
+		http.Error(w, "verify_token 不相符", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if data.ChannelType == challengeChannel {
+		logger.Infof("Kook回调地址校验")
+		_ = json.NewEncoder(w).Encode(map[string]string{"challenge": data.Challenge})
+		return
+	}
+
+	// 回调失败时 Kook 会重试，重复投递的事件只确认不再分发
+	if k.deduper.Mark(data.MsgId) {
+		k.raw <- payload.Data
+	} else {
+		logger.Debugf("忽略重复的Kook事件: %s", data.MsgId)
+	}
+	_, _ = w.Write([]byte("{}"))
+}
+
+// decodeWebhook 解压并解密回调内容，未开启压缩或加密时原样解析
+func (k *Kook) decodeWebhook(body []byte) (*webhookPayload, error) {
+	if len(body) > 0 && body[0] != '{' {
+		reader, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("解压失败: %v", err)
+		}
+		body, err = io.ReadAll(io.LimitReader(reader, maxWebhookBody))
+		_ = reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解压失败: %v", err)
+		}
+	}
+
+	var encrypted struct {
+		Encrypt string `json:"encrypt"`
+	}
+	if err := json.Unmarshal(body, &encrypted); err != nil {
+		return nil, err
+	}
+	switch {
+	case encrypted.Encrypt != "" && k.Config.EncryptKey == "":
+		return nil, errors.New("回调内容已加密，但未配置 encrypt_key")
+	case encrypted.Encrypt == "" && k.Config.EncryptKey != "":
+		return nil, errors.New("已配置 encrypt_key，但回调内容未加密")
+	case encrypted.Encrypt != "":
+		var err error
+		if body, err = decrypt(encrypted.Encrypt, k.Config.EncryptKey); err != nil {
+			return nil, fmt.Errorf("解密失败: %v", err)
+		}
+	}
+
+	payload := &webhookPayload{}
+	if err := json.Unmarshal(body, payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Data) == 0 {
+		return nil, errors.New("缺少事件内容")
+	}
+	return payload, nil
+}
+
+// decrypt 解密回调内容
+//
+// encrypt 经 base64 解码后前 16 字节为 iv，其余为 base64 编码的密文；
+// 密钥为 encrypt_key 右侧补 \0 至 32 字节，算法为 AES-256-CBC，PKCS#7 填充。
+func decrypt(encrypt string, encryptKey string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) <= aes.BlockSize {
+		return nil, errors.New("密文长度不足")
+	}
+	iv := decoded[:aes.BlockSize]
+	ciphertext, err := base64.StdEncoding.DecodeString(string(decoded[aes.BlockSize:]))
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("密文长度无效")
+	}
+
+	key := make([]byte, 32)
+	copy(key, encryptKey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(plaintext) {
+		return nil, errors.New("填充无效")
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("填充无效")
+		}
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
@@ -0,0 +1,141 @@
+package kook
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/utils"
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testEncrypt 按 Kook 的方式加密回调内容
+func testEncrypt(t *testing.T, plaintext []byte, encryptKey string) string {
+	t.Helper()
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	return encryptBlocks(t, append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...), encryptKey)
+}
+
+// encryptBlocks 加密长度为 16 的倍数的明文，不做填充，用于构造无效填充
+func encryptBlocks(t *testing.T, plaintext []byte, encryptKey string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	copy(key, encryptKey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := []byte("fedcba9876543210")
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	return base64.StdEncoding.EncodeToString(append(iv, base64.StdEncoding.EncodeToString(ciphertext)...))
+}
+
+func TestDecrypt(t *testing.T) {
+	// openssl enc -aes-256-cbc 生成，密钥 kookkey 补 \0 至 32 字节
+	known := "MDEyMzQ1Njc4OWFiY2RlZldJMDRtNS92NEoybW9xR3lBL0labm1sWWMzelBUaEhoSDVYMTRGUjNDbm80b25IODNDZm1sY0M3cjEzZlpZNnk="
+	if plaintext, err := decrypt(known, "kookkey"); err != nil || string(plaintext) != `{"s":0,"d":{"verify_token":"TOKEN"}}` {
+		t.Fatalf("解密得到 %q err=%v", plaintext, err)
+	}
+	if plaintext, err := decrypt(testEncrypt(t, []byte("0123456789abcdef"), "kookkey"), "kookkey"); err != nil || string(plaintext) != "0123456789abcdef" {
+		t.Fatalf("整块明文解密得到 %q err=%v", plaintext, err)
+	}
+
+	for _, c := range []struct {
+		name    string
+		encrypt string
+	}{
+		{"填充为 0", encryptBlocks(t, append(bytes.Repeat([]byte("a"), 15), 0), "kookkey")},
+		{"填充超过块大小", encryptBlocks(t, append(bytes.Repeat([]byte("a"), 15), 17), "kookkey")},
+		{"填充字节不一致", encryptBlocks(t, append(bytes.Repeat([]byte("a"), 15), 3), "kookkey")},
+		{"密文长度无效", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef" + base64.StdEncoding.EncodeToString([]byte("short"))))},
+		{"密文长度不足", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))},
+		{"不是 base64", "!!!"},
+	} {
+		if plaintext, err := decrypt(c.encrypt, "kookkey"); err == nil {
+			t.Fatalf("%s 应返回错误, 得到 %q", c.name, plaintext)
+		}
+	}
+}
+
+// newWebhookKook 创建未启动处理协程的 webhook 模式机器人，事件保留在 raw 中
+func newWebhookKook(encryptKey string) *Kook {
+	utils.Store = utils.NewMemoryStorage()
+	k := NewKook(&config.Kook{Mode: "webhook", VerifyToken: "TOKEN", EncryptKey: encryptKey})
+	k.deduper = protocol.NewEventDeduper("kook:test", 0)
+	return k
+}
+
+// postWebhook 回调并返回状态码与返回内容
+func postWebhook(k *Kook, body []byte) (int, string) {
+	recorder := httptest.NewRecorder()
+	k.WebhookHandler(recorder, httptest.NewRequest(http.MethodPost, "/kook", bytes.NewReader(body)))
+	return recorder.Code, strings.TrimSpace(recorder.Body.String())
+}
+
+func compress(t *testing.T, data string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zlib.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestWebhookHandler(t *testing.T) {
+	event := `{"s":0,"d":{"channel_type":"GROUP","type":1,"msg_id":"MSG1","verify_token":"TOKEN"}}`
+	k := newWebhookKook("")
+	for _, c := range []struct {
+		name string
+		body []byte
+		code int
+		resp string
+	}{
+		{"未压缩", []byte(event), http.StatusOK, "{}"},
+		{"zlib 压缩且重复投递", compress(t, event), http.StatusOK, "{}"},
+		{"校验请求", compress(t, `{"s":0,"d":{"channel_type":"WEBHOOK_CHALLENGE","challenge":"CHALLENGE","verify_token":"TOKEN"}}`), http.StatusOK, `{"challenge":"CHALLENGE"}`},
+		{"verify_token 错误", []byte(strings.Replace(event, `"TOKEN"`, `"WRONG"`, 1)), http.StatusUnauthorized, "verify_token 不相符"},
+		{"无法解压", []byte("\x78\x9cbroken"), http.StatusBadRequest, "无法解析请求"},
+		{"缺少事件内容", []byte(`{"s":0}`), http.StatusBadRequest, "无法解析请求"},
+		{"未配置 encrypt_key", []byte(`{"encrypt":"` + testEncrypt(t, []byte(event), "kookkey") + `"}`), http.StatusBadRequest, "无法解析请求"},
+	} {
+		if code, resp := postWebhook(k, c.body); code != c.code || resp != c.resp {
+			t.Fatalf("%s 返回 %d %q, 期望 %d %q", c.name, code, resp, c.code, c.resp)
+		}
+	}
+	// 重复投递的事件只分发一次
+	if len(k.raw) != 1 {
+		t.Fatalf("分发 %d 条事件, 期望 1", len(k.raw))
+	}
+
+	recorder := httptest.NewRecorder()
+	k.WebhookHandler(recorder, httptest.NewRequest(http.MethodGet, "/kook", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET 请求返回 %d", recorder.Code)
+	}
+}
+
+func TestWebhookEncrypted(t *testing.T) {
+	k := newWebhookKook("kookkey")
+	event := `{"s":0,"d":{"channel_type":"GROUP","type":1,"msg_id":"MSG1","verify_token":"TOKEN"}}`
+	body := compress(t, `{"encrypt":"`+testEncrypt(t, []byte(event), "kookkey")+`"}`)
+	if code, resp := postWebhook(k, body); code != http.StatusOK || resp != "{}" || len(k.raw) != 1 {
+		t.Fatalf("加密回调返回 %d %q, 分发 %d 条事件", code, resp, len(k.raw))
+	}
+	// 已配置 encrypt_key 时拒绝未加密及密钥不同的回调
+	for _, body := range [][]byte{[]byte(event), []byte(`{"encrypt":"` + testEncrypt(t, []byte(event), "other") + `"}`)} {
+		if code, _ := postWebhook(k, body); code != http.StatusBadRequest {
+			t.Fatalf("回调 %q 返回 %d, 期望 400", body, code)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxMediaSize 下载网络图片及文件的大小上限
+const maxMediaSize = 30 << 20
+
+// ReadMedia 读取 base64://、file:// 及网络地址的媒体内容，返回文件名与内容
+func ReadMedia(file string) (string, []byte, error) {
+	switch {
+	case strings.HasPrefix(file, "base64://"):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(file, "base64://"))
+		if err != nil {
+			return "", nil, fmt.Errorf("base64 解码失败: %v", err)
+		}
+		return "file", data, nil
+	case strings.HasPrefix(file, "file://"):
+		filePath := strings.TrimPrefix(file, "file://")
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", nil, fmt.Errorf("读取文件失败: %v", err)
+		}
+		return path.Base(filePath), data, nil
+	case strings.HasPrefix(file, "http://"), strings.HasPrefix(file, "https://"):
+		resp, err := http.Get(file)
+		if err != nil {
+			return "", nil, fmt.Errorf("下载文件失败: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", nil, fmt.Errorf("下载文件失败: status %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+		if err != nil {
+			return "", nil, fmt.Errorf("下载文件失败: %v", err)
+		}
+		if len(data) > maxMediaSize {
+			return "", nil, errors.New("文件过大")
+		}
+		return path.Base(resp.Request.URL.Path), data, nil
+	default:
+		return "", nil, fmt.Errorf("不支持的文件地址: %s", file)
+	}
+}
@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"GoQHttp/internal/constant"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"encoding/json"
+	"fmt"
+)
+
+// actions Telegram 机器人支持的动作
+var actions = protocol.Actions{
+	"send_msg":         SendMsgAction,
+	"send_group_msg":   SendGroupMsgAction,
+	"send_private_msg": SendPrivateMsgAction,
+	"delete_msg":       DeleteMsgAction,
+	"update_msg":       UpdateMsgAction,
+}
+
+// SendMsgParams send_msg、send_group_msg、send_private_msg 参数
+type SendMsgParams struct {
+	MessageType onebot.MessageType `json:"message_type"`
+	GroupId     int32              `json:"group_id"`
+	UserId      int64              `json:"user_id"`
+	Message     json.RawMessage    `json:"message"`
+	AutoEscape  bool               `json:"auto_escape"`
+}
+
+// MessageIdParams delete_msg 参数
+type MessageIdParams struct {
+	MessageId int32 `json:"message_id"`
+}
+
+// UpdateMsgParams update_msg 参数
+type UpdateMsgParams struct {
+	MessageId int32           `json:"message_id"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// botFor 获取发起动作的连接所对应的机器人
+func botFor(selfId int64) (*Telegram, error) {
+	bot, ok := GetBot(selfId)
+	if !ok {
+		return nil, fmt.Errorf("%w: 未找到 self_id 为 %d 的Telegram机器人", protocol.ErrBotNotFound, selfId)
+	}
+	return bot, nil
+}
+
+// parseMessage 解析 message 参数，auto_escape 时CQ码作为纯文本发送
+func parseMessage(raw json.RawMessage, autoEscape bool) ([]*onebot.Element, error) {
+	var text string
+	if autoEscape && json.Unmarshal(raw, &text) == nil {
+		return []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: text}}}, nil
+	}
+	return constant.CQCode.ParseMessage(raw)
+}
+
+// send 发送消息并返回消息id
+func send(selfId int64, messageType onebot.MessageType, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p SendMsgParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if messageType == "" {
+		messageType = p.MessageType
+	}
+	chatId, err := targetOf(messageType, p.GroupId, p.UserId)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := parseMessage(p.Message, p.AutoEscape)
+	if err != nil {
+		return nil, err
+	}
+	msgId, err := bot.Send(chatId, elements)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int32{"message_id": msgId}, nil
+}
+
+// SendMsgAction 发送消息，未指定 message_type 时按 group_id 判断
+func SendMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	return send(selfId, "", params)
+}
+
+// SendGroupMsgAction 发送群组消息
+func SendGroupMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	return send(selfId, onebot.GroupMessage, params)
+}
+
+// SendPrivateMsgAction 发送私聊消息
+func SendPrivateMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	return send(selfId, onebot.PrivateMessage, params)
+}
+
+// DeleteMsgAction 删除消息
+func DeleteMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p MessageIdParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	chatId, msgId, err := messageOf(p.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	return nil, bot.Api.DeleteMessage(chatId, msgId)
+}
+
+// UpdateMsgAction 编辑消息内容，仅支持文本与 @
+func UpdateMsgAction(selfId int64, params json.RawMessage) (any, error) {
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	var p UpdateMsgParams
+	if err = json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	chatId, msgId, err := messageOf(p.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := constant.CQCode.ParseMessage(p.Message)
+	if err != nil {
+		return nil, err
+	}
+	text, err := buildText(elements)
+	if err != nil {
+		return nil, err
+	}
+	return nil, bot.Api.EditMessageText(chatId, msgId, text)
+}
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/profile"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"encoding/json"
+	"strconv"
+)
+
+func init() {
+	protocol.RegisterPlatform("Telegram", newBots)
+	profile.RegisterResolver("telegram", resolveProfile)
+}
+
+// newBots 根据配置创建 Telegram 机器人
+func newBots(cfg *config.Config) []*protocol.Bot {
+	bot := &cfg.Bot.Telegram
+	if !bot.Enable {
+		return nil
+	}
+	if bot.Token == "" {
+		logger.Warnf("Telegram机器人启动失败,请检查设置")
+		return nil
+	}
+	return []*protocol.Bot{{Adapter: NewTelegram(bot), Channels: bot.Channels}}
+}
+
+// Identity 机器人身份
+func (t *Telegram) Identity() protocol.Identity {
+	return protocol.Identity{
+		Platform: "telegram",
+		SelfId:   t.SelfId,
+		UserId:   strconv.FormatInt(t.SelfId, 10),
+		Nickname: t.Username,
+	}
+}
+
+// Stop 停止长轮询，webhook 模式下之后的回调不再处理
+func (t *Telegram) Stop() error {
+	if t.stopped.Swap(true) {
+		return nil
+	}
+	close(t.stop)
+	botsMu.Lock()
+	delete(bots, t.SelfId)
+	botsMu.Unlock()
+	return nil
+}
+
+// Events 转换后的 OneBot 事件
+func (t *Telegram) Events() <-chan onebot.Event {
+	return t.events
+}
+
+// Call 执行 OneBot 动作
+func (t *Telegram) Call(action string, params json.RawMessage) (any, error) {
+	return actions.Call(t.SelfId, action, params)
+}
+
+// Capabilities 支持的 OneBot 动作
+func (t *Telegram) Capabilities() []string {
+	return actions.Names()
+}
+
+// resolveProfile 获取群组成员的昵称与角色，机器人无法获取私聊用户的资料
+func resolveProfile(selfId int64, groupId int32, userId int64) (*onebot.Sender, error) {
+	if groupId == 0 {
+		return nil, nil
+	}
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	chatId, err := groupChat(groupId)
+	if err != nil {
+		return nil, err
+	}
+	member, err := bot.Api.GetChatMember(chatId, userId)
+	if err != nil {
+		return nil, err
+	}
+	sender := &onebot.Sender{UserId: userId, Role: onebot.Member, Title: member.CustomTitle}
+	if member.User != nil {
+		sender.NickName = member.User.Name()
+	}
+	switch member.Status {
+	case CreatorMember:
+		sender.Role = onebot.Owner
+	case AdministratorMember:
+		sender.Role = onebot.Admin
+	}
+	return sender, nil
+}
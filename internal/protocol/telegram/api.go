@@ -0,0 +1,243 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Api Telegram Bot API
+type Api struct {
+	Token   string
+	BaseUrl string
+	client  *http.Client
+}
+
+// APIError Bot API 返回的错误信息
+type APIError struct {
+	Status      int
+	Code        int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram api error: status=%d code=%d description=%s", e.Status, e.Code, e.Description)
+}
+
+// response Bot API 统一的返回结构
+type response struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// NewApi 创建 Bot API，baseUrl 为空时使用官方地址
+func NewApi(token string, baseUrl string) *Api {
+	if baseUrl == "" {
+		baseUrl = "https://api.telegram.org"
+	}
+	return &Api{
+		Token:   token,
+		BaseUrl: baseUrl,
+		client:  &http.Client{},
+	}
+}
+
+// doRequest 以 JSON 调用接口，ok 为 false 时返回 *APIError
+func (a *Api) doRequest(method string, params any, result any, timeout time.Duration) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal request error: %v", err)
+	}
+	r, err := http.NewRequest(http.MethodPost, a.methodUrl(method), bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("create request error: %v", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	return a.do(r, result, timeout)
+}
+
+// do 发送请求并解析返回结构中的 result
+func (a *Api) do(r *http.Request, result any, timeout time.Duration) error {
+	client := *a.client
+	client.Timeout = timeout
+	resp, err := client.Do(r)
+	if err != nil {
+		// 请求地址中包含 token，避免写入日志
+		return fmt.Errorf("send request error: %s", strings.ReplaceAll(err.Error(), a.Token, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %v", err)
+	}
+	var res response
+	if err = json.Unmarshal(body, &res); err != nil {
+		return &APIError{Status: resp.StatusCode, Description: string(body)}
+	}
+	if !res.Ok {
+		return &APIError{Status: resp.StatusCode, Code: res.ErrorCode, Description: res.Description}
+	}
+	if result != nil && len(res.Result) > 0 {
+		if err = json.Unmarshal(res.Result, result); err != nil {
+			return fmt.Errorf("unmarshal error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (a *Api) methodUrl(method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", a.BaseUrl, a.Token, method)
+}
+
+// Download 下载 getFile 返回的 file_path 对应的文件，下载地址包含 token，不能提供给功能端
+func (a *Api) Download(filePath string) (*http.Response, error) {
+	client := *a.client
+	client.Timeout = time.Minute
+	resp, err := client.Get(fmt.Sprintf("%s/file/bot%s/%s", a.BaseUrl, a.Token, filePath))
+	if err != nil {
+		return nil, fmt.Errorf("download error: %s", strings.ReplaceAll(err.Error(), a.Token, "<token>"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, &APIError{Status: resp.StatusCode, Description: "download failed"}
+	}
+	return resp, nil
+}
+
+// GetMe 获取机器人自身的信息
+func (a *Api) GetMe() (*User, error) {
+	var user *User
+	if err := a.doRequest("getMe", struct{}{}, &user, 10*time.Second); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUpdates 长轮询获取更新，timeout 为服务端等待的秒数
+func (a *Api) GetUpdates(offset int64, timeout int) ([]*Update, error) {
+	params := map[string]any{
+		"offset":          offset,
+		"timeout":         timeout,
+		"allowed_updates": []string{"message"},
+	}
+	var updates []*Update
+	if err := a.doRequest("getUpdates", params, &updates, time.Duration(timeout+10)*time.Second); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SetWebhook 设置回调地址，secretToken 会在回调请求头 X-Telegram-Bot-Api-Secret-Token 中返回
+func (a *Api) SetWebhook(url string, secretToken string) error {
+	params := map[string]any{
+		"url":             url,
+		"secret_token":    secretToken,
+		"allowed_updates": []string{"message"},
+	}
+	return a.doRequest("setWebhook", params, nil, 30*time.Second)
+}
+
+// GetFile 获取文件信息，返回的 file_path 用于拼接下载地址
+func (a *Api) GetFile(fileId string) (*File, error) {
+	var file *File
+	if err := a.doRequest("getFile", map[string]string{"file_id": fileId}, &file, 10*time.Second); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetChatMember 获取群组成员信息
+func (a *Api) GetChatMember(chatId int64, userId int64) (*ChatMember, error) {
+	var member *ChatMember
+	params := map[string]any{"chat_id": chatId, "user_id": userId}
+	if err := a.doRequest("getChatMember", params, &member, 10*time.Second); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// SendMessageParams sendMessage 参数，文本使用 HTML 格式
+type SendMessageParams struct {
+	ChatId           int64  `json:"chat_id"`
+	Text             string `json:"text"`
+	ParseMode        string `json:"parse_mode,omitempty"`
+	ReplyToMessageId int64  `json:"reply_to_message_id,omitempty"`
+}
+
+// SendMessage 发送文本消息
+func (a *Api) SendMessage(params *SendMessageParams) (*Message, error) {
+	var message *Message
+	if err := a.doRequest("sendMessage", params, &message, 30*time.Second); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// InputFile 待发送的媒体，Data 为空时 Url 为网络地址或 file_id，由 Telegram 自行获取
+type InputFile struct {
+	Url  string
+	Name string
+	Data []byte
+}
+
+// SendMedia 发送图片、语音、视频或文件，method 为 sendPhoto 等，field 为对应的参数名
+func (a *Api) SendMedia(method string, field string, chatId int64, file *InputFile, replyTo int64) (*Message, error) {
+	var message *Message
+	if file.Data == nil {
+		params := map[string]any{"chat_id": chatId, field: file.Url}
+		if replyTo != 0 {
+			params["reply_to_message_id"] = replyTo
+		}
+		if err := a.doRequest(method, params, &message, 60*time.Second); err != nil {
+			return nil, err
+		}
+		return message, nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("chat_id", fmt.Sprint(chatId))
+	if replyTo != 0 {
+		_ = writer.WriteField("reply_to_message_id", fmt.Sprint(replyTo))
+	}
+	fileWriter, err := writer.CreateFormFile(field, file.Name)
+	if err != nil {
+		return nil, fmt.Errorf("创建表单文件字段失败: %v", err)
+	}
+	if _, err = fileWriter.Write(file.Data); err != nil {
+		return nil, fmt.Errorf("写入文件数据失败: %v", err)
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, a.methodUrl(method), body)
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %v", err)
+	}
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	if err = a.do(r, &message, 120*time.Second); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// EditMessageText 编辑文本消息
+func (a *Api) EditMessageText(chatId int64, messageId int64, text string) error {
+	params := map[string]any{"chat_id": chatId, "message_id": messageId, "text": text, "parse_mode": "HTML"}
+	return a.doRequest("editMessageText", params, nil, 30*time.Second)
+}
+
+// DeleteMessage 删除消息
+func (a *Api) DeleteMessage(chatId int64, messageId int64) error {
+	params := map[string]any{"chat_id": chatId, "message_id": messageId}
+	return a.doRequest("deleteMessage", params, nil, 30*time.Second)
+}
@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSelfId = 42
+
+// apiCall 模拟 Bot API 收到的一次调用，参数值统一转为字符串
+type apiCall struct {
+	method string
+	params map[string]string
+}
+
+// fakeApi 模拟 Bot API，通过 getUpdates 下发更新并记录其他调用
+type fakeApi struct {
+	mu      sync.Mutex
+	updates []*Update
+	calls   []apiCall
+	nextId  int64
+}
+
+// push 加入待下发的更新
+func (f *fakeApi) push(updates ...*Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, updates...)
+}
+
+// recorded 获取调用的接口与参数，getMe 与 getUpdates 除外
+func (f *fakeApi) recorded() []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]apiCall(nil), f.calls...)
+}
+
+func (f *fakeApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := path.Base(r.URL.Path)
+	params := make(map[string]string)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for key, values := range r.MultipartForm.Value {
+			params[key] = values[0]
+		}
+		for key, files := range r.MultipartForm.File {
+			params[key] = "upload:" + files[0].Filename
+		}
+	} else {
+		var body map[string]any
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for key, value := range body {
+			params[key] = fmt.Sprint(value)
+		}
+	}
+
+	var result any
+	switch method {
+	case "getMe":
+		result = &User{Id: testSelfId, IsBot: true, FirstName: "Test", Username: "test_bot"}
+	case "getUpdates":
+		f.mu.Lock()
+		var offset int64
+		_, _ = fmt.Sscan(params["offset"], &offset)
+		updates := make([]*Update, 0)
+		for _, update := range f.updates {
+			if update.UpdateId >= offset {
+				updates = append(updates, update)
+			}
+		}
+		f.mu.Unlock()
+		if len(updates) == 0 {
+			// 模拟长轮询，避免空转
+			time.Sleep(10 * time.Millisecond)
+		}
+		result = updates
+	case "deleteMessage", "editMessageText":
+		f.record(method, params)
+		result = true
+	default:
+		f.record(method, params)
+		f.mu.Lock()
+		f.nextId++
+		result = &Message{MessageId: f.nextId}
+		f.mu.Unlock()
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func (f *fakeApi) record(method string, params map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, apiCall{method: method, params: params})
+}
+
+// startFakeBot 使用新的存储启动长轮询模式的机器人
+func startFakeBot(t *testing.T) (*Telegram, *fakeApi) {
+	t.Helper()
+	utils.Store = utils.NewMemoryStorage()
+	idmap.Init(config.IdMapping{Mode: idmap.SequenceMode})
+	api := &fakeApi{}
+	bot := newTestBot(t, api.ServeHTTP)
+	if err := bot.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bot.Stop() })
+	return bot, api
+}
@@ -0,0 +1,115 @@
+package telegram
+
+// ChatType 会话类型
+type ChatType string
+
+const (
+	PrivateChat    ChatType = "private"
+	GroupChat      ChatType = "group"
+	SupergroupChat ChatType = "supergroup"
+	ChannelChat    ChatType = "channel"
+)
+
+// 消息实体类型
+const (
+	MentionEntity     = "mention"
+	TextMentionEntity = "text_mention"
+	TextLinkEntity    = "text_link"
+)
+
+// Update getUpdates 及 webhook 推送的更新
+type Update struct {
+	UpdateId int64    `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+// User 用户或机器人
+type User struct {
+	Id        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// Name 用户的显示名称
+func (u *User) Name() string {
+	if u.LastName == "" {
+		return u.FirstName
+	}
+	return u.FirstName + " " + u.LastName
+}
+
+// ChatMemberStatus 群组成员状态
+type ChatMemberStatus string
+
+const (
+	CreatorMember       ChatMemberStatus = "creator"
+	AdministratorMember ChatMemberStatus = "administrator"
+)
+
+// ChatMember 群组成员
+type ChatMember struct {
+	Status      ChatMemberStatus `json:"status"`
+	User        *User            `json:"user"`
+	CustomTitle string           `json:"custom_title,omitempty"`
+}
+
+// Chat 私聊、群组或频道
+type Chat struct {
+	Id    int64    `json:"id"`
+	Type  ChatType `json:"type"`
+	Title string   `json:"title,omitempty"`
+}
+
+// Message 消息
+type Message struct {
+	MessageId       int64            `json:"message_id"`
+	From            *User            `json:"from,omitempty"`
+	Chat            *Chat            `json:"chat"`
+	Date            int64            `json:"date"`
+	ReplyToMessage  *Message         `json:"reply_to_message,omitempty"`
+	Text            string           `json:"text,omitempty"`
+	Entities        []*MessageEntity `json:"entities,omitempty"`
+	Caption         string           `json:"caption,omitempty"`
+	CaptionEntities []*MessageEntity `json:"caption_entities,omitempty"`
+	Photo           []*PhotoSize     `json:"photo,omitempty"`
+	Voice           *File            `json:"voice,omitempty"`
+	Audio           *File            `json:"audio,omitempty"`
+	Video           *File            `json:"video,omitempty"`
+	Document        *File            `json:"document,omitempty"`
+	Sticker         *Sticker         `json:"sticker,omitempty"`
+}
+
+// MessageEntity 文本中的实体，offset 与 length 以 UTF-16 码元计
+type MessageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Url    string `json:"url,omitempty"`
+	User   *User  `json:"user,omitempty"`
+}
+
+// PhotoSize 图片的一种尺寸
+type PhotoSize struct {
+	FileId   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int64  `json:"file_size,omitempty"`
+}
+
+// File 语音、音频、视频及文件，同时用于 getFile 的返回
+type File struct {
+	FileId   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+}
+
+// Sticker 贴纸
+type Sticker struct {
+	FileId     string `json:"file_id"`
+	Emoji      string `json:"emoji,omitempty"`
+	IsAnimated bool   `json:"is_animated"`
+	IsVideo    bool   `json:"is_video"`
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"GoQHttp/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 文件代理
+//
+// Bot API 的文件下载地址包含机器人 token，不能出现在发给功能端、写入消息记录或转发到其他平台的内容中。
+// 配置 file_proxy_url 时媒体消息段的 url 指向本服务，收到请求后通过 getFile 获取文件路径再向 Telegram 下载。
+
+// fileUrl 媒体消息段的 url，未配置文件代理时为空，file 字段仍保留 file_id 可用于发送
+func (t *Telegram) fileUrl(fileId string) string {
+	if t.Config.FileProxyUrl == "" || fileId == "" {
+		return ""
+	}
+	return strings.TrimSuffix(t.Config.FileProxyUrl, "/") + "/" + url.PathEscape(fileId)
+}
+
+// fileHandler 处理 prefix 下的文件请求，路径的最后一段为 file_id
+func (t *Telegram) fileHandler(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+			return
+		}
+		fileId := strings.TrimPrefix(r.URL.Path, prefix)
+		if fileId == "" || strings.Contains(fileId, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		file, err := t.Api.GetFile(fileId)
+		if err != nil || file.FilePath == "" {
+			logger.Warnf("Telegram获取文件 %s 失败: %v", fileId, err)
+			http.NotFound(w, r)
+			return
+		}
+		resp, err := t.Api.Download(file.FilePath)
+		if err != nil {
+			logger.Warnf("Telegram下载文件 %s 失败: %v", fileId, err)
+			http.Error(w, "下载文件失败", http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		for _, header := range []string{"Content-Type", "Content-Length", "Last-Modified"} {
+			if value := resp.Header.Get(header); value != "" {
+				w.Header().Set(header, value)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = io.Copy(w, resp.Body)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "123:SECRET"
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+// newTestBot 创建指向模拟 Bot API 的机器人
+func newTestBot(t *testing.T, handler http.HandlerFunc, options ...func(bot *config.Telegram)) *Telegram {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	bot := &config.Telegram{Token: testToken, ApiUrl: server.URL}
+	for _, option := range options {
+		option(bot)
+	}
+	return NewTelegram(bot)
+}
+
+func TestFileUrlHidesToken(t *testing.T) {
+	api := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot" + testToken + "/getFile":
+			if body, _ := io.ReadAll(r.Body); !strings.Contains(string(body), `"PHOTO"`) {
+				_, _ = io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: invalid file_id"}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"ok":true,"result":{"file_id":"PHOTO","file_path":"photos/1.jpg"}}`)
+		case "/file/bot" + testToken + "/photos/1.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = io.WriteString(w, "jpeg")
+		default:
+			http.NotFound(w, r)
+		}
+	}
+
+	// 未配置文件代理时 url 留空
+	bot := newTestBot(t, api)
+	elements := bot.parseMedia(&Message{Photo: []*PhotoSize{{FileId: "PHOTO"}}})
+	if len(elements) != 1 || elements[0].Field("url") != "" || elements[0].Field("file") != "PHOTO" {
+		t.Fatalf("消息段 %+v", elements)
+	}
+
+	bot = newTestBot(t, api, func(bot *config.Telegram) { bot.FileProxyUrl = "https://example.com/telegram/file/" })
+	elements = bot.parseMedia(&Message{Photo: []*PhotoSize{{FileId: "PHOTO"}}})
+	if url := elements[0].Field("url"); url != "https://example.com/telegram/file/PHOTO" {
+		t.Fatalf("代理地址 %q", url)
+	}
+
+	// 代理向 Bot API 下载文件，返回内容不包含 token
+	handler := bot.fileHandler("/telegram/file/")
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/telegram/file/PHOTO", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "jpeg" || recorder.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("代理返回 %d %q %v", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/telegram/file/MISSING", nil))
+	if recorder.Code != http.StatusNotFound || strings.Contains(recorder.Body.String(), testToken) {
+		t.Fatalf("文件不存在时返回 %d %q", recorder.Code, recorder.Body.String())
+	}
+}
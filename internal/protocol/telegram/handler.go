@@ -0,0 +1,156 @@
+package telegram
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/profile"
+	"GoQHttp/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// MessageEventHandler 私聊与群组消息
+func (t *Telegram) MessageEventHandler(message *Message) error {
+	// 忽略频道消息及机器人自己发送的消息
+	if message.From == nil || message.Chat == nil || message.Chat.Type == ChannelChat || message.From.Id == t.SelfId {
+		return nil
+	}
+	logger.Infof("Telegram消息: %s%s", message.Text, message.Caption)
+
+	msgId, err := messageId(message.Chat.Id, message.MessageId)
+	if err != nil {
+		return err
+	}
+
+	var messages []*onebot.Element
+	if message.ReplyToMessage != nil {
+		replyId, err := messageId(message.Chat.Id, message.ReplyToMessage.MessageId)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, &onebot.Element{
+			ElementType: onebot.ReplyType,
+			Data:        &onebot.Reply{Id: strconv.Itoa(int(replyId))},
+		})
+	}
+	messages = append(messages, t.parseText(message.Text, message.Entities)...)
+	messages = append(messages, t.parseMedia(message)...)
+	messages = append(messages, t.parseText(message.Caption, message.CaptionEntities)...)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageRequest := onebot.MessageRequest{
+		MessageBase: onebot.MessageBase{
+			Time:     message.Date,
+			SelfId:   t.SelfId,
+			PostType: onebot.MessagePost,
+		},
+		MessageId:       msgId,
+		UserId:          message.From.Id,
+		OriginalMessage: messages,
+		Message:         messages,
+		RawMessage:      onebot.CQString(messages),
+		Font:            1,
+		Sender: onebot.Sender{
+			UserId:   message.From.Id,
+			NickName: message.From.Name(),
+		},
+	}
+
+	switch message.Chat.Type {
+	case PrivateChat:
+		messageRequest.MessageType = onebot.PrivateMessage
+		messageRequest.SubType = onebot.Friend
+	default:
+		if messageRequest.GroupId, err = groupId(message.Chat.Id); err != nil {
+			return err
+		}
+		messageRequest.MessageType = onebot.GroupMessage
+		messageRequest.SubType = onebot.Normal
+	}
+	profile.Enrich(t.Identity(), messageRequest.GroupId, &messageRequest.Sender)
+
+	t.events <- messageRequest
+	return nil
+}
+
+// parseText 将文本及实体转换为消息段，提及用户转换为 at，其余实体作为文本
+func (t *Telegram) parseText(text string, entities []*MessageEntity) []*onebot.Element {
+	if text == "" {
+		return nil
+	}
+	var messages []*onebot.Element
+	appendText := func(text string) {
+		if text != "" {
+			messages = append(messages, &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: text}})
+		}
+	}
+
+	// 实体的位置以 UTF-16 码元计
+	units := utf16.Encode([]rune(text))
+	slice := func(start, end int) string {
+		return string(utf16.Decode(units[start:end]))
+	}
+	sorted := append([]*MessageEntity(nil), entities...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+
+	last := 0
+	for _, entity := range sorted {
+		start, end := entity.Offset, entity.Offset+entity.Length
+		if start < last || end > len(units) {
+			continue
+		}
+		var target int64
+		switch entity.Type {
+		case TextMentionEntity:
+			if entity.User != nil {
+				target = entity.User.Id
+			}
+		case MentionEntity:
+			if t.Username != "" && strings.EqualFold(slice(start, end), "@"+t.Username) {
+				target = t.SelfId
+			}
+		}
+		if target == 0 {
+			continue
+		}
+		appendText(slice(last, start))
+		messages = append(messages, &onebot.Element{ElementType: onebot.AtType, Data: &onebot.At{Uid: strconv.FormatInt(target, 10)}})
+		last = end
+	}
+	appendText(slice(last, len(units)))
+	return messages
+}
+
+// parseMedia 转换图片、贴纸、语音、视频及文件
+func (t *Telegram) parseMedia(message *Message) []*onebot.Element {
+	switch {
+	case len(message.Photo) > 0:
+		// 同一图片有多种尺寸，取最大的一张
+		photo := message.Photo[0]
+		for _, size := range message.Photo {
+			if size.Width*size.Height > photo.Width*photo.Height {
+				photo = size
+			}
+		}
+		return []*onebot.Element{{ElementType: onebot.ImageType, Data: &onebot.Image{File: photo.FileId, Url: t.fileUrl(photo.FileId)}}}
+	case message.Sticker != nil:
+		return []*onebot.Element{{ElementType: onebot.ImageType, Data: &onebot.Image{File: message.Sticker.FileId, Url: t.fileUrl(message.Sticker.FileId)}}}
+	case message.Voice != nil:
+		return []*onebot.Element{{ElementType: onebot.RecordType, Data: &onebot.Record{File: message.Voice.FileId, Url: t.fileUrl(message.Voice.FileId)}}}
+	case message.Audio != nil:
+		return []*onebot.Element{{ElementType: onebot.RecordType, Data: &onebot.Record{File: message.Audio.FileId, Url: t.fileUrl(message.Audio.FileId)}}}
+	case message.Video != nil:
+		return []*onebot.Element{{ElementType: onebot.VideoType, Data: &onebot.Video{File: message.Video.FileId, Url: t.fileUrl(message.Video.FileId)}}}
+	case message.Document != nil:
+		return []*onebot.Element{{ElementType: onebot.FileType, Data: &onebot.File{
+			File: message.Document.FileId,
+			Url:  t.fileUrl(message.Document.FileId),
+			Name: message.Document.FileName,
+			Size: message.Document.FileSize,
+		}}}
+	}
+	return nil
+}
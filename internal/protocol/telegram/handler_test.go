@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"GoQHttp/internal/onebot"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// nextMessage 等待下一条消息事件
+func nextMessage(t *testing.T, bot *Telegram) onebot.MessageRequest {
+	t.Helper()
+	select {
+	case event := <-bot.Events():
+		message, ok := event.(onebot.MessageRequest)
+		if !ok {
+			t.Fatalf("事件类型 %T", event)
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("未收到消息事件")
+	}
+	return onebot.MessageRequest{}
+}
+
+// elementsOf 将消息段转换为 类型:主要字段 的形式便于比较
+func elementsOf(elements []*onebot.Element) []string {
+	var result []string
+	for _, element := range elements {
+		value := element.Field("text")
+		switch element.ElementType {
+		case onebot.AtType:
+			value = element.Field("qq")
+		case onebot.ReplyType:
+			value = element.Field("id")
+		case onebot.ImageType:
+			value = element.Field("file")
+		}
+		result = append(result, string(element.ElementType)+":"+value)
+	}
+	return result
+}
+
+func expectElements(t *testing.T, elements []*onebot.Element, expected ...string) {
+	t.Helper()
+	actual := elementsOf(elements)
+	if len(actual) != len(expected) {
+		t.Fatalf("消息段 %q, 期望 %q", actual, expected)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("消息段 %q, 期望 %q", actual, expected)
+		}
+	}
+}
+
+func TestPollConvertsMessages(t *testing.T) {
+	bot, api := startFakeBot(t)
+	alice := &User{Id: 7, FirstName: "Alice"}
+	group := &Chat{Id: -100123, Type: SupergroupChat, Title: "群组"}
+	api.push(
+		// 实体位置以 UTF-16 码元计，表情占两个码元
+		&Update{UpdateId: 1, Message: &Message{
+			MessageId:      10,
+			From:           alice,
+			Chat:           &Chat{Id: 7, Type: PrivateChat},
+			Text:           "你好 @test_bot 😀 @Bob!",
+			Entities:       []*MessageEntity{{Type: TextMentionEntity, Offset: 16, Length: 4, User: &User{Id: 8}}, {Type: MentionEntity, Offset: 3, Length: 9}},
+			ReplyToMessage: &Message{MessageId: 5},
+		}},
+		// 机器人自己发送的消息被忽略
+		&Update{UpdateId: 2, Message: &Message{MessageId: 11, From: &User{Id: testSelfId}, Chat: group, Text: "自己"}},
+		&Update{UpdateId: 3, Message: &Message{
+			MessageId: 12,
+			From:      alice,
+			Chat:      group,
+			Caption:   "看图",
+			Photo:     []*PhotoSize{{FileId: "SMALL", Width: 90, Height: 90}, {FileId: "LARGE", Width: 1280, Height: 720}, {FileId: "MEDIUM", Width: 320, Height: 180}},
+		}},
+	)
+
+	private := nextMessage(t, bot)
+	if private.MessageType != onebot.PrivateMessage || private.UserId != 7 || private.GroupId != 0 || private.Sender.NickName != "Alice" {
+		t.Fatalf("私聊消息 %+v", private)
+	}
+	replyId, _ := messageId(7, 5)
+	expectElements(t, private.Message, "reply:"+strconv.Itoa(int(replyId)), "text:你好 ", "at:"+strconv.Itoa(testSelfId), "text: 😀 ", "at:8", "text:!")
+	if id, _ := messageId(7, 10); private.MessageId != id {
+		t.Fatalf("私聊消息id %d, 期望 %d", private.MessageId, id)
+	}
+
+	groupMessage := nextMessage(t, bot)
+	if expected, _ := groupId(group.Id); groupMessage.MessageType != onebot.GroupMessage || groupMessage.GroupId != expected || groupMessage.UserId != 7 {
+		t.Fatalf("群组消息 %+v, 期望群号 %d", groupMessage, expected)
+	}
+	// 取面积最大的图片尺寸
+	expectElements(t, groupMessage.Message, "image:LARGE", "text:看图")
+}
@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// mediaMethods 媒体消息段对应的发送接口与参数名
+var mediaMethods = map[onebot.ElementType][2]string{
+	onebot.ImageType:  {"sendPhoto", "photo"},
+	onebot.RecordType: {"sendVoice", "voice"},
+	onebot.VideoType:  {"sendVideo", "video"},
+	onebot.FileType:   {"sendDocument", "document"},
+}
+
+// SendMessage 发送功能端下发的消息
+func (t *Telegram) SendMessage(data *onebot.MessageRequest) {
+	chatId, err := targetOf(data.MessageType, data.GroupId, data.UserId)
+	if err != nil {
+		logger.Warnf("Telegram消息发送失败: %v", err)
+		return
+	}
+	if _, err = t.Send(chatId, data.Message); err != nil {
+		logger.Warnf("Telegram消息发送失败: %v", err)
+	}
+}
+
+// targetOf 根据 OneBot 参数获取会话id，未指定消息类型时按 group_id 判断
+func targetOf(messageType onebot.MessageType, group int32, user int64) (int64, error) {
+	if messageType == onebot.PrivateMessage || (messageType == "" && group == 0) {
+		if user == 0 {
+			return 0, errors.New("user_id 不能为空")
+		}
+		return user, nil
+	}
+	return groupChat(group)
+}
+
+// Send 发送消息，文本与 @ 合并为一条 HTML 消息，媒体单独发送，返回最后一条消息的 OneBot 消息id
+func (t *Telegram) Send(chatId int64, elements []*onebot.Element) (int32, error) {
+	var replyTo, lastId int64
+	var text strings.Builder
+	flush := func() error {
+		if strings.TrimSpace(text.String()) == "" {
+			text.Reset()
+			return nil
+		}
+		message, err := t.Api.SendMessage(&SendMessageParams{ChatId: chatId, Text: text.String(), ParseMode: "HTML", ReplyToMessageId: replyTo})
+		text.Reset()
+		if err != nil {
+			return err
+		}
+		replyTo, lastId = 0, message.MessageId
+		return nil
+	}
+
+	for _, element := range elements {
+		switch element.ElementType {
+		case onebot.ReplyType:
+			id, err := strconv.ParseInt(element.Field("id"), 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("回复消息id无效: %s", element.Field("id"))
+			}
+			replyChat, msgId, err := messageOf(int32(id))
+			if err != nil {
+				return 0, err
+			}
+			if replyChat == chatId {
+				replyTo = msgId
+			}
+		case onebot.ImageType, onebot.RecordType, onebot.VideoType, onebot.FileType:
+			if err := flush(); err != nil {
+				return 0, err
+			}
+			file, err := inputFile(element)
+			if err != nil {
+				return 0, err
+			}
+			method := mediaMethods[element.ElementType]
+			message, err := t.Api.SendMedia(method[0], method[1], chatId, file, replyTo)
+			if err != nil {
+				return 0, err
+			}
+			replyTo, lastId = 0, message.MessageId
+		default:
+			if !writeText(&text, element) {
+				logger.Warnf("暂不支持的消息类型: %s", element.ElementType)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	if lastId == 0 {
+		return 0, errors.New("消息内容为空")
+	}
+	id, err := messageId(chatId, lastId)
+	if err != nil {
+		return 0, err
+	}
+	t.sent(chatId, elements, id)
+	return id, nil
+}
+
+// sent 通知消息发送成功，群组会话id为负数，私聊会话id即用户id
+func (t *Telegram) sent(chatId int64, elements []*onebot.Element, msgId int32) {
+	message := &onebot.MessageRequest{MessageId: msgId, Message: elements, RawMessage: onebot.CQString(elements)}
+	if chatId > 0 {
+		message.MessageType = onebot.PrivateMessage
+		message.UserId = chatId
+	} else {
+		group, err := groupId(chatId)
+		if err != nil {
+			logger.Warnf("Telegram群组 %d 映射失败: %v", chatId, err)
+			return
+		}
+		message.MessageType = onebot.GroupMessage
+		message.GroupId = group
+	}
+	protocol.MessageSent(t.Identity(), message)
+}
+
+// inputFile 网络地址与 file_id 由 Telegram 获取，base64:// 与 file:// 读取后上传
+func inputFile(element *onebot.Element) (*InputFile, error) {
+	file := element.Field("file")
+	if file == "" {
+		file = element.Field("url")
+	}
+	if file == "" {
+		return nil, errors.New("file 不能为空")
+	}
+	if !strings.HasPrefix(file, "base64://") && !strings.HasPrefix(file, "file://") {
+		return &InputFile{Url: file}, nil
+	}
+	name, data, err := protocol.ReadMedia(file)
+	if err != nil {
+		return nil, err
+	}
+	if fileName := element.Field("name"); fileName != "" {
+		name = fileName
+	}
+	return &InputFile{Name: name, Data: data}, nil
+}
+
+// writeText 将文本与 @ 写入 HTML 消息，其他消息段返回 false
+func writeText(builder *strings.Builder, element *onebot.Element) bool {
+	switch element.ElementType {
+	case onebot.TextType:
+		builder.WriteString(html.EscapeString(element.Field("text")))
+	case onebot.AtType:
+		// Telegram 没有 @全体成员
+		if target := element.Field("qq"); target != "all" {
+			builder.WriteString(fmt.Sprintf(`<a href="tg://user?id=%s">@%s</a>`, html.EscapeString(target), html.EscapeString(target)))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// buildText 将文本与 @ 转换为 HTML，用于编辑消息
+func buildText(elements []*onebot.Element) (string, error) {
+	var text strings.Builder
+	for _, element := range elements {
+		if !writeText(&text, element) {
+			return "", errors.New("仅支持更新为文本消息")
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return "", errors.New("消息内容为空")
+	}
+	return text.String(), nil
+}
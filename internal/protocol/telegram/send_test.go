@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"GoQHttp/internal/onebot"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestSendSplitsTextAndMedia(t *testing.T) {
+	bot, api := startFakeBot(t)
+	chatId := int64(-100123)
+	replyId, _ := messageId(chatId, 99)
+	elements := []*onebot.Element{
+		{ElementType: onebot.ReplyType, Data: &onebot.Reply{Id: strconv.Itoa(int(replyId))}},
+		{ElementType: onebot.TextType, Data: &onebot.Text{Text: "a<b "}},
+		{ElementType: onebot.AtType, Data: &onebot.At{Uid: "8"}},
+		{ElementType: onebot.ImageType, Data: &onebot.Image{File: "https://example.com/1.jpg"}},
+		{ElementType: onebot.TextType, Data: &onebot.Text{Text: "之后"}},
+		{ElementType: onebot.FileType, Data: &onebot.File{File: "base64://" + base64.StdEncoding.EncodeToString([]byte("data")), Name: "a.txt"}},
+	}
+	id, err := bot.Send(chatId, elements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 文本与 @ 合并，遇到媒体时先发送之前的文本，仅第一条消息回复
+	expected := []apiCall{
+		{"sendMessage", map[string]string{"chat_id": "-100123", "text": `a&lt;b <a href="tg://user?id=8">@8</a>`, "parse_mode": "HTML", "reply_to_message_id": "99"}},
+		{"sendPhoto", map[string]string{"chat_id": "-100123", "photo": "https://example.com/1.jpg"}},
+		{"sendMessage", map[string]string{"chat_id": "-100123", "text": "之后", "parse_mode": "HTML"}},
+		{"sendDocument", map[string]string{"chat_id": "-100123", "document": "upload:a.txt"}},
+	}
+	calls := api.recorded()
+	if len(calls) != len(expected) {
+		t.Fatalf("调用 %+v, 期望 %+v", calls, expected)
+	}
+	for i, call := range calls {
+		if call.method != expected[i].method || fmt.Sprint(call.params) != fmt.Sprint(expected[i].params) {
+			t.Fatalf("第 %d 次调用 %+v, 期望 %+v", i+1, call, expected[i])
+		}
+	}
+	// 返回最后一条消息的id
+	if last, _ := messageId(chatId, 4); id != last {
+		t.Fatalf("消息id %d, 期望 %d", id, last)
+	}
+
+	if _, err = bot.Send(chatId, []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: " "}}}); err == nil {
+		t.Fatal("空消息应返回错误")
+	}
+}
+
+func TestMessageIdRoundTrip(t *testing.T) {
+	bot, api := startFakeBot(t)
+	params, _ := json.Marshal(map[string]any{"user_id": 7, "message": "hello"})
+	result, err := SendPrivateMsgAction(bot.SelfId, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(result)
+	var sent struct {
+		MessageId int32 `json:"message_id"`
+	}
+	if err = json.Unmarshal(data, &sent); err != nil || sent.MessageId == 0 {
+		t.Fatalf("发送结果 %s err=%v", data, err)
+	}
+
+	// 发送返回的id可用于编辑与删除原消息
+	params, _ = json.Marshal(map[string]any{"message_id": sent.MessageId, "message": "[CQ:at,qq=8]改"})
+	if _, err = UpdateMsgAction(bot.SelfId, params); err != nil {
+		t.Fatal(err)
+	}
+	params, _ = json.Marshal(map[string]any{"message_id": sent.MessageId})
+	if _, err = DeleteMsgAction(bot.SelfId, params); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.recorded()
+	if len(calls) != 3 {
+		t.Fatalf("调用 %+v", calls)
+	}
+	edit, remove := calls[1], calls[2]
+	if edit.method != "editMessageText" || edit.params["chat_id"] != "7" || edit.params["message_id"] != "1" || edit.params["text"] != `<a href="tg://user?id=8">@8</a>改` {
+		t.Fatalf("编辑消息 %+v", edit)
+	}
+	if remove.method != "deleteMessage" || remove.params["chat_id"] != "7" || remove.params["message_id"] != "1" {
+		t.Fatalf("删除消息 %+v", remove)
+	}
+
+	params, _ = json.Marshal(map[string]any{"message_id": sent.MessageId + 100})
+	if _, err = DeleteMsgAction(bot.SelfId, params); err == nil {
+		t.Fatal("未知的消息id应返回错误")
+	}
+}
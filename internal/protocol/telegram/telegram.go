@@ -1,4 +1,207 @@
 package telegram
 
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// id 映射的命名空间
+//
+// Telegram 用户id直接作为 user_id 使用；群组id为负数且超出 int32 范围，消息id仅在会话内唯一，需要映射。
+var (
+	groupNamespace   = idmap.NewNamespace("telegram", idmap.Group)
+	messageNamespace = idmap.NewNamespace("telegram", idmap.Message)
+)
+
+const (
+	pollRetryMin = time.Second
+	pollRetryMax = time.Minute
+)
+
+// Telegram 机器人，将 Bot API 的更新转换为 OneBot 事件
 type Telegram struct {
+	Config *config.Telegram
+	Api    *Api
+	SelfId int64
+	// Username 机器人的用户名，用于识别文本中 @机器人
+	Username string
+	// updates webhook 模式下待处理的更新，按接收顺序处理
+	updates chan *Update
+	// deduper webhook 模式下忽略重试投递的重复更新
+	deduper *protocol.EventDeduper
+	// events 转换后的 OneBot 事件
+	events  chan onebot.Event
+	stop    chan struct{}
+	stopped atomic.Bool
+}
+
+var (
+	bots   = make(map[int64]*Telegram)
+	botsMu sync.RWMutex
+)
+
+// NewTelegram 根据配置创建机器人
+func NewTelegram(bot *config.Telegram) *Telegram {
+	return &Telegram{
+		Config:  bot,
+		Api:     NewApi(bot.Token, bot.ApiUrl),
+		updates: make(chan *Update, 100),
+		events:  make(chan onebot.Event, 100),
+		stop:    make(chan struct{}),
+	}
+}
+
+// IsWebhook 是否通过 HTTP 回调接收更新
+func (t *Telegram) IsWebhook() bool {
+	return t.Config.Mode == "webhook"
+}
+
+// Start 获取机器人信息，按配置开始长轮询或设置回调地址
+func (t *Telegram) Start() error {
+	if t.IsWebhook() && t.Config.SecretToken == "" {
+		return fmt.Errorf("webhook 模式需要设置 secret_token")
+	}
+	var proxyPath string
+	if t.Config.FileProxyUrl != "" {
+		proxy, err := url.Parse(t.Config.FileProxyUrl)
+		if err != nil || proxy.Host == "" {
+			return fmt.Errorf("文件代理地址无效: %s", t.Config.FileProxyUrl)
+		}
+		proxyPath = strings.TrimSuffix(proxy.Path, "/") + "/"
+	}
+	me, err := t.Api.GetMe()
+	if err != nil {
+		return fmt.Errorf("获取机器人信息失败: %v", err)
+	}
+	t.SelfId = me.Id
+	t.Username = me.Username
+	logger.Infof("Telegram机器人 %s(@%s) 已登录", me.Name(), me.Username)
+
+	if t.IsWebhook() && t.Config.WebhookUrl != "" {
+		if err = t.Api.SetWebhook(t.Config.WebhookUrl, t.Config.SecretToken); err != nil {
+			return fmt.Errorf("设置回调地址失败: %v", err)
+		}
+		logger.Infof("Telegram回调地址已设置为 %s", t.Config.WebhookUrl)
+	}
+
+	botsMu.Lock()
+	bots[t.SelfId] = t
+	botsMu.Unlock()
+
+	if t.IsWebhook() {
+		t.deduper = protocol.NewEventDeduper("telegram:"+strconv.FormatInt(t.SelfId, 10), 0)
+		go t.HandlerEvent()
+		protocol.HandleWebhook("Telegram", t.Config.WebhookPath, t.WebhookHandler)
+	} else {
+		go t.Poll()
+	}
+	if proxyPath != "" {
+		http.HandleFunc(proxyPath, t.fileHandler(proxyPath))
+		logger.Infof("Telegram文件代理监听地址: %s", proxyPath)
+	}
+	return nil
+}
+
+// GetBot 获取 self_id 对应的机器人
+func GetBot(selfId int64) (*Telegram, bool) {
+	botsMu.RLock()
+	defer botsMu.RUnlock()
+	bot, ok := bots[selfId]
+	return bot, ok
+}
+
+// Poll 通过 getUpdates 长轮询接收更新，失败时按指数退避重试，直到调用 Stop
+func (t *Telegram) Poll() {
+	var offset int64
+	retry := pollRetryMin
+	for !t.stopped.Load() {
+		updates, err := t.Api.GetUpdates(offset, t.Config.PollTimeout)
+		if t.stopped.Load() {
+			return
+		}
+		if err != nil {
+			logger.Warnf("Telegram获取更新失败: %v, 将在%v后重试", err, retry)
+			select {
+			case <-time.After(retry):
+			case <-t.stop:
+				return
+			}
+			if retry *= 2; retry > pollRetryMax {
+				retry = pollRetryMax
+			}
+			continue
+		}
+		retry = pollRetryMin
+		for _, update := range updates {
+			offset = update.UpdateId + 1
+			t.HandleUpdate(update)
+		}
+	}
+}
+
+// HandlerEvent 按顺序处理 webhook 接收的更新
+func (t *Telegram) HandlerEvent() {
+	for update := range t.updates {
+		t.HandleUpdate(update)
+	}
+}
+
+// HandleUpdate 处理一条更新
+func (t *Telegram) HandleUpdate(update *Update) {
+	if update.Message == nil {
+		return
+	}
+	if err := t.MessageEventHandler(update.Message); err != nil {
+		logger.Warnf("Telegram事件处理失败: %v", err)
+	}
+}
+
+// groupId 获取群组对应的群号
+func groupId(chatId int64) (int32, error) {
+	id, err := idmap.Id(groupNamespace, strconv.FormatInt(chatId, 10))
+	return int32(id), err
+}
+
+// groupChat 获取群号对应的群组id
+func groupChat(group int32) (int64, error) {
+	raw, err := idmap.Raw(groupNamespace, int64(group))
+	if err != nil {
+		return 0, fmt.Errorf("未找到群号 %d 对应的群组: %v", group, err)
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// messageId 获取消息对应的数字id，消息以 会话id:消息id 的形式映射
+func messageId(chatId int64, msgId int64) (int32, error) {
+	id, err := idmap.Id(messageNamespace, fmt.Sprintf("%d:%d", chatId, msgId))
+	return int32(id), err
+}
+
+// messageOf 获取数字id对应的会话id与消息id
+func messageOf(id int32) (int64, int64, error) {
+	raw, err := idmap.Raw(messageNamespace, int64(id))
+	if err != nil {
+		return 0, 0, fmt.Errorf("未找到消息 %d: %v", id, err)
+	}
+	chat, msg, _ := strings.Cut(raw, ":")
+	chatId, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("消息映射无效: %s", raw)
+	}
+	msgId, err := strconv.ParseInt(msg, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("消息映射无效: %s", raw)
+	}
+	return chatId, msgId, nil
 }
@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"GoQHttp/logger"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxWebhookBody 回调请求体大小上限
+const maxWebhookBody = 1 << 20
+
+// secretTokenHeader setWebhook 时设置的 secret_token 所在的请求头
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// WebhookHandler 处理 Telegram 的回调，校验 secret_token 后与长轮询共用同一处理流程
+func (t *Telegram) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+	if t.stopped.Load() {
+		http.Error(w, "机器人已停止", http.StatusServiceUnavailable)
+		return
+	}
+
+	secret := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(t.Config.SecretToken)) != 1 {
+		logger.Warnf("Telegram回调 secret_token 不相符")
+		http.Error(w, "secret_token 不相符", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "无法读取请求体", http.StatusBadRequest)
+		return
+	}
+	update := &Update{}
+	if err = json.Unmarshal(body, update); err != nil {
+		logger.Warnf("Telegram回调解析失败: %v", err)
+		http.Error(w, "无法解析请求", http.StatusBadRequest)
+		return
+	}
+
+	// 回调失败时 Telegram 会重试，重复投递的更新只确认不再分发
+	updateId := strconv.FormatInt(update.UpdateId, 10)
+	if !t.deduper.Reserve(updateId) {
+		logger.Debugf("忽略重复的Telegram更新: %d", update.UpdateId)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	// 处理积压时不阻塞回调，释放更新id等待 Telegram 重试
+	select {
+	case t.updates <- update:
+		t.deduper.Commit(updateId)
+	default:
+		t.deduper.Release(updateId)
+		logger.Warnf("Telegram更新处理积压, 丢弃更新 %d 等待重试", update.UpdateId)
+		http.Error(w, "更新处理积压", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newWebhookBot 创建未启动处理协程的 webhook 模式机器人，更新保留在 updates 中
+func newWebhookBot(size int) *Telegram {
+	utils.Store = utils.NewMemoryStorage()
+	bot := NewTelegram(&config.Telegram{Token: testToken, Mode: "webhook", SecretToken: "SECRET"})
+	bot.SelfId = testSelfId
+	bot.updates = make(chan *Update, size)
+	bot.deduper = protocol.NewEventDeduper("telegram:test", 0)
+	return bot
+}
+
+// postUpdate 以指定的 secret_token 回调更新
+func postUpdate(bot *Telegram, method string, secret string, body string) int {
+	r := httptest.NewRequest(method, "/telegram", strings.NewReader(body))
+	if secret != "" {
+		r.Header.Set(secretTokenHeader, secret)
+	}
+	recorder := httptest.NewRecorder()
+	bot.WebhookHandler(recorder, r)
+	return recorder.Code
+}
+
+func TestWebhookRejects(t *testing.T) {
+	bot := newWebhookBot(10)
+	for _, c := range []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		code   int
+	}{
+		{"非 POST 请求", http.MethodGet, "SECRET", "", http.StatusMethodNotAllowed},
+		{"缺少 secret_token", http.MethodPost, "", `{"update_id":1}`, http.StatusUnauthorized},
+		{"secret_token 错误", http.MethodPost, "WRONG", `{"update_id":1}`, http.StatusUnauthorized},
+		{"无法解析", http.MethodPost, "SECRET", `{`, http.StatusBadRequest},
+	} {
+		if code := postUpdate(bot, c.method, c.secret, c.body); code != c.code {
+			t.Fatalf("%s 返回 %d, 期望 %d", c.name, code, c.code)
+		}
+	}
+	if len(bot.updates) != 0 {
+		t.Fatalf("被拒绝的回调产生了 %d 条更新", len(bot.updates))
+	}
+}
+
+func TestWebhookDuplicateUpdate(t *testing.T) {
+	bot := newWebhookBot(10)
+	for i := 0; i < 2; i++ {
+		if code := postUpdate(bot, http.MethodPost, "SECRET", `{"update_id":7}`); code != http.StatusOK {
+			t.Fatalf("第 %d 次回调返回 %d", i+1, code)
+		}
+	}
+	if code := postUpdate(bot, http.MethodPost, "SECRET", `{"update_id":8}`); code != http.StatusOK {
+		t.Fatalf("回调返回 %d", code)
+	}
+	// 重复投递的更新只分发一次
+	if len(bot.updates) != 2 || (<-bot.updates).UpdateId != 7 || (<-bot.updates).UpdateId != 8 {
+		t.Fatal("更新分发不正确")
+	}
+}
+
+func TestWebhookBacklog(t *testing.T) {
+	bot := newWebhookBot(1)
+	if code := postUpdate(bot, http.MethodPost, "SECRET", `{"update_id":1}`); code != http.StatusOK {
+		t.Fatalf("回调返回 %d", code)
+	}
+	// 积压时不阻塞，返回 503 由 Telegram 重试
+	if code := postUpdate(bot, http.MethodPost, "SECRET", `{"update_id":2}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("积压时返回 %d, 期望 503", code)
+	}
+	<-bot.updates
+	if code := postUpdate(bot, http.MethodPost, "SECRET", `{"update_id":2}`); code != http.StatusOK {
+		t.Fatalf("重试返回 %d", code)
+	}
+	if update := <-bot.updates; update.UpdateId != 2 {
+		t.Fatalf("重试后分发更新 %d", update.UpdateId)
+	}
+
+	// 停止后不再接收
+	_ = bot.Stop()
+	if code := postUpdate(bot, http.MethodPost, "SECRET", `{"update_id":3}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("停止后返回 %d", code)
+	}
+}
@@ -0,0 +1,300 @@
+package tencent
+
+import (
+	"GoQHttp/internal/constant"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/openapi"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// actions QQ官方机器人支持的动作，发送消息由 SendMessage 处理
+var actions = protocol.Actions{
+	"get_api_permissions":          GetAPIPermissionsAction,
+	"create_api_permission_demand": CreateAPIPermissionDemandAction,
+	"send_guild_channel_msg":       SendGuildChannelMessageAction,
+}
+
+// GuildParams 频道相关动作的公共参数，频道与子频道为映射后的数字id
+type GuildParams struct {
+	GuildId   int64 `json:"guild_id"`
+	ChannelId int64 `json:"channel_id"`
+}
+
+// raw 获取频道与子频道的原始id，未提供的id为空字符串
+func (p GuildParams) raw(appId int) (guildId string, channelId string, err error) {
+	if p.GuildId != 0 {
+		if guildId, err = openapi.RawOf(openapi.GuildNamespace, appId, p.GuildId); err != nil {
+			return "", "", err
+		}
+	}
+	if p.ChannelId != 0 {
+		if channelId, err = openapi.RawOf(openapi.ChannelNamespace, appId, p.ChannelId); err != nil {
+			return "", "", err
+		}
+	}
+	return guildId, channelId, nil
+}
+
+// APIPermissionDemandParams create_api_permission_demand 参数
+type APIPermissionDemandParams struct {
+	GuildParams
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	Desc   string `json:"desc"`
+}
+
+// SendGuildChannelMessageParams send_guild_channel_msg 参数
+type SendGuildChannelMessageParams struct {
+	GuildParams
+	Message json.RawMessage `json:"message"`
+}
+
+// botFor 获取发起动作的连接所对应的机器人
+func botFor(selfId int64) (*Tencent, error) {
+	bot, ok := GetBot(selfId)
+	if !ok {
+		return nil, fmt.Errorf("%w: 未找到 self_id 为 %d 的QQ机器人", protocol.ErrBotNotFound, selfId)
+	}
+	return bot, nil
+}
+
+// GetAPIPermissionsAction 获取机器人在频道内的接口权限列表
+func GetAPIPermissionsAction(selfId int64, params json.RawMessage) (any, error) {
+	var p GuildParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.GuildId == 0 {
+		return nil, errors.New("guild_id 不能为空")
+	}
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	guildId, _, err := p.raw(bot.Config().Id)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := bot.Api.GetAPIPermissions(guildId)
+	if err != nil {
+		return nil, err
+	}
+	return permissions.APIList, nil
+}
+
+// CreateAPIPermissionDemandAction 在子频道中发起接口授权申请
+func CreateAPIPermissionDemandAction(selfId int64, params json.RawMessage) (any, error) {
+	var p APIPermissionDemandParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.GuildId == 0 || p.ChannelId == 0 || p.Path == "" || p.Method == "" {
+		return nil, errors.New("guild_id、channel_id、path、method 不能为空")
+	}
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	guildId, channelId, err := p.GuildParams.raw(bot.Config().Id)
+	if err != nil {
+		return nil, err
+	}
+	return bot.Api.RequireAPIPermissions(guildId, &dto.APIPermissionDemandToCreate{
+		ChannelID: channelId,
+		APIIdentify: &dto.APIPermissionDemandIdentify{
+			Path:   p.Path,
+			Method: strings.ToUpper(p.Method),
+		},
+		Desc: p.Desc,
+	})
+}
+
+// SendGuildChannelMessageAction 发送子频道消息
+func SendGuildChannelMessageAction(selfId int64, params json.RawMessage) (any, error) {
+	var p SendGuildChannelMessageParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.GuildId == 0 || p.ChannelId == 0 {
+		return nil, errors.New("guild_id、channel_id 不能为空")
+	}
+	bot, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := constant.CQCode.ParseMessage(p.Message)
+	if err != nil {
+		return nil, err
+	}
+	messageId, err := bot.sendGuildMessage(&onebot.MessageRequest{
+		MessageType: onebot.GuildMessage,
+		GuildId:     p.GuildId,
+		ChannelId:   p.ChannelId,
+		Message:     elements,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int32{"message_id": messageId}, nil
+}
+
+// sendGuildMessage 发送子频道消息或频道私信，返回映射后的消息id
+func (qq *Tencent) sendGuildMessage(data *onebot.MessageRequest) (int32, error) {
+	appId := qq.Config().Id
+	guildId, channelId, err := GuildParams{GuildId: data.GuildId, ChannelId: data.ChannelId}.raw(appId)
+	if err != nil {
+		return 0, err
+	}
+	messages := qq.buildChannelMessages(data.Message)
+	if len(messages) == 0 {
+		return 0, errors.New("消息内容为空")
+	}
+
+	var sentId string
+	for _, message := range messages {
+		var result *dto.Message
+		if data.MessageType == onebot.GuildMessage {
+			if result, err = qq.Api.SendChannelMessage(channelId, message); err != nil {
+				return 0, qq.handlePermissionError(err, guildId, channelId, "/channels/{channel_id}/messages", http.MethodPost)
+			}
+		} else if result, err = qq.Api.SendDirectMessage(guildId, message); err != nil {
+			return 0, qq.handlePermissionError(err, "", "", "/dms/{guild_id}/messages", http.MethodPost)
+		}
+		sentId = result.ID
+	}
+	id, err := openapi.IdOf(openapi.MessageNamespace, appId, sentId)
+	if err != nil {
+		return 0, err
+	}
+	protocol.MessageSent(qq.Identity(), &onebot.MessageRequest{
+		MessageType: data.MessageType,
+		MessageId:   int32(id),
+		UserId:      data.UserId,
+		GuildId:     data.GuildId,
+		ChannelId:   data.ChannelId,
+		Message:     data.Message,
+		RawMessage:  onebot.CQString(data.Message),
+	})
+	return int32(id), nil
+}
+
+// buildChannelMessages 将消息段转换为子频道消息，子频道消息每条只能携带一张图片，@ 与回复的数字id转换为原始id
+func (qq *Tencent) buildChannelMessages(elements []*onebot.Element) []*dto.MessageToCreate {
+	appId := qq.Config().Id
+	var messages []*dto.MessageToCreate
+	current := &dto.MessageToCreate{}
+	var reference *dto.MessageReference
+	for _, element := range elements {
+		switch element.ElementType {
+		case onebot.TextType:
+			current.Content += element.Field("text")
+		case onebot.AtType:
+			if uid := element.Field("qq"); uid == "all" {
+				current.Content += "@everyone"
+			} else {
+				current.Content += fmt.Sprintf("<@!%s>", rawOf(openapi.GuildUserNamespace, appId, uid))
+			}
+		case onebot.ImageType:
+			if current.Image != "" {
+				messages = append(messages, current)
+				current = &dto.MessageToCreate{}
+			}
+			current.Image = element.Field("url")
+			if current.Image == "" {
+				current.Image = element.Field("file")
+			}
+		case onebot.ReplyType:
+			reference = &dto.MessageReference{MessageID: rawOf(openapi.MessageNamespace, appId, element.Field("id")), IgnoreGetMessageError: true}
+		default:
+			logger.Warnf("暂不支持的消息类型: %s", element.ElementType)
+		}
+	}
+	if strings.TrimSpace(current.Content) != "" || current.Image != "" {
+		messages = append(messages, current)
+	}
+	if reference != nil {
+		for _, message := range messages {
+			message.MsgID = reference.MessageID
+			message.MessageReference = reference
+		}
+	}
+	return messages
+}
+
+// rawOf 获取消息段中数字id对应的原始id，不是数字或没有映射时原样返回
+func rawOf(namespace idmap.Namespace, appId int, id string) string {
+	number, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return id
+	}
+	raw, err := openapi.RawOf(namespace, appId, number)
+	if err != nil {
+		return id
+	}
+	return raw
+}
+
+// permissionDemandTTL 同一频道同一接口的授权申请间隔，期间不再重复发起
+const permissionDemandTTL = time.Hour
+
+// permissionDemand 授权申请的去重键
+type permissionDemand struct {
+	guildId string
+	path    string
+	method  string
+}
+
+// handlePermissionError 识别接口无权限错误，按配置自动发起授权申请或给出申请建议，
+// 授权申请需要在子频道中发起，群聊与单聊接口只给出提示
+func (qq *Tencent) handlePermissionError(err error, guildId string, channelId string, path string, method string) error {
+	var apiErr *openapi.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsNoPermission() {
+		return err
+	}
+
+	if guildId == "" || channelId == "" {
+		logger.Warnf("机器人缺少接口权限 %s %s, 请在QQ开放平台确认已开通该接口", method, path)
+		return fmt.Errorf("%w, 缺少接口权限 %s %s, 请在QQ开放平台确认已开通该接口", err, method, path)
+	}
+
+	if !qq.Config().AutoPermissionDemand {
+		logger.Warnf("机器人缺少接口权限 %s %s, 可调用 create_api_permission_demand 申请授权", method, path)
+		return fmt.Errorf("%w, 缺少接口权限 %s %s, 可调用 create_api_permission_demand 申请授权", err, method, path)
+	}
+
+	// 每次调用失败都会进入这里，同一频道同一接口在间隔内只发起一次申请
+	key := permissionDemand{guildId: guildId, path: path, method: method}
+	now := time.Now()
+	if last, loaded := qq.demands.LoadOrStore(key, now); loaded {
+		if now.Sub(last.(time.Time)) < permissionDemandTTL || !qq.demands.CompareAndSwap(key, last, now) {
+			return fmt.Errorf("%w, 已发起接口授权申请 %s %s, 请频道管理员确认后重试", err, method, path)
+		}
+	}
+
+	_, demandErr := qq.Api.RequireAPIPermissions(guildId, &dto.APIPermissionDemandToCreate{
+		ChannelID: channelId,
+		APIIdentify: &dto.APIPermissionDemandIdentify{
+			Path:   path,
+			Method: method,
+		},
+		Desc: "机器人需要该权限以正常发送消息",
+	})
+	if demandErr != nil {
+		// 申请失败时允许下次重新发起
+		qq.demands.CompareAndDelete(key, now)
+		logger.Warnf("自动发起接口授权申请失败: %v", demandErr)
+		return fmt.Errorf("%w, 自动发起接口授权申请失败: %v", err, demandErr)
+	}
+	logger.Infof("已在子频道 %s 发起接口授权申请 %s %s", channelId, method, path)
+	return fmt.Errorf("%w, 已在子频道发起接口授权申请, 请频道管理员确认后重试", err)
+}
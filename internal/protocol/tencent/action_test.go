@@ -0,0 +1,52 @@
+package tencent
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/openapi"
+	"GoQHttp/internal/openapi/mock"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPermissionDemandDeduped(t *testing.T) {
+	s := mock.NewServer()
+	defer s.Close()
+	defer s.Install()()
+	qq := NewTencent(&config.QQ{Id: 1, Secret: testSecret, AutoPermissionDemand: true})
+	denied := &openapi.APIError{Status: http.StatusForbidden, Code: 11264, Message: "no permission"}
+	demands := func() int {
+		return len(s.RequestsTo(http.MethodPost, "/guilds/GUILD/api_permission/demand"))
+	}
+
+	// 申请失败时下次调用重新发起
+	s.Script(http.MethodPost, "/guilds/GUILD/api_permission/demand", http.StatusInternalServerError, &mock.ErrorResponse{Code: 500})
+	_ = qq.handlePermissionError(denied, "GUILD", "CHANNEL", "/channels/{channel_id}/messages", http.MethodPost)
+	for i := 0; i < 3; i++ {
+		err := qq.handlePermissionError(denied, "GUILD", "CHANNEL", "/channels/{channel_id}/messages", http.MethodPost)
+		// 调用方可以取得原始的接口错误
+		var apiErr *openapi.APIError
+		if !errors.As(err, &apiErr) || !apiErr.IsNoPermission() {
+			t.Fatalf("第 %d 次 err=%v, 应包装接口错误", i+1, err)
+		}
+	}
+	if n := demands(); n != 2 {
+		t.Fatalf("发起授权申请 %d 次, 期望 2", n)
+	}
+
+	// 不同接口分别申请
+	_ = qq.handlePermissionError(denied, "GUILD", "CHANNEL", "/channels/{channel_id}/messages/{message_id}", http.MethodDelete)
+	if n := demands(); n != 3 {
+		t.Fatalf("发起授权申请 %d 次, 期望 3", n)
+	}
+	// 间隔过后重新申请
+	qq.demands.Range(func(key, value any) bool {
+		qq.demands.Store(key, value.(time.Time).Add(-permissionDemandTTL))
+		return true
+	})
+	_ = qq.handlePermissionError(denied, "GUILD", "CHANNEL", "/channels/{channel_id}/messages", http.MethodPost)
+	if n := demands(); n != 4 {
+		t.Fatalf("发起授权申请 %d 次, 期望 4", n)
+	}
+}
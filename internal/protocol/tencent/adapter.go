@@ -0,0 +1,328 @@
+package tencent
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/history"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/lifecycle"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/openapi"
+	"GoQHttp/internal/profile"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	protocol.RegisterPlatform("QQ", newBots)
+	lifecycle.RegisterEraser("qq", eraseGroupMessages)
+	// 删除好友时的对象为单聊用户，群成员与频道用户的id单独分配
+	lifecycle.RegisterNamespace("qq", lifecycle.UserSubject, openapi.C2CUserNamespace)
+	lifecycle.RegisterNamespace("qq", lifecycle.UserSubject, openapi.UserNamespace)
+	lifecycle.RegisterNamespace("qq", lifecycle.UserSubject, openapi.GuildUserNamespace)
+	history.RegisterUserNamespace("qq", userNamespace)
+	profile.RegisterResolver("qq", resolveProfile)
+}
+
+// newBots 根据配置创建 QQ官方机器人
+func newBots(cfg *config.Config) []*protocol.Bot {
+	var result []*protocol.Bot
+	for i := range cfg.Bot.QQ {
+		bot := &cfg.Bot.QQ[i]
+		if !bot.Enable {
+			continue
+		}
+		if bot.WebhookPath == "" || bot.Secret == "" || bot.Id == 0 || bot.Token == "" || bot.ScopeType == "" {
+			logger.Warnf("QQ机器人 %d 启动失败,请检查设置", bot.Id)
+			continue
+		}
+		result = append(result, &protocol.Bot{Adapter: NewTencent(bot), Channels: bot.Channels})
+	}
+	// 仅有一个机器人时沿用旧版本未区分机器人的映射数据
+	if len(result) == 1 {
+		if err := utils.Store.ClaimLegacyRows(result[0].Adapter.(*Tencent).Config().Id); err != nil {
+			logger.Warnf("旧版本映射数据迁移失败: %v", err)
+		}
+	}
+	return result
+}
+
+// eraseGroupMessages 删除群或单聊用户的消息id记录
+func eraseGroupMessages(subject string, namespace idmap.Namespace, subjectId int64) (int64, error) {
+	if subject == lifecycle.GroupSubject {
+		return utils.Store.GroupMessageErase(int32(subjectId), 0)
+	}
+	if namespace != openapi.C2CUserNamespace {
+		return 0, nil
+	}
+	return utils.Store.GroupMessageErase(0, subjectId)
+}
+
+// userNamespace 消息中用户id的映射命名空间，私聊包括单聊与频道私信
+func userNamespace(message *onebot.MessageRequest) idmap.Namespace {
+	switch {
+	case message.MessageType == onebot.GroupMessage:
+		return openapi.UserNamespace
+	case message.MessageType == onebot.GuildMessage || message.GuildId != 0:
+		return openapi.GuildUserNamespace
+	}
+	return openapi.C2CUserNamespace
+}
+
+// Identity 机器人身份
+func (qq *Tencent) Identity() protocol.Identity {
+	return protocol.Identity{
+		Platform: "qq",
+		SelfId:   qq.SelfId,
+		UserId:   qq.UserId,
+		Nickname: qq.Nickname,
+	}
+}
+
+// Stop 断开网关或停止接收回调，并停止处理持久化队列
+func (qq *Tencent) Stop() error {
+	if qq.gateway != nil {
+		qq.gateway.Stop()
+	} else {
+		removeWebhook(qq)
+	}
+	if qq.queue != nil {
+		qq.queue.Stop()
+	}
+	botsMu.Lock()
+	delete(bots, qq.SelfId)
+	botsMu.Unlock()
+	return nil
+}
+
+// Events 转换后的 OneBot 事件
+func (qq *Tencent) Events() <-chan onebot.Event {
+	return qq.events
+}
+
+// Call 执行 OneBot 动作
+func (qq *Tencent) Call(action string, params json.RawMessage) (any, error) {
+	return actions.Call(qq.SelfId, action, params)
+}
+
+// Capabilities 支持的 OneBot 动作
+func (qq *Tencent) Capabilities() []string {
+	return append(actions.Names(), "send_msg", "send_group_msg", "send_private_msg")
+}
+
+// Reload 按 appid 切换到重新加载的配置，刷新签名密钥与回调转发，timestamp_window、auto_permission_demand 随之生效。
+// mode、webhook_path、intents、sandbox、durable_queue、dedupe_size、uid、channels 需要重启后生效：
+// uid 决定的 self_id 已用于功能端连接，其余设置在启动时确定了接收事件的方式。
+func (qq *Tencent) Reload(cfg *config.Config) error {
+	current := qq.Config()
+	for i := range cfg.Bot.QQ {
+		bot := &cfg.Bot.QQ[i]
+		if bot.Id != current.Id {
+			continue
+		}
+		if err := qq.LoadKeys(bot); err != nil {
+			return err
+		}
+		relays, err := newRelays(bot)
+		if err != nil {
+			return err
+		}
+		if changed := restartRequired(current, bot); len(changed) > 0 {
+			logger.Warnf("QQ机器人 %d 的 %s 设置需要重启后生效", bot.Id, strings.Join(changed, "、"))
+		}
+		qq.Api.SetSecret(bot.Secret)
+		qq.settings.Store(bot)
+		qq.replaceRelays(relays)
+		return nil
+	}
+	return nil
+}
+
+// restartRequired 返回发生变化且需要重启后生效的设置
+func restartRequired(old *config.QQ, bot *config.QQ) []string {
+	var changed []string
+	check := func(name string, differs bool) {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+	check("mode", old.Mode != bot.Mode)
+	check("webhook_path", old.WebhookPath != bot.WebhookPath)
+	check("intents", strings.Join(old.Intents, ",") != strings.Join(bot.Intents, ","))
+	check("sandbox", old.Sandbox != bot.Sandbox)
+	check("durable_queue", old.DurableQueue != bot.DurableQueue)
+	check("dedupe_size", old.DedupeSize != bot.DedupeSize)
+	check("uid", old.Uid != bot.Uid)
+	check("channels", !reflect.DeepEqual(old.Channels, bot.Channels))
+	return changed
+}
+
+// webhooks 回调地址对应的机器人，同一回调地址的多个机器人按 appid 分发
+var (
+	webhooks   = make(map[string]map[string]*Tencent)
+	webhooksMu sync.RWMutex
+)
+
+// handleWebhook 将机器人加入回调地址，地址首次使用时注册路由
+func handleWebhook(qq *Tencent) {
+	path := qq.Config().WebhookPath
+	webhooksMu.Lock()
+	routes, ok := webhooks[path]
+	if !ok {
+		routes = make(map[string]*Tencent)
+		webhooks[path] = routes
+	}
+	routes[strconv.Itoa(qq.Config().Id)] = qq
+	webhooksMu.Unlock()
+
+	if !ok {
+		protocol.HandleWebhook("QQ", path, webhookRouter(path))
+	}
+}
+
+// removeWebhook 将机器人移出回调地址，之后的请求按 appid 不相符拒绝
+func removeWebhook(qq *Tencent) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	delete(webhooks[qq.Config().WebhookPath], strconv.Itoa(qq.Config().Id))
+}
+
+// webhookRouter 按 X-Bot-Appid 将同一回调地址的请求分发给对应的机器人
+func webhookRouter(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhooksMu.RLock()
+		qq, ok := webhooks[path][r.Header.Get("X-Bot-Appid")]
+		webhooksMu.RUnlock()
+		if !ok {
+			sendErrorResponse(w, "appid不相符", http.StatusUnauthorized)
+			return
+		}
+		qq.Init(w, r)
+	}
+}
+
+const (
+	groupMembersPageSize = 200
+	// maxGroupMembersPages 获取群成员列表的最大页数
+	maxGroupMembersPages = 50
+)
+
+// 频道的默认身份组
+const (
+	ownerRoleId        = "4"
+	adminRoleId        = "2"
+	channelAdminRoleId = "5"
+)
+
+// groupMembers 缓存的群成员列表，按资料有效期刷新
+type groupMembers struct {
+	members map[string]struct{}
+	expire  time.Time
+}
+
+// resolveProfile 获取用户资料
+//
+// 群成员列表只包含成员的 openid，只能确认成员身份；groupId 为 0 时为频道用户，使用用户最近所在的频道查询频道成员。
+// 单聊用户没有资料接口，不经过该函数。
+func resolveProfile(selfId int64, groupId int32, userId int64) (*onebot.Sender, error) {
+	qq, err := botFor(selfId)
+	if err != nil {
+		return nil, err
+	}
+	if groupId != 0 {
+		return qq.groupMemberProfile(groupId, userId)
+	}
+	return qq.guildMemberProfile(userId)
+}
+
+// groupMemberProfile 获取群成员资料，不在群成员列表中时返回 nil
+func (qq *Tencent) groupMemberProfile(groupId int32, userId int64) (*onebot.Sender, error) {
+	appId := qq.Config().Id
+	groupOpenId, err := openapi.GroupOpenId(appId, groupId)
+	if err != nil {
+		return nil, err
+	}
+	memberOpenId, err := openapi.UserOpenId(appId, userId)
+	if err != nil {
+		return nil, err
+	}
+	members, err := qq.groupMembers(groupOpenId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := members[memberOpenId]; !ok {
+		return nil, nil
+	}
+	return &onebot.Sender{UserId: userId, Role: onebot.Member}, nil
+}
+
+// groupMembers 获取群成员列表，缓存过期后重新获取，获取失败时使用过期的缓存
+func (qq *Tencent) groupMembers(groupOpenId string) (map[string]struct{}, error) {
+	value, ok := qq.memberLists.Load(groupOpenId)
+	if ok && time.Now().Before(value.(*groupMembers).expire) {
+		return value.(*groupMembers).members, nil
+	}
+	members := make(map[string]struct{})
+	req := &dto.GetGroupMembersReq{Limit: groupMembersPageSize}
+	for page := 0; page < maxGroupMembersPages; page++ {
+		resp, err := qq.Api.GetGroupMembers(groupOpenId, req)
+		if err != nil {
+			if ok {
+				return value.(*groupMembers).members, nil
+			}
+			return nil, err
+		}
+		for _, member := range resp.Members {
+			members[member.MemberOpenId] = struct{}{}
+		}
+		if resp.NextIndex == 0 || len(resp.Members) == 0 {
+			break
+		}
+		req.StartIndex = resp.NextIndex
+	}
+	qq.memberLists.Store(groupOpenId, &groupMembers{members: members, expire: time.Now().Add(profile.TTL())})
+	return members, nil
+}
+
+// guildMemberProfile 获取频道成员资料，用户未在频道中发言时返回 nil
+func (qq *Tencent) guildMemberProfile(userId int64) (*onebot.Sender, error) {
+	guildId, ok := qq.guildUsers.Load(userId)
+	if !ok {
+		return nil, nil
+	}
+	rawUserId, err := openapi.RawOf(openapi.GuildUserNamespace, qq.Config().Id, userId)
+	if err != nil {
+		return nil, err
+	}
+	member, err := qq.Api.GetGuildMember(guildId.(string), rawUserId)
+	if err != nil {
+		return nil, err
+	}
+	sender := &onebot.Sender{UserId: userId, Card: member.Nick, Role: guildRole(member.Roles)}
+	if member.User != nil {
+		sender.NickName = member.User.Username
+	}
+	return sender, nil
+}
+
+// guildRole 将频道身份组转换为群角色
+func guildRole(roles []string) onebot.Role {
+	role := onebot.Member
+	for _, id := range roles {
+		switch id {
+		case ownerRoleId:
+			return onebot.Owner
+		case adminRoleId, channelAdminRoleId:
+			role = onebot.Admin
+		}
+	}
+	return role
+}
@@ -0,0 +1,93 @@
+package tencent
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloadRepointsConfig(t *testing.T) {
+	received := make(chan string, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer downstream.Close()
+
+	old := &config.QQ{Id: 1, Secret: testSecret, TimestampWindow: 300, Mode: "webhook"}
+	qq := NewTencent(old)
+	if err := qq.LoadKeys(old); err != nil {
+		t.Fatal(err)
+	}
+	qq.replaceRelays(nil)
+
+	cfg := &config.Config{}
+	cfg.Bot.QQ = config.QQList{
+		{Id: 2, Secret: testSecondarySecret},
+		{Id: 1, Secret: testSecondarySecret, TimestampWindow: 60, Mode: "webhook", AutoPermissionDemand: true,
+			Relays: []config.Relay{{URL: downstream.URL}}},
+	}
+	if err := qq.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// 按 appid 切换到新的配置
+	if qq.Config() != &cfg.Bot.QQ[1] || qq.Config().TimestampWindow != 60 || !qq.Config().AutoPermissionDemand {
+		t.Fatalf("重新加载后的配置 %+v", qq.Config())
+	}
+	if old.TimestampWindow != 300 {
+		t.Fatal("旧配置被修改")
+	}
+	primary, _ := DeriveKey(testSecondarySecret)
+	if !qq.keys.Load().Primary.Equal(primary) {
+		t.Fatal("签名密钥未刷新")
+	}
+
+	// 新的转发目标生效
+	qq.relay(dto.EventGroupATMessageCreate, []byte(`{"op":0}`), http.Header{})
+	select {
+	case body := <-received:
+		if body != `{"op":0}` {
+			t.Fatalf("转发内容 %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("重新加载后未转发回调")
+	}
+}
+
+func TestReloadStopsOldRelays(t *testing.T) {
+	bot := &config.QQ{Id: 1, Secret: testSecret, Relays: []config.Relay{{URL: "http://127.0.0.1:0"}}}
+	qq := NewTencent(bot)
+	relays, err := newRelays(bot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qq.replaceRelays(relays)
+
+	cfg := &config.Config{}
+	cfg.Bot.QQ = config.QQList{{Id: 1, Secret: testSecret}}
+	if err = qq.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-relays[0].done:
+	default:
+		t.Fatal("原有的转发目标未停止")
+	}
+	if len(*qq.relays.Load()) != 0 {
+		t.Fatal("转发目标未替换")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := &config.QQ{Id: 1, Mode: "webhook", WebhookPath: "/qq", Uid: 1}
+	bot := &config.QQ{Id: 1, Mode: "websocket", WebhookPath: "/qq", Uid: 2, TimestampWindow: 10}
+	if changed := restartRequired(old, bot); !reflect.DeepEqual(changed, []string{"mode", "uid"}) {
+		t.Fatalf("需要重启的设置 %v", changed)
+	}
+}
@@ -3,6 +3,8 @@ package dto
 type EventType string
 
 const (
+	EventReady                 EventType = "READY"
+	EventResumed               EventType = "RESUMED"
 	EventGuildCreate           EventType = "GUILD_CREATE"
 	EventGuildUpdate           EventType = "GUILD_UPDATE"
 	EventGuildDelete           EventType = "GUILD_DELETE"
@@ -0,0 +1,93 @@
+package dto
+
+// Intent 事件订阅类型
+type Intent int
+
+// websocket intent 声明
+const (
+	// IntentGuilds 包含
+	// - GUILD_CREATE
+	// - GUILD_UPDATE
+	// - GUILD_DELETE
+	// - CHANNEL_CREATE
+	// - CHANNEL_UPDATE
+	// - CHANNEL_DELETE
+	IntentGuilds Intent = 1 << iota
+
+	// IntentGuildMembers 包含
+	// - GUILD_MEMBER_ADD
+	// - GUILD_MEMBER_UPDATE
+	// - GUILD_MEMBER_REMOVE
+	IntentGuildMembers
+
+	// IntentGuildMessages 消息事件，仅 *私域* 机器人能够设置此 intents
+	// - MESSAGE_CREATE
+	// - MESSAGE_DELETE
+	IntentGuildMessages
+
+	// IntentGuildMessageReactions 包含
+	// - MESSAGE_REACTION_ADD
+	// - MESSAGE_REACTION_REMOVE
+	IntentGuildMessageReactions
+
+	IntentNone Intent = 0
+)
+
+const (
+	// IntentDirectMessages 私信事件
+	// - DIRECT_MESSAGE_CREATE
+	// - DIRECT_MESSAGE_DELETE
+	IntentDirectMessages Intent = 1 << 12
+
+	// IntentGroupAndC2CEvent 群聊与单聊事件
+	// - C2C_MESSAGE_CREATE
+	// - GROUP_AT_MESSAGE_CREATE
+	// - FRIEND_ADD / FRIEND_DEL / C2C_MSG_REJECT / C2C_MSG_RECEIVE
+	// - GROUP_ADD_ROBOT / GROUP_DEL_ROBOT / GROUP_MSG_REJECT / GROUP_MSG_RECEIVE
+	IntentGroupAndC2CEvent Intent = 1 << 25
+
+	// IntentInteraction 互动事件
+	// - INTERACTION_CREATE
+	IntentInteraction Intent = 1 << 26
+
+	// IntentMessageAudit 消息审核事件
+	// - MESSAGE_AUDIT_PASS
+	// - MESSAGE_AUDIT_REJECT
+	IntentMessageAudit Intent = 1 << 27
+
+	// IntentForums 论坛事件，仅 *私域* 机器人能够设置此 intents
+	IntentForums Intent = 1 << 28
+
+	// IntentAudio 音频事件
+	IntentAudio Intent = 1 << 29
+
+	// IntentPublicGuildMessages 公域机器人 @ 消息事件
+	// - AT_MESSAGE_CREATE
+	// - PUBLIC_MESSAGE_DELETE
+	IntentPublicGuildMessages Intent = 1 << 30
+)
+
+// intentNames 配置文件中使用的 intent 名称
+var intentNames = map[string]Intent{
+	"guilds":                  IntentGuilds,
+	"guild_members":           IntentGuildMembers,
+	"guild_messages":          IntentGuildMessages,
+	"guild_message_reactions": IntentGuildMessageReactions,
+	"direct_messages":         IntentDirectMessages,
+	"group_and_c2c_event":     IntentGroupAndC2CEvent,
+	"interaction":             IntentInteraction,
+	"message_audit":           IntentMessageAudit,
+	"forums":                  IntentForums,
+	"audio":                   IntentAudio,
+	"public_guild_messages":   IntentPublicGuildMessages,
+}
+
+// ParseIntent 根据名称获取 intent
+func ParseIntent(name string) (Intent, bool) {
+	intent, ok := intentNames[name]
+	return intent, ok
+}
+
+// DefaultIntents 未配置时订阅的事件：公域频道 @ 消息、群聊与单聊、频道与成员变更、互动
+const DefaultIntents = IntentGuilds | IntentGuildMembers | IntentPublicGuildMessages |
+	IntentGroupAndC2CEvent | IntentInteraction
@@ -18,18 +18,21 @@ const (
 	WSHello
 	WSHeartbeatAck
 	HTTPCallbackAck
+	HTTPCallbackValidation
 )
 
 // opMeans op 对应的含义字符串标识
 var opMeans = map[OPCode]string{
-	WSDispatchEvent:  "Event",
-	WSHeartbeat:      "Heartbeat",
-	WSIdentity:       "Identity",
-	WSResume:         "Resume",
-	WSReconnect:      "Reconnect",
-	WSInvalidSession: "InvalidSession",
-	WSHello:          "Hello",
-	WSHeartbeatAck:   "HeartbeatAck",
+	WSDispatchEvent:        "Event",
+	WSHeartbeat:            "Heartbeat",
+	WSIdentity:             "Identity",
+	WSResume:               "Resume",
+	WSReconnect:            "Reconnect",
+	WSInvalidSession:       "InvalidSession",
+	WSHello:                "Hello",
+	WSHeartbeatAck:         "HeartbeatAck",
+	HTTPCallbackAck:        "HTTPCallbackAck",
+	HTTPCallbackValidation: "HTTPCallbackValidation",
 }
 
 // OPMeans 返回 op 含义
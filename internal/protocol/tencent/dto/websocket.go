@@ -0,0 +1,61 @@
+package dto
+
+// WebsocketAP 获取 websocket 接入点返回的数据
+type WebsocketAP struct {
+	URL               string            `json:"url"`
+	Shards            uint32            `json:"shards"`
+	SessionStartLimit SessionStartLimit `json:"session_start_limit"`
+}
+
+// SessionStartLimit 连接频率限制
+type SessionStartLimit struct {
+	Total          uint32 `json:"total"`
+	Remaining      uint32 `json:"remaining"`
+	ResetAfter     uint32 `json:"reset_after"`
+	MaxConcurrency uint32 `json:"max_concurrency"`
+}
+
+// ShardConfig 连接的 shard 配置，ShardID 从 0 开始，ShardCount 最小为 1
+type ShardConfig struct {
+	ShardID    uint32
+	ShardCount uint32
+}
+
+// WSHelloData hello 返回的数据
+type WSHelloData struct {
+	HeartbeatInterval int `json:"heartbeat_interval"`
+}
+
+// WSIdentityData 鉴权数据
+type WSIdentityData struct {
+	Token      string              `json:"token"`
+	Intents    Intent              `json:"intents"`
+	Shard      []uint32            `json:"shard"` // array of two integers (shard_id, num_shards)
+	Properties WSIdentityPropertie `json:"properties,omitempty"`
+}
+
+// WSIdentityPropertie 鉴权附加信息
+type WSIdentityPropertie struct {
+	Os      string `json:"$os,omitempty"`
+	Browser string `json:"$browser,omitempty"`
+	Device  string `json:"$device,omitempty"`
+}
+
+// WSResumeData 重连数据
+type WSResumeData struct {
+	Token     string `json:"token"`
+	SessionID string `json:"session_id"`
+	Seq       uint32 `json:"seq"`
+}
+
+// WSReadyData ready 事件返回的数据
+type WSReadyData struct {
+	Version   int    `json:"version"`
+	SessionID string `json:"session_id"`
+	User      struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Bot      bool   `json:"bot"`
+	} `json:"user"`
+	Shard []uint32 `json:"shard"`
+}
@@ -0,0 +1,329 @@
+package tencent
+
+import (
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	logging "github.com/sacOO7/go-logger"
+	"github.com/sacOO7/gowebsocket"
+)
+
+// 重连间隔，测试中缩短
+var (
+	gatewayRetryMin = 3 * time.Second
+	gatewayRetryMax = 2 * time.Minute
+)
+
+// Gateway QQ 官方 websocket 网关连接，收到的事件与 webhook 进入同一处理流程
+//
+// 断线后优先使用 session_id 与最后的 seq 恢复会话，服务端要求重新鉴权时再重新 Identify。
+type Gateway struct {
+	qq       *Tencent
+	intents  dto.Intent
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu        sync.Mutex
+	conn      *gowebsocket.Socket
+	done      chan struct{}
+	closeOnce *sync.Once
+	sessionId string
+	seq       uint32
+}
+
+// gatewayPayload 网关下发的数据，d 按 op 再解析
+type gatewayPayload struct {
+	dto.PayloadBase
+	Data json.RawMessage `json:"d,omitempty"`
+}
+
+// NewGateway 创建网关连接
+func NewGateway(qq *Tencent, intents dto.Intent) *Gateway {
+	return &Gateway{
+		qq:      qq,
+		intents: intents,
+		stop:    make(chan struct{}),
+	}
+}
+
+// parseIntents 将配置中的 intent 名称转换为订阅类型，未配置时使用默认订阅
+func parseIntents(names []string) (dto.Intent, error) {
+	if len(names) == 0 {
+		return dto.DefaultIntents, nil
+	}
+	var intents dto.Intent
+	for _, name := range names {
+		intent, ok := dto.ParseIntent(name)
+		if !ok {
+			return dto.IntentNone, fmt.Errorf("未知的 intent: %s", name)
+		}
+		intents |= intent
+	}
+	return intents, nil
+}
+
+// Run 连接网关并在断开后按指数退避重连，直到调用 Stop
+func (g *Gateway) Run() {
+	delay := gatewayRetryMin
+	for !g.stopped() {
+		started := time.Now()
+		if err := g.session(); err != nil {
+			logger.Errorf("QQ机器人 %d 连接网关失败: %v", g.qq.Config().Id, err)
+		}
+		if g.stopped() {
+			return
+		}
+
+		// 连接保持一段时间后视为恢复正常，重置重连间隔
+		if time.Since(started) > gatewayRetryMax {
+			delay = gatewayRetryMin
+		}
+		logger.Warnf("QQ机器人 %d 网关将在%v后重连", g.qq.Config().Id, delay)
+		select {
+		case <-time.After(delay):
+		case <-g.stop:
+			return
+		}
+		if delay *= 2; delay > gatewayRetryMax {
+			delay = gatewayRetryMax
+		}
+	}
+}
+
+// Stop 关闭网关连接且不再重连，退避等待中的 Run 立即退出
+func (g *Gateway) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// stopped 是否已调用 Stop
+func (g *Gateway) stopped() bool {
+	select {
+	case <-g.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// session 建立一次连接，等待连接断开或调用 Stop 后关闭底层连接
+func (g *Gateway) session() error {
+	conn, done, err := g.connect()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-done:
+	case <-g.stop:
+	}
+	g.close()
+	// gowebsocket 的 Close 读写 IsConnected 存在竞争，直接关闭底层连接
+	if conn.Conn != nil {
+		_ = conn.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		_ = conn.Conn.Close()
+	}
+	return nil
+}
+
+// connect 获取接入点并建立连接，返回连接断开时关闭的通道
+func (g *Gateway) connect() (*gowebsocket.Socket, chan struct{}, error) {
+	ap, err := g.qq.Api.GetGatewayBot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取网关地址失败: %v", err)
+	}
+	if ap.URL == "" {
+		return nil, nil, fmt.Errorf("网关地址为空")
+	}
+	logger.Infof("QQ机器人 %d 尝试连接网关: %s", g.qq.Config().Id, ap.URL)
+
+	conn := gowebsocket.New(ap.URL)
+	conn.GetLogger().SetLevel(logging.OFF)
+	// UseSSL 为 true 时会跳过证书校验
+	conn.ConnectionOptions = gowebsocket.ConnectionOptions{
+		UseSSL:       false,
+		Subprotocols: []string{},
+	}
+	done := make(chan struct{})
+	once := &sync.Once{}
+	disconnect := func() {
+		once.Do(func() { close(done) })
+	}
+
+	conn.OnConnectError = func(err error, socket gowebsocket.Socket) {
+		logger.Errorf("%v 连接出错:  %v", ap.URL, err)
+		disconnect()
+	}
+	conn.OnTextMessage = func(message string, socket gowebsocket.Socket) {
+		g.handleMessage([]byte(message), done)
+	}
+	conn.OnDisconnected = func(err error, socket gowebsocket.Socket) {
+		logger.Warnf("QQ机器人 %d 网关断开连接: %v", g.qq.Config().Id, err)
+		disconnect()
+	}
+
+	g.mu.Lock()
+	g.conn = &conn
+	g.done = done
+	g.closeOnce = once
+	g.mu.Unlock()
+
+	conn.Connect()
+	return &conn, done, nil
+}
+
+// close 结束当前连接，由 Run 关闭底层连接后按会话信息恢复
+func (g *Gateway) close() {
+	g.mu.Lock()
+	done, once := g.done, g.closeOnce
+	g.mu.Unlock()
+	if once == nil {
+		return
+	}
+	once.Do(func() { close(done) })
+}
+
+// send 发送网关数据
+func (g *Gateway) send(op dto.OPCode, data any) {
+	g.mu.Lock()
+	conn := g.conn
+	g.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	msg, err := json.Marshal(map[string]any{"op": op, "d": data})
+	if err != nil {
+		logger.Warnf("网关数据序列化失败: %v", err)
+		return
+	}
+	conn.SendText(string(msg))
+}
+
+// handleMessage 处理网关下发的数据
+func (g *Gateway) handleMessage(message []byte, done chan struct{}) {
+	logger.Debugf("QQ机器人 %d 网关数据 %s", g.qq.Config().Id, string(message))
+	var payload gatewayPayload
+	if err := json.Unmarshal(message, &payload); err != nil {
+		logger.Warnf("无法解析网关数据: %v", err)
+		return
+	}
+
+	switch payload.OPCode {
+	case dto.WSHello:
+		var hello dto.WSHelloData
+		if err := json.Unmarshal(payload.Data, &hello); err != nil {
+			logger.Warnf("无法解析 Hello: %v", err)
+			return
+		}
+		if err := g.authenticate(); err != nil {
+			logger.Errorf("QQ机器人 %d 网关鉴权失败: %v", g.qq.Config().Id, err)
+			g.close()
+			return
+		}
+		go g.heartbeat(time.Duration(hello.HeartbeatInterval)*time.Millisecond, done)
+	case dto.WSDispatchEvent:
+		g.mu.Lock()
+		if payload.Seq > 0 {
+			g.seq = payload.Seq
+		}
+		g.mu.Unlock()
+		g.handleDispatch(&payload, message)
+	case dto.WSHeartbeatAck:
+		logger.Debugf("QQ机器人 %d 心跳确认", g.qq.Config().Id)
+	case dto.WSReconnect:
+		logger.Infof("QQ机器人 %d 服务器要求重连", g.qq.Config().Id)
+		g.close()
+	case dto.WSInvalidSession:
+		// 会话失效，清空会话信息后重新鉴权
+		logger.Warnf("QQ机器人 %d 会话失效，将重新鉴权", g.qq.Config().Id)
+		g.mu.Lock()
+		g.sessionId = ""
+		g.seq = 0
+		g.mu.Unlock()
+		g.close()
+	default:
+		logger.Warnf("未处理操作: %s", string(message))
+	}
+}
+
+// authenticate 有会话时恢复会话，否则发送 Identify
+func (g *Gateway) authenticate() error {
+	token, err := g.qq.Api.AuthToken()
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	sessionId, seq := g.sessionId, g.seq
+	g.mu.Unlock()
+
+	if sessionId != "" {
+		logger.Infof("QQ机器人 %d 恢复会话 %s seq=%d", g.qq.Config().Id, sessionId, seq)
+		g.send(dto.WSResume, &dto.WSResumeData{
+			Token:     token,
+			SessionID: sessionId,
+			Seq:       seq,
+		})
+		return nil
+	}
+	g.send(dto.WSIdentity, &dto.WSIdentityData{
+		Token:   token,
+		Intents: g.intents,
+		Shard:   []uint32{0, 1},
+	})
+	return nil
+}
+
+// heartbeat 按 Hello 下发的间隔发送心跳，连接断开后退出
+func (g *Gateway) heartbeat(interval time.Duration, done chan struct{}) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			g.mu.Lock()
+			seq := g.seq
+			g.mu.Unlock()
+			if seq == 0 {
+				g.send(dto.WSHeartbeat, nil)
+			} else {
+				g.send(dto.WSHeartbeat, seq)
+			}
+		}
+	}
+}
+
+// handleDispatch 处理事件，READY/RESUMED 维护会话，其余事件交给 HandlerEvent
+func (g *Gateway) handleDispatch(payload *gatewayPayload, message []byte) {
+	switch payload.Type {
+	case dto.EventReady:
+		var ready dto.WSReadyData
+		if err := json.Unmarshal(payload.Data, &ready); err != nil {
+			logger.Warnf("无法解析 READY: %v", err)
+			return
+		}
+		g.mu.Lock()
+		g.sessionId = ready.SessionID
+		g.mu.Unlock()
+		logger.Infof("QQ机器人 %d 网关已就绪: %s(%s)", g.qq.Config().Id, ready.User.Username, ready.User.ID)
+	case dto.EventResumed:
+		logger.Infof("QQ机器人 %d 会话已恢复", g.qq.Config().Id)
+	default:
+		event := &dto.Payload{}
+		if err := json.Unmarshal(message, event); err != nil {
+			logger.Warnf("parse gateway payload err %s", err)
+			return
+		}
+		if !g.qq.accept(event, message, nil) {
+			logger.Debugf("忽略重复事件: %s", event.ID)
+		}
+	}
+}
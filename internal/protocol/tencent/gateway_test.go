@@ -0,0 +1,151 @@
+package tencent
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/openapi/mock"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startGateway 启动模拟网关，serve 处理每个连接，返回的计数为建立的连接数
+func startGateway(t *testing.T, serve func(n int64, conn *websocket.Conn)) (*Gateway, *atomic.Int64) {
+	t.Helper()
+	var connections atomic.Int64
+	upgrader := websocket.Upgrader{}
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(connections.Add(1), conn)
+	}))
+	t.Cleanup(gateway.Close)
+
+	s := mock.NewServer()
+	t.Cleanup(s.Close)
+	t.Cleanup(s.Install())
+	s.GatewayURL = "ws" + strings.TrimPrefix(gateway.URL, "http")
+
+	qq := NewTencent(&config.QQ{Id: 1, Secret: testSecret, Mode: "websocket"})
+	return NewGateway(qq, dto.DefaultIntents), &connections
+}
+
+// setRetry 临时修改重连间隔
+func setRetry(t *testing.T, delay time.Duration) {
+	retryMin, retryMax := gatewayRetryMin, gatewayRetryMax
+	gatewayRetryMin, gatewayRetryMax = delay, delay
+	t.Cleanup(func() { gatewayRetryMin, gatewayRetryMax = retryMin, retryMax })
+}
+
+// hello 发送 Hello 并读取客户端的鉴权数据
+func hello(t *testing.T, conn *websocket.Conn) gatewayPayload {
+	if err := conn.WriteJSON(map[string]any{"op": dto.WSHello, "d": dto.WSHelloData{HeartbeatInterval: 60000}}); err != nil {
+		t.Error(err)
+	}
+	var payload gatewayPayload
+	if err := conn.ReadJSON(&payload); err != nil {
+		t.Error(err)
+	}
+	return payload
+}
+
+// runGateway 在后台运行网关，返回 Run 退出时关闭的通道
+func runGateway(g *Gateway) chan struct{} {
+	exited := make(chan struct{})
+	go func() {
+		g.Run()
+		close(exited)
+	}()
+	return exited
+}
+
+func TestGatewayResume(t *testing.T) {
+	setRetry(t, 10*time.Millisecond)
+	resumed := make(chan dto.WSResumeData, 1)
+	g, _ := startGateway(t, func(n int64, conn *websocket.Conn) {
+		payload := hello(t, conn)
+		if n > 1 {
+			var resume dto.WSResumeData
+			if payload.OPCode != dto.WSResume || json.Unmarshal(payload.Data, &resume) != nil {
+				t.Errorf("重连后收到 op=%d, 期望恢复会话", payload.OPCode)
+			}
+			resumed <- resume
+			_, _, _ = conn.ReadMessage()
+			return
+		}
+		if payload.OPCode != dto.WSIdentity {
+			t.Errorf("首次连接收到 op=%d, 期望 Identify", payload.OPCode)
+		}
+		// 就绪后断开，客户端应使用会话信息恢复
+		_ = conn.WriteJSON(map[string]any{"op": dto.WSDispatchEvent, "s": 3, "t": dto.EventReady, "d": map[string]any{"session_id": "session"}})
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""))
+	})
+	exited := runGateway(g)
+	defer func() {
+		g.Stop()
+		<-exited
+	}()
+
+	select {
+	case resume := <-resumed:
+		if resume.SessionID != "session" || resume.Seq != 3 {
+			t.Fatalf("恢复会话 %+v", resume)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("断开后未恢复会话")
+	}
+}
+
+func TestGatewayStopWhileConnected(t *testing.T) {
+	closed := make(chan struct{})
+	g, _ := startGateway(t, func(n int64, conn *websocket.Conn) {
+		hello(t, conn)
+		// 阻塞到客户端关闭连接
+		_, _, _ = conn.ReadMessage()
+		close(closed)
+	})
+	exited := runGateway(g)
+
+	time.Sleep(100 * time.Millisecond)
+	g.Stop()
+	for name, ch := range map[string]chan struct{}{"连接未关闭": closed, "Run 未退出": exited} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal(name)
+		}
+	}
+}
+
+func TestGatewayStopDuringBackoff(t *testing.T) {
+	// 退避间隔远大于测试时长，Stop 未打断退避时 Run 无法退出
+	setRetry(t, time.Minute)
+	g, connections := startGateway(t, func(n int64, conn *websocket.Conn) {
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""))
+	})
+	exited := runGateway(g)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for connections.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	g.Stop()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("退避期间调用 Stop 后 Run 未退出")
+	}
+	if n := connections.Load(); n != 1 {
+		t.Fatalf("建立连接 %d 次, 期望 1", n)
+	}
+}
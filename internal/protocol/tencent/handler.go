@@ -1,13 +1,16 @@
 package tencent
 
 import (
-	"GoQHttp/internal/constant"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/lifecycle"
 	"GoQHttp/internal/onebot"
-	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/openapi"
+	"GoQHttp/internal/profile"
 	"GoQHttp/internal/protocol/tencent/dto"
 	"GoQHttp/logger"
 	"GoQHttp/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -73,27 +76,40 @@ func parseFaceTags(input string) bool {
 	return len(tags) > 0
 }
 
-func GroupAddRobotEventHandler(event *dto.Payload, data *dto.GroupAddRobotDataEvent) error {
+func (qq *Tencent) GroupAddRobotEventHandler(event *dto.Payload, data *dto.GroupAddRobotDataEvent) error {
 	logger.Infof("收到群添加机器人事件, 群号:%v", data.GroupOpenId)
+	// 等待期内重新添加时取消数据删除
+	if groupId, err := openapi.LookupGroupId(qq.Config().Id, data.GroupOpenId); err == nil {
+		lifecycle.Restored(qq.Identity(), lifecycle.GroupSubject, int64(groupId))
+	}
 	return nil
 }
 
-func GroupDelRobotEventHandler(event *dto.Payload, data *dto.GroupDelRobotDataEvent) error {
+func (qq *Tencent) GroupDelRobotEventHandler(event *dto.Payload, data *dto.GroupDelRobotDataEvent) error {
 	logger.Infof("收到群删除机器人事件, 群号:%v", data.GroupOpenId)
+	groupId, err := openapi.LookupGroupId(qq.Config().Id, data.GroupOpenId)
+	if errors.Is(err, idmap.ErrNotFound) {
+		// 群没有收发过消息，没有需要删除的数据
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lifecycle.Removed(qq.Identity(), lifecycle.GroupSubject, int64(groupId), string(event.Type))
 	return nil
 }
 
-func GroupMsgRejectEventHandler(event *dto.Payload, data *dto.GroupMsgRejectDataEvent) error {
+func (qq *Tencent) GroupMsgRejectEventHandler(event *dto.Payload, data *dto.GroupMsgRejectDataEvent) error {
 	logger.Infof("收到群聊拒绝机器人主动消息事件, 群号:%v", data.GroupOpenId)
 	return nil
 }
 
-func GroupMsgReceiveEventHandler(event *dto.Payload, data *dto.GroupMsgReceiveDataEvent) error {
+func (qq *Tencent) GroupMsgReceiveEventHandler(event *dto.Payload, data *dto.GroupMsgReceiveDataEvent) error {
 	logger.Infof("收到群聊接受机器人主动消息事件, 群号:%v", data.GroupOpenId)
 	return nil
 }
 
-func GroupAtMessageEventHandler(event *dto.Payload, data *dto.GroupATMessageDataEvent) error {
+func (qq *Tencent) GroupAtMessageEventHandler(event *dto.Payload, data *dto.GroupATMessageDataEvent) error {
 
 	jsonBytes, err := json.Marshal(data)
 	if err != nil {
@@ -102,19 +118,19 @@ func GroupAtMessageEventHandler(event *dto.Payload, data *dto.GroupATMessageData
 		logger.Infof("群消息: %+v", string(jsonBytes))
 	}
 
-	GroupId, err := utils.DBUtil.GroupInsert(data.GroupId, data.GroupOpenId)
+	GroupId, err := openapi.GroupId(qq.Config().Id, data.GroupOpenId)
 	if err != nil {
 		logger.Warnf("群维护失败: %v", err)
 		return err
 	}
 
-	SenderId, err := utils.DBUtil.SenderInsert(data.Author.UserOpenId, data.Author.UserId)
+	SenderId, err := openapi.UserId(qq.Config().Id, data.Author.UserOpenId)
 	if err != nil {
 		logger.Warnf("群用户维护失败: %v", err)
 		return err
 	}
 
-	MessageId, err := utils.DBUtil.GroupMessageInsert(data.MsgId, GroupId, SenderId)
+	MessageId, err := utils.Store.GroupMessageInsert(qq.Config().Id, data.MsgId, GroupId, SenderId)
 	if err != nil {
 		logger.Warnf("群消息维护失败: %v", err)
 		return err
@@ -152,7 +168,7 @@ func GroupAtMessageEventHandler(event *dto.Payload, data *dto.GroupATMessageData
 	messageRequest := onebot.MessageRequest{
 		MessageBase: onebot.MessageBase{
 			Time:     time.Now().Unix(),
-			SelfId:   1, // 会在发送的时候根据websocket内部的XSelfId进行覆盖
+			SelfId:   qq.SelfId,
 			PostType: onebot.MessagePost,
 		},
 		MessageType:     onebot.GroupMessage,
@@ -165,150 +181,276 @@ func GroupAtMessageEventHandler(event *dto.Payload, data *dto.GroupATMessageData
 		RawMessage:      rawMessage,
 		Font:            1,
 		Sender: onebot.Sender{
-			UserId:   SenderId,
-			NickName: "UNKNOWN",
-			Sex:      "未知",
-			Age:      0,
-			Card:     "",
-			Area:     "",
-			Level:    "",
-			Role:     "",
-			Title:    "",
+			UserId: SenderId,
 		},
 	}
+	profile.Enrich(qq.Identity(), GroupId, &messageRequest.Sender)
 
-	protocol.BroadcastChan <- messageRequest
+	qq.emit(event, messageRequest)
 	return nil
 }
 
-func GroupMessageEventHandler(event *dto.Payload, data *dto.GroupMessageDataEvent) error {
+func (qq *Tencent) GroupMessageEventHandler(event *dto.Payload, data *dto.GroupMessageDataEvent) error {
 	return nil
 }
 
 // GuildEventHandler 频道事件handler
-func GuildEventHandler(event *dto.Payload, data *dto.GuildDataEvent) error {
+func (qq *Tencent) GuildEventHandler(event *dto.Payload, data *dto.GuildDataEvent) error {
 
 	return nil
 }
 
 // GuildMemberEventHandler 频道成员事件 handler
-func GuildMemberEventHandler(event *dto.Payload, data *dto.GuildMemberDataEvent) error {
+func (qq *Tencent) GuildMemberEventHandler(event *dto.Payload, data *dto.GuildMemberDataEvent) error {
 	return nil
 }
 
 // ChannelEventHandler 子频道事件 handler
-func ChannelEventHandler(event *dto.Payload, data *dto.ChannelDataEvent) error {
+func (qq *Tencent) ChannelEventHandler(event *dto.Payload, data *dto.ChannelDataEvent) error {
 	return nil
 }
 
 // MessageEventHandler 消息事件 handler
-func MessageEventHandler(event *dto.Payload, data *dto.MessageDataEvent) error {
+func (qq *Tencent) MessageEventHandler(event *dto.Payload, data *dto.MessageDataEvent) error {
 	return nil
 }
 
 // MessageDeleteEventHandler 消息事件 handler
-func MessageDeleteEventHandler(event *dto.Payload, data *dto.MessageDeleteDataEvent) error {
+func (qq *Tencent) MessageDeleteEventHandler(event *dto.Payload, data *dto.MessageDeleteDataEvent) error {
 	return nil
 }
 
 // PublicMessageDeleteEventHandler 消息事件 handler
-func PublicMessageDeleteEventHandler(event *dto.Payload, data *dto.PublicMessageDeleteDataEvent) error {
+func (qq *Tencent) PublicMessageDeleteEventHandler(event *dto.Payload, data *dto.PublicMessageDeleteDataEvent) error {
 	return nil
 }
 
 // DirectMessageDeleteEventHandler 消息事件 handler
-func DirectMessageDeleteEventHandler(event *dto.Payload, data *dto.DirectMessageDeleteDataEvent) error {
+func (qq *Tencent) DirectMessageDeleteEventHandler(event *dto.Payload, data *dto.DirectMessageDeleteDataEvent) error {
 	return nil
 }
 
 // MessageReactionEventHandler 表情表态事件 handler
-func MessageReactionEventHandler(event *dto.Payload, data *dto.MessageReactionDataEvent) error {
+func (qq *Tencent) MessageReactionEventHandler(event *dto.Payload, data *dto.MessageReactionDataEvent) error {
 	return nil
 }
 
 // ATMessageEventHandler at 机器人消息事件 handler
-func ATMessageEventHandler(event *dto.Payload, data *dto.ATMessageDataEvent) error {
-	guild, err := constant.OpenApi.GetGuild(data.GuildID)
+func (qq *Tencent) ATMessageEventHandler(event *dto.Payload, data *dto.ATMessageDataEvent) error {
+	messageRequest, err := qq.guildMessage((*dto.Message)(data))
 	if err != nil {
+		logger.Warnf("频道消息维护失败: %v", err)
 		return err
 	}
-	channel, err := constant.OpenApi.GetChannel(data.ChannelID)
-	if err != nil {
+	if messageRequest.ChannelId, err = openapi.IdOf(openapi.ChannelNamespace, qq.Config().Id, data.ChannelID); err != nil {
+		logger.Warnf("子频道维护失败: %v", err)
 		return err
 	}
-	rawMessage := data.Content
-	attachments, err := constant.CQCode.BuildCQCodeFromAttachments(data.Attachments)
+	messageRequest.MessageType = onebot.GuildMessage
+	messageRequest.SubType = onebot.ChannelSubType
+	// 日志只记录原始id，不为此查询频道与子频道信息，查询失败或缺少权限时不影响消息分发
+	logger.Infof("[频道AT][%v][%v]%v:%v", data.GuildID, data.ChannelID, data.Author.Username, messageRequest.RawMessage)
+
+	profile.Enrich(qq.Identity(), 0, &messageRequest.Sender)
+	qq.emit(event, messageRequest)
+	return nil
+}
+
+// DirectMessageEventHandler 私信消息事件 handler，guild_id 为私信会话所在的频道，回复时携带 guild_id
+func (qq *Tencent) DirectMessageEventHandler(event *dto.Payload, data *dto.DirectMessageDataEvent) error {
+	messageRequest, err := qq.guildMessage((*dto.Message)(data))
 	if err != nil {
+		logger.Warnf("频道私信维护失败: %v", err)
 		return err
 	}
-	rawMessage += strings.Join(attachments, "")
-	logger.Infof("[频道AT][%v][%v]%v:%v", guild.Name, channel.Name, data.Author.Username, rawMessage)
+	messageRequest.MessageType = onebot.PrivateMessage
+	messageRequest.SubType = onebot.Other
+	logger.Infof("[频道私聊]%v:%v", data.Author.Username, messageRequest.RawMessage)
+
+	profile.Enrich(qq.Identity(), 0, &messageRequest.Sender)
+	qq.emit(event, messageRequest)
 	return nil
 }
 
-// DirectMessageEventHandler 私信消息事件 handler
-func DirectMessageEventHandler(event *dto.Payload, data *dto.DirectMessageDataEvent) error {
-	rawMessage := data.Content
-	attachments, err := constant.CQCode.BuildCQCodeFromAttachments(data.Attachments)
+// guildMessage 转换频道消息与频道私信，频道、用户与消息映射为数字id
+func (qq *Tencent) guildMessage(data *dto.Message) (onebot.MessageRequest, error) {
+	if data.Author == nil {
+		return onebot.MessageRequest{}, errors.New("消息缺少发送者")
+	}
+	appId := qq.Config().Id
+	guildId, err := openapi.IdOf(openapi.GuildNamespace, appId, data.GuildID)
 	if err != nil {
-		return err
+		return onebot.MessageRequest{}, err
 	}
-	rawMessage += strings.Join(attachments, "")
-	logger.Infof("[频道私聊]%v:%v", data.Author.Username, rawMessage)
-	return nil
+	userId, err := openapi.IdOf(openapi.GuildUserNamespace, appId, data.Author.ID)
+	if err != nil {
+		return onebot.MessageRequest{}, err
+	}
+	messageId, err := openapi.IdOf(openapi.MessageNamespace, appId, data.ID)
+	if err != nil {
+		return onebot.MessageRequest{}, err
+	}
+
+	urls := make([]string, 0, len(data.Attachments))
+	for _, attachment := range data.Attachments {
+		urls = append(urls, attachment.URL)
+	}
+	messages := contentElements(data.Content, urls)
+	sender := onebot.Sender{
+		UserId:   userId,
+		NickName: data.Author.Username,
+	}
+	if data.Member != nil {
+		sender.Card = data.Member.Nick
+		if len(data.Member.Roles) > 0 {
+			sender.Role = guildRole(data.Member.Roles)
+		}
+	}
+	// 频道私信所在的频道不是用户所在的频道，使用来源频道
+	if !data.DirectMessage {
+		qq.guildUsers.Store(userId, data.GuildID)
+	} else if data.SrcGuildID != "" {
+		qq.guildUsers.Store(userId, data.SrcGuildID)
+	}
+	return onebot.MessageRequest{
+		MessageBase: onebot.MessageBase{
+			Time:     time.Now().Unix(),
+			SelfId:   qq.SelfId,
+			PostType: onebot.MessagePost,
+		},
+		MessageId:       int32(messageId),
+		UserId:          userId,
+		GuildId:         guildId,
+		OriginalMessage: messages,
+		Message:         messages,
+		RawMessage:      onebot.CQString(messages),
+		Font:            1,
+		Sender:          sender,
+	}, nil
+}
+
+// contentElements 将消息文本与附件转换为消息段，频道附件地址不带协议时补全为 https
+func contentElements(content string, urls []string) []*onebot.Element {
+	var messages []*onebot.Element
+	if content = strings.TrimSpace(content); content != "" {
+		messages = append(messages, &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: content}})
+	}
+	for _, url := range urls {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			url = "https://" + url
+		}
+		messages = append(messages, &onebot.Element{ElementType: onebot.ImageType, Data: &onebot.Image{File: url, Url: url}})
+	}
+	return messages
 }
 
 // AudioEventHandler 音频机器人事件 handler
-func AudioEventHandler(event *dto.Payload, data *dto.AudioDataEvent) error {
+func (qq *Tencent) AudioEventHandler(event *dto.Payload, data *dto.AudioDataEvent) error {
 	return nil
 }
 
 // MessageAuditEventHandler 消息审核事件 handler
-func MessageAuditEventHandler(event *dto.Payload, data *dto.MessageAuditDataEvent) error {
+func (qq *Tencent) MessageAuditEventHandler(event *dto.Payload, data *dto.MessageAuditDataEvent) error {
 	return nil
 }
 
 // ThreadEventHandler 论坛主题事件 handler
-func ThreadEventHandler(event *dto.Payload, data *dto.ThreadDataEvent) error {
+func (qq *Tencent) ThreadEventHandler(event *dto.Payload, data *dto.ThreadDataEvent) error {
 	return nil
 }
 
 // PostEventHandler 论坛回帖事件 handler
-func PostEventHandler(event *dto.Payload, data *dto.PostDataEvent) error {
+func (qq *Tencent) PostEventHandler(event *dto.Payload, data *dto.PostDataEvent) error {
 	return nil
 }
 
 // ReplyEventHandler 论坛帖子回复事件 handler
-func ReplyEventHandler(event *dto.Payload, data *dto.ReplyDataEvent) error {
+func (qq *Tencent) ReplyEventHandler(event *dto.Payload, data *dto.ReplyDataEvent) error {
 	return nil
 }
 
 // ForumAuditEventHandler 论坛帖子审核事件 handler
-func ForumAuditEventHandler(event *dto.Payload, data *dto.ForumAuditDataEvent) error {
+func (qq *Tencent) ForumAuditEventHandler(event *dto.Payload, data *dto.ForumAuditDataEvent) error {
 	return nil
 }
 
 // InteractionEventHandler 互动事件 handler
-func InteractionEventHandler(event *dto.Payload, data *dto.InteractionDataEvent) error {
+func (qq *Tencent) InteractionEventHandler(event *dto.Payload, data *dto.InteractionDataEvent) error {
 	return nil
 }
 
-func C2CMessageEventHandler(event *dto.Payload, data *dto.C2CMessageDataEvent) error {
+// C2CMessageEventHandler 单聊消息事件 handler
+func (qq *Tencent) C2CMessageEventHandler(event *dto.Payload, data *dto.C2CMessageDataEvent) error {
+	if data.Author == nil {
+		return errors.New("单聊消息缺少发送者")
+	}
+	logger.Infof("单聊消息: %v", data.Content)
+
+	UserId, err := openapi.C2CUserId(qq.Config().Id, data.Author.UserOpenId)
+	if err != nil {
+		logger.Warnf("单聊用户维护失败: %v", err)
+		return err
+	}
+	// 单聊消息与群消息记录在一起，群号为 0
+	MessageId, err := utils.Store.GroupMessageInsert(qq.Config().Id, data.Id, 0, UserId)
+	if err != nil {
+		logger.Warnf("单聊消息维护失败: %v", err)
+		return err
+	}
+
+	urls := make([]string, 0, len(data.Attachments))
+	for _, attachment := range data.Attachments {
+		urls = append(urls, attachment.Url)
+	}
+	messages := contentElements(data.Content, urls)
+	messageRequest := onebot.MessageRequest{
+		MessageBase: onebot.MessageBase{
+			Time:     time.Now().Unix(),
+			SelfId:   qq.SelfId,
+			PostType: onebot.MessagePost,
+		},
+		MessageType:     onebot.PrivateMessage,
+		SubType:         onebot.Friend,
+		MessageId:       MessageId,
+		UserId:          UserId,
+		OriginalMessage: messages,
+		Message:         messages,
+		RawMessage:      onebot.CQString(messages),
+		Font:            1,
+		Sender: onebot.Sender{
+			UserId: UserId,
+		},
+	}
+	// 单聊用户没有资料接口
+	profile.Apply(qq.Identity(), 0, &messageRequest.Sender)
+
+	qq.emit(event, messageRequest)
 	return nil
 }
 
-func FriendAddEventHandler(event *dto.Payload, data *dto.FriendAddDataEvent) error {
+func (qq *Tencent) FriendAddEventHandler(event *dto.Payload, data *dto.FriendAddDataEvent) error {
+	if userId, err := openapi.LookupC2CUserId(qq.Config().Id, data.OpenId); err == nil {
+		lifecycle.Restored(qq.Identity(), lifecycle.UserSubject, userId)
+	}
 	return nil
 }
 
-func FriendDelEventHandler(event *dto.Payload, data *dto.FriendDelDataEvent) error {
+func (qq *Tencent) FriendDelEventHandler(event *dto.Payload, data *dto.FriendDelDataEvent) error {
+	userId, err := openapi.LookupC2CUserId(qq.Config().Id, data.OpenId)
+	if errors.Is(err, idmap.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lifecycle.Removed(qq.Identity(), lifecycle.UserSubject, userId, string(event.Type))
 	return nil
 }
 
-func C2CMsgRejectHandler(event *dto.Payload, data *dto.FriendMsgRejectDataEvent) error {
+func (qq *Tencent) C2CMsgRejectHandler(event *dto.Payload, data *dto.FriendMsgRejectDataEvent) error {
 	return nil
 }
 
-func C2CMsgReceiveHandler(event *dto.Payload, data *dto.FriendMsgReceiveDataEvent) error {
+func (qq *Tencent) C2CMsgReceiveHandler(event *dto.Payload, data *dto.FriendMsgReceiveDataEvent) error {
 	return nil
 }
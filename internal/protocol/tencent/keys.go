@@ -0,0 +1,65 @@
+package tencent
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+)
+
+// BotKeys 机器人回调签名使用的密钥对，启动及重新加载配置时生成一次
+type BotKeys struct {
+	// Primary 当前密钥，用于回调地址验证签名及校验回调
+	Primary ed25519.PrivateKey
+	// Secondary 密钥轮换期间的备用密钥，仅用于校验回调
+	Secondary ed25519.PrivateKey
+
+	primaryPublic   ed25519.PublicKey
+	secondaryPublic ed25519.PublicKey
+}
+
+// newKeyFromSeed 由种子生成密钥，测试中替换以统计生成次数
+var newKeyFromSeed = ed25519.NewKeyFromSeed
+
+// DeriveKey 按照官方规则由机器人密钥生成 ed25519 密钥：重复密钥直至满足种子长度后截断
+func DeriveKey(secret string) (ed25519.PrivateKey, error) {
+	if secret == "" {
+		return nil, errors.New("机器人密钥不能为空")
+	}
+	seed := secret
+	for len(seed) < ed25519.SeedSize {
+		seed = strings.Repeat(seed, 2)
+	}
+	return newKeyFromSeed([]byte(seed[:ed25519.SeedSize])), nil
+}
+
+// NewBotKeys 生成机器人密钥对，secondary 为空时不启用备用密钥
+func NewBotKeys(secret string, secondary string) (*BotKeys, error) {
+	primary, err := DeriveKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	keys := &BotKeys{
+		Primary:       primary,
+		primaryPublic: primary.Public().(ed25519.PublicKey),
+	}
+	if secondary != "" {
+		if keys.Secondary, err = DeriveKey(secondary); err != nil {
+			return nil, err
+		}
+		keys.secondaryPublic = keys.Secondary.Public().(ed25519.PublicKey)
+	}
+	return keys, nil
+}
+
+// Sign 使用当前密钥签名
+func (k *BotKeys) Sign(msg []byte) []byte {
+	return ed25519.Sign(k.Primary, msg)
+}
+
+// Verify 使用当前密钥或备用密钥校验签名
+func (k *BotKeys) Verify(msg []byte, sig []byte) bool {
+	if ed25519.Verify(k.primaryPublic, msg, sig) {
+		return true
+	}
+	return k.secondaryPublic != nil && ed25519.Verify(k.secondaryPublic, msg, sig)
+}
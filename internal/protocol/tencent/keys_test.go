@@ -0,0 +1,159 @@
+package tencent
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret          = "0123456789abcdef0123456789abcdef"
+	testSecondarySecret = "fedcba9876543210fedcba9876543210"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	utils.StorageInit(utils.MemoryDriver, "")
+	m.Run()
+}
+
+// countKeyDerivation 统计测试期间生成密钥的次数
+func countKeyDerivation(t testing.TB) *atomic.Int64 {
+	var count atomic.Int64
+	original := newKeyFromSeed
+	newKeyFromSeed = func(seed []byte) ed25519.PrivateKey {
+		count.Add(1)
+		return original(seed)
+	}
+	t.Cleanup(func() { newKeyFromSeed = original })
+	return &count
+}
+
+// signedRequest 生成签名的回调请求
+func signedRequest(key ed25519.PrivateKey, appId int, body []byte) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	msg := append([]byte(timestamp), body...)
+	r := httptest.NewRequest(http.MethodPost, "/qq", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Bot-Appid", strconv.Itoa(appId))
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, msg)))
+	return r
+}
+
+func TestDeriveKey(t *testing.T) {
+	if _, err := DeriveKey(""); err == nil {
+		t.Fatal("空密钥应返回错误")
+	}
+	// 短密钥重复后截断为种子
+	short, err := DeriveKey("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := bytes.Repeat([]byte("abc"), 16)[:ed25519.SeedSize]
+	if !bytes.Equal(short, ed25519.NewKeyFromSeed(seed)) {
+		t.Fatal("短密钥生成的密钥不符合官方规则")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	keys, err := NewBotKeys(testSecret, testSecondarySecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("1700000000{}")
+	timestamp, body := "1700000000", []byte("{}")
+	primary := hex.EncodeToString(ed25519.Sign(keys.Primary, msg))
+	secondary := hex.EncodeToString(ed25519.Sign(keys.Secondary, msg))
+
+	if !verifySignature(primary, timestamp, body, keys) || !verifySignature(secondary, timestamp, body, keys) {
+		t.Fatal("当前密钥或备用密钥签名校验失败")
+	}
+	other, _ := DeriveKey("another-secret")
+	if verifySignature(hex.EncodeToString(ed25519.Sign(other, msg)), timestamp, body, keys) {
+		t.Fatal("其他密钥的签名校验通过")
+	}
+	if verifySignature(primary, "1700000001", body, keys) || verifySignature("", timestamp, body, keys) || verifySignature("zz", timestamp, body, keys) {
+		t.Fatal("无效签名校验通过")
+	}
+}
+
+// TestKeysDerivedOnce 密钥只在加载时生成，处理回调时不再生成
+func TestKeysDerivedOnce(t *testing.T) {
+	// 每次运行使用新的存储，-count 重复运行时事件不被当作重复事件
+	utils.Store = utils.NewMemoryStorage()
+	count := countKeyDerivation(t)
+	bot := &config.QQ{Id: 1, Secret: testSecret, SecondarySecret: testSecondarySecret, TimestampWindow: 300}
+	qq := NewTencent(bot)
+	if err := qq.LoadKeys(bot); err != nil {
+		t.Fatal(err)
+	}
+	if count.Load() != 2 {
+		t.Fatalf("加载密钥生成 %d 次, 期望 2", count.Load())
+	}
+	key := qq.keys.Load().Primary
+
+	for i := 0; i < 50; i++ {
+		body, _ := json.Marshal(&dto.Payload{
+			PayloadBase: dto.PayloadBase{OPCode: dto.WSDispatchEvent, ID: fmt.Sprintf("event-%d", i), Type: dto.EventGroupMsgReceive},
+			Data:        map[string]string{"group_openid": "GROUP"},
+		})
+		w := httptest.NewRecorder()
+		qq.Init(w, signedRequest(key, bot.Id, body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("回调返回 %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if count.Load() != 2 {
+		t.Fatalf("处理回调时生成了密钥, 共 %d 次", count.Load())
+	}
+	if len(qq.payloads) != 50 {
+		t.Fatalf("接收事件 %d 个, 期望 50", len(qq.payloads))
+	}
+}
+
+func BenchmarkVerifySignature(b *testing.B) {
+	count := countKeyDerivation(b)
+	keys, err := NewBotKeys(testSecret, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	timestamp := "1700000000"
+	body := []byte(`{"op":0,"id":"GROUP_AT_MESSAGE_CREATE:1","d":{"content":"hello"},"t":"GROUP_AT_MESSAGE_CREATE"}`)
+	signature := hex.EncodeToString(keys.Sign(append([]byte(timestamp), body...)))
+	derived := count.Load()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !verifySignature(signature, timestamp, body, keys) {
+			b.Fatal("签名校验失败")
+		}
+	}
+	b.StopTimer()
+	if count.Load() != derived {
+		b.Fatalf("校验签名时生成了 %d 次密钥", count.Load()-derived)
+	}
+}
+
+// BenchmarkDeriveKey 每次请求生成密钥时的开销，用于与 BenchmarkVerifySignature 对比
+func BenchmarkDeriveKey(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := DeriveKey(testSecret); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
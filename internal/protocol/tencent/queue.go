@@ -0,0 +1,182 @@
+package tencent
+
+import (
+	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"GoQHttp/utils"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	queueBatchSize   = 100
+	queueMaxAttempts = 5
+	queueRetryDelay  = 5 * time.Second
+	// queueDeliveryTimeout 等待事件写入功能端连接的时间
+	queueDeliveryTimeout = 30 * time.Second
+)
+
+// Handler 处理队列中的事件，返回发出的需要确认送达的事件，retry 为 true 时事件上次未送达
+type Handler func(payload *dto.Payload, retry bool) ([]*protocol.Delivery, error)
+
+// EventQueue 基于 SQLite 的持久化事件队列
+//
+// 事件写入队列后立即确认回调，处理成功且发出的事件写入功能端连接后才从队列删除；
+// 进程退出时未处理的事件在下次启动时重新处理，功能端可能重复收到事件。
+// 处理失败的事件按顺序重试，超过 queueMaxAttempts 次后丢弃；未送达的事件不计入失败次数，一直保留到送达为止。
+type EventQueue struct {
+	appId  int
+	notify chan struct{}
+	// undelivered 上次未送达的事件
+	undelivered int64
+	// deliveryTimeout 等待送达的时间
+	deliveryTimeout time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+	// running 正在运行的处理协程
+	running sync.WaitGroup
+}
+
+// NewEventQueue 创建机器人的持久化事件队列
+func NewEventQueue(appId int) *EventQueue {
+	return &EventQueue{
+		appId:           appId,
+		notify:          make(chan struct{}, 1),
+		deliveryTimeout: queueDeliveryTimeout,
+		stop:            make(chan struct{}),
+	}
+}
+
+// Push 事件与去重记录在同一事务中写入队列并唤醒处理协程，dedupeKey 已记录时不写入并返回 false
+func (q *EventQueue) Push(payload []byte, dedupeKey string) (bool, error) {
+	inserted, err := utils.Store.EventQueuePush(q.appId, payload, dedupeKey)
+	if err != nil || !inserted {
+		return false, err
+	}
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// Start 启动处理协程
+func (q *EventQueue) Start(handle Handler) {
+	q.running.Add(1)
+	go func() {
+		defer q.running.Done()
+		q.Run(handle)
+	}()
+}
+
+// Stop 停止处理并等待处理协程退出，未处理的事件保留在队列中
+func (q *EventQueue) Stop() {
+	q.stopOnce.Do(func() {
+		close(q.stop)
+	})
+	q.running.Wait()
+}
+
+// Run 处理队列中的事件直到 Stop，启动时先处理上次未完成的事件
+func (q *EventQueue) Run(handle Handler) {
+	ticker := time.NewTicker(queueRetryDelay)
+	defer ticker.Stop()
+	for {
+		q.drain(handle)
+		select {
+		case <-q.notify:
+		case <-ticker.C:
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// stopped 是否已停止
+func (q *EventQueue) stopped() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain 按写入顺序处理事件，遇到失败或未送达时停止本轮处理等待重试
+func (q *EventQueue) drain(handle Handler) {
+	for !q.stopped() {
+		events, err := utils.Store.EventQueuePending(q.appId, queueBatchSize)
+		if err != nil {
+			logger.Warnf("读取事件队列失败: %v", err)
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+		for _, event := range events {
+			if q.stopped() {
+				return
+			}
+			payload := &dto.Payload{}
+			var deliveries []*protocol.Delivery
+			if err = json.Unmarshal([]byte(event.Payload), payload); err == nil {
+				deliveries, err = handle(payload, event.ID == q.undelivered)
+			}
+			if err == nil {
+				if !q.delivered(deliveries) {
+					q.undelivered = event.ID
+					logger.Warnf("事件 %d 未送达功能端，稍后重试", event.ID)
+					return
+				}
+				if !q.ack(event.ID) {
+					return
+				}
+				continue
+			}
+
+			attempts, failErr := utils.Store.EventQueueFail(event.ID)
+			if failErr != nil {
+				logger.Warnf("记录事件处理失败次数失败: %v", failErr)
+				return
+			}
+			if attempts < queueMaxAttempts {
+				logger.Warnf("事件 %d 处理失败(第%d次)，稍后重试: %v", event.ID, attempts, err)
+				return
+			}
+			logger.Errorf("事件 %d 处理失败%d次，已丢弃: %v", event.ID, attempts, err)
+			if !q.ack(event.ID) {
+				return
+			}
+		}
+	}
+}
+
+// delivered 等待发出的事件全部写入功能端连接，超时、写入失败或停止时返回 false
+func (q *EventQueue) delivered(deliveries []*protocol.Delivery) bool {
+	timeout := time.NewTimer(q.deliveryTimeout)
+	defer timeout.Stop()
+	for _, delivery := range deliveries {
+		select {
+		case delivered := <-delivery.Result():
+			if !delivered {
+				return false
+			}
+		case <-timeout.C:
+			return false
+		case <-q.stop:
+			return false
+		}
+	}
+	return true
+}
+
+// ack 从队列删除事件，失败时返回 false
+func (q *EventQueue) ack(id int64) bool {
+	if err := utils.Store.EventQueueAck(id); err != nil {
+		logger.Warnf("事件 %d 确认失败: %v", id, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package tencent
+
+import (
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/utils"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// newTestQueue 使用新的存储创建队列，-count 重复运行时不受上次剩余事件的影响
+func newTestQueue(appId int) *EventQueue {
+	utils.Store = utils.NewMemoryStorage()
+	return NewEventQueue(appId)
+}
+
+// pushEvent 写入一条队列事件
+func pushEvent(t *testing.T, q *EventQueue, id string) {
+	t.Helper()
+	raw, _ := json.Marshal(&dto.Payload{PayloadBase: dto.PayloadBase{ID: id, Type: dto.EventGroupATMessageCreate}})
+	if _, err := q.Push(raw, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pending 队列中尚未确认的事件
+func pending(t *testing.T, q *EventQueue) []utils.TencentEventQueue {
+	t.Helper()
+	events, err := utils.Store.EventQueuePending(q.appId, queueBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// deliver 处理事件时发出一个事件，由 result 决定是否送达
+func deliver(result func(delivery *protocol.Delivery), retries *[]bool) Handler {
+	return func(payload *dto.Payload, retry bool) ([]*protocol.Delivery, error) {
+		*retries = append(*retries, retry)
+		delivery := protocol.NewDelivery(onebot.MessageRequest{}, retry)
+		go result(delivery)
+		return []*protocol.Delivery{delivery}, nil
+	}
+}
+
+func TestQueueAckAfterDelivery(t *testing.T) {
+	q := newTestQueue(1001)
+	pushEvent(t, q, "delivery")
+
+	var retries []bool
+	q.drain(deliver(func(delivery *protocol.Delivery) { delivery.Done(false) }, &retries))
+	events := pending(t, q)
+	if len(events) != 1 || events[0].Attempts != 0 {
+		t.Fatalf("未送达的事件应保留且不计入失败次数: %+v", events)
+	}
+
+	q.drain(deliver(func(delivery *protocol.Delivery) { delivery.Done(true) }, &retries))
+	if events = pending(t, q); len(events) != 0 {
+		t.Fatalf("送达后事件应从队列删除: %+v", events)
+	}
+	if len(retries) != 2 || retries[0] || !retries[1] {
+		t.Fatalf("重试标记 %v, 期望 [false true]", retries)
+	}
+}
+
+func TestQueueDeliveryTimeout(t *testing.T) {
+	q := newTestQueue(1002)
+	q.deliveryTimeout = 50 * time.Millisecond
+	pushEvent(t, q, "timeout")
+	pushEvent(t, q, "after")
+
+	var retries []bool
+	q.drain(deliver(func(delivery *protocol.Delivery) {}, &retries))
+	if events := pending(t, q); len(events) != 2 {
+		t.Fatalf("超时未送达时应停止本轮处理, 剩余 %d", len(events))
+	}
+	if len(retries) != 1 {
+		t.Fatalf("处理次数 %d, 期望 1", len(retries))
+	}
+}
+
+func TestQueueNoDeliveries(t *testing.T) {
+	q := newTestQueue(1003)
+	pushEvent(t, q, "notice")
+	q.drain(func(payload *dto.Payload, retry bool) ([]*protocol.Delivery, error) {
+		return nil, nil
+	})
+	if events := pending(t, q); len(events) != 0 {
+		t.Fatalf("未发出事件时应直接确认: %+v", events)
+	}
+}
+
+func TestQueueStop(t *testing.T) {
+	q := newTestQueue(1004)
+	pushEvent(t, q, "stop")
+	handled := make(chan struct{}, 1)
+	// 事件一直未送达，停止时不再等待
+	q.Start(func(payload *dto.Payload, retry bool) ([]*protocol.Delivery, error) {
+		handled <- struct{}{}
+		return []*protocol.Delivery{protocol.NewDelivery(onebot.MessageRequest{}, retry)}, nil
+	})
+	<-handled
+
+	stopped := make(chan struct{})
+	go func() {
+		q.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("停止时应放弃等待送达")
+	}
+	if events := pending(t, q); len(events) != 1 {
+		t.Fatalf("停止后未送达的事件应保留, 剩余 %d", len(events))
+	}
+	// 停止后写入的事件不再处理
+	pushEvent(t, q, "after")
+	select {
+	case <-handled:
+		t.Fatal("停止后仍在处理事件")
+	case <-time.After(50 * time.Millisecond):
+	}
+	q.Stop()
+}
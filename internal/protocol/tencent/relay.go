@@ -0,0 +1,184 @@
+package tencent
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/protocol/tencent/dto"
+	"GoQHttp/logger"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// relayHeaders 沿用原始签名时转发的请求头
+var relayHeaders = []string{
+	"User-Agent",
+	"X-Bot-Appid",
+	"X-Signature-Ed25519",
+	"X-Signature-Timestamp",
+}
+
+// Relay 将回调原文转发到下游地址，转发在独立协程中进行，不阻塞回调响应
+type Relay struct {
+	URL        string
+	appId      int
+	keys       *BotKeys
+	events     map[dto.EventType]struct{}
+	maxRetries int
+	retryDelay time.Duration
+	client     *http.Client
+	queue      chan *relayRequest
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+type relayRequest struct {
+	eventType dto.EventType
+	body      []byte
+	header    http.Header
+}
+
+// NewRelay 根据配置创建转发目标
+func NewRelay(appId int, cfg *config.Relay) (*Relay, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("转发地址不能为空")
+	}
+	relay := &Relay{
+		URL:        cfg.URL,
+		appId:      appId,
+		maxRetries: cfg.MaxRetries,
+		retryDelay: time.Duration(cfg.RetryDelay) * time.Millisecond,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan *relayRequest, 1000),
+		done:       make(chan struct{}),
+	}
+	if cfg.Timeout > 0 {
+		relay.client.Timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if relay.retryDelay <= 0 {
+		relay.retryDelay = time.Second
+	}
+	if cfg.Secret != "" {
+		keys, err := NewBotKeys(cfg.Secret, "")
+		if err != nil {
+			return nil, err
+		}
+		relay.keys = keys
+	}
+	if len(cfg.Events) > 0 {
+		relay.events = make(map[dto.EventType]struct{}, len(cfg.Events))
+		for _, event := range cfg.Events {
+			relay.events[dto.EventType(event)] = struct{}{}
+		}
+	}
+	return relay, nil
+}
+
+// newRelays 根据机器人配置创建全部转发目标
+func newRelays(bot *config.QQ) ([]*Relay, error) {
+	relays := make([]*Relay, 0, len(bot.Relays))
+	for i := range bot.Relays {
+		relay, err := NewRelay(bot.Id, &bot.Relays[i])
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, relay)
+	}
+	return relays, nil
+}
+
+// Run 依次转发队列中的回调，Stop 后转发完已接收的回调再退出
+func (r *Relay) Run() {
+	for {
+		select {
+		case req := <-r.queue:
+			r.post(req)
+		case <-r.done:
+			for {
+				select {
+				case req := <-r.queue:
+					r.post(req)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop 停止接收新的回调
+func (r *Relay) Stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
+
+// Forward 转发回调，header 为空时仅在配置了密钥时携带签名
+func (r *Relay) Forward(eventType dto.EventType, body []byte, header http.Header) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	if r.events != nil {
+		if _, ok := r.events[eventType]; !ok {
+			return
+		}
+	}
+	select {
+	case r.queue <- &relayRequest{eventType: eventType, body: body, header: header}:
+	default:
+		logger.Warnf("转发队列已满，丢弃事件 %s -> %s", eventType, r.URL)
+	}
+}
+
+// post 发送请求，失败时按 retryDelay 递增间隔重试
+func (r *Relay) post(req *relayRequest) {
+	for attempt := 0; ; attempt++ {
+		err := r.send(req)
+		if err == nil {
+			return
+		}
+		if attempt >= r.maxRetries {
+			logger.Warnf("事件 %s 转发到 %s 失败: %v", req.eventType, r.URL, err)
+			return
+		}
+		logger.Debugf("事件 %s 转发到 %s 失败(第%d次)，稍后重试: %v", req.eventType, r.URL, attempt+1, err)
+		time.Sleep(r.retryDelay * time.Duration(attempt+1))
+	}
+}
+
+func (r *Relay) send(req *relayRequest) error {
+	request, err := http.NewRequest(http.MethodPost, r.URL, bytes.NewReader(req.body))
+	if err != nil {
+		return fmt.Errorf("create request error: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Bot-Appid", strconv.Itoa(r.appId))
+	if r.keys != nil {
+		// 使用转发密钥重新签名，时间戳为转发时间
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		msg := append([]byte(timestamp), req.body...)
+		request.Header.Set("X-Signature-Timestamp", timestamp)
+		request.Header.Set("X-Signature-Ed25519", hex.EncodeToString(r.keys.Sign(msg)))
+	} else {
+		for _, key := range relayHeaders {
+			if value := req.header.Get(key); value != "" {
+				request.Header.Set(key, value)
+			}
+		}
+	}
+
+	resp, err := r.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("send request error: %v", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -2,21 +2,205 @@ package tencent
 
 import (
 	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/openapi"
 	"GoQHttp/internal/protocol"
 	"GoQHttp/internal/protocol/tencent/dto"
 	"GoQHttp/logger"
+	"GoQHttp/utils"
 	"bytes"
 	"crypto/ed25519"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Tencent 单个 QQ 官方机器人，持有独立的接口凭证、签名密钥与事件队列
 type Tencent struct {
+	Api    *openapi.OpenApi
+	SelfId int64
+	// UserId 机器人在QQ的用户id，Nickname 机器人名称，由 /users/@me 获取
+	UserId   string
+	Nickname string
+
+	// settings 机器人配置，重新加载配置时替换
+	settings atomic.Pointer[config.QQ]
+	// payloads 未经过持久化队列、待处理的事件
+	payloads chan *dto.Payload
+	// events 转换后的 OneBot 事件
+	events  chan onebot.Event
+	deduper *protocol.EventDeduper
+	keys    atomic.Pointer[BotKeys]
+	gateway *Gateway
+	queue   *EventQueue
+	relays  atomic.Pointer[[]*Relay]
+	// deliveries 正在处理的队列事件所发出的事件，*dto.Payload -> *deliveryTracker
+	deliveries sync.Map
+	// memberLists 群成员列表，群 openid -> *groupMembers
+	memberLists sync.Map
+	// guildUsers 频道用户最近所在的频道，用户数字id -> 频道id
+	guildUsers sync.Map
+	// demands 自动发起的接口授权申请，permissionDemand -> 发起时间
+	demands sync.Map
+}
+
+// deliveryTracker 记录队列事件处理时发出的需要确认送达的事件
+type deliveryTracker struct {
+	retry      bool
+	deliveries []*protocol.Delivery
+}
+
+var (
+	bots   = make(map[int64]*Tencent)
+	botsMu sync.RWMutex
+)
+
+// NewTencent 根据配置创建机器人
+func NewTencent(bot *config.QQ) *Tencent {
+	qq := &Tencent{
+		Api:      openapi.NewOpenApi(bot.Id, bot.Secret, bot.Sandbox),
+		payloads: make(chan *dto.Payload, 100),
+		events:   make(chan onebot.Event, 100),
+		deduper:  protocol.NewEventDeduper(strconv.Itoa(bot.Id), bot.DedupeSize),
+	}
+	qq.settings.Store(bot)
+	return qq
+}
+
+// Config 机器人当前的配置，返回的配置只读
+func (qq *Tencent) Config() *config.QQ {
+	return qq.settings.Load()
+}
+
+// GetBot 获取 self_id 对应的机器人
+func GetBot(selfId int64) (*Tencent, bool) {
+	botsMu.RLock()
+	defer botsMu.RUnlock()
+	bot, ok := bots[selfId]
+	return bot, ok
+}
+
+// Start 获取凭证、生成签名密钥并开始处理事件，websocket 模式下同时连接网关
+func (qq *Tencent) Start() error {
+	if qq.IsGateway() {
+		intents, err := parseIntents(qq.Config().Intents)
+		if err != nil {
+			return err
+		}
+		qq.gateway = NewGateway(qq, intents)
+	}
+	relays, err := newRelays(qq.Config())
+	if err != nil {
+		return err
+	}
+	if err := qq.Api.Init(qq.Config().Id, qq.Config().Secret, qq.Config().Sandbox); err != nil {
+		return err
+	}
+	if err := qq.LoadKeys(qq.Config()); err != nil {
+		return err
+	}
+	if err := qq.login(); err != nil {
+		return err
+	}
+
+	botsMu.Lock()
+	bots[qq.SelfId] = qq
+	botsMu.Unlock()
+
+	go qq.HandlerEvent()
+	qq.replaceRelays(relays)
+	if qq.Config().DurableQueue {
+		qq.queue = NewEventQueue(qq.Config().Id)
+		qq.queue.Start(qq.dispatchQueued)
+	}
+
+	if qq.gateway != nil {
+		go qq.gateway.Run()
+	} else {
+		handleWebhook(qq)
+	}
+	return nil
+}
+
+// login 获取机器人自身的信息并确定 self_id，配置了 uid 时使用配置的值
+func (qq *Tencent) login() error {
+	qq.UserId = strconv.Itoa(qq.Config().Id)
+	me, err := qq.Api.GetMe()
+	if err != nil {
+		err = qq.handlePermissionError(err, "", "", "/users/@me", http.MethodGet)
+		if qq.Config().Uid == 0 {
+			return fmt.Errorf("获取机器人信息失败: %v", err)
+		}
+		logger.Warnf("QQ机器人 %d 获取机器人信息失败, 使用配置的 uid %d: %v", qq.Config().Id, qq.Config().Uid, err)
+		qq.SelfId = int64(qq.Config().Uid)
+		return nil
+	}
+	qq.UserId = me.ID
+	qq.Nickname = me.Username
+
+	if qq.Config().Uid != 0 {
+		qq.SelfId = int64(qq.Config().Uid)
+	} else if qq.SelfId, err = strconv.ParseInt(me.ID, 10, 64); err != nil || qq.SelfId <= 0 {
+		// 机器人id不是数字时与成员id一样映射为数字id
+		if qq.SelfId, err = openapi.UserId(qq.Config().Id, me.ID); err != nil {
+			return fmt.Errorf("机器人id映射失败: %v", err)
+		}
+	}
+	logger.Infof("QQ机器人 %s(%s) 已登录, self_id: %d", me.Username, me.ID, qq.SelfId)
+	return nil
+}
+
+// IsGateway 是否通过 websocket 网关接收事件
+func (qq *Tencent) IsGateway() bool {
+	return qq.Config().Mode == "websocket"
+}
+
+// SendMessage 发送功能端下发的消息，频道消息与带 guild_id 的私聊消息按子频道消息与频道私信发送
+func (qq *Tencent) SendMessage(data *onebot.MessageRequest) {
+	if data.MessageType == onebot.GuildMessage || (data.MessageType == onebot.PrivateMessage && data.GuildId != 0) {
+		if _, err := qq.sendGuildMessage(data); err != nil {
+			logger.Warnf("消息发送失败: %v", err)
+		}
+		return
+	}
+
+	path := "/v2/groups/{group_openid}/messages"
+	groupId := data.GroupId
+	if data.MessageType == onebot.PrivateMessage {
+		path = "/v2/users/{openid}/messages"
+		groupId = 0
+	}
+	msgId, err := qq.Api.SendMessage(data)
+	if err != nil {
+		logger.Warnf("消息发送失败: %v", qq.handlePermissionError(err, "", "", path, http.MethodPost))
+	}
+	if msgId == "" {
+		return
+	}
+	// 发送的消息与收到的消息使用相同的消息id，发送者为机器人自身
+	id, err := utils.Store.GroupMessageInsert(qq.Config().Id, msgId, groupId, qq.SelfId)
+	if err != nil {
+		logger.Warnf("消息维护失败: %v", err)
+		return
+	}
+	sent := &onebot.MessageRequest{
+		MessageType: data.MessageType,
+		MessageId:   id,
+		GroupId:     groupId,
+		Message:     data.Message,
+		RawMessage:  onebot.CQString(data.Message),
+	}
+	if groupId == 0 {
+		sent.UserId = data.UserId
+	}
+	protocol.MessageSent(qq.Identity(), sent)
 }
 
 type ValidationRequest struct {
@@ -28,7 +212,7 @@ type ValidationResponse struct {
 	Signature  string `json:"signature"`
 }
 
-func handleValidation(payload *dto.Payload, config *config.Config) ([]byte, error) {
+func handleValidation(payload *dto.Payload, keys *BotKeys) ([]byte, error) {
 	validationPayload := &ValidationRequest{}
 	b, _ := json.Marshal(payload.Data)
 	if err := json.Unmarshal(b, validationPayload); err != nil {
@@ -36,22 +220,11 @@ func handleValidation(payload *dto.Payload, config *config.Config) ([]byte, erro
 		return nil, err
 	}
 
-	seed := config.Bot.QQ.Secret
-	for len(seed) < ed25519.SeedSize {
-		seed = strings.Repeat(seed, 2)
-	}
-	seed = seed[:ed25519.SeedSize]
-	reader := strings.NewReader(seed)
-	// GenerateKey 方法会返回公钥、私钥，这里只需要私钥进行签名生成不需要返回公钥
-	_, privateKey, err := ed25519.GenerateKey(reader)
-	if err != nil {
-		logger.Warnf("ed25519 generate key failed: %s", err)
-		return nil, err
-	}
+	// 回调地址验证始终使用当前密钥签名
 	var msg bytes.Buffer
 	msg.WriteString(validationPayload.EventTs)
 	msg.WriteString(validationPayload.PlainToken)
-	signature := hex.EncodeToString(ed25519.Sign(privateKey, msg.Bytes()))
+	signature := hex.EncodeToString(keys.Sign(msg.Bytes()))
 
 	rspBytes, err := json.Marshal(
 		&ValidationResponse{
@@ -65,19 +238,7 @@ func handleValidation(payload *dto.Payload, config *config.Config) ([]byte, erro
 	return rspBytes, nil
 }
 
-func verifySignature(signature string, timestamp string, body []byte, config *config.Config) bool {
-	seed := config.Bot.QQ.Secret
-	for len(seed) < ed25519.SeedSize {
-		seed = strings.Repeat(seed, 2)
-	}
-	rand := strings.NewReader(seed[:ed25519.SeedSize])
-	publicKey, _, err := ed25519.GenerateKey(rand)
-
-	if err != nil {
-		logger.Warnf("ed25519 generate key failed: %s", err)
-		return false
-	}
-
+func verifySignature(signature string, timestamp string, body []byte, keys *BotKeys) bool {
 	if signature == "" {
 		logger.Warn("signature: NULL")
 		return false
@@ -98,14 +259,41 @@ func verifySignature(signature string, timestamp string, body []byte, config *co
 	}
 
 	// 按照timestamp+Body顺序组成签名体
-	var msg bytes.Buffer
-	msg.Write([]byte(timestamp))
-	msg.Write(body)
+	msg := make([]byte, 0, len(timestamp)+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, body...)
 
-	return ed25519.Verify(publicKey, msg.Bytes(), sig)
+	return keys.Verify(msg, sig)
 }
 
-func (qq *Tencent) Init(w http.ResponseWriter, r *http.Request, config *config.Config) {
+// LoadKeys 根据配置生成签名密钥，启动及重新加载配置时调用
+func (qq *Tencent) LoadKeys(bot *config.QQ) error {
+	keys, err := NewBotKeys(bot.Secret, bot.SecondarySecret)
+	if err != nil {
+		return err
+	}
+	qq.keys.Store(keys)
+	return nil
+}
+
+// checkTimestamp 校验签名时间戳与当前时间的偏差，window 小于 0 时不校验
+func checkTimestamp(timestamp string, window int) bool {
+	if window < 0 {
+		return true
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		logger.Warnf("signature timestamp invalid: %s", timestamp)
+		return false
+	}
+	diff := time.Now().Unix() - ts
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= int64(window)
+}
+
+func (qq *Tencent) Init(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
 	// 验证内容类型
@@ -119,7 +307,7 @@ func (qq *Tencent) Init(w http.ResponseWriter, r *http.Request, config *config.C
 	signature := r.Header.Get("X-Signature-Ed25519")
 	timestamp := r.Header.Get("X-Signature-Timestamp")
 	appid := r.Header.Get("X-Bot-Appid")
-	if appid != strconv.Itoa(config.Bot.QQ.Id) {
+	if appid != strconv.Itoa(qq.Config().Id) {
 		sendErrorResponse(w, "appid不相符", http.StatusUnauthorized)
 		return
 	}
@@ -143,19 +331,30 @@ func (qq *Tencent) Init(w http.ResponseWriter, r *http.Request, config *config.C
 		return
 	}
 
+	keys := qq.keys.Load()
+	if keys == nil {
+		sendErrorResponse(w, "签名密钥尚未加载", http.StatusServiceUnavailable)
+		return
+	}
+
 	// 记录接收到的 webhook
 	logger.Debugf("收到 Webhook 事件: %s", payload.Type)
 	switch payload.OPCode {
 	case 0:
 		{
-			isValid := verifySignature(signature, timestamp, body, config)
+			isValid := verifySignature(signature, timestamp, body, keys)
 			logger.Debugf("消息验签: %v", isValid)
 			// 根据事件类型处理
 			if !isValid {
 				w.WriteHeader(http.StatusBadRequest)
 				_, _ = w.Write([]byte("true"))
+			} else if !checkTimestamp(timestamp, qq.Config().TimestampWindow) {
+				sendErrorResponse(w, "签名时间戳超出允许范围", http.StatusUnauthorized)
 			} else {
-				protocol.QQClientChan <- payload
+				// 重复投递的事件只确认不再分发
+				if !qq.accept(payload, body, r.Header) {
+					logger.Debugf("忽略重复事件: %s", payload.ID)
+				}
 				w.WriteHeader(http.StatusOK)
 				_, _ = w.Write([]byte("ok"))
 			}
@@ -163,7 +362,7 @@ func (qq *Tencent) Init(w http.ResponseWriter, r *http.Request, config *config.C
 	case 13:
 		{
 			//	验证签名
-			result, err := handleValidation(payload, config)
+			result, err := handleValidation(payload, keys)
 			if err != nil {
 				sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 			} else {
@@ -192,191 +391,253 @@ func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
-// HandlerEvent 根据事件类型处理 webhook
-func (qq *Tencent) HandlerEvent() {
-	for payload := range protocol.QQClientChan {
-		message, _ := json.Marshal(payload.Data)
-
-		switch payload.Type {
-		case dto.EventGroupATMessageCreate:
-			{
-				gm := &dto.GroupATMessageDataEvent{}
-				err := json.Unmarshal(message, gm)
-				if err == nil {
-					_ = GroupAtMessageEventHandler(payload, gm)
-				}
+// relay 将回调原文转发到配置的下游地址
+func (qq *Tencent) relay(eventType dto.EventType, body []byte, header http.Header) {
+	relays := qq.relays.Load()
+	if relays == nil {
+		return
+	}
+	for _, relay := range *relays {
+		relay.Forward(eventType, body, header)
+	}
+}
+
+// replaceRelays 启用新的转发目标并停止原有的转发目标，原有目标已接收的回调转发完成后退出
+func (qq *Tencent) replaceRelays(relays []*Relay) {
+	for _, relay := range relays {
+		go relay.Run()
+	}
+	if old := qq.relays.Swap(&relays); old != nil {
+		for _, relay := range *old {
+			relay.Stop()
+		}
+	}
+}
+
+// accept 去重后将事件交给处理流程，事件进入处理流程后才记录事件id，重复事件返回 false
+//
+// 启用持久化队列时事件id与队列记录在同一事务中写入，写入失败时改为直接处理。
+func (qq *Tencent) accept(payload *dto.Payload, raw []byte, header http.Header) bool {
+	if !qq.deduper.Reserve(payload.ID) {
+		return false
+	}
+	if qq.queue != nil {
+		fresh, err := qq.queue.Push(raw, qq.deduper.Key(payload.ID))
+		if err == nil {
+			qq.deduper.Commit(payload.ID)
+			if fresh {
+				qq.relay(payload.Type, raw, header)
 			}
-		case dto.EventGroupAddRobbot:
-			{
-				gar := &dto.GroupAddRobotDataEvent{}
-				err := json.Unmarshal(message, gar)
-				if err == nil {
-					_ = GroupAddRobotEventHandler(payload, gar)
-				}
+			return fresh
+		}
+		logger.Warnf("事件写入持久化队列失败，改为直接处理: %v", err)
+	}
+	qq.payloads <- payload
+	qq.deduper.Commit(payload.ID)
+	qq.relay(payload.Type, raw, header)
+	return true
+}
+
+// HandlerEvent 处理未经过持久化队列的事件
+func (qq *Tencent) HandlerEvent() {
+	for payload := range qq.payloads {
+		if err := qq.dispatch(payload); err != nil {
+			logger.Warnf("事件 %s 处理失败: %v", payload.Type, err)
+		}
+	}
+}
+
+// dispatchQueued 处理队列中的事件，返回发出的需要确认送达的事件，retry 为 true 时事件上次未送达
+func (qq *Tencent) dispatchQueued(payload *dto.Payload, retry bool) ([]*protocol.Delivery, error) {
+	tracker := &deliveryTracker{retry: retry}
+	qq.deliveries.Store(payload, tracker)
+	defer qq.deliveries.Delete(payload)
+	err := qq.dispatch(payload)
+	return tracker.deliveries, err
+}
+
+// emit 发出转换后的 OneBot 事件，处理队列中的事件时包装为需要确认送达的事件
+func (qq *Tencent) emit(payload *dto.Payload, event onebot.Event) {
+	if value, ok := qq.deliveries.Load(payload); ok {
+		tracker := value.(*deliveryTracker)
+		delivery := protocol.NewDelivery(event, tracker.retry)
+		tracker.deliveries = append(tracker.deliveries, delivery)
+		event = delivery
+	}
+	qq.events <- event
+}
+
+// dispatch 根据事件类型处理事件
+func (qq *Tencent) dispatch(payload *dto.Payload) error {
+	message, _ := json.Marshal(payload.Data)
+
+	switch payload.Type {
+	case dto.EventGroupATMessageCreate:
+		{
+			gm := &dto.GroupATMessageDataEvent{}
+			if err := json.Unmarshal(message, gm); err != nil {
+				return err
 			}
-		case dto.EventGroupDelRobbot:
-			{
-				gdr := &dto.GroupDelRobotDataEvent{}
-				err := json.Unmarshal(message, gdr)
-				if err == nil {
-					_ = GroupDelRobotEventHandler(payload, gdr)
-				}
+			return qq.GroupAtMessageEventHandler(payload, gm)
+		}
+	case dto.EventGroupAddRobbot:
+		{
+			gar := &dto.GroupAddRobotDataEvent{}
+			if err := json.Unmarshal(message, gar); err != nil {
+				return err
 			}
-		case dto.EventGroupMsgReceive:
-			{
-				gmr := &dto.GroupMsgReceiveDataEvent{}
-				err := json.Unmarshal(message, gmr)
-				if err == nil {
-					_ = GroupMsgReceiveEventHandler(payload, gmr)
-				}
+			return qq.GroupAddRobotEventHandler(payload, gar)
+		}
+	case dto.EventGroupDelRobbot:
+		{
+			gdr := &dto.GroupDelRobotDataEvent{}
+			if err := json.Unmarshal(message, gdr); err != nil {
+				return err
 			}
-		case dto.EventGroupMsgReject:
-			{
-				gmr := &dto.GroupMsgRejectDataEvent{}
-				err := json.Unmarshal(message, gmr)
-				if err == nil {
-					_ = GroupMsgRejectEventHandler(payload, gmr)
-				}
+			return qq.GroupDelRobotEventHandler(payload, gdr)
+		}
+	case dto.EventGroupMsgReceive:
+		{
+			gmr := &dto.GroupMsgReceiveDataEvent{}
+			if err := json.Unmarshal(message, gmr); err != nil {
+				return err
 			}
-		case dto.EventC2CMessageCreate:
-			{
-				cmc := &dto.C2CMessageDataEvent{}
-				err := json.Unmarshal(message, cmc)
-				if err == nil {
-					_ = C2CMessageEventHandler(payload, cmc)
-				}
+			return qq.GroupMsgReceiveEventHandler(payload, gmr)
+		}
+	case dto.EventGroupMsgReject:
+		{
+			gmr := &dto.GroupMsgRejectDataEvent{}
+			if err := json.Unmarshal(message, gmr); err != nil {
+				return err
 			}
-		case dto.EventC2CMsgReceive:
-			{
-				fmr := &dto.FriendMsgReceiveDataEvent{}
-				err := json.Unmarshal(message, fmr)
-				if err == nil {
-					_ = C2CMsgReceiveHandler(payload, fmr)
-				}
+			return qq.GroupMsgRejectEventHandler(payload, gmr)
+		}
+	case dto.EventC2CMessageCreate:
+		{
+			cmc := &dto.C2CMessageDataEvent{}
+			if err := json.Unmarshal(message, cmc); err != nil {
+				return err
 			}
-		case dto.EventC2CMsgReject:
-			{
-				fmr := &dto.FriendMsgRejectDataEvent{}
-				err := json.Unmarshal(message, fmr)
-				if err == nil {
-					_ = C2CMsgRejectHandler(payload, fmr)
-				}
+			return qq.C2CMessageEventHandler(payload, cmc)
+		}
+	case dto.EventC2CMsgReceive:
+		{
+			fmr := &dto.FriendMsgReceiveDataEvent{}
+			if err := json.Unmarshal(message, fmr); err != nil {
+				return err
 			}
-		case dto.EventFriendAdd:
-			{
-				fad := &dto.FriendAddDataEvent{}
-				err := json.Unmarshal(message, fad)
-				if err == nil {
-					_ = FriendAddEventHandler(payload, fad)
-				}
+			return qq.C2CMsgReceiveHandler(payload, fmr)
+		}
+	case dto.EventC2CMsgReject:
+		{
+			fmr := &dto.FriendMsgRejectDataEvent{}
+			if err := json.Unmarshal(message, fmr); err != nil {
+				return err
 			}
-		case dto.EventFriendDel:
-			{
-				fad := &dto.FriendDelDataEvent{}
-				err := json.Unmarshal(message, fad)
-				if err == nil {
-					_ = FriendDelEventHandler(payload, fad)
-				}
+			return qq.C2CMsgRejectHandler(payload, fmr)
+		}
+	case dto.EventFriendAdd:
+		{
+			fad := &dto.FriendAddDataEvent{}
+			if err := json.Unmarshal(message, fad); err != nil {
+				return err
 			}
-		case dto.EventAtMessageCreate:
-			{
-				am := &dto.ATMessageDataEvent{}
-				err := json.Unmarshal(message, am)
-				if err == nil {
-					_ = ATMessageEventHandler(payload, am)
-				}
+			return qq.FriendAddEventHandler(payload, fad)
+		}
+	case dto.EventFriendDel:
+		{
+			fad := &dto.FriendDelDataEvent{}
+			if err := json.Unmarshal(message, fad); err != nil {
+				return err
 			}
-		case dto.EventMessageCreate:
-			{
-				m := &dto.MessageDataEvent{}
-				err := json.Unmarshal(message, m)
-				if err == nil {
-					_ = MessageEventHandler(payload, m)
-				}
+			return qq.FriendDelEventHandler(payload, fad)
+		}
+	case dto.EventAtMessageCreate:
+		{
+			am := &dto.ATMessageDataEvent{}
+			if err := json.Unmarshal(message, am); err != nil {
+				return err
 			}
-		case dto.EventInteractionCreate:
-			{
-				i := &dto.InteractionDataEvent{}
-				err := json.Unmarshal(message, i)
-				if err == nil {
-					i.ID = payload.ID
-					_ = InteractionEventHandler(payload, i)
-				}
+			return qq.ATMessageEventHandler(payload, am)
+		}
+	case dto.EventMessageCreate:
+		{
+			m := &dto.MessageDataEvent{}
+			if err := json.Unmarshal(message, m); err != nil {
+				return err
 			}
-		case dto.EventDirectMessageCreate:
-			{
-				i := &dto.DirectMessageDataEvent{}
-				err := json.Unmarshal(message, i)
-				if err == nil {
-					_ = DirectMessageEventHandler(payload, i)
-				}
+			return qq.MessageEventHandler(payload, m)
+		}
+	case dto.EventInteractionCreate:
+		{
+			i := &dto.InteractionDataEvent{}
+			if err := json.Unmarshal(message, i); err != nil {
+				return err
 			}
-		case dto.EventMessageReactionRemove:
-		case dto.EventMessageReactionAdd:
-			{
-				mr := &dto.MessageReactionDataEvent{}
-				err := json.Unmarshal(message, mr)
-				if err == nil {
-					_ = MessageReactionEventHandler(payload, mr)
-				}
+			i.ID = payload.ID
+			return qq.InteractionEventHandler(payload, i)
+		}
+	case dto.EventDirectMessageCreate:
+		{
+			i := &dto.DirectMessageDataEvent{}
+			if err := json.Unmarshal(message, i); err != nil {
+				return err
 			}
-		case dto.EventMessageAuditReject:
-		case dto.EventMessageAuditPass:
-			{
-				mr := &dto.MessageAuditDataEvent{}
-				err := json.Unmarshal(message, mr)
-				if err == nil {
-					_ = MessageAuditEventHandler(payload, mr)
-				}
+			return qq.DirectMessageEventHandler(payload, i)
+		}
+	case dto.EventMessageReactionAdd, dto.EventMessageReactionRemove:
+		{
+			mr := &dto.MessageReactionDataEvent{}
+			if err := json.Unmarshal(message, mr); err != nil {
+				return err
 			}
-		case dto.EventForumReplyDelete:
-		case dto.EventForumThreadDelete:
-		case dto.EventForumPostDelete:
-		case dto.EventForumThreadUpdate:
-		case dto.EventForumReplyCreate:
-		case dto.EventForumPostCreate:
-		case dto.EventForumThreadCreate:
-			{
-				ft := &dto.ForumAuditDataEvent{}
-				err := json.Unmarshal(message, ft)
-				if err == nil {
-					_ = ForumAuditEventHandler(payload, ft)
-				}
+			return qq.MessageReactionEventHandler(payload, mr)
+		}
+	case dto.EventMessageAuditPass, dto.EventMessageAuditReject:
+		{
+			mr := &dto.MessageAuditDataEvent{}
+			if err := json.Unmarshal(message, mr); err != nil {
+				return err
 			}
-		case dto.EventGuildDelete:
-		case dto.EventGuildUpdate:
-		case dto.EventGuildCreate:
-			{
-				g := &dto.GuildDataEvent{}
-				err := json.Unmarshal(message, g)
-				if err == nil {
-					_ = GuildEventHandler(payload, g)
-				}
+			return qq.MessageAuditEventHandler(payload, mr)
+		}
+	case dto.EventForumThreadCreate, dto.EventForumThreadUpdate, dto.EventForumThreadDelete,
+		dto.EventForumPostCreate, dto.EventForumPostDelete, dto.EventForumReplyCreate, dto.EventForumReplyDelete:
+		{
+			ft := &dto.ForumAuditDataEvent{}
+			if err := json.Unmarshal(message, ft); err != nil {
+				return err
 			}
-		case dto.EventChannelDelete:
-		case dto.EventChannelUpdate:
-		case dto.EventChannelCreate:
-			{
-				c := &dto.ChannelDataEvent{}
-				err := json.Unmarshal(message, c)
-				if err == nil {
-					_ = ChannelEventHandler(payload, c)
-				}
+			return qq.ForumAuditEventHandler(payload, ft)
+		}
+	case dto.EventGuildCreate, dto.EventGuildUpdate, dto.EventGuildDelete:
+		{
+			g := &dto.GuildDataEvent{}
+			if err := json.Unmarshal(message, g); err != nil {
+				return err
 			}
-		case dto.EventGuildMemberUpdate:
-		case dto.EventGuildMemberRemove:
-		case dto.EventGuildMemberAdd:
-			{
-				gm := &dto.GuildMemberDataEvent{}
-				err := json.Unmarshal(message, gm)
-				if err == nil {
-					_ = GuildMemberEventHandler(payload, gm)
-				}
+			return qq.GuildEventHandler(payload, g)
+		}
+	case dto.EventChannelCreate, dto.EventChannelUpdate, dto.EventChannelDelete:
+		{
+			c := &dto.ChannelDataEvent{}
+			if err := json.Unmarshal(message, c); err != nil {
+				return err
 			}
-		default:
-			{
-				logger.Warnf("暂未支持的事件%v", payload.Type)
+			return qq.ChannelEventHandler(payload, c)
+		}
+	case dto.EventGuildMemberAdd, dto.EventGuildMemberUpdate, dto.EventGuildMemberRemove:
+		{
+			gm := &dto.GuildMemberDataEvent{}
+			if err := json.Unmarshal(message, gm); err != nil {
+				return err
 			}
+			return qq.GuildMemberEventHandler(payload, gm)
+		}
+	default:
+		{
+			logger.Warnf("暂未支持的事件%v", payload.Type)
 		}
 	}
+	return nil
 }
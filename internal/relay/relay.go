@@ -0,0 +1,341 @@
+package relay
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 跨平台消息转发
+//
+// 按规则将群消息转换后交给目标平台机器人的 SendMessage 发送。
+// 机器人自己发送的消息不转发；同一群内有多个机器人时，刚转发进该群的消息再次出现在事件中也不转发，避免循环。
+
+const (
+	// echoTTL 转发进群的消息在该时间内再次出现视为转发产生的消息
+	echoTTL = time.Minute
+	// maxEchoes 记录的转发消息数量上限，超过后清理过期及最早的记录
+	maxEchoes = 1000
+	queueSize = 100
+)
+
+// mediaLabels 媒体消息段转为文本时的名称
+var mediaLabels = map[onebot.ElementType]string{
+	onebot.ImageType:  "图片",
+	onebot.RecordType: "语音",
+	onebot.VideoType:  "视频",
+	onebot.FileType:   "文件",
+}
+
+// job 待发送的转发消息
+type job struct {
+	target  config.RelayEndpoint
+	message []*onebot.Element
+}
+
+var (
+	rules    atomic.Pointer[[]config.RelayRule]
+	jobs     = make(chan *job, queueSize)
+	echoes   = make(map[string]time.Time)
+	echoesMu sync.Mutex
+	once     sync.Once
+)
+
+// Load 加载转发规则，首次调用时开始转发，重新加载配置时再次调用
+func Load(relayRules []config.RelayRule) {
+	valid := make([]config.RelayRule, 0, len(relayRules))
+	for _, rule := range relayRules {
+		if err := validate(&rule); err != nil {
+			logger.Warnf("转发规则 %s 无效: %v", rule.Name, err)
+			continue
+		}
+		valid = append(valid, rule)
+	}
+	rules.Store(&valid)
+	once.Do(func() {
+		protocol.AddEventHook(hook)
+		go run()
+	})
+	if len(valid) > 0 {
+		logger.Infof("已加载 %d 条转发规则", len(valid))
+	}
+}
+
+// validate 校验转发规则
+func validate(rule *config.RelayRule) error {
+	if rule.Source.Platform == "" || rule.Source.GroupId == 0 {
+		return errors.New("source 需要设置 platform 与 group_id")
+	}
+	if len(rule.Targets) == 0 {
+		return errors.New("targets 不能为空")
+	}
+	for _, target := range rule.Targets {
+		if target.Platform == "" || target.GroupId == 0 {
+			return errors.New("target 需要设置 platform 与 group_id")
+		}
+	}
+	switch rule.Direction {
+	case "oneway", "both":
+	default:
+		return fmt.Errorf("不支持的转发方向: %s", rule.Direction)
+	}
+	switch rule.Media {
+	case "forward", "link", "drop":
+	default:
+		return fmt.Errorf("不支持的媒体处理方式: %s", rule.Media)
+	}
+	return nil
+}
+
+// match 事件是否来自该群
+func match(endpoint config.RelayEndpoint, identity protocol.Identity, groupId int32) bool {
+	return strings.EqualFold(endpoint.Platform, identity.Platform) && endpoint.GroupId == groupId &&
+		(endpoint.SelfId == 0 || endpoint.SelfId == identity.SelfId)
+}
+
+// targetsOf 获取群消息需要转发到的群
+func targetsOf(rule *config.RelayRule, identity protocol.Identity, groupId int32) []config.RelayEndpoint {
+	if match(rule.Source, identity, groupId) {
+		return rule.Targets
+	}
+	if rule.Direction != "both" {
+		return nil
+	}
+	for i, target := range rule.Targets {
+		if !match(target, identity, groupId) {
+			continue
+		}
+		targets := []config.RelayEndpoint{rule.Source}
+		targets = append(targets, rule.Targets[:i]...)
+		return append(targets, rule.Targets[i+1:]...)
+	}
+	return nil
+}
+
+// hook 匹配群消息并加入发送队列
+func hook(identity protocol.Identity, event onebot.Event) {
+	var message *onebot.MessageRequest
+	switch e := event.(type) {
+	case onebot.MessageRequest:
+		message = &e
+	case *onebot.MessageRequest:
+		message = e
+	default:
+		return
+	}
+	if message.MessageType != onebot.GroupMessage || isBot(identity.Platform, message.UserId) {
+		return
+	}
+	if isEcho(identity.Platform, message.GroupId, message.Message) {
+		logger.Debugf("忽略转发产生的消息: %s", message.RawMessage)
+		return
+	}
+
+	loaded := rules.Load()
+	if loaded == nil {
+		return
+	}
+	for i := range *loaded {
+		rule := &(*loaded)[i]
+		targets := targetsOf(rule, identity, message.GroupId)
+		if len(targets) == 0 {
+			continue
+		}
+		elements := convert(rule, identity, message)
+		if len(elements) == 0 {
+			continue
+		}
+		for _, target := range targets {
+			select {
+			case jobs <- &job{target: target, message: elements}:
+			default:
+				logger.Warnf("转发规则 %s 发送队列已满, 消息已丢弃", rule.Name)
+			}
+		}
+	}
+}
+
+// isBot 发送者是否为已启动的机器人
+func isBot(platform string, userId int64) bool {
+	for _, adapter := range protocol.Adapters() {
+		identity := adapter.Identity()
+		if identity.SelfId == userId && strings.EqualFold(identity.Platform, platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// convert 添加前缀并转换消息段，@、表情等平台相关的消息段转为文本，回复丢弃
+func convert(rule *config.RelayRule, identity protocol.Identity, message *onebot.MessageRequest) []*onebot.Element {
+	nickname := message.Sender.NickName
+	if nickname == "" {
+		nickname = strconv.FormatInt(message.UserId, 10)
+	}
+	prefix := strings.NewReplacer(
+		"{platform}", identity.Platform,
+		"{nickname}", nickname,
+		"{user_id}", strconv.FormatInt(message.UserId, 10),
+		"{group_id}", strconv.Itoa(int(message.GroupId)),
+		"{rule}", rule.Name,
+	).Replace(rule.Format)
+
+	var elements []*onebot.Element
+	text := func(content string) {
+		elements = append(elements, &onebot.Element{ElementType: onebot.TextType, Data: &onebot.Text{Text: content}})
+	}
+	var hasContent bool
+	for _, element := range message.Message {
+		switch element.ElementType {
+		case onebot.TextType:
+			text(element.Field("text"))
+		case onebot.AtType:
+			if target := element.Field("qq"); target == "all" {
+				text("@全体成员")
+			} else {
+				text("@" + target)
+			}
+		case onebot.FaceType:
+			if name := element.Field("name"); name != "" {
+				text("[" + name + "]")
+			} else {
+				text("[表情]")
+			}
+		case onebot.ChannelType:
+			text("#" + element.Field("id"))
+		case onebot.ImageType, onebot.RecordType, onebot.VideoType, onebot.FileType:
+			switch rule.Media {
+			case "forward":
+				elements = append(elements, element)
+			case "link":
+				url := element.Field("url")
+				if url == "" {
+					url = element.Field("file")
+				}
+				if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+					text(fmt.Sprintf("[%s] %s", mediaLabels[element.ElementType], url))
+				} else {
+					text("[" + mediaLabels[element.ElementType] + "]")
+				}
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		hasContent = true
+	}
+	if !hasContent {
+		return nil
+	}
+	if prefix != "" {
+		elements = append([]*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: prefix}}}, elements...)
+	}
+	return elements
+}
+
+// run 依次发送转发消息，保持消息顺序
+func run() {
+	for j := range jobs {
+		adapter, ok := adapterFor(j.target)
+		if !ok {
+			logger.Warnf("转发失败, 未找到 %s 平台的机器人", j.target.Platform)
+			continue
+		}
+		identity := adapter.Identity()
+		// 发送前记录，发送期间到达的事件同样可以识别
+		markEcho(identity.Platform, j.target.GroupId, j.message)
+		adapter.SendMessage(&onebot.MessageRequest{
+			MessageBase: onebot.MessageBase{SelfId: identity.SelfId},
+			MessageType: onebot.GroupMessage,
+			GroupId:     j.target.GroupId,
+			Message:     j.message,
+		})
+	}
+}
+
+// adapterFor 获取发送到目标群的机器人，未指定 self_id 时使用该平台 self_id 最小的机器人
+func adapterFor(target config.RelayEndpoint) (protocol.Adapter, bool) {
+	if target.SelfId != 0 {
+		return protocol.GetAdapter(target.SelfId)
+	}
+	var result protocol.Adapter
+	for _, adapter := range protocol.Adapters() {
+		identity := adapter.Identity()
+		if !strings.EqualFold(identity.Platform, target.Platform) {
+			continue
+		}
+		if result == nil || identity.SelfId < result.Identity().SelfId {
+			result = adapter
+		}
+	}
+	return result, result != nil
+}
+
+// echoKey 以群与去除空白后的消息文本识别转发的消息，没有文本的消息不记录
+func echoKey(platform string, groupId int32, elements []*onebot.Element) string {
+	var builder strings.Builder
+	for _, element := range elements {
+		if element.ElementType == onebot.TextType {
+			builder.WriteString(element.Field("text"))
+		}
+	}
+	text := strings.Join(strings.Fields(builder.String()), "")
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%s", strings.ToLower(platform), groupId, text)
+}
+
+// markEcho 记录转发进群的消息
+func markEcho(platform string, groupId int32, elements []*onebot.Element) {
+	key := echoKey(platform, groupId, elements)
+	if key == "" {
+		return
+	}
+	echoesMu.Lock()
+	defer echoesMu.Unlock()
+	now := time.Now()
+	if len(echoes) >= maxEchoes {
+		for k, expire := range echoes {
+			if now.After(expire) {
+				delete(echoes, k)
+			}
+		}
+	}
+	// 短时间内转发大量消息时移除最早的记录，保持数量不超过上限
+	for len(echoes) >= maxEchoes {
+		var oldest string
+		for k, expire := range echoes {
+			if oldest == "" || expire.Before(echoes[oldest]) {
+				oldest = k
+			}
+		}
+		delete(echoes, oldest)
+	}
+	echoes[key] = now.Add(echoTTL)
+}
+
+// isEcho 消息是否为刚转发进群的消息，识别后移除记录
+func isEcho(platform string, groupId int32, elements []*onebot.Element) bool {
+	key := echoKey(platform, groupId, elements)
+	if key == "" {
+		return false
+	}
+	echoesMu.Lock()
+	defer echoesMu.Unlock()
+	expire, ok := echoes[key]
+	if !ok {
+		return false
+	}
+	delete(echoes, key)
+	return time.Now().Before(expire)
+}
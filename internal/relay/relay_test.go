@@ -0,0 +1,173 @@
+package relay
+
+import (
+	"GoQHttp/config"
+	"GoQHttp/internal/onebot"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/logger"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	m.Run()
+}
+
+var (
+	qqGroup   = config.RelayEndpoint{Platform: "qq", GroupId: 1}
+	kookGroup = config.RelayEndpoint{Platform: "kook", GroupId: 2}
+	tgGroup   = config.RelayEndpoint{Platform: "telegram", SelfId: 9, GroupId: 3}
+)
+
+func TestTargetsOf(t *testing.T) {
+	oneway := &config.RelayRule{Source: qqGroup, Targets: []config.RelayEndpoint{kookGroup, tgGroup}, Direction: "oneway"}
+	both := &config.RelayRule{Source: qqGroup, Targets: []config.RelayEndpoint{kookGroup, tgGroup}, Direction: "both"}
+	for _, c := range []struct {
+		name     string
+		rule     *config.RelayRule
+		identity protocol.Identity
+		groupId  int32
+		expected []config.RelayEndpoint
+	}{
+		{"单向来源群", oneway, protocol.Identity{Platform: "QQ", SelfId: 1}, 1, []config.RelayEndpoint{kookGroup, tgGroup}},
+		{"单向目标群", oneway, protocol.Identity{Platform: "kook", SelfId: 1}, 2, nil},
+		{"其他群", oneway, protocol.Identity{Platform: "qq", SelfId: 1}, 2, nil},
+		{"双向来源群", both, protocol.Identity{Platform: "qq", SelfId: 1}, 1, []config.RelayEndpoint{kookGroup, tgGroup}},
+		{"双向目标群", both, protocol.Identity{Platform: "kook", SelfId: 1}, 2, []config.RelayEndpoint{qqGroup, tgGroup}},
+		{"双向指定机器人", both, protocol.Identity{Platform: "telegram", SelfId: 9}, 3, []config.RelayEndpoint{qqGroup, kookGroup}},
+		{"双向其他机器人", both, protocol.Identity{Platform: "telegram", SelfId: 8}, 3, nil},
+	} {
+		if targets := targetsOf(c.rule, c.identity, c.groupId); fmt.Sprint(targets) != fmt.Sprint(c.expected) {
+			t.Fatalf("%s 转发到 %v, 期望 %v", c.name, targets, c.expected)
+		}
+	}
+}
+
+// textOf 拼接文本消息段，其他消息段以 <类型> 表示，便于比较
+func textOf(elements []*onebot.Element) string {
+	if elements == nil {
+		return "<nil>"
+	}
+	var builder strings.Builder
+	for _, element := range elements {
+		if element.ElementType == onebot.TextType {
+			builder.WriteString(element.Field("text"))
+		} else {
+			builder.WriteString("<" + string(element.ElementType) + ">")
+		}
+	}
+	return builder.String()
+}
+
+func TestConvert(t *testing.T) {
+	identity := protocol.Identity{Platform: "qq", SelfId: 1}
+	image := &onebot.Element{ElementType: onebot.ImageType, Data: &onebot.Image{File: "FILE_ID", Url: "https://example.com/1.jpg"}}
+	record := &onebot.Element{ElementType: onebot.RecordType, Data: &onebot.Record{File: "FILE_ID"}}
+	message := &onebot.MessageRequest{
+		UserId:  7,
+		GroupId: 1,
+		Sender:  onebot.Sender{NickName: "Alice"},
+		Message: []*onebot.Element{
+			{ElementType: onebot.ReplyType, Data: &onebot.Reply{Id: "3"}},
+			{ElementType: onebot.TextType, Data: &onebot.Text{Text: "看 "}},
+			{ElementType: onebot.AtType, Data: &onebot.At{Uid: "all"}},
+			image,
+			record,
+		},
+	}
+	for _, c := range []struct {
+		name     string
+		rule     config.RelayRule
+		message  *onebot.MessageRequest
+		expected string
+	}{
+		{"原样转发", config.RelayRule{Name: "r", Format: "[{platform}] {nickname}({user_id})@{group_id}/{rule}: ", Media: "forward"}, message,
+			"[qq] Alice(7)@1/r: 看 @全体成员<image><record>"},
+		{"转为链接", config.RelayRule{Media: "link"}, message, "看 @全体成员[图片] https://example.com/1.jpg[语音]"},
+		{"丢弃媒体", config.RelayRule{Format: "{nickname}: ", Media: "drop"}, message, "Alice: 看 @全体成员"},
+		{"没有昵称", config.RelayRule{Format: "{nickname}: ", Media: "drop"}, &onebot.MessageRequest{UserId: 7, Message: message.Message[1:2]}, "7: 看 "},
+		{"只有媒体且丢弃", config.RelayRule{Format: "{nickname}: ", Media: "drop"}, &onebot.MessageRequest{UserId: 7, Message: []*onebot.Element{image}}, "<nil>"},
+	} {
+		if result := textOf(convert(&c.rule, identity, c.message)); result != c.expected {
+			t.Fatalf("%s 得到 %q, 期望 %q", c.name, result, c.expected)
+		}
+	}
+}
+
+// drainJobs 取出队列中的全部转发消息
+func drainJobs() []*job {
+	var result []*job
+	for {
+		select {
+		case j := <-jobs:
+			result = append(result, j)
+		default:
+			return result
+		}
+	}
+}
+
+func TestEchoIgnored(t *testing.T) {
+	loaded := []config.RelayRule{{Name: "echo", Source: qqGroup, Targets: []config.RelayEndpoint{kookGroup}, Direction: "both", Format: "{nickname}: ", Media: "forward"}}
+	rules.Store(&loaded)
+	defer rules.Store(nil)
+	drainJobs()
+
+	hook(protocol.Identity{Platform: "qq", SelfId: 1}, onebot.MessageRequest{
+		MessageType: onebot.GroupMessage,
+		GroupId:     1,
+		UserId:      7,
+		Sender:      onebot.Sender{NickName: "Alice"},
+		Message:     []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: "hello"}}},
+	})
+	queued := drainJobs()
+	if len(queued) != 1 || queued[0].target != kookGroup {
+		t.Fatalf("转发队列 %+v", queued)
+	}
+	// 模拟发送：转发的消息出现在目标群的事件中
+	markEcho("kook", kookGroup.GroupId, queued[0].message)
+	echo := onebot.MessageRequest{
+		MessageType: onebot.GroupMessage,
+		GroupId:     kookGroup.GroupId,
+		UserId:      8,
+		Message:     []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: "Alice:  hello"}}},
+	}
+	hook(protocol.Identity{Platform: "kook", SelfId: 2}, &echo)
+	if queued = drainJobs(); len(queued) != 0 {
+		t.Fatalf("转发产生的消息被再次转发 %+v", queued)
+	}
+	// 记录只识别一次，之后相同内容的消息正常转发
+	hook(protocol.Identity{Platform: "kook", SelfId: 2}, &echo)
+	if queued = drainJobs(); len(queued) != 1 || queued[0].target != qqGroup {
+		t.Fatalf("转发队列 %+v", queued)
+	}
+}
+
+func TestMarkEchoCapped(t *testing.T) {
+	echoesMu.Lock()
+	echoes = make(map[string]time.Time)
+	echoesMu.Unlock()
+	text := func(content string) []*onebot.Element {
+		return []*onebot.Element{{ElementType: onebot.TextType, Data: &onebot.Text{Text: content}}}
+	}
+
+	// 记录均未过期时移除最早的记录
+	for i := 0; i < maxEchoes+10; i++ {
+		markEcho("qq", 1, text(fmt.Sprint("message", i)))
+	}
+	echoesMu.Lock()
+	count := len(echoes)
+	echoesMu.Unlock()
+	if count != maxEchoes {
+		t.Fatalf("记录 %d 条, 上限 %d", count, maxEchoes)
+	}
+	if isEcho("qq", 1, text("message0")) {
+		t.Fatal("最早的记录应被移除")
+	}
+	if !isEcho("qq", 1, text(fmt.Sprint("message", maxEchoes+9))) {
+		t.Fatal("最新的记录应保留")
+	}
+}
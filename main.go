@@ -3,21 +3,26 @@ package main
 import (
 	"GoQHttp/config"
 	"GoQHttp/internal"
+	"GoQHttp/internal/admin"
 	"GoQHttp/internal/constant"
-	protocol "GoQHttp/internal/protocol/tencent"
+	"GoQHttp/internal/history"
+	"GoQHttp/internal/idmap"
+	"GoQHttp/internal/lifecycle"
+	"GoQHttp/internal/profile"
+	"GoQHttp/internal/protocol"
+	"GoQHttp/internal/relay"
 	"GoQHttp/logger"
 	"GoQHttp/utils"
 	"GoQHttp/websocket/client"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
-var (
-	QQClient protocol.Tencent
-)
-
 // initLogger 初始化日志系统
 func initLogger(config *config.Config) error {
 	// 创建日志记录器
@@ -31,14 +36,6 @@ func initLogger(config *config.Config) error {
 	return nil
 }
 
-// webhookHandler 处理传入的 webhook 请求
-func webhookHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/qq" {
-		QQClient.Init(w, r, constant.Configuration)
-	}
-
-}
-
 // healthHandler 提供健康检查端点
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -54,6 +51,54 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// addChannels 为机器人创建功能端连接
+func addChannels(channels []config.Channel, selfId int64) {
+	for _, channel := range channels {
+		if channel.WSReverse != nil {
+			// 反向 WebSocket
+			NoneBotClient := client.NewNoneBotClient(
+				channel.WSReverse.Universal,
+				selfId,
+				"Universal",
+				channel.WSReverse.Authorization,
+				channel.WSReverse.ReconnectInterval,
+				channel.WSReverse.MaxRetries,
+				channel.WSReverse.RetryDelay,
+			)
+			client.NoneBotManager.AddClient(NoneBotClient)
+		} else {
+			//hub := websocket.NewHub()
+			//go hub.Run()
+			//
+			//http.HandleFunc(channel.WS.Address, func(w http.ResponseWriter, r *http.Request) {
+			//	websocket.ServeWs(hub, w, r)
+			//})
+			//TODO 正向 WebSocket
+		}
+	}
+}
+
+// watchReload 收到 SIGHUP 时重新加载配置并通知机器人
+func watchReload() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := config.LoadConfig("config.yml"); err != nil {
+			logger.Errorf("重新加载配置失败: %v", err)
+			continue
+		}
+		// 使用新解析的配置，constant.Configuration 仍为启动时的配置
+		cfg := config.GetConfig()
+		protocol.ReloadBots(cfg)
+		history.Load(cfg.MessageHistory)
+		profile.Load(cfg.Profile)
+		lifecycle.Load(cfg.DataLifecycle)
+		relay.Load(cfg.RelayRules)
+		admin.Load(cfg.Admin)
+		logger.Info("配置已重新加载")
+	}
+}
+
 func displayBanner() {
 	fmt.Println(" ██████   ██████   ██████  ██   ██ ████████ ████████ ██████  ")
 	fmt.Println("██       ██    ██ ██    ██ ██   ██    ██       ██    ██   ██ ")
@@ -88,69 +133,34 @@ func main() {
 		}
 	}()
 
-	// 初始化数据库
-	utils.SqLiteInit()
+	// 初始化存储
+	utils.StorageInit(constant.Configuration.Database.Driver, constant.Configuration.Database.Path)
+	idmap.Init(constant.Configuration.IdMapping)
 
 	// 设置 HTTP 路由
 	http.HandleFunc("/health", healthHandler)
-
-	if constant.Configuration.Bot.QQ.Enable {
-		// 启动 QQ官方
-		if constant.Configuration.Bot.QQ.WebhookPath != "" && constant.Configuration.Bot.QQ.Secret != "" && constant.Configuration.Bot.QQ.Id != 0 && constant.Configuration.Bot.QQ.Uid != 0 && constant.Configuration.Bot.QQ.Token != "" && constant.Configuration.Bot.QQ.ScopeType != "" {
-			err := constant.OpenApi.Init(constant.Configuration.Bot.QQ.Id, constant.Configuration.Bot.QQ.Secret, constant.Configuration.Bot.QQ.Sandbox)
-			if err != nil {
-				logger.Errorf("GetAppAccessToken err: %v", err)
-				return
-			}
-
-			http.HandleFunc(constant.Configuration.Bot.QQ.WebhookPath, webhookHandler)
-			go QQClient.HandlerEvent()
-			go constant.OpenApi.SendPacket()
-		} else {
-			logger.Warnf("QQ机器人启动失败,请检查设置")
+	admin.Load(constant.Configuration.Admin)
+
+	// 消息记录与跨平台消息转发
+	history.Load(constant.Configuration.MessageHistory)
+	profile.Load(constant.Configuration.Profile)
+	lifecycle.Load(constant.Configuration.DataLifecycle)
+	relay.Load(constant.Configuration.RelayRules)
+
+	// 启动各平台的机器人，机器人未单独配置功能端时使用全局配置
+	for _, bot := range protocol.StartBots(constant.Configuration) {
+		channels := bot.Channels
+		if len(channels) == 0 {
+			channels = constant.Configuration.Channels
 		}
-	}
-
-	if constant.Configuration.Bot.Kook.Enable {
-		if constant.Configuration.Bot.Kook.Token == "" {
-			logger.Warnf("Kook机器人启动失败,请检查设置")
-			return
-		}
-		kookClient := client.NewKookClient(constant.Configuration.Bot.Kook.Token, true)
-		go kookClient.Connect()
+		addChannels(channels, bot.Adapter.Identity().SelfId)
 	}
 
 	// 功能端对接
-	if len(constant.Configuration.Channels) > 0 {
-
-		for _, channel := range constant.Configuration.Channels {
-			if channel.WSReverse != nil {
-				// 反向 WebSocket
-				NoneBotClient := client.NewNoneBotClient(
-					channel.WSReverse.Universal,
-					int64(constant.Configuration.Bot.QQ.Uid),
-					"Universal",
-					channel.WSReverse.Authorization,
-					channel.WSReverse.ReconnectInterval,
-					constant.Configuration.Bot.QQ.Sandbox,
-					channel.WSReverse.MaxRetries,
-					channel.WSReverse.RetryDelay,
-				)
-				client.NoneBotManager.AddClient(NoneBotClient)
-			} else {
-				//hub := websocket.NewHub()
-				//go hub.Run()
-				//
-				//http.HandleFunc(channel.WS.Address, func(w http.ResponseWriter, r *http.Request) {
-				//	websocket.ServeWs(hub, w, r)
-				//})
-				//TODO 正向 WebSocket
-			}
+	client.NoneBotManager.StartAll()
+	go client.NoneBotManager.Broadcast()
 
-		}
-		client.NoneBotManager.StartAll()
-		go client.NoneBotManager.Broadcast()
-	}
+	go watchReload()
 
 	// 创建带超时的 HTTP 服务器
 	server := &http.Server{
@@ -161,7 +171,6 @@ func main() {
 
 	// 启动服务器
 	logger.Infof("服务器监听端口 %s", constant.Configuration.Server.Port)
-	logger.Infof("QQ Webhook 监听地址: %s", constant.Configuration.Bot.QQ.WebhookPath)
 	logger.Infof("日志级别: %s", constant.Configuration.Logging.Level)
 	if constant.Configuration.Logging.FilePath != "" {
 		logger.Infof("日志文件: %s", constant.Configuration.Logging.FilePath)
@@ -0,0 +1,8 @@
+package main
+
+// 接入的平台，各平台在 init 中注册到 protocol
+import (
+	_ "GoQHttp/internal/protocol/kook"
+	_ "GoQHttp/internal/protocol/telegram"
+	_ "GoQHttp/internal/protocol/tencent"
+)
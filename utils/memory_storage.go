@@ -0,0 +1,540 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage 内存存储，用于测试与不需要保留数据的部署，进程退出后数据丢失
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	// idMaps 命名空间中数字id与原始id的双向映射
+	idMaps map[string]*memoryIdMap
+
+	// messages 按记录序号排列的消息记录
+	messages      []*MessageHistory
+	messageIndex  map[memoryMessageKey]*MessageHistory
+	lastMessageId int64
+
+	groupMessages      []TencentGroupMessage
+	lastGroupMessageId int32
+
+	// dedupe 事件id对应的写入顺序
+	dedupe     map[string]int64
+	dedupeSeq  int64
+	queue      []TencentEventQueue
+	lastQueued int64
+
+	aliases map[memoryAliasKey]string
+
+	erasures []DataErasure
+}
+
+type memoryIdMap struct {
+	ids  map[int64]string
+	raws map[string]int64
+	max  int64
+}
+
+type memoryAliasKey struct {
+	platform string
+	groupId  int32
+	userId   int64
+}
+
+type memoryMessageKey struct {
+	selfId    int64
+	messageId int32
+}
+
+// NewMemoryStorage 创建内存存储
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		idMaps:       make(map[string]*memoryIdMap),
+		messageIndex: make(map[memoryMessageKey]*MessageHistory),
+		dedupe:       make(map[string]int64),
+		aliases:      make(map[memoryAliasKey]string),
+	}
+}
+
+func (m *MemoryStorage) IdMapInsert(namespace string, id int64, rawId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idMap, ok := m.idMaps[namespace]
+	if !ok {
+		idMap = &memoryIdMap{ids: make(map[int64]string), raws: make(map[string]int64)}
+		m.idMaps[namespace] = idMap
+	}
+	if _, ok = idMap.ids[id]; ok {
+		return false, nil
+	}
+	if _, ok = idMap.raws[rawId]; ok {
+		return false, nil
+	}
+	idMap.ids[id] = rawId
+	idMap.raws[rawId] = id
+	idMap.max = max(idMap.max, id)
+	return true, nil
+}
+
+func (m *MemoryStorage) IdMapId(namespace string, rawId string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if idMap, ok := m.idMaps[namespace]; ok {
+		if id, ok := idMap.raws[rawId]; ok {
+			return id, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (m *MemoryStorage) IdMapRaw(namespace string, id int64) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if idMap, ok := m.idMaps[namespace]; ok {
+		if rawId, ok := idMap.ids[id]; ok {
+			return rawId, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+func (m *MemoryStorage) IdMapMax(namespace string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if idMap, ok := m.idMaps[namespace]; ok {
+		return idMap.max, nil
+	}
+	return 0, nil
+}
+
+func (m *MemoryStorage) IdMapErase(namespace string, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idMap, ok := m.idMaps[namespace]
+	if !ok {
+		return false, nil
+	}
+	rawId, ok := idMap.ids[id]
+	if !ok {
+		return false, nil
+	}
+	delete(idMap.raws, rawId)
+	erased := ErasedRawId(id)
+	idMap.ids[id] = erased
+	idMap.raws[erased] = id
+	return true, nil
+}
+
+func (m *MemoryStorage) MessageHistoryInsert(history *MessageHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memoryMessageKey{selfId: history.SelfId, messageId: history.MessageId}
+	if _, ok := m.messageIndex[key]; ok {
+		return nil
+	}
+	m.lastMessageId++
+	record := *history
+	record.ID = m.lastMessageId
+	m.messages = append(m.messages, &record)
+	m.messageIndex[key] = &record
+	return nil
+}
+
+func (m *MemoryStorage) MessageHistoryGet(selfId int64, messageId int32) (*MessageHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.messageIndex[memoryMessageKey{selfId: selfId, messageId: messageId}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *record
+	return &result, nil
+}
+
+func (m *MemoryStorage) MessageHistoryGroup(selfId int64, groupId int32, beforeId int64, count int) ([]MessageHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []MessageHistory
+	for i := len(m.messages) - 1; i >= 0 && len(result) < count; i-- {
+		record := m.messages[i]
+		if beforeId > 0 && record.ID > beforeId {
+			continue
+		}
+		if record.SelfId == selfId && record.MessageType == "group" && record.GroupId == groupId {
+			result = append(result, *record)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (m *MemoryStorage) MessageHistorySearch(q *MessageSearchQuery) ([]MessageHistory, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keyword := strings.ToLower(strings.TrimSpace(q.Keyword))
+	var result []MessageHistory
+	total := 0
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		record := m.messages[i]
+		switch {
+		case keyword != "" && !strings.Contains(strings.ToLower(record.PlainText), keyword),
+			q.SelfId != 0 && record.SelfId != q.SelfId,
+			q.GroupId != 0 && (record.MessageType != "group" || record.GroupId != q.GroupId),
+			q.UserId != 0 && record.SenderId != q.UserId,
+			q.StartTime != 0 && record.TimeStamp < q.StartTime,
+			q.EndTime != 0 && record.TimeStamp > q.EndTime:
+			continue
+		}
+		if total >= q.Offset && len(result) < q.Limit {
+			result = append(result, *record)
+		}
+		total++
+	}
+	return result, total, nil
+}
+
+func (m *MemoryStorage) MessageHistoryPurge(before int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.messages[:0]
+	var deleted int64
+	for _, record := range m.messages {
+		if record.TimeStamp < before {
+			delete(m.messageIndex, memoryMessageKey{selfId: record.SelfId, messageId: record.MessageId})
+			deleted++
+			continue
+		}
+		kept = append(kept, record)
+	}
+	m.messages = kept
+	return deleted, nil
+}
+
+// matchErase 消息记录是否在删除范围内
+func matchErase(record *MessageHistory, q *EraseQuery) bool {
+	if record.Platform != q.Platform || (q.SelfId != 0 && record.SelfId != q.SelfId) {
+		return false
+	}
+	if q.GroupId != 0 {
+		return record.MessageType == "group" && record.GroupId == q.GroupId
+	}
+	if q.UserNamespace != "" && record.UserNamespace != "" && record.UserNamespace != q.UserNamespace {
+		return false
+	}
+	return (record.SenderId == q.UserId && !record.Outgoing) || record.UserId == q.UserId
+}
+
+func (m *MemoryStorage) MessageHistoryErase(q *EraseQuery) (int64, error) {
+	if q.GroupId == 0 && q.UserId == 0 {
+		return 0, fmt.Errorf("未指定删除的群或用户")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.messages[:0]
+	var erased int64
+	for _, record := range m.messages {
+		if !matchErase(record, q) {
+			kept = append(kept, record)
+			continue
+		}
+		erased++
+		if !q.Anonymize {
+			delete(m.messageIndex, memoryMessageKey{selfId: record.SelfId, messageId: record.MessageId})
+			continue
+		}
+		record.UserId = 0
+		if !record.Outgoing {
+			record.SenderId = 0
+		}
+		record.Sender, record.Segments, record.RawMessage, record.PlainText = "{}", "[]", "", ""
+		kept = append(kept, record)
+	}
+	m.messages = kept
+	return erased, nil
+}
+
+func (m *MemoryStorage) GroupMessageInsert(appId int, messageId string, selfGroupId int32, selfSenderId int64) (int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, message := range m.groupMessages {
+		if message.AppId == appId && message.MessageId == messageId && message.SelfGroupId == selfGroupId && message.SelfSenderId == selfSenderId {
+			return message.ID, nil
+		}
+	}
+	m.lastGroupMessageId++
+	m.groupMessages = append(m.groupMessages, TencentGroupMessage{
+		ID:           m.lastGroupMessageId,
+		AppId:        appId,
+		SelfGroupId:  selfGroupId,
+		SelfSenderId: selfSenderId,
+		MessageId:    messageId,
+		TimeStamp:    int(time.Now().Unix()),
+	})
+	return m.lastGroupMessageId, nil
+}
+
+func (m *MemoryStorage) GetGroupMessageID(appId int, selfGroupId int32, selfSenderId int64) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.groupMessages) - 1; i >= 0; i-- {
+		message := m.groupMessages[i]
+		if message.AppId == appId && message.SelfGroupId == selfGroupId && message.SelfSenderId == selfSenderId {
+			return message.MessageId, nil
+		}
+	}
+	return "", nil
+}
+
+func (m *MemoryStorage) GroupMessageErase(selfGroupId int32, selfSenderId int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.groupMessages[:0]
+	var deleted int64
+	for _, message := range m.groupMessages {
+		if (selfGroupId != 0 && message.SelfGroupId == selfGroupId) || (selfGroupId == 0 && message.SelfGroupId == 0 && message.SelfSenderId == selfSenderId) {
+			deleted++
+			continue
+		}
+		kept = append(kept, message)
+	}
+	m.groupMessages = kept
+	return deleted, nil
+}
+
+// ClaimLegacyRows 内存存储没有旧版本数据
+func (m *MemoryStorage) ClaimLegacyRows(appId int) error {
+	return nil
+}
+
+func (m *MemoryStorage) EventDedupeInsert(eventId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.dedupe[eventId]; ok {
+		return false, nil
+	}
+	m.dedupeSeq++
+	m.dedupe[eventId] = m.dedupeSeq
+	return true, nil
+}
+
+func (m *MemoryStorage) EventDedupeExists(eventId string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.dedupe[eventId]
+	return ok, nil
+}
+
+func (m *MemoryStorage) EventDedupePrune(prefix string, keep int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var eventIds []string
+	for eventId := range m.dedupe {
+		if strings.HasPrefix(eventId, prefix) {
+			eventIds = append(eventIds, eventId)
+		}
+	}
+	if len(eventIds) <= keep {
+		return 0, nil
+	}
+	sort.Slice(eventIds, func(i, j int) bool { return m.dedupe[eventIds[i]] > m.dedupe[eventIds[j]] })
+	for _, eventId := range eventIds[keep:] {
+		delete(m.dedupe, eventId)
+	}
+	return int64(len(eventIds) - keep), nil
+}
+
+func (m *MemoryStorage) EventQueuePush(appId int, payload []byte, eventId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if eventId != "" {
+		if _, ok := m.dedupe[eventId]; ok {
+			return false, nil
+		}
+		m.dedupeSeq++
+		m.dedupe[eventId] = m.dedupeSeq
+	}
+	m.lastQueued++
+	m.queue = append(m.queue, TencentEventQueue{
+		ID:        m.lastQueued,
+		AppId:     appId,
+		Payload:   string(payload),
+		TimeStamp: time.Now().Unix(),
+	})
+	return true, nil
+}
+
+func (m *MemoryStorage) EventQueuePending(appId int, limit int) ([]TencentEventQueue, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []TencentEventQueue
+	for _, event := range m.queue {
+		if len(events) >= limit {
+			break
+		}
+		if event.AppId == appId {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryStorage) EventQueueAck(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, event := range m.queue {
+		if event.ID == id {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) EventQueueFail(id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.queue {
+		if m.queue[i].ID == id {
+			m.queue[i].Attempts++
+			return m.queue[i].Attempts, nil
+		}
+	}
+	return 0, fmt.Errorf("事件 %d 不在队列中", id)
+}
+
+func (m *MemoryStorage) UserAliasSet(platform string, groupId int32, userId int64, alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memoryAliasKey{platform: platform, groupId: groupId, userId: userId}
+	if alias == "" {
+		delete(m.aliases, key)
+	} else {
+		m.aliases[key] = alias
+	}
+	return nil
+}
+
+func (m *MemoryStorage) UserAliasList() ([]UserAlias, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	aliases := make([]UserAlias, 0, len(m.aliases))
+	for key, alias := range m.aliases {
+		aliases = append(aliases, UserAlias{Platform: key.platform, GroupId: key.groupId, UserId: key.userId, Alias: alias})
+	}
+	return aliases, nil
+}
+
+func (m *MemoryStorage) UserAliasErase(q *EraseQuery) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for key := range m.aliases {
+		if key.platform != q.Platform {
+			continue
+		}
+		if (q.GroupId != 0 && key.groupId == q.GroupId) || (q.GroupId == 0 && key.userId == q.UserId) {
+			delete(m.aliases, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStorage) DataErasureInsert(e *DataErasure) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record := *e
+	record.ID = int64(len(m.erasures)) + 1
+	m.erasures = append(m.erasures, record)
+	return record.ID, nil
+}
+
+func (m *MemoryStorage) DataErasureDue(now int64) ([]DataErasure, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var erasures []DataErasure
+	for _, erasure := range m.erasures {
+		if erasure.Status == ErasurePending && erasure.DueAt <= now {
+			erasures = append(erasures, erasure)
+		}
+	}
+	return erasures, nil
+}
+
+func (m *MemoryStorage) DataErasureCancel(platform string, selfId int64, subject string, subjectId int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var cancelled int64
+	for i := range m.erasures {
+		erasure := &m.erasures[i]
+		if erasure.Status == ErasurePending && erasure.Platform == platform && (selfId == 0 || erasure.SelfId == selfId) &&
+			erasure.Subject == subject && erasure.SubjectId == subjectId {
+			erasure.Status = ErasureCancelled
+			erasure.DoneAt = time.Now().Unix()
+			cancelled++
+		}
+	}
+	return cancelled, nil
+}
+
+func (m *MemoryStorage) DataErasureClaim(id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id <= 0 || id > int64(len(m.erasures)) {
+		return false, fmt.Errorf("数据删除任务 %d 不存在", id)
+	}
+	erasure := &m.erasures[id-1]
+	if erasure.Status != ErasurePending {
+		return false, nil
+	}
+	erasure.Status = ErasureRunning
+	return true, nil
+}
+
+func (m *MemoryStorage) DataErasureRequeue() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var requeued int64
+	for i := range m.erasures {
+		if m.erasures[i].Status == ErasureRunning {
+			m.erasures[i].Status = ErasurePending
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+func (m *MemoryStorage) DataErasureFinish(id int64, status string, result string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id <= 0 || id > int64(len(m.erasures)) {
+		return fmt.Errorf("数据删除任务 %d 不存在", id)
+	}
+	erasure := &m.erasures[id-1]
+	erasure.Status, erasure.Result, erasure.DoneAt = status, result, time.Now().Unix()
+	return nil
+}
+
+func (m *MemoryStorage) DataErasureList(offset int, limit int) ([]DataErasure, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var erasures []DataErasure
+	for i := len(m.erasures) - 1 - offset; i >= 0 && len(erasures) < limit; i-- {
+		erasures = append(erasures, m.erasures[i])
+	}
+	return erasures, len(m.erasures), nil
+}
+
+// Vacuum 内存存储删除的数据立即释放
+func (m *MemoryStorage) Vacuum() error {
+	return nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,323 @@
+package utils
+
+import (
+	"GoQHttp/logger"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration 数据库结构迁移，按版本号顺序在事务中执行，已执行的版本记录在 schema_version
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// migrations 全部迁移，只能在末尾追加，已发布的迁移不再修改
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "初始表结构",
+		Up:          createTables,
+	},
+	{
+		Version:     2,
+		Description: "旧版本映射表迁移到 IdMap",
+		Up:          migrateIdMap,
+	},
+	{
+		Version:     3,
+		Description: "消息与事件索引",
+		Up: execAll(
+			"CREATE INDEX IF NOT EXISTS idx_group_message_id ON TencentGroupMessage (app_id, message_id)",
+			"CREATE INDEX IF NOT EXISTS idx_group_message_sender ON TencentGroupMessage (app_id, self_group_id, self_sender_id, id)",
+			// 群与用户的 openid 保存在 IdMap 的 raw_id 中，由 UNIQUE (namespace, raw_id) 索引
+			"CREATE INDEX IF NOT EXISTS idx_event_dedupe_time ON TencentEventDedupe (time_stamp)",
+		),
+	},
+	{
+		Version:     4,
+		Description: "消息记录",
+		Up: execAll(`
+		CREATE TABLE IF NOT EXISTS MessageHistory (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			platform TEXT NOT NULL,
+			self_id INTEGER NOT NULL,
+			message_id INTEGER NOT NULL,
+			message_type TEXT NOT NULL,
+			group_id INTEGER NOT NULL DEFAULT 0,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			sender_id INTEGER NOT NULL,
+			sender TEXT NOT NULL,
+			segments TEXT NOT NULL,
+			raw_message TEXT NOT NULL,
+			outgoing INTEGER NOT NULL DEFAULT 0,
+			time_stamp INTEGER NOT NULL,
+			UNIQUE (self_id, message_id)
+		)
+		`,
+			"CREATE INDEX IF NOT EXISTS idx_message_history_group ON MessageHistory (self_id, group_id, id)",
+			"CREATE INDEX IF NOT EXISTS idx_message_history_time ON MessageHistory (time_stamp)",
+		),
+	},
+	{
+		Version:     5,
+		Description: "消息全文检索",
+		Up:          createMessageSearch,
+	},
+	{
+		Version:     6,
+		Description: "用户别名",
+		Up: execAll(`
+		CREATE TABLE IF NOT EXISTS UserAlias (
+			platform TEXT NOT NULL,
+			group_id INTEGER NOT NULL DEFAULT 0,
+			user_id INTEGER NOT NULL,
+			alias TEXT NOT NULL,
+			PRIMARY KEY (platform, group_id, user_id)
+		)
+		`),
+	},
+	{
+		Version:     7,
+		Description: "数据删除记录",
+		Up: execAll(`
+		CREATE TABLE IF NOT EXISTS DataErasure (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			platform TEXT NOT NULL,
+			self_id INTEGER NOT NULL DEFAULT 0,
+			subject TEXT NOT NULL,
+			subject_id INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			policy TEXT NOT NULL,
+			status TEXT NOT NULL,
+			result TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			due_at INTEGER NOT NULL,
+			done_at INTEGER NOT NULL DEFAULT 0
+		)
+		`,
+			"CREATE INDEX IF NOT EXISTS idx_data_erasure_due ON DataErasure (status, due_at)",
+		),
+	},
+	{
+		Version:     8,
+		Description: "消息记录与数据删除任务的用户命名空间",
+		Up: execAll(
+			"ALTER TABLE MessageHistory ADD COLUMN user_namespace TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE DataErasure ADD COLUMN namespace TEXT NOT NULL DEFAULT ''",
+			// QQ 私聊可能来自单聊或频道私信，旧记录无法区分，保留为空
+			`UPDATE MessageHistory SET user_namespace = CASE
+				WHEN platform = 'qq' AND message_type = 'group' THEN 'qq:user'
+				WHEN platform = 'qq' AND message_type = 'guild' THEN 'qq:guild:user'
+				WHEN platform = 'qq' THEN ''
+				ELSE platform || ':user' END`,
+		),
+	},
+}
+
+// Migrate 执行未应用的迁移，数据库版本高于程序支持的版本时返回错误
+func (s *SQLite3Util) Migrate(migrations []Migration) error {
+	createSQL := `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)
+	`
+	if _, err := s.Exec(createSQL); err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if current > latest {
+		return fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d, 请升级程序", current, latest)
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		err = s.Transaction(func(tx *sql.Tx) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			insertSQL := "INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)"
+			_, err := tx.Exec(insertSQL, migration.Version, migration.Description, time.Now().Unix())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("数据库迁移 %d(%s) 失败: %v", migration.Version, migration.Description, err)
+		}
+		logger.Infof("数据库迁移 %d(%s) 已完成", migration.Version, migration.Description)
+	}
+	return nil
+}
+
+// SchemaVersion 获取数据库已应用的最高版本，未执行过迁移时返回 0
+func (s *SQLite3Util) SchemaVersion() (int, error) {
+	var version int
+	err := s.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// execAll 依次执行多条 SQL 的迁移
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// createTables 创建表，兼容引入迁移前由旧版本创建的数据库
+func createTables(tx *sql.Tx) error {
+	err := execAll(`
+	CREATE TABLE IF NOT EXISTS TencentGroupMessage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		app_id INTEGER NOT NULL DEFAULT 0,
+		message_id TEXT NOT NULL,
+		self_group_id  INTEGER NOT NULL,
+	    self_sender_id INTEGER NOT NULL,
+		time_stamp INTEGER NOT NULL
+	)
+	`, `
+	CREATE TABLE IF NOT EXISTS TencentEventDedupe (
+		event_id TEXT PRIMARY KEY,
+		time_stamp INTEGER NOT NULL
+	)
+	`, `
+	CREATE TABLE IF NOT EXISTS TencentEventQueue (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		app_id INTEGER NOT NULL,
+		payload TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		time_stamp INTEGER NOT NULL
+	)
+	`,
+		"CREATE INDEX IF NOT EXISTS idx_event_queue_app ON TencentEventQueue (app_id, id)",
+		// 数字id在命名空间内唯一，不同命名空间可以使用相同的id
+		`
+	CREATE TABLE IF NOT EXISTS IdMap (
+		namespace TEXT NOT NULL,
+		id INTEGER NOT NULL,
+		raw_id TEXT NOT NULL,
+		PRIMARY KEY (namespace, id),
+		UNIQUE (namespace, raw_id)
+	)
+	`)(tx)
+	if err != nil {
+		return err
+	}
+	// 旧版本数据库没有 app_id 列，补充后由 ClaimLegacyRows 归属到机器人
+	return ensureColumn(tx, "TencentGroupMessage", "app_id", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// createMessageSearch 以 trigram 分词建立消息文本的全文索引，由触发器与 MessageHistory 保持同步
+func createMessageSearch(tx *sql.Tx) error {
+	if err := ensureColumn(tx, "MessageHistory", "plain_text", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return execAll(
+		// 已有的消息没有提取文本，使用原始消息
+		"UPDATE MessageHistory SET plain_text = raw_message WHERE plain_text = ''",
+		"CREATE VIRTUAL TABLE IF NOT EXISTS MessageSearch USING fts5(plain_text, content='MessageHistory', content_rowid='id', tokenize='trigram')",
+		`
+		CREATE TRIGGER IF NOT EXISTS message_search_insert AFTER INSERT ON MessageHistory BEGIN
+			INSERT INTO MessageSearch (rowid, plain_text) VALUES (new.id, new.plain_text);
+		END
+		`, `
+		CREATE TRIGGER IF NOT EXISTS message_search_delete AFTER DELETE ON MessageHistory BEGIN
+			INSERT INTO MessageSearch (MessageSearch, rowid, plain_text) VALUES ('delete', old.id, old.plain_text);
+		END
+		`, `
+		CREATE TRIGGER IF NOT EXISTS message_search_update AFTER UPDATE OF plain_text ON MessageHistory BEGIN
+			INSERT INTO MessageSearch (MessageSearch, rowid, plain_text) VALUES ('delete', old.id, old.plain_text);
+			INSERT INTO MessageSearch (rowid, plain_text) VALUES (new.id, new.plain_text);
+		END
+		`,
+		"INSERT INTO MessageSearch (MessageSearch) VALUES ('rebuild')",
+	)(tx)
+}
+
+// legacyIdTables 旧版本的映射表，迁移到 IdMap 后删除，原始id中的 app_id 为 0 时由 ClaimLegacyRows 归属到机器人
+var legacyIdTables = []struct {
+	table     string
+	namespace string
+	rawId     string
+}{
+	{"IdMapping", "namespace", "raw_id"},
+	{"TencentGroup", "'qq:group'", "app_id || ':' || group_openid"},
+	{"TencentAuthor", "'qq:user'", "app_id || ':' || member_openid"},
+}
+
+// migrateIdMap 将旧版本映射表中的数据保留原有id迁移到 IdMap
+func migrateIdMap(tx *sql.Tx) error {
+	for _, legacy := range legacyIdTables {
+		exists, err := tableExists(tx, legacy.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if legacy.table != "IdMapping" {
+			// 更早版本的表没有 app_id 列
+			if err = ensureColumn(tx, legacy.table, "app_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+		}
+		migrateSQL := fmt.Sprintf("INSERT OR IGNORE INTO IdMap (namespace, id, raw_id) SELECT %s, id, %s FROM %s ORDER BY id",
+			legacy.namespace, legacy.rawId, legacy.table)
+		if _, err = tx.Exec(migrateSQL); err != nil {
+			return err
+		}
+		if _, err = tx.Exec("DROP TABLE " + legacy.table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableExists 事务中检查表是否存在
+func tableExists(tx *sql.Tx, tableName string) (bool, error) {
+	var name string
+	err := tx.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ensureColumn 表中缺少指定列时补充该列
+func ensureColumn(tx *sql.Tx, tableName string, column string, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", tableName))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, column, definition))
+	return err
+}
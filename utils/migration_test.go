@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestSQLite 在临时目录打开未迁移的 SQLite 数据库
+func openTestSQLite(t *testing.T) *SQLite3Util {
+	t.Helper()
+	s, err := NewSQLite3Util(Options{
+		DBPath:      filepath.Join(t.TempDir(), "test.db"),
+		MaxIdle:     1,
+		MaxOpen:     1,
+		MaxLifetime: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// execSQL 依次执行 SQL，用于准备旧版本的数据库
+func execSQL(t *testing.T, s *SQLite3Util, statements ...string) {
+	t.Helper()
+	for _, statement := range statements {
+		if _, err := s.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+}
+
+func TestMigrateFresh(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].Version
+	if version, err := s.SchemaVersion(); err != nil || version != latest {
+		t.Fatalf("版本 %d err=%v, 期望 %d", version, err, latest)
+	}
+	for _, table := range []string{"TencentGroupMessage", "IdMap", "MessageHistory", "MessageSearch", "UserAlias", "DataErasure"} {
+		if exists, err := s.TableExists(table); err != nil || !exists {
+			t.Fatalf("表 %s exists=%v err=%v", table, exists, err)
+		}
+	}
+	// 再次执行不重复迁移
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := s.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&count); err != nil || count != len(migrations) {
+		t.Fatalf("迁移记录 %d err=%v, 期望 %d", count, err, len(migrations))
+	}
+}
+
+func TestMigrateVersionsAscending(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Fatalf("迁移 %d 的版本号不大于前一个迁移 %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestMigrateLegacyTables(t *testing.T) {
+	s := openTestSQLite(t)
+	// 引入迁移前的数据库：没有 app_id 列，映射保存在单独的表中
+	execSQL(t, s,
+		"CREATE TABLE TencentGroupMessage (id INTEGER PRIMARY KEY AUTOINCREMENT, message_id TEXT NOT NULL, self_group_id INTEGER NOT NULL, self_sender_id INTEGER NOT NULL, time_stamp INTEGER NOT NULL)",
+		"INSERT INTO TencentGroupMessage (message_id, self_group_id, self_sender_id, time_stamp) VALUES ('ROBOT1.0_old', 7, 9, 1)",
+		"CREATE TABLE TencentGroup (id INTEGER PRIMARY KEY AUTOINCREMENT, group_openid TEXT NOT NULL)",
+		"INSERT INTO TencentGroup (id, group_openid) VALUES (7, 'GROUP_OPENID')",
+		"CREATE TABLE TencentAuthor (id INTEGER PRIMARY KEY AUTOINCREMENT, member_openid TEXT NOT NULL)",
+		"INSERT INTO TencentAuthor (id, member_openid) VALUES (9, 'MEMBER_OPENID')",
+		"CREATE TABLE IdMapping (namespace TEXT NOT NULL, id INTEGER NOT NULL, raw_id TEXT NOT NULL)",
+		"INSERT INTO IdMapping (namespace, id, raw_id) VALUES ('kook:user', 3, 'KOOK_USER')",
+	)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"TencentGroup", "TencentAuthor", "IdMapping"} {
+		if exists, _ := s.TableExists(table); exists {
+			t.Fatalf("旧版本的表 %s 应在迁移后删除", table)
+		}
+	}
+	// 保留原有id，原始id的 app_id 为 0
+	if id, err := s.IdMapId("qq:group", "0:GROUP_OPENID"); err != nil || id != 7 {
+		t.Fatalf("群映射 %d err=%v", id, err)
+	}
+	if id, err := s.IdMapId("kook:user", "KOOK_USER"); err != nil || id != 3 {
+		t.Fatalf("通用映射 %d err=%v", id, err)
+	}
+
+	// 机器人启动后认领旧数据
+	if err := s.ClaimLegacyRows(102); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := s.IdMapRaw("qq:user", 9); err != nil || raw != "102:MEMBER_OPENID" {
+		t.Fatalf("认领后的用户映射 %q err=%v", raw, err)
+	}
+	if messageId, err := s.GetGroupMessageID(102, 7, 9); err != nil || messageId != "ROBOT1.0_old" {
+		t.Fatalf("认领后的群消息 %q err=%v", messageId, err)
+	}
+}
+
+func TestMigrateSearchExistingMessages(t *testing.T) {
+	s := openTestSQLite(t)
+	// 全文检索之前的版本已有消息记录
+	if err := s.Migrate(migrations[:4]); err != nil {
+		t.Fatal(err)
+	}
+	execSQL(t, s, `INSERT INTO MessageHistory (platform, self_id, message_id, message_type, group_id, sender_id, sender, segments, raw_message, time_stamp)
+		VALUES ('qq', 1, 1, 'group', 2, 3, '{}', '[]', 'hello migration', 1)`)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	messages, total, err := s.MessageHistorySearch(&MessageSearchQuery{Keyword: "migration", Limit: 10})
+	if err != nil || total != 1 || len(messages) != 1 || messages[0].PlainText != "hello migration" {
+		t.Fatalf("检索迁移前的消息 %+v total=%d err=%v", messages, total, err)
+	}
+}
+
+func TestMigrateUserNamespace(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.Migrate(migrations[:7]); err != nil {
+		t.Fatal(err)
+	}
+	execSQL(t, s, `INSERT INTO MessageHistory (platform, self_id, message_id, message_type, group_id, user_id, sender_id, sender, segments, raw_message, time_stamp)
+		VALUES ('qq', 1, 1, 'group', 2, 0, 3, '{}', '[]', '', 1), ('qq', 1, 2, 'private', 0, 3, 3, '{}', '[]', '', 1),
+		('kook', 1, 3, 'private', 0, 3, 3, '{}', '[]', '', 1)`)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	// QQ 私聊无法区分单聊与频道私信，保留为空
+	for messageId, expected := range map[int32]string{1: "qq:user", 2: "", 3: "kook:user"} {
+		if message, err := s.MessageHistoryGet(1, messageId); err != nil || message.UserNamespace != expected {
+			t.Fatalf("消息 %d 的命名空间 %+v err=%v, 期望 %q", messageId, message, err, expected)
+		}
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].Version
+	failing := append(append([]Migration(nil), migrations...), Migration{
+		Version:     latest + 1,
+		Description: "失败的迁移",
+		Up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE Broken (id INTEGER)"); err != nil {
+				return err
+			}
+			return errors.New("broken")
+		},
+	})
+	if err := s.Migrate(failing); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("失败的迁移 err=%v", err)
+	}
+	// 失败的迁移整体回滚
+	if exists, _ := s.TableExists("Broken"); exists {
+		t.Fatal("失败的迁移创建的表应回滚")
+	}
+	if version, _ := s.SchemaVersion(); version != latest {
+		t.Fatalf("失败后版本 %d, 期望 %d", version, latest)
+	}
+}
+
+func TestMigrateNewerDatabase(t *testing.T) {
+	s := openTestSQLite(t)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本程序打开新版本的数据库
+	if err := s.Migrate(migrations[:len(migrations)-1]); err == nil {
+		t.Fatal("数据库版本高于程序支持的版本时应返回错误")
+	}
+}
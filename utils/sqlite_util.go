@@ -5,8 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/glebarez/sqlite"
 )
@@ -28,27 +33,111 @@ type Options struct {
 	MaxLifetime time.Duration
 }
 
-type TencentGroup struct {
-	ID          int32  `db:"id"`
-	GroupId     string `db:"group_id"`
-	GroupOpenid string `db:"group_openid"`
-}
-
-type TencentAuthor struct {
-	ID           int64  `db:"id"`
-	AuthorId     string `db:"author_id"`
-	MemberOpenid string `db:"member_openid"`
-}
 type TencentGroupMessage struct {
 	ID           int32  `db:"id"`
+	AppId        int    `db:"app_id"`
 	SelfGroupId  int32  `db:"self_group_id"`
 	SelfSenderId int64  `db:"self_sender_id"`
 	MessageId    string `db:"message_id"`
 	TimeStamp    int    `db:"time_stamp"`
 }
 
+// TencentEventQueue 持久化队列中等待处理的事件
+type TencentEventQueue struct {
+	ID        int64  `db:"id"`
+	AppId     int    `db:"app_id"`
+	Payload   string `db:"payload"`
+	Attempts  int    `db:"attempts"`
+	TimeStamp int64  `db:"time_stamp"`
+}
+
+// MessageHistory 收发消息的记录，user_id 为私聊的对方，sender 与 segments 为 JSON
+type MessageHistory struct {
+	ID          int64  `db:"id"`
+	Platform    string `db:"platform"`
+	SelfId      int64  `db:"self_id"`
+	MessageId   int32  `db:"message_id"`
+	MessageType string `db:"message_type"`
+	GroupId     int32  `db:"group_id"`
+	UserId      int64  `db:"user_id"`
+	SenderId    int64  `db:"sender_id"`
+	Sender      string `db:"sender"`
+	Segments    string `db:"segments"`
+	RawMessage  string `db:"raw_message"`
+	Outgoing    bool   `db:"outgoing"`
+	TimeStamp   int64  `db:"time_stamp"`
+	// PlainText 消息中的文本，用于全文检索
+	PlainText string `db:"plain_text"`
+	// UserNamespace 用户id的映射命名空间，同一平台不同命名空间的数字id可能相同
+	UserNamespace string `db:"user_namespace"`
+}
+
+// UserAlias 管理员设置的用户别名，group_id 为 0 时为全局别名
+type UserAlias struct {
+	Platform string `db:"platform"`
+	GroupId  int32  `db:"group_id"`
+	UserId   int64  `db:"user_id"`
+	Alias    string `db:"alias"`
+}
+
+// EraseQuery 删除或匿名化数据的范围，GroupId 与 UserId 二选一，SelfId 为 0 时不限制机器人
+type EraseQuery struct {
+	Platform string
+	SelfId   int64
+	GroupId  int32
+	UserId   int64
+	// UserNamespace 用户id的映射命名空间，为空时不限制
+	UserNamespace string
+	// Anonymize 保留消息记录的时间与会话，清除内容与发送者
+	Anonymize bool
+}
+
+// DataErasure 数据删除任务，执行后作为审计记录保留，result 为处理结果的 JSON
+type DataErasure struct {
+	ID        int64  `db:"id"`
+	Platform  string `db:"platform"`
+	SelfId    int64  `db:"self_id"`
+	Subject   string `db:"subject"`
+	SubjectId int64  `db:"subject_id"`
+	// Namespace 对象id的映射命名空间
+	Namespace string `db:"namespace"`
+	Reason    string `db:"reason"`
+	Policy    string `db:"policy"`
+	Status    string `db:"status"`
+	Result    string `db:"result"`
+	CreatedAt int64  `db:"created_at"`
+	DueAt     int64  `db:"due_at"`
+	DoneAt    int64  `db:"done_at"`
+}
+
+// 数据删除任务的状态
+const (
+	ErasurePending   = "pending"
+	ErasureRunning   = "running"
+	ErasureDone      = "done"
+	ErasureFailed    = "failed"
+	ErasureCancelled = "cancelled"
+)
+
+// MessageSearchQuery 消息检索条件，为零值的条件不限制
+type MessageSearchQuery struct {
+	Keyword string
+	SelfId  int64
+	GroupId int32
+	// UserId 发送者
+	UserId int64
+	// StartTime 与 EndTime 为秒级时间戳，包含边界
+	StartTime int64
+	EndTime   int64
+	Offset    int
+	Limit     int
+}
+
 var DBUtil *SQLite3Util
 
+// defaultDBPath 未配置数据库路径时使用的路径
+const defaultDBPath = "./sqlite.db"
+
 // DefaultOptions 默认选项
 var DefaultOptions = Options{
 	DBPath:      ":memory:",
@@ -332,10 +421,20 @@ func (s *SQLite3Util) QueryToStructs(dest interface{}, sqlStr string, args ...in
 	return rows.Err()
 }
 
-func SqLiteInit() {
+// SqLiteInit 打开数据库并执行未应用的迁移，path 为空时使用 ./sqlite.db
+func SqLiteInit(path string) {
+	if path == "" {
+		path = defaultDBPath
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// 初始化数据库连接
 	dbUtil, err := NewSQLite3Util(Options{
-		DBPath:      "./sqlite.db",
+		DBPath:      path,
 		MaxIdle:     10,
 		MaxOpen:     100,
 		MaxLifetime: time.Hour,
@@ -344,132 +443,389 @@ func SqLiteInit() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	//defer dbUtil.Close()
 
-	// 创建表
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS TencentGroup (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		group_id TEXT NOT NULL,
-		group_openid TEXT NOT NULL
-	)
-	`
-	_, err = dbUtil.Exec(createTableSQL)
-	if err != nil {
+	if err = dbUtil.Migrate(migrations); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	createTableSQL = `
-	CREATE TABLE IF NOT EXISTS TencentAuthor (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		member_openid TEXT NOT NULL,
-		author_id TEXT NOT NULL
-	)
-	`
-	_, err = dbUtil.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal(err)
+// GroupMessageInsert 记录QQ群消息，返回消息id，已记录时返回原有的消息id
+func (s *SQLite3Util) GroupMessageInsert(appId int, messageId string, selfGroupId int32, selfSenderId int64) (int32, error) {
+	selectSQL := "SELECT * FROM TencentGroupMessage WHERE app_id = ? AND message_id = ? and self_group_id = ? AND self_sender_id = ?"
+	var tgms []TencentGroupMessage
+	err := s.QueryToStructs(&tgms, selectSQL, appId, messageId, selfGroupId, selfSenderId)
+	if tgms != nil {
+		return tgms[0].ID, err
 	}
 
-	createTableSQL = `
-	CREATE TABLE IF NOT EXISTS TencentGroupMessage (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		message_id TEXT NOT NULL,
-		self_group_id  INTEGER NOT NULL,
-	    self_sender_id INTEGER NOT NULL,
-		time_stamp INTEGER NOT NULL 
-	)
-	`
-	_, err = dbUtil.Exec(createTableSQL)
+	insertSQL := "INSERT INTO TencentGroupMessage (app_id,message_id,self_group_id,self_sender_id,time_stamp) VALUES (?,?,?,?,?)"
+	id, err := s.Insert(insertSQL, appId, messageId, selfGroupId, selfSenderId, time.Now().Unix())
+
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
+
+	return int32(id), nil
 }
 
-func (s *SQLite3Util) GroupInsert(groupId string, groupOpenId string) (int32, error) {
-	selectSQL := "SELECT * FROM TencentGroup WHERE group_id = ? AND group_openid = ?"
-	var tgs []TencentGroup
-	err := s.QueryToStructs(&tgs, selectSQL, groupId, groupOpenId)
-	if tgs != nil {
-		return tgs[0].ID, err
+// GetGroupMessageID 获取群成员最近一条消息的原始id，用于发送被动回复，没有消息时返回空字符串
+func (s *SQLite3Util) GetGroupMessageID(appId int, selfGroupId int32, selfSenderId int64) (string, error) {
+	selectSQL := "SELECT * FROM TencentGroupMessage WHERE app_id = ? AND self_group_id = ? AND self_sender_id = ? ORDER BY id DESC"
+	var tgms []TencentGroupMessage
+	err := s.QueryToStructs(&tgms, selectSQL, appId, selfGroupId, selfSenderId)
+	if err == nil {
+		if len(tgms) > 0 {
+			return tgms[0].MessageId, nil
+		}
 	}
+	return "", err
+}
 
-	insertSQL := "INSERT INTO TencentGroup (group_id, group_openid) VALUES (?, ?)"
-	id, err := s.Insert(insertSQL, groupId, groupOpenId)
+// ClaimLegacyRows 将旧版本未区分机器人的映射数据归属到指定机器人
+func (s *SQLite3Util) ClaimLegacyRows(appId int) error {
+	return s.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("UPDATE TencentGroupMessage SET app_id = ? WHERE app_id = 0", appId); err != nil {
+			return err
+		}
+		claimSQL := "UPDATE OR IGNORE IdMap SET raw_id = ? || substr(raw_id, 2) WHERE namespace IN ('qq:group', 'qq:user') AND raw_id LIKE '0:%'"
+		_, err := tx.Exec(claimSQL, appId)
+		return err
+	})
+}
 
+// EventDedupeInsert 记录已处理的事件id，事件已存在时返回 false
+func (s *SQLite3Util) EventDedupeInsert(eventId string) (bool, error) {
+	insertSQL := "INSERT OR IGNORE INTO TencentEventDedupe (event_id, time_stamp) VALUES (?, ?)"
+	rows, err := s.Update(insertSQL, eventId, time.Now().Unix())
 	if err != nil {
-		return 0, err
+		return false, err
 	}
+	return rows > 0, nil
+}
 
-	return int32(id), nil
+// EventDedupeExists 事件id是否已记录
+func (s *SQLite3Util) EventDedupeExists(eventId string) (bool, error) {
+	var count int
+	err := s.QueryRow("SELECT COUNT(*) FROM TencentEventDedupe WHERE event_id = ?", eventId).Scan(&count)
+	return count > 0, err
 }
-func (s *SQLite3Util) SenderInsert(memberOpenId string, authorId string) (int64, error) {
-	selectSQL := "SELECT * FROM TencentAuthor WHERE member_openid = ? AND author_id = ?"
-	var tas []TencentAuthor
-	err := s.QueryToStructs(&tas, selectSQL, memberOpenId, authorId)
-	if tas != nil {
-		return tas[0].ID, err
-	}
 
-	insertSQL := "INSERT INTO TencentAuthor (member_openid, author_id) VALUES (?, ?)"
-	id, err := s.Insert(insertSQL, memberOpenId, authorId)
+// EventDedupePrune 仅保留以 prefix 开头的最近 keep 条事件记录
+func (s *SQLite3Util) EventDedupePrune(prefix string, keep int) (int64, error) {
+	deleteSQL := "DELETE FROM TencentEventDedupe WHERE event_id LIKE ? AND event_id NOT IN (SELECT event_id FROM TencentEventDedupe WHERE event_id LIKE ? ORDER BY time_stamp DESC LIMIT ?)"
+	return s.Delete(deleteSQL, prefix+"%", prefix+"%", keep)
+}
 
-	if err != nil {
+// EventQueuePush 事件写入持久化队列，去重记录与队列记录在同一事务中写入
+func (s *SQLite3Util) EventQueuePush(appId int, payload []byte, eventId string) (bool, error) {
+	inserted := true
+	err := s.Transaction(func(tx *sql.Tx) error {
+		now := time.Now().Unix()
+		if eventId != "" {
+			result, err := tx.Exec("INSERT OR IGNORE INTO TencentEventDedupe (event_id, time_stamp) VALUES (?, ?)", eventId, now)
+			if err != nil {
+				return err
+			}
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				inserted = false
+				return nil
+			}
+		}
+		_, err := tx.Exec("INSERT INTO TencentEventQueue (app_id, payload, time_stamp) VALUES (?, ?, ?)", appId, string(payload), now)
+		return err
+	})
+	return inserted && err == nil, err
+}
+
+// EventQueuePending 按写入顺序获取机器人尚未确认的事件
+func (s *SQLite3Util) EventQueuePending(appId int, limit int) ([]TencentEventQueue, error) {
+	selectSQL := "SELECT * FROM TencentEventQueue WHERE app_id = ? ORDER BY id LIMIT ?"
+	var events []TencentEventQueue
+	err := s.QueryToStructs(&events, selectSQL, appId, limit)
+	return events, err
+}
+
+// EventQueueAck 事件处理完成后从队列删除
+func (s *SQLite3Util) EventQueueAck(id int64) error {
+	_, err := s.Delete("DELETE FROM TencentEventQueue WHERE id = ?", id)
+	return err
+}
+
+// EventQueueFail 记录事件处理失败，返回累计失败次数
+func (s *SQLite3Util) EventQueueFail(id int64) (int, error) {
+	if _, err := s.Update("UPDATE TencentEventQueue SET attempts = attempts + 1 WHERE id = ?", id); err != nil {
 		return 0, err
 	}
-
-	return id, nil
+	var attempts int
+	err := s.QueryRow("SELECT attempts FROM TencentEventQueue WHERE id = ?", id).Scan(&attempts)
+	return attempts, err
 }
-func (s *SQLite3Util) GroupMessageInsert(messageId string, selfGroupId int32, selfSenderId int64) (int32, error) {
-	selectSQL := "SELECT * FROM TencentGroupMessage WHERE message_id = ? and self_group_id = ? AND self_sender_id = ?"
-	var tgms []TencentGroupMessage
-	err := s.QueryToStructs(&tgms, selectSQL, messageId, selfGroupId, selfSenderId)
-	if tgms != nil {
-		return tgms[0].ID, err
+
+// IdMapInsert 记录映射，数字id或原始id已被使用时返回 false
+func (s *SQLite3Util) IdMapInsert(namespace string, id int64, rawId string) (bool, error) {
+	insertSQL := "INSERT OR IGNORE INTO IdMap (namespace, id, raw_id) VALUES (?, ?, ?)"
+	rows, err := s.Update(insertSQL, namespace, id, rawId)
+	if err != nil {
+		return false, err
 	}
+	return rows > 0, nil
+}
+
+// IdMapId 获取原始id对应的数字id，不存在时返回 ErrNotFound
+func (s *SQLite3Util) IdMapId(namespace string, rawId string) (int64, error) {
+	var id int64
+	err := s.QueryRow("SELECT id FROM IdMap WHERE namespace = ? AND raw_id = ?", namespace, rawId).Scan(&id)
+	return id, err
+}
+
+// IdMapRaw 获取数字id对应的原始id，不存在时返回 ErrNotFound
+func (s *SQLite3Util) IdMapRaw(namespace string, id int64) (string, error) {
+	var rawId string
+	err := s.QueryRow("SELECT raw_id FROM IdMap WHERE namespace = ? AND id = ?", namespace, id).Scan(&rawId)
+	return rawId, err
+}
 
-	insertSQL := "INSERT INTO TencentGroupMessage (message_id,self_group_id,self_sender_id,time_stamp) VALUES (?,?,?,?)"
-	id, err := s.Insert(insertSQL, messageId, selfGroupId, selfSenderId, time.Now().Unix())
+// IdMapMax 获取命名空间中最大的数字id，没有映射时返回 0
+func (s *SQLite3Util) IdMapMax(namespace string) (int64, error) {
+	var id int64
+	err := s.QueryRow("SELECT COALESCE(MAX(id), 0) FROM IdMap WHERE namespace = ?", namespace).Scan(&id)
+	return id, err
+}
+
+// MessageHistoryInsert 记录消息，同一机器人的消息id已存在时忽略
+func (s *SQLite3Util) MessageHistoryInsert(m *MessageHistory) error {
+	insertSQL := `INSERT OR IGNORE INTO MessageHistory
+		(platform, self_id, message_id, message_type, group_id, user_id, sender_id, sender, segments, raw_message, outgoing, time_stamp, plain_text, user_namespace)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.Exec(insertSQL, m.Platform, m.SelfId, m.MessageId, m.MessageType, m.GroupId, m.UserId, m.SenderId,
+		m.Sender, m.Segments, m.RawMessage, m.Outgoing, m.TimeStamp, m.PlainText, m.UserNamespace)
+	return err
+}
 
+// MessageHistoryGet 获取机器人的消息记录，不存在时返回 ErrNotFound
+func (s *SQLite3Util) MessageHistoryGet(selfId int64, messageId int32) (*MessageHistory, error) {
+	var messages []MessageHistory
+	err := s.QueryToStructs(&messages, "SELECT * FROM MessageHistory WHERE self_id = ? AND message_id = ?", selfId, messageId)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNotFound
 	}
+	return &messages[0], nil
+}
 
-	return int32(id), nil
+// MessageHistoryGroup 按时间顺序获取群中记录序号不大于 beforeId 的最近 count 条消息，beforeId 为 0 时获取最新的消息
+func (s *SQLite3Util) MessageHistoryGroup(selfId int64, groupId int32, beforeId int64, count int) ([]MessageHistory, error) {
+	if beforeId <= 0 {
+		beforeId = math.MaxInt64
+	}
+	selectSQL := `SELECT * FROM (
+		SELECT * FROM MessageHistory WHERE self_id = ? AND group_id = ? AND message_type = 'group' AND id <= ? ORDER BY id DESC LIMIT ?
+	) ORDER BY id`
+	var messages []MessageHistory
+	err := s.QueryToStructs(&messages, selectSQL, selfId, groupId, beforeId, count)
+	return messages, err
 }
 
-func (s *SQLite3Util) GetGroupMessageID(selfGroupId int32, selfSenderId int64) (string, error) {
-	selectSQL := "SELECT * FROM TencentGroupMessage WHERE self_group_id = ? AND self_sender_id = ? ORDER BY id DESC"
-	var tgms []TencentGroupMessage
-	err := s.QueryToStructs(&tgms, selectSQL, selfGroupId, selfSenderId)
-	if err == nil {
-		if len(tgms) > 0 {
-			return tgms[0].MessageId, nil
+// MessageHistorySearch 按条件检索消息，按时间倒序返回一页结果与符合条件的总数
+//
+// 全文索引使用 trigram 分词，不足三个字符的关键词无法使用索引，改为逐条匹配。
+func (s *SQLite3Util) MessageHistorySearch(q *MessageSearchQuery) ([]MessageHistory, int, error) {
+	var conditions []string
+	var args []interface{}
+	from := "MessageHistory"
+	if keyword := strings.TrimSpace(q.Keyword); keyword != "" {
+		if utf8.RuneCountInString(keyword) >= 3 {
+			from = "MessageHistory JOIN MessageSearch ON MessageSearch.rowid = MessageHistory.id"
+			conditions = append(conditions, "MessageSearch MATCH ?")
+			args = append(args, `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`)
+		} else {
+			conditions = append(conditions, `MessageHistory.plain_text LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+likeEscaper.Replace(keyword)+"%")
 		}
 	}
-	return "", err
+	if q.SelfId != 0 {
+		conditions = append(conditions, "MessageHistory.self_id = ?")
+		args = append(args, q.SelfId)
+	}
+	if q.GroupId != 0 {
+		conditions = append(conditions, "MessageHistory.message_type = 'group' AND MessageHistory.group_id = ?")
+		args = append(args, q.GroupId)
+	}
+	if q.UserId != 0 {
+		conditions = append(conditions, "MessageHistory.sender_id = ?")
+		args = append(args, q.UserId)
+	}
+	if q.StartTime != 0 {
+		conditions = append(conditions, "MessageHistory.time_stamp >= ?")
+		args = append(args, q.StartTime)
+	}
+	if q.EndTime != 0 {
+		conditions = append(conditions, "MessageHistory.time_stamp <= ?")
+		args = append(args, q.EndTime)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.QueryRow("SELECT COUNT(*) FROM "+from+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	selectSQL := "SELECT MessageHistory.* FROM " + from + where + " ORDER BY MessageHistory.id DESC LIMIT ? OFFSET ?"
+	var messages []MessageHistory
+	err := s.QueryToStructs(&messages, selectSQL, append(args, q.Limit, q.Offset)...)
+	return messages, total, err
 }
-func (s *SQLite3Util) GetGroupID(messageId int32) (string, error) {
-	selectSQL := "SELECT * FROM TencentGroup WHERE id = ?"
-	var tgms []TencentGroup
-	err := s.QueryToStructs(&tgms, selectSQL, messageId)
-	if err == nil {
-		if len(tgms) > 0 {
-			return tgms[0].GroupOpenid, nil
+
+// likeEscaper 转义 LIKE 中的通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// MessageHistoryPurge 删除指定时间之前的消息记录
+func (s *SQLite3Util) MessageHistoryPurge(before int64) (int64, error) {
+	return s.Delete("DELETE FROM MessageHistory WHERE time_stamp < ?", before)
+}
+
+// eraseConditions 消息记录的删除范围
+func eraseConditions(q *EraseQuery) (string, []interface{}) {
+	conditions := []string{"platform = ?"}
+	args := []interface{}{q.Platform}
+	if q.SelfId != 0 {
+		conditions = append(conditions, "self_id = ?")
+		args = append(args, q.SelfId)
+	}
+	if q.GroupId != 0 {
+		conditions = append(conditions, "message_type = 'group' AND group_id = ?")
+		args = append(args, q.GroupId)
+	} else {
+		// 发送的消息 sender_id 为机器人自身
+		conditions = append(conditions, "((sender_id = ? AND NOT outgoing) OR user_id = ?)")
+		args = append(args, q.UserId, q.UserId)
+		if q.UserNamespace != "" {
+			// 无法确定命名空间的旧记录同样删除
+			conditions = append(conditions, "user_namespace IN (?, '')")
+			args = append(args, q.UserNamespace)
 		}
 	}
-	return "", err
+	return strings.Join(conditions, " AND "), args
 }
-func (s *SQLite3Util) GetSenderID(messageId int32) (string, error) {
-	selectSQL := "SELECT * FROM TencentAuthor WHERE id = ?"
-	var tgms []TencentAuthor
-	err := s.QueryToStructs(&tgms, selectSQL, messageId)
-	if err == nil {
-		if len(tgms) > 0 {
-			return tgms[0].MemberOpenid, nil
-		}
+
+// MessageHistoryErase 删除或匿名化消息记录，匿名化的记录不再包含内容与用户id
+func (s *SQLite3Util) MessageHistoryErase(q *EraseQuery) (int64, error) {
+	if q.GroupId == 0 && q.UserId == 0 {
+		return 0, errors.New("未指定删除的群或用户")
 	}
-	return "", err
+	where, args := eraseConditions(q)
+	if !q.Anonymize {
+		return s.Delete("DELETE FROM MessageHistory WHERE "+where, args...)
+	}
+	updateSQL := `UPDATE MessageHistory SET
+		user_id = 0, sender_id = CASE WHEN outgoing THEN sender_id ELSE 0 END,
+		sender = '{}', segments = '[]', raw_message = '', plain_text = ''
+		WHERE ` + where
+	return s.Update(updateSQL, args...)
+}
+
+// GroupMessageErase 删除群或单聊用户的消息记录
+func (s *SQLite3Util) GroupMessageErase(selfGroupId int32, selfSenderId int64) (int64, error) {
+	if selfGroupId != 0 {
+		return s.Delete("DELETE FROM TencentGroupMessage WHERE self_group_id = ?", selfGroupId)
+	}
+	return s.Delete("DELETE FROM TencentGroupMessage WHERE self_group_id = 0 AND self_sender_id = ?", selfSenderId)
+}
+
+// IdMapErase 将映射的原始id替换为占位值
+func (s *SQLite3Util) IdMapErase(namespace string, id int64) (bool, error) {
+	rows, err := s.Update("UPDATE IdMap SET raw_id = ? WHERE namespace = ? AND id = ?", ErasedRawId(id), namespace, id)
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// UserAliasSet 设置用户别名，alias 为空时删除别名
+func (s *SQLite3Util) UserAliasSet(platform string, groupId int32, userId int64, alias string) error {
+	if alias == "" {
+		_, err := s.Delete("DELETE FROM UserAlias WHERE platform = ? AND group_id = ? AND user_id = ?", platform, groupId, userId)
+		return err
+	}
+	_, err := s.Exec("INSERT OR REPLACE INTO UserAlias (platform, group_id, user_id, alias) VALUES (?, ?, ?, ?)", platform, groupId, userId, alias)
+	return err
+}
+
+// UserAliasList 获取全部用户别名
+func (s *SQLite3Util) UserAliasList() ([]UserAlias, error) {
+	var aliases []UserAlias
+	err := s.QueryToStructs(&aliases, "SELECT * FROM UserAlias")
+	return aliases, err
+}
+
+// UserAliasErase 删除群中的全部别名或用户的全部别名
+func (s *SQLite3Util) UserAliasErase(q *EraseQuery) (int64, error) {
+	if q.GroupId != 0 {
+		return s.Delete("DELETE FROM UserAlias WHERE platform = ? AND group_id = ?", q.Platform, q.GroupId)
+	}
+	return s.Delete("DELETE FROM UserAlias WHERE platform = ? AND user_id = ?", q.Platform, q.UserId)
+}
+
+// DataErasureInsert 记录数据删除任务
+func (s *SQLite3Util) DataErasureInsert(e *DataErasure) (int64, error) {
+	insertSQL := `INSERT INTO DataErasure (platform, self_id, subject, subject_id, namespace, reason, policy, status, result, created_at, due_at, done_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	return s.Insert(insertSQL, e.Platform, e.SelfId, e.Subject, e.SubjectId, e.Namespace, e.Reason, e.Policy, e.Status, e.Result, e.CreatedAt, e.DueAt, e.DoneAt)
+}
+
+// DataErasureDue 获取已到执行时间的等待中任务
+func (s *SQLite3Util) DataErasureDue(now int64) ([]DataErasure, error) {
+	var erasures []DataErasure
+	err := s.QueryToStructs(&erasures, "SELECT * FROM DataErasure WHERE status = ? AND due_at <= ? ORDER BY id", ErasurePending, now)
+	return erasures, err
+}
+
+// DataErasureCancel 取消对象等待中的任务
+func (s *SQLite3Util) DataErasureCancel(platform string, selfId int64, subject string, subjectId int64) (int64, error) {
+	updateSQL := `UPDATE DataErasure SET status = ?, done_at = ?
+		WHERE status = ? AND platform = ? AND (? = 0 OR self_id = ?) AND subject = ? AND subject_id = ?`
+	return s.Update(updateSQL, ErasureCancelled, time.Now().Unix(), ErasurePending, platform, selfId, selfId, subject, subjectId)
+}
+
+// DataErasureClaim 将等待中的任务标记为执行中，任务已被执行或取消时返回 false
+func (s *SQLite3Util) DataErasureClaim(id int64) (bool, error) {
+	rows, err := s.Update("UPDATE DataErasure SET status = ? WHERE id = ? AND status = ?", ErasureRunning, id, ErasurePending)
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// DataErasureRequeue 将中断的执行中任务恢复为等待中
+func (s *SQLite3Util) DataErasureRequeue() (int64, error) {
+	return s.Update("UPDATE DataErasure SET status = ? WHERE status = ?", ErasurePending, ErasureRunning)
+}
+
+// DataErasureFinish 记录任务的执行结果
+func (s *SQLite3Util) DataErasureFinish(id int64, status string, result string) error {
+	_, err := s.Exec("UPDATE DataErasure SET status = ?, result = ?, done_at = ? WHERE id = ?", status, result, time.Now().Unix(), id)
+	return err
+}
+
+// DataErasureList 按时间倒序获取任务记录与总数
+func (s *SQLite3Util) DataErasureList(offset int, limit int) ([]DataErasure, int, error) {
+	var total int
+	if err := s.QueryRow("SELECT COUNT(*) FROM DataErasure").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	var erasures []DataErasure
+	err := s.QueryToStructs(&erasures, "SELECT * FROM DataErasure ORDER BY id DESC LIMIT ? OFFSET ?", limit, offset)
+	return erasures, total, err
+}
+
+// Vacuum 重建数据库文件以回收删除数据占用的空间
+func (s *SQLite3Util) Vacuum() error {
+	_, err := s.db.Exec("VACUUM")
+	return err
 }
 
 // ExampleUsage 示例使用
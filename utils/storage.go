@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+)
+
+// Storage 持久化存储，查询的记录不存在时返回 ErrNotFound
+type Storage interface {
+	// IdMapInsert 记录映射，数字id或原始id已被使用时返回 false
+	IdMapInsert(namespace string, id int64, rawId string) (bool, error)
+	// IdMapId 获取原始id对应的数字id
+	IdMapId(namespace string, rawId string) (int64, error)
+	// IdMapRaw 获取数字id对应的原始id
+	IdMapRaw(namespace string, id int64) (string, error)
+	// IdMapMax 获取命名空间中最大的数字id，没有映射时返回 0
+	IdMapMax(namespace string) (int64, error)
+	// IdMapErase 将映射的原始id替换为占位值，数字id不再分配给其他原始id，映射不存在时返回 false
+	IdMapErase(namespace string, id int64) (bool, error)
+
+	// MessageHistoryInsert 记录消息，同一机器人的消息id已存在时忽略
+	MessageHistoryInsert(m *MessageHistory) error
+	// MessageHistoryGet 获取机器人的消息记录
+	MessageHistoryGet(selfId int64, messageId int32) (*MessageHistory, error)
+	// MessageHistoryGroup 按时间顺序获取群中记录序号不大于 beforeId 的最近 count 条消息，beforeId 为 0 时获取最新的消息
+	MessageHistoryGroup(selfId int64, groupId int32, beforeId int64, count int) ([]MessageHistory, error)
+	// MessageHistorySearch 按条件检索消息，按时间倒序返回一页结果与符合条件的总数
+	MessageHistorySearch(q *MessageSearchQuery) ([]MessageHistory, int, error)
+	// MessageHistoryPurge 删除指定时间之前的消息记录
+	MessageHistoryPurge(before int64) (int64, error)
+	// MessageHistoryErase 删除或匿名化群的消息、用户发送及私聊的消息，返回处理的记录数量
+	MessageHistoryErase(q *EraseQuery) (int64, error)
+
+	// GroupMessageInsert 记录QQ群消息，selfGroupId 为 0 时为单聊消息，返回消息id，已记录时返回原有的消息id
+	GroupMessageInsert(appId int, messageId string, selfGroupId int32, selfSenderId int64) (int32, error)
+	// GetGroupMessageID 获取群成员或单聊用户最近一条消息的原始id，用于发送被动回复，没有消息时返回空字符串
+	GetGroupMessageID(appId int, selfGroupId int32, selfSenderId int64) (string, error)
+	// ClaimLegacyRows 将旧版本未区分机器人的映射数据归属到指定机器人
+	ClaimLegacyRows(appId int) error
+	// GroupMessageErase 删除群或单聊用户的消息记录，selfGroupId 为 0 时删除单聊用户 selfSenderId 的记录
+	GroupMessageErase(selfGroupId int32, selfSenderId int64) (int64, error)
+
+	// EventDedupeInsert 记录已处理的事件id，事件已存在时返回 false
+	EventDedupeInsert(eventId string) (bool, error)
+	// EventDedupeExists 事件id是否已记录
+	EventDedupeExists(eventId string) (bool, error)
+	// EventDedupePrune 仅保留以 prefix 开头的最近 keep 条事件记录
+	EventDedupePrune(prefix string, keep int) (int64, error)
+
+	// EventQueuePush 事件写入队列，eventId 不为空时在同一事务中记录事件id，事件已记录时不写入并返回 false
+	EventQueuePush(appId int, payload []byte, eventId string) (bool, error)
+	// EventQueuePending 按写入顺序获取机器人尚未确认的事件
+	EventQueuePending(appId int, limit int) ([]TencentEventQueue, error)
+	// EventQueueAck 事件处理完成后从队列删除
+	EventQueueAck(id int64) error
+	// EventQueueFail 记录事件处理失败，返回累计失败次数
+	EventQueueFail(id int64) (int, error)
+
+	// UserAliasSet 设置用户别名，groupId 为 0 时为全局别名，alias 为空时删除别名
+	UserAliasSet(platform string, groupId int32, userId int64, alias string) error
+	// UserAliasList 获取全部用户别名
+	UserAliasList() ([]UserAlias, error)
+	// UserAliasErase 删除群中的全部别名或用户的全部别名
+	UserAliasErase(q *EraseQuery) (int64, error)
+
+	// DataErasureInsert 记录数据删除任务
+	DataErasureInsert(e *DataErasure) (int64, error)
+	// DataErasureDue 获取已到执行时间的等待中任务
+	DataErasureDue(now int64) ([]DataErasure, error)
+	// DataErasureCancel 取消对象等待中的任务，selfId 为 0 时不限制机器人
+	DataErasureCancel(platform string, selfId int64, subject string, subjectId int64) (int64, error)
+	// DataErasureClaim 将等待中的任务标记为执行中，任务已被执行或取消时返回 false
+	DataErasureClaim(id int64) (bool, error)
+	// DataErasureRequeue 将中断的执行中任务恢复为等待中，启动时调用
+	DataErasureRequeue() (int64, error)
+	// DataErasureFinish 记录任务的执行结果
+	DataErasureFinish(id int64, status string, result string) error
+	// DataErasureList 按时间倒序获取任务记录与总数
+	DataErasureList(offset int, limit int) ([]DataErasure, int, error)
+
+	// Vacuum 回收删除数据占用的空间
+	Vacuum() error
+	Close() error
+}
+
+// ErrNotFound 查询的记录不存在
+var ErrNotFound = sql.ErrNoRows
+
+// ErasedRawId 数据删除后映射使用的占位原始id
+func ErasedRawId(id int64) string {
+	return "erased:" + strconv.FormatInt(id, 10)
+}
+
+var (
+	_ Storage = (*SQLite3Util)(nil)
+	_ Storage = (*MemoryStorage)(nil)
+)
+
+const (
+	SQLiteDriver = "sqlite"
+	MemoryDriver = "memory"
+)
+
+// Store 全局存储，由 StorageInit 初始化
+var Store Storage
+
+// StorageInit 按驱动初始化存储，sqlite(默认) 使用 path 指定的数据库文件，memory 仅保存在内存中，重启后数据丢失
+func StorageInit(driver string, path string) {
+	switch driver {
+	case "", SQLiteDriver:
+		SqLiteInit(path)
+		Store = DBUtil
+	case MemoryDriver:
+		Store = NewMemoryStorage()
+	default:
+		log.Fatalf("不支持的存储驱动: %s", driver)
+	}
+}
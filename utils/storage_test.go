@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"GoQHttp/logger"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	m.Run()
+}
+
+// newTestSQLite 在临时目录创建并迁移 SQLite 数据库
+func newTestSQLite(t *testing.T) *SQLite3Util {
+	t.Helper()
+	s := openTestSQLite(t)
+	if err := s.Migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// eachStorage 分别使用 SQLite 与内存存储执行测试
+func eachStorage(t *testing.T, test func(t *testing.T, s Storage)) {
+	t.Run(SQLiteDriver, func(t *testing.T) { test(t, newTestSQLite(t)) })
+	t.Run(MemoryDriver, func(t *testing.T) { test(t, NewMemoryStorage()) })
+}
+
+func TestEventQueuePushDedupe(t *testing.T) {
+	eachStorage(t, func(t *testing.T, s Storage) {
+		inserted, err := s.EventQueuePush(1, []byte(`{"id":"a"}`), "1:a")
+		if err != nil || !inserted {
+			t.Fatalf("首次写入 inserted=%v err=%v", inserted, err)
+		}
+		// 去重记录与队列记录一起写入
+		if exists, err := s.EventDedupeExists("1:a"); err != nil || !exists {
+			t.Fatalf("去重记录 exists=%v err=%v", exists, err)
+		}
+		inserted, err = s.EventQueuePush(1, []byte(`{"id":"a"}`), "1:a")
+		if err != nil || inserted {
+			t.Fatalf("重复写入 inserted=%v err=%v", inserted, err)
+		}
+		// 不去重的事件直接写入
+		if inserted, err = s.EventQueuePush(1, []byte(`{}`), ""); err != nil || !inserted {
+			t.Fatalf("无id事件 inserted=%v err=%v", inserted, err)
+		}
+
+		events, err := s.EventQueuePending(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Payload != `{"id":"a"}` {
+			t.Fatalf("队列中的事件 %+v", events)
+		}
+		if err = s.EventQueueAck(events[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		if events, _ = s.EventQueuePending(1, 10); len(events) != 1 {
+			t.Fatalf("确认后剩余 %d 个事件", len(events))
+		}
+	})
+}
+
+// insertHistory 写入用于检索的消息记录
+func insertHistory(t *testing.T, s Storage, messages ...MessageHistory) {
+	t.Helper()
+	for i := range messages {
+		message := messages[i]
+		message.Platform = "qq"
+		message.SelfId = 1
+		message.MessageId = int32(i + 1)
+		if message.MessageType == "" {
+			message.MessageType = "group"
+		}
+		message.Sender, message.Segments, message.RawMessage = "{}", "[]", message.PlainText
+		if err := s.MessageHistoryInsert(&message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// searchTexts 检索消息并返回按顺序排列的文本
+func searchTexts(t *testing.T, s Storage, q MessageSearchQuery) ([]string, int) {
+	t.Helper()
+	if q.Limit == 0 {
+		q.Limit = 10
+	}
+	messages, total, err := s.MessageHistorySearch(&q)
+	if err != nil {
+		t.Fatalf("检索 %+v: %v", q, err)
+	}
+	texts := make([]string, 0, len(messages))
+	for _, message := range messages {
+		texts = append(texts, message.PlainText)
+	}
+	return texts, total
+}
+
+func TestMessageHistorySearch(t *testing.T) {
+	eachStorage(t, func(t *testing.T, s Storage) {
+		insertHistory(t, s,
+			MessageHistory{GroupId: 10, SenderId: 100, TimeStamp: 1000, PlainText: "今天天气不错"},
+			MessageHistory{GroupId: 10, SenderId: 200, TimeStamp: 2000, PlainText: "Hello World"},
+			MessageHistory{GroupId: 20, SenderId: 100, TimeStamp: 3000, PlainText: "进度 100%_done"},
+			MessageHistory{GroupId: 20, SenderId: 200, TimeStamp: 4000, PlainText: "明天天气转晴"},
+			MessageHistory{MessageType: "private", UserId: 100, SenderId: 100, TimeStamp: 5000, PlainText: "私聊 hello again"},
+		)
+
+		cases := []struct {
+			name  string
+			query MessageSearchQuery
+			texts []string
+		}{
+			// 三个字符以上使用全文索引
+			{"全文索引", MessageSearchQuery{Keyword: "天气不"}, []string{"今天天气不错"}},
+			{"不区分大小写", MessageSearchQuery{Keyword: "HELLO"}, []string{"私聊 hello again", "Hello World"}},
+			// 不足三个字符逐条匹配
+			{"短关键词", MessageSearchQuery{Keyword: "天气"}, []string{"明天天气转晴", "今天天气不错"}},
+			{"通配符按原文匹配", MessageSearchQuery{Keyword: "%_"}, []string{"进度 100%_done"}},
+			{"引号", MessageSearchQuery{Keyword: `"hello"`}, []string{}},
+			{"群", MessageSearchQuery{GroupId: 20}, []string{"明天天气转晴", "进度 100%_done"}},
+			{"发送者", MessageSearchQuery{Keyword: "天气", UserId: 100}, []string{"今天天气不错"}},
+			{"时间范围包含边界", MessageSearchQuery{StartTime: 2000, EndTime: 4000}, []string{"明天天气转晴", "进度 100%_done", "Hello World"}},
+			{"其他机器人", MessageSearchQuery{Keyword: "天气", SelfId: 2}, []string{}},
+		}
+		for _, c := range cases {
+			texts, total := searchTexts(t, s, c.query)
+			if strings.Join(texts, "|") != strings.Join(c.texts, "|") || total != len(c.texts) {
+				t.Errorf("%s: 得到 %q total=%d, 期望 %q", c.name, texts, total, c.texts)
+			}
+		}
+
+		// 分页时 total 为全部结果的数量
+		texts, total := searchTexts(t, s, MessageSearchQuery{Offset: 1, Limit: 2})
+		if total != 5 || strings.Join(texts, "|") != "明天天气转晴|进度 100%_done" {
+			t.Fatalf("分页得到 %q total=%d", texts, total)
+		}
+	})
+}
+
+func TestMessageHistorySearchAfterErase(t *testing.T) {
+	eachStorage(t, func(t *testing.T, s Storage) {
+		insertHistory(t, s,
+			MessageHistory{GroupId: 10, SenderId: 100, TimeStamp: 1000, PlainText: "删除的消息"},
+			MessageHistory{GroupId: 10, SenderId: 200, TimeStamp: 2000, PlainText: "匿名的消息"},
+			MessageHistory{GroupId: 10, SenderId: 300, TimeStamp: 3000, PlainText: "过期的消息"},
+			MessageHistory{GroupId: 10, SenderId: 400, TimeStamp: 9000, PlainText: "保留的消息"},
+		)
+		if _, err := s.MessageHistoryErase(&EraseQuery{Platform: "qq", UserId: 100}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.MessageHistoryErase(&EraseQuery{Platform: "qq", UserId: 200, Anonymize: true}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.MessageHistoryPurge(5000); err != nil {
+			t.Fatal(err)
+		}
+		// 索引随消息记录删除与匿名化同步更新
+		if texts, total := searchTexts(t, s, MessageSearchQuery{Keyword: "的消息"}); total != 1 || texts[0] != "保留的消息" {
+			t.Fatalf("删除后检索得到 %q total=%d", texts, total)
+		}
+	})
+}
+
+func TestMessageHistoryEraseNamespace(t *testing.T) {
+	eachStorage(t, func(t *testing.T, s Storage) {
+		insertHistory(t, s,
+			MessageHistory{GroupId: 10, SenderId: 7, UserNamespace: "qq:user", PlainText: "群成员"},
+			MessageHistory{MessageType: "private", UserId: 7, SenderId: 7, UserNamespace: "qq:c2c:user", PlainText: "单聊用户"},
+			MessageHistory{MessageType: "private", UserId: 7, SenderId: 1, Outgoing: true, UserNamespace: "qq:c2c:user", PlainText: "回复单聊用户"},
+			MessageHistory{GroupId: 10, SenderId: 7, PlainText: "旧记录"},
+		)
+		// 只删除单聊用户与无法确定命名空间的旧记录，id相同的群成员保留
+		erased, err := s.MessageHistoryErase(&EraseQuery{Platform: "qq", UserId: 7, UserNamespace: "qq:c2c:user"})
+		if err != nil || erased != 3 {
+			t.Fatalf("删除 %d 条 err=%v, 期望 3", erased, err)
+		}
+		if texts, total := searchTexts(t, s, MessageSearchQuery{}); total != 1 || texts[0] != "群成员" {
+			t.Fatalf("删除后剩余 %q total=%d", texts, total)
+		}
+	})
+}
@@ -1,41 +1,70 @@
 package client
 
 import (
-	"GoQHttp/internal/protocol"
 	"GoQHttp/logger"
 	"bytes"
 	"compress/zlib"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	logging "github.com/sacOO7/go-logger"
 	"github.com/sacOO7/gowebsocket"
 	uuid "github.com/satori/go.uuid"
 )
 
-// KookClient 表示一个 WebSocket 客户端连接
+const (
+	kookMaxBufferedSn  = 1000
+	kookMaxResumeTimes = 2
+)
+
+// 网关的等待时间，测试时缩短
+var (
+	kookHelloTimeout   = 6 * time.Second
+	kookPongTimeout    = 6 * time.Second
+	kookPingInterval   = 30 * time.Second
+	kookPingJitter     = 5 * time.Second
+	kookPingRetryDelay = time.Second
+	kookBackoffMin     = 2 * time.Second
+	kookBackoffMax     = 60 * time.Second
+)
+
+// KookClient Kook 网关连接
+//
+// 按照官方文档的状态机工作：获取网关 -> 连接并等待 HELLO -> 心跳；
+// 心跳超时或断线时携带 sn 与 session_id 恢复会话，恢复失败或收到 RECONNECT 时清空状态重新连接。
+// 消息按 sn 顺序投递，乱序到达的消息暂存等待缺失的消息，重复的消息直接丢弃。
 type KookClient struct {
 	ID                   string
 	Domain               string
 	WssURL               string
 	GatewayURL           string
-	Conn                 gowebsocket.Socket
-	mu                   sync.RWMutex
+	Conn                 *gowebsocket.Socket
+	mu                   sync.Mutex
 	Connected            bool
-	MaxRetries           int64
-	RetryDelay           time.Duration
-	RetryCount           int64
 	Authorization        string
-	Interval             int64
-	LastSuccessMessageId int64
-	Resume               bool // 是否需要重连
-	Compress             bool // 是否需要Zlib压缩
+	SessionId            string
+	LastSuccessMessageId int64                     // 已按顺序投递的最大 sn
+	buffer               map[int64]json.RawMessage // 乱序到达、等待投递的消息
+	Compress             bool                      // 是否需要Zlib压缩
+	// stop 调用 Close 后关闭，结束当前会话且不再重连
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// sink 按顺序投递的消息事件
+	sink chan<- json.RawMessage
+
+	signals chan *KookMessage
 }
+
 type KookRequestSignal int
 
 const (
@@ -50,24 +79,56 @@ const (
 
 type KookMessage struct {
 	Signal               KookRequestSignal `json:"s"`
-	Data                 json.RawMessage   `json:"d"`
-	LastSuccessMessageId int64             `json:"sn"`
+	Data                 json.RawMessage   `json:"d,omitempty"`
+	LastSuccessMessageId int64             `json:"sn,omitempty"`
+}
+
+// kookHello HELLO 及 RESUME_ACK 信令的数据
+type kookHello struct {
+	Code      int    `json:"code"`
+	SessionId string `json:"session_id"`
+}
+
+// 会话结束的原因
+var (
+	errKookHelloTimeout = errors.New("等待 HELLO 超时")
+	errKookPingTimeout  = errors.New("心跳超时")
+	errKookReconnect    = errors.New("服务器要求重新连接")
+	errKookDisconnected = errors.New("连接已断开")
+	errKookStopped      = errors.New("连接已关闭")
+)
+
+// kookHelloError HELLO 返回的错误码
+type kookHelloError struct {
+	Code int
+}
+
+func (e *kookHelloError) Error() string {
+	switch e.Code {
+	case 40100:
+		return "缺少参数"
+	case 40101:
+		return "无效的 token"
+	case 40102:
+		return "token 验证失败"
+	case 40103:
+		return "token 过期"
+	default:
+		return fmt.Sprintf("未知错误 %d", e.Code)
+	}
 }
 
-// NewKookClient 创建新的 WebSocket 客户端
-func NewKookClient(authorization string, compress bool) *KookClient {
+// NewKookClient 创建新的 WebSocket 客户端，消息事件按 sn 顺序写入 sink
+func NewKookClient(authorization string, compress bool, sink chan<- json.RawMessage) *KookClient {
 	return &KookClient{
-		ID:                   generateKookClientID(),
-		Domain:               "https://www.kookapp.cn",
-		GatewayURL:           "/api/v3/gateway/index",
-		Connected:            false,
-		MaxRetries:           0,
-		RetryCount:           0,
-		RetryDelay:           time.Duration(5) * time.Second,
-		Authorization:        authorization,
-		Interval:             3000,
-		LastSuccessMessageId: 0,
-		Compress:             compress,
+		ID:            generateKookClientID(),
+		Domain:        "https://www.kookapp.cn",
+		GatewayURL:    "/api/v3/gateway/index",
+		Authorization: authorization,
+		Compress:      compress,
+		buffer:        make(map[int64]json.RawMessage),
+		sink:          sink,
+		stop:          make(chan struct{}),
 	}
 }
 
@@ -75,38 +136,30 @@ func generateKookClientID() string {
 	return fmt.Sprintf("KookClient-%d", uuid.NewV4())
 }
 
+// GetWssURL 获取网关地址
 func (c *KookClient) GetWssURL() error {
 	kookGetGatewayUrl := fmt.Sprintf("%s%s", c.Domain, c.GatewayURL)
-	logger.Infof("尝试获取Kook GateWay Url: %s", kookGetGatewayUrl)
-
-	if c.Resume && c.Compress {
-		kookGetGatewayUrl = fmt.Sprintf("%s%s", kookGetGatewayUrl, "?resume=1&compress=1")
-	} else if !c.Resume && c.Compress {
-		kookGetGatewayUrl = fmt.Sprintf("%s%s", kookGetGatewayUrl, "?compress=1")
+	if c.Compress {
+		kookGetGatewayUrl += "?compress=1"
 	} else {
-		kookGetGatewayUrl = fmt.Sprintf("%s%s", kookGetGatewayUrl, "?compress=0")
-
+		kookGetGatewayUrl += "?compress=0"
 	}
+	logger.Infof("尝试获取Kook GateWay Url: %s", kookGetGatewayUrl)
 
 	r, err := http.NewRequest("GET", kookGetGatewayUrl, nil)
 	if err != nil {
-		logger.Errorf("Error creating request: %v", err)
-		return err
+		return fmt.Errorf("create request error: %v", err)
 	}
-
 	r.Header = http.Header{
 		"Content-Type":  []string{"application/json"},
 		"Authorization": []string{"Bot " + c.Authorization},
 	}
 
-	// 发送请求
-	client := &http.Client{}
+	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(r)
 	if err != nil {
-		logger.Errorf("Error sending request: %v", err)
-		return err
+		return fmt.Errorf("send request error: %v", err)
 	}
-
 	defer resp.Body.Close()
 
 	type Response struct {
@@ -117,149 +170,257 @@ func (c *KookClient) GetWssURL() error {
 		} `json:"data"`
 	}
 
-	// 读取响应
-	body, _ := io.ReadAll(resp.Body)
-	var response Response
-	err = json.Unmarshal(body, &response)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return fmt.Errorf("read body error: %v", err)
 	}
-	c.GatewayURL = response.Data.URL
+	var response Response
+	if err = json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("unmarshal error: %v", err)
+	}
+	if response.Code != 0 || response.Data.URL == "" {
+		return fmt.Errorf("获取网关失败: code=%d message=%s", response.Code, response.Message)
+	}
+	c.WssURL = response.Data.URL
 	return nil
 }
 
-// Connect 连接到 WebSocket 服务器
+// Connect 连接网关并维持会话，直到调用 Close
 func (c *KookClient) Connect() {
-	err := c.GetWssURL()
-	if err != nil {
-		return
-	}
+	backoff := kookBackoffMin
+	resumeTimes := 0
+	for {
+		c.mu.Lock()
+		resume := c.SessionId != ""
+		c.mu.Unlock()
 
-	logger.Infof("尝试连接到: %s", c.GatewayURL)
+		if resume && resumeTimes >= kookMaxResumeTimes {
+			// 多次恢复会话失败，清空状态重新连接
+			logger.Warnf("Kook会话恢复失败，重新连接")
+			c.reset()
+			resume = false
+		}
 
-	// 解析 URL
-	u, err := url.Parse(c.GatewayURL)
-	if err != nil {
-		logger.Warnf("URL 解析错误: %v", err)
-		time.Sleep(5 * time.Second)
-		return
-	}
+		err := c.GetWssURL()
+		if err == nil {
+			if resume {
+				resumeTimes++
+			}
+			var ready bool
+			ready, err = c.session(resume)
+			if ready {
+				backoff = kookBackoffMin
+				resumeTimes = 0
+			}
+		}
 
-	header := http.Header{}
-	if c.Authorization != "" {
-		header.Set("Authorization", "Bot "+c.Authorization)
+		var helloErr *kookHelloError
+		switch {
+		case errors.Is(err, errKookStopped):
+			return
+		case errors.Is(err, errKookReconnect):
+			c.reset()
+		case errors.As(err, &helloErr):
+			// token 相关错误需要重新获取网关，旧会话不再可用
+			c.reset()
+		}
+		logger.Warnf("Kook网关 %v, 将在%v后重连", err, backoff)
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > kookBackoffMax {
+			backoff = kookBackoffMax
+		}
 	}
+}
 
-	// 建立连接
-	c.Conn = gowebsocket.New(u.String())
-	c.Conn.RequestHeader = header
-	c.Conn.WebsocketDialer.WriteBufferSize = 8192
-	c.Conn.WebsocketDialer.ReadBufferSize = 8192
-	c.Conn.GetLogger().SetLevel(logging.OFF)
-	c.Conn.ConnectionOptions = gowebsocket.ConnectionOptions{
-		UseSSL:         true,
-		UseCompression: true,
-		Subprotocols:   []string{},
+// session 建立一次连接并维持心跳，返回是否成功建立会话及会话结束的原因
+func (c *KookClient) session(resume bool) (bool, error) {
+	u, err := url.Parse(c.WssURL)
+	if err != nil {
+		return false, fmt.Errorf("URL 解析错误: %v", err)
 	}
-
-	c.Conn.OnConnected = func(socket gowebsocket.Socket) {
+	if resume {
 		c.mu.Lock()
-		c.Connected = true
-		c.RetryCount = 0 // 重置重试计数
+		query := u.Query()
+		query.Set("resume", "1")
+		query.Set("sn", fmt.Sprint(c.LastSuccessMessageId))
+		query.Set("session_id", c.SessionId)
 		c.mu.Unlock()
+		u.RawQuery = query.Encode()
+	}
+	logger.Infof("尝试连接到: %s", u.String())
 
-		logger.Infof("已连接 %v", u.String())
+	signals := make(chan *KookMessage, 16)
+	done := make(chan struct{})
+	once := &sync.Once{}
+	disconnect := func() {
+		once.Do(func() { close(done) })
 	}
-	c.Conn.OnConnectError = func(err error, socket gowebsocket.Socket) {
-		c.mu.Lock()
-		c.Connected = false
-		c.mu.Unlock()
-		logger.Errorf("%v 连接出错:  %v", c.GatewayURL, err.Error())
+
+	conn := gowebsocket.New(u.String())
+	conn.WebsocketDialer.WriteBufferSize = 8192
+	conn.WebsocketDialer.ReadBufferSize = 8192
+	conn.GetLogger().SetLevel(logging.OFF)
+	// UseSSL 为 true 时会跳过证书校验
+	conn.ConnectionOptions = gowebsocket.ConnectionOptions{
+		UseSSL:         false,
+		UseCompression: true,
+		Subprotocols:   []string{},
 	}
-	c.Conn.OnTextMessage = func(message string, socket gowebsocket.Socket) {
-		c.handleMessage([]byte(message))
+	conn.OnConnectError = func(err error, socket gowebsocket.Socket) {
+		logger.Errorf("%v 连接出错:  %v", c.WssURL, err)
+		disconnect()
 	}
-	c.Conn.OnBinaryMessage = func(message []byte, socket gowebsocket.Socket) {
-
-		if c.Compress {
-			b := bytes.NewBuffer(message)
-			r, err := zlib.NewReader(b)
-			if err != nil {
-				panic(err)
-			}
-			defer r.Close()
-
-			decompressedData, err := io.ReadAll(r)
-			if err != nil {
-				panic(err)
-			}
-			c.handleMessage(decompressedData)
+	conn.OnTextMessage = func(message string, socket gowebsocket.Socket) {
+		c.handleMessage([]byte(message), signals)
+	}
+	conn.OnBinaryMessage = func(message []byte, socket gowebsocket.Socket) {
+		data, err := inflate(message)
+		if err != nil {
+			logger.Warnf("Kook消息解压失败: %v", err)
+			return
 		}
+		c.handleMessage(data, signals)
 	}
-	c.Conn.OnDisconnected = func(err error, socket gowebsocket.Socket) {
+	conn.OnDisconnected = func(err error, socket gowebsocket.Socket) {
+		logger.Warnf("%v 断开连接", c.WssURL)
+		disconnect()
+	}
+
+	c.mu.Lock()
+	c.Conn = &conn
+	c.mu.Unlock()
+	conn.Connect()
+	defer func() {
 		c.mu.Lock()
 		c.Connected = false
 		c.mu.Unlock()
-		logger.Warnf("%v 断开连接", c.GatewayURL)
-	}
-	c.Conn.Connect()
-	for {
-		if !c.Connected {
-			return
+		select {
+		case <-done:
+		default:
+			// gowebsocket 的 Close 与读取协程同时修改连接状态，直接关闭底层连接
+			_ = conn.Conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			_ = conn.Conn.Close()
 		}
-		c.HeartBeat()
-		time.Sleep(time.Second * time.Duration(30))
-	}
-}
-
-func (c *KookClient) Daemon() {
-	for {
-		if c.MaxRetries != 0 {
-			if c.RetryCount >= c.MaxRetries && c.MaxRetries > 0 {
-				logger.Warnf("达到最大重试次数 (%d)，停止连接: %s", c.MaxRetries, c.GatewayURL)
-				return
+	}()
+
+	// 等待 HELLO，恢复会话时以 RESUME_ACK 为准
+	helloTimer := time.NewTimer(kookHelloTimeout)
+	defer helloTimer.Stop()
+	for ready := false; !ready; {
+		select {
+		case <-c.stop:
+			return false, errKookStopped
+		case <-done:
+			return false, errKookDisconnected
+		case <-helloTimer.C:
+			return false, errKookHelloTimeout
+		case signal := <-signals:
+			switch signal.Signal {
+			case HANDSHAKE, RESUME_ACK:
+				var hello kookHello
+				_ = json.Unmarshal(signal.Data, &hello)
+				if hello.Code != 0 {
+					return false, &kookHelloError{Code: hello.Code}
+				}
+				if signal.Signal == RESUME_ACK || !resume {
+					ready = true
+				}
+			case RECONNECT:
+				return false, errKookReconnect
 			}
 		}
+	}
 
-		c.Connect()
-
-		c.mu.Lock()
-		c.Connected = false
-		c.RetryCount++
-		c.mu.Unlock()
+	c.mu.Lock()
+	c.Connected = true
+	c.mu.Unlock()
+	logger.Infof("已连接 %v", c.WssURL)
 
-		if c.RetryDelay > 0 {
-			logger.Warnf("%v将在%v后重连", c.GatewayURL, c.RetryDelay)
-			time.Sleep(c.RetryDelay)
+	// 心跳：每 30 秒(±5 秒)发送 PING，6 秒内未收到 PONG 时间隔 2、4 秒重试两次，仍未收到则恢复会话
+	pingTimer := time.NewTimer(0)
+	defer pingTimer.Stop()
+	var pongTimer <-chan time.Time
+	retries := 0
+	for {
+		select {
+		case <-c.stop:
+			return true, errKookStopped
+		case <-done:
+			return true, errKookDisconnected
+		case <-pingTimer.C:
+			c.HeartBeat()
+			pongTimer = time.After(kookPongTimeout)
+		case <-pongTimer:
+			if retries >= 2 {
+				return true, errKookPingTimeout
+			}
+			retries++
+			pongTimer = nil
+			pingTimer.Reset(kookPingRetryDelay << retries)
+		case signal := <-signals:
+			switch signal.Signal {
+			case PONG:
+				retries = 0
+				pongTimer = nil
+				pingTimer.Reset(kookPingInterval + time.Duration(rand.Int63n(int64(2*kookPingJitter))) - kookPingJitter)
+			case RECONNECT:
+				return true, errKookReconnect
+			}
 		}
+	}
+}
 
+// inflate 解压 zlib 压缩的消息
+func inflate(message []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewBuffer(message))
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// reset 清空会话状态，下次连接时重新建立会话
+func (c *KookClient) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SessionId = ""
+	c.LastSuccessMessageId = 0
+	c.buffer = make(map[int64]json.RawMessage)
 }
 
 func (c *KookClient) HeartBeat() {
 	c.mu.Lock()
 	req := KookMessage{
 		Signal:               PING,
-		Data:                 nil,
 		LastSuccessMessageId: c.LastSuccessMessageId,
 	}
-	msg, _ := json.Marshal(req)
-	c.Conn.SendText(string(msg))
+	conn := c.Conn
 	c.mu.Unlock()
+	msg, _ := json.Marshal(req)
+	if conn != nil {
+		conn.SendText(string(msg))
+	}
 }
 
 func (c *KookClient) SendMessage(data string) {
 	c.mu.Lock()
+	conn, connected := c.Conn, c.Connected
+	c.mu.Unlock()
 	logger.Debug(data)
-	if c.Connected {
-		c.Conn.SendText(data)
+	if connected {
+		conn.SendText(data)
 	}
-	c.mu.Unlock()
 }
 
 // handleMessage 处理接收到的消息
-func (c *KookClient) handleMessage(message []byte) {
-	logger.Debugf("%s 接收数据 %s", c.GatewayURL, string(message))
+func (c *KookClient) handleMessage(message []byte, signals chan *KookMessage) {
+	logger.Debugf("%s 接收数据 %s", c.WssURL, string(message))
 	var request KookMessage
 	err := json.Unmarshal(message, &request)
 	if err != nil {
@@ -271,55 +432,85 @@ func (c *KookClient) handleMessage(message []byte) {
 	switch request.Signal {
 	case HANDSHAKE:
 		logger.Infof("[Kook] 连接事件: %v", string(message))
-		type Data struct {
-			Code       int    `json:"code"`
-			SessionIdB string `json:"sessionId"`
-			SessionId  string `json:"session_id"`
-		}
-		var data Data
-		err := json.Unmarshal(request.Data, &data)
-		if err != nil {
-			logger.Error(err)
-			return
+		var hello kookHello
+		if err = json.Unmarshal(request.Data, &hello); err == nil && hello.Code == 0 {
+			c.mu.Lock()
+			c.SessionId = hello.SessionId
+			c.mu.Unlock()
 		}
-		if data.Code != 0 {
-			switch data.Code {
-			case 40100:
-				logger.Warn("缺少参数")
-			case 40101:
-				logger.Warn("无效的 token")
-			case 40102:
-				logger.Warn("token 验证失败")
-			case 40103:
-				//todo 需要重新连接
-				logger.Warn("token 过期")
-			}
-		}
-
 	case PONG:
-		logger.Infof("[Kook] 心跳事件: %v", string(message))
+		logger.Debugf("[Kook] 心跳事件: %v", string(message))
 	case RECONNECT:
 		logger.Infof("[Kook] 服务器要求重连事件: %v", string(message))
 	case RESUME_ACK:
 		logger.Infof("[Kook] 连接恢复事件: %v", string(message))
+		var hello kookHello
+		if err = json.Unmarshal(request.Data, &hello); err == nil && hello.SessionId != "" {
+			c.mu.Lock()
+			c.SessionId = hello.SessionId
+			c.mu.Unlock()
+		}
 	case MESSAGE:
-		logger.Infof("[Kook] 消息事件: %s", string(message))
-		c.LastSuccessMessageId = request.LastSuccessMessageId
-		protocol.KookChan <- request.Data
+		logger.Debugf("[Kook] 消息事件: %s", string(message))
+		c.deliver(request.LastSuccessMessageId, request.Data)
+		return
 	default:
 		logger.Warnf("不支持处理的事件: %s", string(message))
+		return
+	}
+
+	select {
+	case signals <- &request:
+	default:
 	}
 }
 
-// Close 关闭连接
-func (c *KookClient) Close() {
+// deliver 按 sn 顺序投递消息：重复的丢弃，乱序的暂存，缺失的消息到达后依次投递暂存的消息
+func (c *KookClient) deliver(sn int64, data json.RawMessage) {
+	for _, message := range c.ordered(sn, data) {
+		c.sink <- message
+	}
+}
+
+// ordered 记录消息并返回可以按顺序投递的消息
+func (c *KookClient) ordered(sn int64, data json.RawMessage) []json.RawMessage {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.Connected {
-		c.Conn.SendBinary([]byte{})
-		c.Conn.Close()
-		c.Connected = false
+	if sn <= c.LastSuccessMessageId {
+		logger.Debugf("[Kook] 忽略重复消息 sn=%d", sn)
+		return nil
+	}
+	c.buffer[sn] = data
+	if sn != c.LastSuccessMessageId+1 {
+		if len(c.buffer) <= kookMaxBufferedSn {
+			return nil
+		}
+		// 暂存过多时放弃等待缺失的消息
+		keys := make([]int64, 0, len(c.buffer))
+		for key := range c.buffer {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		logger.Warnf("[Kook] 缺失消息 sn=%d-%d 长时间未到达，跳过", c.LastSuccessMessageId+1, keys[0]-1)
+		c.LastSuccessMessageId = keys[0] - 1
+	}
 
+	var messages []json.RawMessage
+	for next := c.LastSuccessMessageId + 1; ; next++ {
+		message, ok := c.buffer[next]
+		if !ok {
+			return messages
+		}
+		delete(c.buffer, next)
+		messages = append(messages, message)
+		c.LastSuccessMessageId = next
 	}
 }
+
+// Close 关闭连接且不再重连，正在等待 HELLO 或等待重连时同样生效
+func (c *KookClient) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
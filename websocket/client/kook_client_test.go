@@ -0,0 +1,304 @@
+package client
+
+import (
+	"GoQHttp/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(logger.LogConfig{Level: "error"})
+	// 缩短网关的等待时间
+	kookHelloTimeout = 200 * time.Millisecond
+	kookPongTimeout = 100 * time.Millisecond
+	kookPingInterval = time.Second
+	kookPingJitter = 10 * time.Millisecond
+	kookPingRetryDelay = 10 * time.Millisecond
+	kookBackoffMin = 10 * time.Millisecond
+	kookBackoffMax = 40 * time.Millisecond
+	m.Run()
+}
+
+// kookGateway 模拟 Kook 网关，每个连接按 script 收发信令
+type kookGateway struct {
+	*httptest.Server
+	mu      sync.Mutex
+	queries []url.Values
+	script  func(n int, conn *websocket.Conn, query url.Values)
+}
+
+func newKookGateway(script func(n int, conn *websocket.Conn, query url.Values)) *kookGateway {
+	g := &kookGateway{script: script}
+	upgrader := websocket.Upgrader{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/gateway/index" {
+			_, _ = fmt.Fprintf(w, `{"code":0,"data":{"url":"%s/gateway"}}`, "ws"+strings.TrimPrefix(g.URL, "http"))
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		g.mu.Lock()
+		g.queries = append(g.queries, r.URL.Query())
+		n := len(g.queries)
+		g.mu.Unlock()
+		g.script(n, conn, r.URL.Query())
+	}))
+	return g
+}
+
+// client 创建连接到模拟网关的客户端
+func (g *kookGateway) client(sink chan json.RawMessage) *KookClient {
+	c := NewKookClient("token", false, sink)
+	c.Domain = g.URL
+	c.WssURL = "ws" + strings.TrimPrefix(g.URL, "http") + "/gateway"
+	return c
+}
+
+func (g *kookGateway) connections() []url.Values {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]url.Values(nil), g.queries...)
+}
+
+// signal 发送信令
+func signal(conn *websocket.Conn, s KookRequestSignal, sn int64, data string) {
+	message := fmt.Sprintf(`{"s":%d,"sn":%d,"d":%s}`, s, sn, data)
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+// readSignal 读取客户端发送的信令，连接关闭时返回 false
+func readSignal(conn *websocket.Conn) (KookMessage, bool) {
+	var message KookMessage
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return message, false
+	}
+	_ = json.Unmarshal(data, &message)
+	return message, true
+}
+
+// pong 回复心跳直到连接关闭
+func pong(conn *websocket.Conn) {
+	for {
+		message, ok := readSignal(conn)
+		if !ok {
+			return
+		}
+		if message.Signal == PING {
+			signal(conn, PONG, 0, "{}")
+		}
+	}
+}
+
+func TestKookOrdered(t *testing.T) {
+	c := NewKookClient("token", false, nil)
+	raw := func(sn int64) json.RawMessage { return json.RawMessage(fmt.Sprint(sn)) }
+
+	if messages := c.ordered(2, raw(2)); len(messages) != 0 {
+		t.Fatalf("乱序的消息应暂存, 投递 %s", messages)
+	}
+	if messages := c.ordered(3, raw(3)); len(messages) != 0 {
+		t.Fatalf("乱序的消息应暂存, 投递 %s", messages)
+	}
+	messages := c.ordered(1, raw(1))
+	if len(messages) != 3 || string(messages[0]) != "1" || string(messages[2]) != "3" {
+		t.Fatalf("缺失的消息到达后应按顺序投递, 投递 %s", messages)
+	}
+	if messages = c.ordered(2, raw(2)); len(messages) != 0 {
+		t.Fatalf("重复的消息应丢弃, 投递 %s", messages)
+	}
+	if c.LastSuccessMessageId != 3 || len(c.buffer) != 0 {
+		t.Fatalf("sn=%d 暂存 %d", c.LastSuccessMessageId, len(c.buffer))
+	}
+
+	// 暂存过多时跳过缺失的消息
+	for sn := int64(5); sn < 5+kookMaxBufferedSn; sn++ {
+		if messages = c.ordered(sn, raw(sn)); len(messages) != 0 {
+			t.Fatalf("sn=%d 不应投递", sn)
+		}
+	}
+	messages = c.ordered(5+kookMaxBufferedSn, raw(5+kookMaxBufferedSn))
+	if len(messages) != kookMaxBufferedSn+1 || string(messages[0]) != "5" {
+		t.Fatalf("跳过缺失的消息后投递 %d 条, 第一条 %s", len(messages), messages[0])
+	}
+}
+
+func TestKookHelloError(t *testing.T) {
+	g := newKookGateway(func(n int, conn *websocket.Conn, query url.Values) {
+		signal(conn, HANDSHAKE, 0, `{"code":40103}`)
+		readSignal(conn)
+	})
+	defer g.Close()
+
+	ready, err := g.client(nil).session(false)
+	var helloErr *kookHelloError
+	if ready || !errors.As(err, &helloErr) || helloErr.Code != 40103 {
+		t.Fatalf("ready=%v err=%v, 期望 token 过期", ready, err)
+	}
+}
+
+func TestKookHelloTimeout(t *testing.T) {
+	g := newKookGateway(func(n int, conn *websocket.Conn, query url.Values) {
+		readSignal(conn)
+	})
+	defer g.Close()
+
+	if ready, err := g.client(nil).session(false); ready || !errors.Is(err, errKookHelloTimeout) {
+		t.Fatalf("ready=%v err=%v, 期望等待 HELLO 超时", ready, err)
+	}
+}
+
+func TestKookSessionDeliversInOrder(t *testing.T) {
+	g := newKookGateway(func(n int, conn *websocket.Conn, query url.Values) {
+		signal(conn, HANDSHAKE, 0, `{"code":0,"session_id":"s1"}`)
+		if message, ok := readSignal(conn); !ok || message.Signal != PING {
+			return
+		}
+		signal(conn, PONG, 0, "{}")
+		signal(conn, MESSAGE, 2, `"second"`)
+		signal(conn, MESSAGE, 1, `"first"`)
+		signal(conn, MESSAGE, 1, `"first"`)
+		signal(conn, RECONNECT, 0, "{}")
+		readSignal(conn)
+	})
+	defer g.Close()
+
+	sink := make(chan json.RawMessage, 10)
+	c := g.client(sink)
+	ready, err := c.session(false)
+	if !ready || !errors.Is(err, errKookReconnect) {
+		t.Fatalf("ready=%v err=%v, 期望服务器要求重新连接", ready, err)
+	}
+	if c.SessionId != "s1" {
+		t.Fatalf("session_id %q", c.SessionId)
+	}
+	close(sink)
+	var received []string
+	for message := range sink {
+		received = append(received, string(message))
+	}
+	if strings.Join(received, ",") != `"first","second"` {
+		t.Fatalf("投递顺序 %v", received)
+	}
+}
+
+func TestKookResume(t *testing.T) {
+	g := newKookGateway(func(n int, conn *websocket.Conn, query url.Values) {
+		signal(conn, HANDSHAKE, 0, `{"code":0,"session_id":"s1"}`)
+		signal(conn, RESUME_ACK, 0, `{"session_id":"s2"}`)
+		if message, ok := readSignal(conn); !ok || message.Signal != PING || message.LastSuccessMessageId != 5 {
+			t.Errorf("恢复会话后的心跳 %+v", message)
+		}
+	})
+	defer g.Close()
+
+	c := g.client(nil)
+	c.SessionId = "s1"
+	c.LastSuccessMessageId = 5
+	ready, err := c.session(true)
+	if !ready || !errors.Is(err, errKookDisconnected) {
+		t.Fatalf("ready=%v err=%v, 期望恢复后断开", ready, err)
+	}
+	query := g.connections()[0]
+	if query.Get("resume") != "1" || query.Get("sn") != "5" || query.Get("session_id") != "s1" {
+		t.Fatalf("恢复会话的参数 %v", query)
+	}
+	if c.SessionId != "s2" {
+		t.Fatalf("session_id %q, 期望 RESUME_ACK 返回的 s2", c.SessionId)
+	}
+}
+
+func TestKookPingTimeout(t *testing.T) {
+	pings := make(chan struct{}, 10)
+	g := newKookGateway(func(n int, conn *websocket.Conn, query url.Values) {
+		signal(conn, HANDSHAKE, 0, `{"code":0,"session_id":"s1"}`)
+		for {
+			message, ok := readSignal(conn)
+			if !ok {
+				return
+			}
+			if message.Signal == PING {
+				pings <- struct{}{}
+			}
+		}
+	})
+	defer g.Close()
+
+	ready, err := g.client(nil).session(false)
+	if !ready || !errors.Is(err, errKookPingTimeout) {
+		t.Fatalf("ready=%v err=%v, 期望心跳超时", ready, err)
+	}
+	// 首次心跳及两次重试
+	if len(pings) != 3 {
+		t.Fatalf("发送心跳 %d 次, 期望 3", len(pings))
+	}
+}
+
+func TestKookReconnectAfterResumeFailure(t *testing.T) {
+	reconnected := make(chan struct{})
+	g := newKookGateway(func(n int, conn *websocket.Conn, query url.Values) {
+		switch {
+		case n == 1:
+			signal(conn, HANDSHAKE, 0, `{"code":0,"session_id":"s1"}`)
+			signal(conn, MESSAGE, 1, `"first"`)
+			// 断开连接，客户端尝试恢复会话
+		case query.Get("resume") == "1":
+			// 恢复会话失败
+		default:
+			signal(conn, HANDSHAKE, 0, `{"code":0,"session_id":"s2"}`)
+			// 收到心跳时客户端已建立会话
+			if message, ok := readSignal(conn); ok && message.Signal == PING {
+				signal(conn, PONG, 0, "{}")
+				close(reconnected)
+			}
+			pong(conn)
+		}
+	})
+	defer g.Close()
+
+	sink := make(chan json.RawMessage, 10)
+	c := g.client(sink)
+	stopped := make(chan struct{})
+	go func() {
+		c.Connect()
+		close(stopped)
+	}()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("恢复会话失败后应重新连接")
+	}
+	c.Close()
+	<-stopped
+
+	connections := g.connections()
+	if len(connections) != 2+kookMaxResumeTimes {
+		t.Fatalf("连接 %d 次, 期望 %d", len(connections), 2+kookMaxResumeTimes)
+	}
+	for _, query := range connections[1 : 1+kookMaxResumeTimes] {
+		if query.Get("resume") != "1" || query.Get("sn") != "1" || query.Get("session_id") != "s1" {
+			t.Fatalf("恢复会话的参数 %v", query)
+		}
+	}
+	if last := connections[len(connections)-1]; last.Get("resume") != "" {
+		t.Fatalf("重新连接不应恢复会话: %v", last)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.SessionId != "s2" || c.LastSuccessMessageId != 0 {
+		t.Fatalf("重新连接后 session_id=%q sn=%d, 期望清空旧状态", c.SessionId, c.LastSuccessMessageId)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"GoQHttp/internal/protocol"
 	"GoQHttp/logger"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	logging "github.com/sacOO7/go-logger"
 	"github.com/sacOO7/gowebsocket"
 	uuid "github.com/satori/go.uuid"
@@ -34,7 +36,6 @@ type NoneBotClient struct {
 	XClientRole   string
 	Authorization string
 	Interval      int64
-	Sandbox       bool
 }
 
 // NoneBotClientManager 管理多个 WebSocket 客户端连接
@@ -67,7 +68,7 @@ func init() {
 }
 
 // NewNoneBotClient 创建新的 WebSocket 客户端
-func NewNoneBotClient(url string, xSelfID int64, xClientRole string, authorization string, interval int64, sandbox bool, maxRetries int64, retryDelay int64) *NoneBotClient {
+func NewNoneBotClient(url string, xSelfID int64, xClientRole string, authorization string, interval int64, maxRetries int64, retryDelay int64) *NoneBotClient {
 	return &NoneBotClient{
 		ID:            generateNoneBotClientID(),
 		URL:           url,
@@ -79,7 +80,6 @@ func NewNoneBotClient(url string, xSelfID int64, xClientRole string, authorizati
 		XClientRole:   xClientRole,
 		Authorization: authorization,
 		Interval:      interval,
-		Sandbox:       sandbox,
 	}
 }
 
@@ -223,6 +223,23 @@ func (c *NoneBotClient) SendMessage(data string) {
 	c.mu.Unlock()
 }
 
+// Send 写入事件，返回写入错误，未连接时返回错误
+func (c *NoneBotClient) Send(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.Connected || c.Conn.Conn == nil {
+		return errors.New("连接未建立")
+	}
+	return c.Conn.Conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// sendActions 由 SendMessage 处理的发送消息动作
+var sendActions = map[string]struct{}{
+	"send_msg":         {},
+	"send_group_msg":   {},
+	"send_private_msg": {},
+}
+
 // handleMessage 处理接收到的消息
 func (c *NoneBotClient) handleMessage(message []byte) {
 	logger.Debugf("%s 接收数据 %s", c.URL, string(message))
@@ -233,23 +250,45 @@ func (c *NoneBotClient) handleMessage(message []byte) {
 		return
 	}
 
-	var messageRequest *onebot.MessageRequest
-	marshal, err := json.Marshal(request.Params)
-
+	// 动作由连接所属的机器人执行并返回结果，机器人未实现发送消息的动作时按消息下发
+	params, err := json.Marshal(request.Params)
 	if err != nil {
+		c.Reply(request.Echo, nil, err)
+		return
+	}
+	data, err := protocol.Call(c.XSelfID, request.Action, params)
+	if _, send := sendActions[request.Action]; !send || !errors.Is(err, protocol.ErrUnsupportedAction) {
+		c.Reply(request.Echo, data, err)
+		return
+	}
+	adapter, ok := protocol.GetAdapter(c.XSelfID)
+	if !ok {
 		return
 	}
+
+	var messageRequest *onebot.MessageRequest
 	// onebot v11
-	err = json.Unmarshal(marshal, &messageRequest)
+	err = json.Unmarshal(params, &messageRequest)
 
 	if err != nil {
-		messages, err := constant.CQCode.ParseAllCQCodes(string(marshal))
+		messages, err := constant.CQCode.ParseAllCQCodes(string(params))
 		if err != nil {
 			return
 		}
 
 		messageRequest.Message = messages
 	}
+	if messageRequest == nil {
+		return
+	}
+	// 由连接所属的机器人发送
+	messageRequest.SelfId = c.XSelfID
+	switch request.Action {
+	case "send_group_msg":
+		messageRequest.MessageType = onebot.GroupMessage
+	case "send_private_msg":
+		messageRequest.MessageType = onebot.PrivateMessage
+	}
 
 	// 根据消息类型处理
 	switch messageRequest.PostType {
@@ -258,14 +297,38 @@ func (c *NoneBotClient) handleMessage(message []byte) {
 	case onebot.RequestPost:
 		logger.Infof("[%s] 请求事件: %v", c.URL, string(message))
 	case onebot.MessagePost:
-		protocol.QQOfficialChan <- messageRequest
+		adapter.SendMessage(messageRequest)
 		logger.Infof("[%s] 消息事件: %s", c.URL, string(message))
 	default:
-		protocol.QQOfficialChan <- messageRequest
+		adapter.SendMessage(messageRequest)
 		logger.Infof("[%s] 消息事件: %s", c.URL, string(message))
 	}
 }
 
+// Reply 返回动作执行结果
+func (c *NoneBotClient) Reply(echo any, data any, err error) {
+	response := NoneBotResponse{
+		Status: "ok",
+		Code:   0,
+		Data:   data,
+		Echo:   echo,
+	}
+	if err != nil {
+		response.Status = "failed"
+		response.Code = 100
+		if errors.Is(err, protocol.ErrUnsupportedAction) {
+			response.Code = 1404
+		}
+		response.Message = err.Error()
+	}
+	msg, err := json.Marshal(response)
+	if err != nil {
+		logger.Warnf("动作结果序列化失败: %v", err)
+		return
+	}
+	c.SendMessage(string(msg))
+}
+
 // Close 关闭连接
 func (c *NoneBotClient) Close() {
 	c.mu.Lock()
@@ -333,18 +396,37 @@ func (m *NoneBotClientManager) StartAll() {
 	}
 }
 
-// Broadcast 向所有连接的客户端广播消息
+// Broadcast 向事件所属机器人的连接广播事件
+//
+// 需要确认送达的事件写入至少一个连接后确认送达，机器人没有配置连接时同样确认，
+// 配置的连接均未写入成功时通知平台保留事件重试。
 func (m *NoneBotClientManager) Broadcast() {
-	for payload := range protocol.BroadcastChan {
+	for event := range protocol.BroadcastChan {
+		payload := protocol.Unwrap(event)
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			logger.Warnf("事件序列化失败: %v", err)
+		}
+		configured, delivered := false, false
+		m.mu.RLock()
 		for _, client := range m.clients {
-			if client.Connected {
-				payload.MessageBase.SelfId = client.XSelfID
-				jsonData, err := json.Marshal(payload)
-				if err != nil {
-					continue
-				}
-				client.Conn.SendText(string(jsonData))
+			// 仅发送给事件所属机器人的连接
+			if client.XSelfID != payload.GetSelfId() {
+				continue
+			}
+			configured = true
+			if err != nil {
+				continue
 			}
+			if sendErr := client.Send(jsonData); sendErr != nil {
+				logger.Debugf("%v 事件写入失败: %v", client.URL, sendErr)
+				continue
+			}
+			delivered = true
+		}
+		m.mu.RUnlock()
+		if delivery, ok := event.(*protocol.Delivery); ok {
+			delivery.Done(delivered || !configured)
 		}
 	}
 }